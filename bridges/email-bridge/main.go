@@ -7,7 +7,8 @@
 //
 //     $ ./email-bridge -email=sysadmin@example.com,hello@gmail.com
 //
-// When a test fails an email will sent via SMTP
+// When a test fails an email will sent via SMTP, securing the connection
+// via STARTTLS when available, or see -smtp-tls.
 //
 // Alberto
 // --
@@ -32,22 +33,21 @@ import (
 
 // TemplateSubject is our text/template which is used to generate the email
 // subject to the user.
-var TemplateSubject = `Overseer [
+var TemplateSubject = `[overseer] 
 {{- if .error -}}
-	ERR
+	{{" "}}FAIL
 	{{- if .isDedup -}}
 	-DUP
 	{{- end -}}
 {{- else -}}
 	{{- if .recovered -}}
-	RECOVERED
+	{{" "}}RECOVERED
 	{{- else -}}
-	OK
+	{{" "}}OK
 	{{- end -}}
 {{- end -}}
-]
-{{- if .tag}} ({{.tag}}){{- end -}}
-: {{.input}} ({{.date}})`
+{{" "}}{{.type}} {{.target}}
+{{- if .tag}} ({{.tag}}){{- end -}}`
 
 // TemplateBody is our text/template which is used to generate the email
 // notification to the user.
@@ -66,6 +66,8 @@ Overseer:
 Details: {{.details}}
 {{- end}}
 
+{{if .name}}Name: {{.name}}
+{{end -}}
 Tag: {{if .tag}}{{.tag}}{{else}}None{{end}}
 Input: {{.input}}
 
@@ -90,10 +92,20 @@ type EmailBridge struct {
 //
 func (bridge *EmailBridge) Process(msg []byte) {
 	testResult, err := test.ResultFromJSON(msg)
+	if _, ok := err.(*test.SchemaVersionError); ok {
+		// Sent by a newer worker, we can't make sense of it.
+		fmt.Printf("Skipping result: %s\n", err.Error())
+		return
+	}
 	if err != nil {
 		panic(err)
 	}
 
+	// The test might want to be notified elsewhere
+	if !testResult.IsNotifyTarget("email") {
+		return
+	}
+
 	// If the test passed then we don't care, unless otherwise defined
 	shouldSend := true
 	if testResult.Error == nil {
@@ -114,11 +126,38 @@ func (bridge *EmailBridge) Process(msg []byte) {
 
 	fmt.Printf("Processing result: %+v\n", testResult)
 
+	subject, body, err := renderEmail(testResult)
+	if err != nil {
+		fmt.Printf("%s\n", err.Error())
+		return
+	}
+
+	// The test might want to be notified to specific addresses
+	emails := testResult.NotifyDestinations("email")
+	if len(emails) == 0 {
+		emails = bridge.Emails
+	}
+
+	// Prepare email to send
+	message := bridge.Sender.WritePlainEmail(emails, subject, body)
+
+	err = bridge.Sender.SendRawMail(emails, message)
+
+	if err != nil {
+		fmt.Printf("Failed to send email: %s\n", err.Error())
+	}
+}
+
+//
+// Render the subject and body of the email describing the given result.
+//
+func renderEmail(testResult *test.Result) (string, string, error) {
 	templateMap := map[string]interface{}{
 		"error":     testResult.Error,
 		"isDedup":   testResult.IsDedup,
 		"recovered": testResult.Recovered,
 		"tag":       testResult.Tag,
+		"name":      testResult.Name,
 		"target":    testResult.Target,
 		"input":     testResult.Input,
 		"type":      testResult.Type,
@@ -135,10 +174,9 @@ func (bridge *EmailBridge) Process(msg []byte) {
 		src := string(TemplateSubject)
 		t := template.Must(template.New("tmpl").Parse(src))
 		buf := &bytes.Buffer{}
-		err = t.Execute(buf, templateMap)
+		err := t.Execute(buf, templateMap)
 		if err != nil {
-			fmt.Printf("Failed to compile email-template subject %s\n", err.Error())
-			return
+			return "", "", fmt.Errorf("failed to compile email-template subject: %s", err.Error())
 		}
 
 		subject = buf.String()
@@ -148,23 +186,15 @@ func (bridge *EmailBridge) Process(msg []byte) {
 		src := strings.TrimSpace(string(TemplateBody))
 		t := template.Must(template.New("tmpl").Parse(src))
 		buf := &bytes.Buffer{}
-		err = t.Execute(buf, templateMap)
+		err := t.Execute(buf, templateMap)
 		if err != nil {
-			fmt.Printf("Failed to compile email-template body %s\n", err.Error())
-			return
+			return "", "", fmt.Errorf("failed to compile email-template body: %s", err.Error())
 		}
 
 		body = buf.String()
 	}
 
-	// Prepare email to send
-	message := bridge.Sender.WritePlainEmail(bridge.Emails, subject, body)
-
-	err = bridge.Sender.SendRawMail(bridge.Emails, message)
-
-	if err != nil {
-		fmt.Printf("Waiting for process to terminate failed: %s\n", err.Error())
-	}
+	return subject, body, nil
 }
 
 //
@@ -183,6 +213,8 @@ func main() {
 	smtpPort := flag.Uint("smtp-port", 587, "The SMTP port")
 	smtpUsername := flag.String("smtp-username", "", "The SMTP username")
 	smtpPassword := flag.String("smtp-password", "", "The SMTP password")
+	smtpFrom := flag.String("smtp-from", "", "The address to send emails from, the SMTP username if empty")
+	smtpTLS := flag.String("smtp-tls", utils.SMTPTLSAuto, "How to secure the SMTP connection: 'auto' uses STARTTLS when offered, 'starttls' requires it, 'tls' connects via TLS (e.g. port 465)")
 
 	emailStr := flag.String("email", "", "The email addresses to notify, separated by comma")
 	sendTestSuccess := flag.Bool("send-test-success", false, "Send also test results when successful")
@@ -190,18 +222,19 @@ func main() {
 
 	flag.Parse()
 
-	emailSender := utils.NewEmailSender(*smtpHost, *smtpPort, *smtpUsername, *smtpPassword)
-
-	emailsSplit := strings.Split(*emailStr, ",")
-	var emailsValid []string
-	for _, email := range emailsSplit {
-		if email == "" {
-			continue
-		}
+	if *smtpTLS != utils.SMTPTLSAuto && *smtpTLS != utils.SMTPTLSStartTLS && *smtpTLS != utils.SMTPTLS {
+		fmt.Printf("Invalid smtp-tls '%s', must be 'auto', 'starttls' or 'tls'\n", *smtpTLS)
+		os.Exit(1)
+	}
 
-		emailsValid = append(emailsValid, email)
+	emailSender := utils.NewEmailSender(*smtpHost, *smtpPort, *smtpUsername, *smtpPassword)
+	emailSender.TLS = *smtpTLS
+	if *smtpFrom != "" {
+		emailSender.From = *smtpFrom
 	}
 
+	emailsValid := utils.ParseEmailAddresses(*emailStr)
+
 	//
 	// Sanity-check.
 	//
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+func TestRenderEmail(t *testing.T) {
+	errorText := "connection refused"
+	details := "Some details"
+
+	result := &test.Result{
+		Input:   "example.com must run http",
+		Target:  "93.184.216.34",
+		Type:    "http",
+		Tag:     "prod",
+		Error:   &errorText,
+		Details: &details,
+	}
+
+	subject, body, err := renderEmail(result)
+	if err != nil {
+		t.Fatalf("failed to render the email: %s", err)
+	}
+	if subject != "[overseer] FAIL http 93.184.216.34 (prod)" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	for _, expected := range []string{"Error: connection refused", "Details: Some details", "Tag: prod", "Input: example.com must run http", "Target: 93.184.216.34", "Type: http"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in the body, got %q", expected, body)
+		}
+	}
+
+	result.Name = "Checkout API health"
+	if _, body, _ = renderEmail(result); !strings.Contains(body, "Name: Checkout API health\nTag: prod") {
+		t.Errorf("expected the name in the body, got %q", body)
+	}
+
+	result.IsDedup = true
+	result.Tag = ""
+	if subject, _, _ = renderEmail(result); subject != "[overseer] FAIL-DUP http 93.184.216.34" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+
+	result.Error = nil
+	result.Details = nil
+	result.Recovered = true
+	subject, body, _ = renderEmail(result)
+	if subject != "[overseer] RECOVERED http 93.184.216.34" || !strings.Contains(body, "Test recovered") {
+		t.Errorf("unexpected recovery email %q, %q", subject, body)
+	}
+
+	result.Recovered = false
+	if subject, _, _ = renderEmail(result); subject != "[overseer] OK http 93.184.216.34" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+}
@@ -0,0 +1,254 @@
+//
+// This is the PagerDuty bridge, which should be built like so:
+//
+//     go build .
+//
+// Once built launch it as follows:
+//
+//     $ ./pagerduty-bridge -pagerduty-key=integration-key
+//
+// When a test fails an incident is triggered via the PagerDuty Events API
+// v2, and when the test recovers the same incident is resolved.
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	"github.com/go-redis/redis"
+)
+
+// pagerDutyEventsURL is the endpoint of the Events API v2.
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutySummaryLimit is the maximum length PagerDuty accepts for the
+// summary of an event.
+const pagerDutySummaryLimit = 1024
+
+// PagerDutyEvent is the body of an Events API v2 request
+type PagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *PagerDutyPayload `json:"payload,omitempty"`
+}
+
+// PagerDutyPayload describes the incident to trigger
+type PagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Component     string            `json:"component,omitempty"`
+	Group         string            `json:"group,omitempty"`
+	Class         string            `json:"class,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// PagerDutyBridge ...
+type PagerDutyBridge struct {
+	routingKey string
+	severity   string
+
+	client *http.Client
+}
+
+//
+// Build the event for the given result: failures trigger an incident,
+// recoveries resolve it.  Other results don't need an event.
+//
+// The dedup_key identifies the test, so that the resolve correlates with
+// the trigger.
+//
+func (bridge *PagerDutyBridge) buildEvent(testResult *test.Result) *PagerDutyEvent {
+	event := &PagerDutyEvent{
+		RoutingKey: bridge.routingKey,
+		DedupKey:   "overseer-" + testResult.Hash(),
+	}
+
+	if testResult.Recovered {
+		event.EventAction = "resolve"
+		return event
+	}
+
+	if testResult.Error == nil {
+		return nil
+	}
+
+	summary := []rune(fmt.Sprintf("%s: %s", testResult.Input, *testResult.Error))
+	if len(summary) > pagerDutySummaryLimit {
+		summary = append(summary[:pagerDutySummaryLimit-3], []rune("...")...)
+	}
+
+	details := map[string]string{
+		"input":  testResult.Input,
+		"target": testResult.Target,
+		"type":   testResult.Type,
+		"error":  *testResult.Error,
+	}
+	if testResult.Tag != "" {
+		details["tag"] = testResult.Tag
+	}
+	if testResult.Details != nil {
+		details["details"] = *testResult.Details
+	}
+
+	event.EventAction = "trigger"
+	event.Payload = &PagerDutyPayload{
+		Summary:       string(summary),
+		Source:        testResult.Target,
+		Severity:      bridge.severity,
+		Component:     testResult.Type,
+		Group:         testResult.Tag,
+		Class:         testResult.Type,
+		CustomDetails: details,
+	}
+
+	return event
+}
+
+//
+// Send the given event to PagerDuty.
+//
+func (bridge *PagerDutyBridge) send(event *PagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, pagerDutyEventsURL, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := bridge.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		reply, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("non-ok response returned from PagerDuty, code %v: %s", resp.StatusCode, reply)
+	}
+
+	return nil
+}
+
+//
+// Given a JSON string decode it and trigger, or resolve, the matching
+// PagerDuty incident.
+//
+func (bridge *PagerDutyBridge) process(msg []byte) {
+	testResult, err := test.ResultFromJSON(msg)
+	if _, ok := err.(*test.SchemaVersionError); ok {
+		// Sent by a newer worker, we can't make sense of it.
+		fmt.Printf("Skipping result: %s\n", err.Error())
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	// The test might want to be notified elsewhere
+	if !testResult.IsNotifyTarget("pagerduty") {
+		return
+	}
+
+	event := bridge.buildEvent(testResult)
+	if event == nil {
+		return
+	}
+
+	fmt.Printf("Processing result: %+v\n", testResult)
+
+	if err = bridge.send(event); err != nil {
+		fmt.Printf("Failed to send %s event to PagerDuty: %s\n", event.EventAction, err.Error())
+	}
+}
+
+//
+// Entry Point
+//
+func main() {
+
+	//
+	// Parse our flags
+	//
+	redisHost := flag.String("redis-host", "127.0.0.1:6379", "Specify the address of the redis queue.")
+	redisPass := flag.String("redis-pass", "", "Specify the password of the redis queue.")
+	redisDB := flag.Int("redis-db", 0, "Specify the database-number for redis.")
+	redisQueueKey := flag.String("redis-queue-key", "overseer.results", "Specify the redis queue key to use.")
+
+	pagerDutyKey := flag.String("pagerduty-key", "", "The routing key of the PagerDuty integration (Events API v2)")
+	pagerDutySeverity := flag.String("pagerduty-severity", "critical", "The severity of the triggered incidents: critical, error, warning or info")
+
+	flag.Parse()
+
+	//
+	// Sanity-check.
+	//
+	if *pagerDutyKey == "" {
+		fmt.Printf("Usage: pagerduty-bridge -pagerduty-key=integration-key [-redis-host=127.0.0.1:6379] [-redis-pass=foo]\n")
+		os.Exit(1)
+	}
+
+	switch *pagerDutySeverity {
+	case "critical", "error", "warning", "info":
+	default:
+		fmt.Printf("Invalid pagerduty-severity '%s', must be 'critical', 'error', 'warning' or 'info'\n", *pagerDutySeverity)
+		os.Exit(1)
+	}
+
+	//
+	// Create the redis client
+	//
+	r := redis.NewClient(&redis.Options{
+		Addr:     *redisHost,
+		Password: *redisPass,
+		DB:       *redisDB,
+	})
+
+	//
+	// And run a ping, just to make sure it worked.
+	//
+	_, err := r.Ping().Result()
+	if err != nil {
+		fmt.Printf("Redis connection failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	bridge := PagerDutyBridge{
+		routingKey: *pagerDutyKey,
+		severity:   *pagerDutySeverity,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+
+	for {
+
+		//
+		// Get test-results
+		//
+		msg, _ := r.BLPop(0, *redisQueueKey).Result()
+
+		//
+		// If they were non-empty, process them.
+		//
+		//   msg[0] will be "overseer.results"
+		//
+		//   msg[1] will be the value removed from the list.
+		//
+		if len(msg) >= 1 {
+			bridge.process([]byte(msg[1]))
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// roundTripFunc mocks the PagerDuty API.
+type roundTripFunc func(req *http.Request) *http.Response
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req), nil
+}
+
+// newTestBridge returns a bridge recording the events it sends, and
+// replying with the given status.
+func newTestBridge(status int, sent *[]map[string]interface{}) *PagerDutyBridge {
+	return &PagerDutyBridge{
+		routingKey: "routing-key",
+		severity:   "critical",
+		client: &http.Client{Transport: roundTripFunc(func(req *http.Request) *http.Response {
+			if req.URL.String() == pagerDutyEventsURL {
+				var body map[string]interface{}
+				json.NewDecoder(req.Body).Decode(&body)
+				*sent = append(*sent, body)
+			}
+
+			return &http.Response{
+				StatusCode: status,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"status":"success","message":"Event processed"}`)),
+				Header:     make(http.Header),
+			}
+		})},
+	}
+}
+
+func resultJSON(t *testing.T, result test.Result) []byte {
+	msg, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("failed to encode result: %s", err)
+	}
+	return msg
+}
+
+func TestTriggerAndResolve(t *testing.T) {
+	var sent []map[string]interface{}
+	bridge := newTestBridge(http.StatusAccepted, &sent)
+
+	errorText := "connection refused"
+	details := "Some details"
+	failure := test.Result{
+		Input:   "example.com must run http",
+		Target:  "93.184.216.34",
+		Type:    "http",
+		Tag:     "prod",
+		Error:   &errorText,
+		Details: &details,
+	}
+	dedupKey := "overseer-" + failure.Hash()
+
+	bridge.process(resultJSON(t, failure))
+	if len(sent) != 1 {
+		t.Fatalf("expected an event to be sent, got %d", len(sent))
+	}
+
+	trigger := sent[0]
+	if trigger["routing_key"] != "routing-key" || trigger["event_action"] != "trigger" || trigger["dedup_key"] != dedupKey {
+		t.Errorf("unexpected trigger event %+v", trigger)
+	}
+	payload := trigger["payload"].(map[string]interface{})
+	if payload["summary"] != "example.com must run http: connection refused" || payload["source"] != "93.184.216.34" || payload["severity"] != "critical" {
+		t.Errorf("unexpected trigger payload %+v", payload)
+	}
+	customDetails := payload["custom_details"].(map[string]interface{})
+	if customDetails["details"] != "Some details" || customDetails["tag"] != "prod" {
+		t.Errorf("unexpected custom details %+v", customDetails)
+	}
+
+	// Successful results don't need an event.
+	bridge.process(resultJSON(t, test.Result{Input: failure.Input, Target: failure.Target, Type: failure.Type, Tag: failure.Tag}))
+	if len(sent) != 1 {
+		t.Fatalf("expected no event for a success, got %d", len(sent))
+	}
+
+	// The recovery resolves the same incident.
+	bridge.process(resultJSON(t, test.Result{Input: failure.Input, Target: failure.Target, Type: failure.Type, Tag: failure.Tag, Recovered: true}))
+	if len(sent) != 2 {
+		t.Fatalf("expected a resolve event, got %d", len(sent))
+	}
+
+	resolve := sent[1]
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != dedupKey {
+		t.Errorf("unexpected resolve event %+v", resolve)
+	}
+	if _, ok := resolve["payload"]; ok {
+		t.Errorf("expected no payload in the resolve event, got %+v", resolve)
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	bridge := &PagerDutyBridge{routingKey: "routing-key", severity: "critical"}
+	errorText := "failed"
+
+	first := bridge.buildEvent(&test.Result{Input: "a.example.com must run http", Target: "a.example.com", Type: "http", Error: &errorText})
+	second := bridge.buildEvent(&test.Result{Input: "b.example.com must run http", Target: "b.example.com", Type: "http", Error: &errorText})
+	if first.DedupKey == second.DedupKey {
+		t.Errorf("expected different tests to have different dedup keys")
+	}
+
+	// Deduplicated failures keep the incident open.
+	again := bridge.buildEvent(&test.Result{Input: "a.example.com must run http", Target: "a.example.com", Type: "http", Error: &errorText, IsDedup: true})
+	if again.DedupKey != first.DedupKey || again.EventAction != "trigger" {
+		t.Errorf("unexpected event for a deduplicated failure %+v", again)
+	}
+
+	// Long summaries are truncated.
+	long := strings.Repeat("x", 2000)
+	event := bridge.buildEvent(&test.Result{Input: "example.com must run http", Error: &long})
+	if len(event.Payload.Summary) != pagerDutySummaryLimit {
+		t.Errorf("expected the summary to be truncated, got %d characters", len(event.Payload.Summary))
+	}
+}
+
+func TestSendFailure(t *testing.T) {
+	var sent []map[string]interface{}
+	bridge := newTestBridge(http.StatusBadRequest, &sent)
+
+	err := bridge.send(&PagerDutyEvent{RoutingKey: "routing-key", EventAction: "resolve", DedupKey: "key"})
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("expected the API error to be reported, got %v", err)
+	}
+}
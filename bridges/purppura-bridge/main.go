@@ -63,10 +63,20 @@ func process(msg []byte) {
 	mutex.Unlock()
 
 	testResult, err := test.ResultFromJSON(msg)
+	if _, ok := err.(*test.SchemaVersionError); ok {
+		// Sent by a newer worker, we can't make sense of it.
+		fmt.Printf("Skipping result: %s\n", err.Error())
+		return
+	}
 	if err != nil {
 		panic(err)
 	}
 
+	// The test might want to be notified elsewhere
+	if !testResult.IsNotifyTarget("purppura") {
+		return
+	}
+
 	//
 	// We need a stable ID for each test - get one by hashing the
 	// complete input-line and the target we executed against.
@@ -57,6 +57,11 @@ type QueueBridge struct {
 //
 func (bridge *QueueBridge) Process(msg []byte) {
 	testResult, err := test.ResultFromJSON(msg)
+	if _, ok := err.(*test.SchemaVersionError); ok {
+		// Sent by a newer worker, we can't make sense of it.
+		fmt.Printf("Skipping result: %s\n", err.Error())
+		return
+	}
 	if err != nil {
 		panic(err)
 	}
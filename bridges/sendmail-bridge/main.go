@@ -61,10 +61,20 @@ type EmailBridge struct {
 //
 func (bridge *EmailBridge) Process(msg []byte) {
 	testResult, err := test.ResultFromJSON(msg)
+	if _, ok := err.(*test.SchemaVersionError); ok {
+		// Sent by a newer worker, we can't make sense of it.
+		fmt.Printf("Skipping result: %s\n", err.Error())
+		return
+	}
 	if err != nil {
 		panic(err)
 	}
 
+	// The test might want to be notified elsewhere
+	if !testResult.IsNotifyTarget("sendmail") {
+		return
+	}
+
 	//
 	// If the test passed then we don't care.
 	//
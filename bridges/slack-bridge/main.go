@@ -9,6 +9,11 @@
 //
 // When a test fails an slack will sent via Slack Webhook
 //
+// Failures can be routed to different channels depending on the tag of
+// the test:
+//
+//     $ ./slack-bridge -slack-webhook=url -slack-channel=#alerts -channel-map=team-a=#alerts-a,team-b=#alerts-b
+//
 // Eka
 // --
 //
@@ -22,9 +27,12 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cmaster11/overseer/test"
+	"github.com/cmaster11/overseer/utils"
 	"github.com/go-redis/redis"
 )
 
@@ -66,13 +74,177 @@ type SlackAttachment struct {
 	Title  string       `json:"title,omitempty"`
 }
 
+// slackSectionTextLimit is the maximum length Slack accepts for the text
+// of a section block.
+const slackSectionTextLimit = 3000
+
+// slackMaxDetailsChunks is the maximum number of attachments we'll split
+// the details of a result into.
+const slackMaxDetailsChunks = 20
+
 // SlackBridge ...
 type SlackBridge struct {
 	slackWebhook string
 	slackChannel string
 
+	// The channel to use for each tag, instead of slackChannel
+	channelMap map[string]string
+
+	// How to handle details which don't fit in a single block, either
+	// "chunk" or "truncate"
+	DetailsMode string
+
 	SendTestSuccess   bool
 	SendTestRecovered bool
+
+	// How many times to retry rate-limited, or failed, requests, and how
+	// long to wait before the first retry
+	maxRetries int
+	retryDelay time.Duration
+}
+
+//
+// Parse the given list of tag=channel pairs, separated by commas.
+//
+func parseChannelMap(value string) (map[string]string, error) {
+	channels := make(map[string]string)
+
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid channel mapping '%s', expected tag=channel", pair)
+		}
+
+		channels[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return channels, nil
+}
+
+//
+// Return the channel the results of tests with the given tag go to.
+//
+func (bridge *SlackBridge) channelFor(tag string) string {
+	if channel, ok := bridge.channelMap[tag]; ok && tag != "" {
+		return channel
+	}
+	return bridge.slackChannel
+}
+
+//
+// Split the given text into chunks no longer than size, preferring to
+// break on newlines.
+//
+func splitDetails(text string, size int) []string {
+	var chunks []string
+	current := ""
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+
+		// Lines which are too long on their own need to be split.
+		for len([]rune(line)) > size {
+			if current != "" {
+				chunks = append(chunks, current)
+				current = ""
+			}
+			runes := []rune(line)
+			chunks = append(chunks, string(runes[:size]))
+			line = string(runes[size:])
+		}
+
+		if len([]rune(current))+len([]rune(line)) > size {
+			chunks = append(chunks, current)
+			current = ""
+		}
+		current += line
+	}
+
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+
+	return chunks
+}
+
+// truncatedSuffix is appended to the details which had to be cut.
+const truncatedSuffix = "\n... (truncated)"
+
+//
+// Truncate the given text to size, marking it as truncated.
+//
+func truncateDetails(text string, size int) string {
+	if len([]rune(text)) <= size {
+		return text
+	}
+	return markTruncated(text, size)
+}
+
+//
+// Mark the given text as truncated, cutting it so that the result is no
+// longer than size.
+//
+func markTruncated(text string, size int) string {
+	runes := []rune(text)
+	if len(runes) > size-len(truncatedSuffix) {
+		runes = runes[:size-len(truncatedSuffix)]
+	}
+	return string(runes) + truncatedSuffix
+}
+
+//
+// Build the attachments holding the details of a result.
+//
+func (bridge *SlackBridge) detailsAttachments(details string) []SlackAttachment {
+	var chunks []string
+
+	if bridge.DetailsMode == "truncate" {
+		chunks = []string{truncateDetails(details, slackSectionTextLimit)}
+	} else {
+		chunks = splitDetails(details, slackSectionTextLimit)
+		if len(chunks) > slackMaxDetailsChunks {
+			chunks = chunks[:slackMaxDetailsChunks]
+			chunks[slackMaxDetailsChunks-1] = markTruncated(chunks[slackMaxDetailsChunks-1], slackSectionTextLimit)
+		}
+	}
+
+	var attachments []SlackAttachment
+	for idx, chunk := range chunks {
+		titleText := "*Details*"
+		if len(chunks) > 1 {
+			titleText = fmt.Sprintf("*Details (%d/%d)*", idx+1, len(chunks))
+		}
+
+		title := SlackBlock{
+			Type: "section",
+			Text: &SlackText{
+				Text: titleText,
+				Type: "mrkdwn",
+			},
+		}
+
+		detail := SlackBlock{
+			Type: "section",
+			Text: &SlackText{
+				Text: chunk,
+				Type: "mrkdwn",
+			},
+		}
+
+		attachments = append(attachments, SlackAttachment{
+			Color: "#a9a9a9",
+			Blocks: []SlackBlock{
+				title,
+				detail,
+			},
+		})
+	}
+
+	return attachments
 }
 
 //
@@ -81,10 +253,20 @@ type SlackBridge struct {
 //
 func (bridge *SlackBridge) process(msg []byte) {
 	testResult, err := test.ResultFromJSON(msg)
+	if _, ok := err.(*test.SchemaVersionError); ok {
+		// Sent by a newer worker, we can't make sense of it.
+		fmt.Printf("Skipping result: %s\n", err.Error())
+		return
+	}
 	if err != nil {
 		panic(err)
 	}
 
+	// The test might want to be notified elsewhere
+	if !testResult.IsNotifyTarget("slack") {
+		return
+	}
+
 	// If the test passed then we don't care, unless otherwise defined
 	shouldSend := true
 	if testResult.Error == nil {
@@ -107,16 +289,24 @@ func (bridge *SlackBridge) process(msg []byte) {
 
 	// Define Title
 	titleText := SlackText{
-		Text: fmt.Sprintf(":warning: *%s %s*", "Error:", *testResult.Error),
+		Text: ":white_check_mark: *Test passed*",
 		Type: "mrkdwn",
 	}
 
-	if testResult.IsDedup {
-		titleText.Text = fmt.Sprintf(":warning: *%s %s*", "Error (deduplicated):", *testResult.Error)
+	if testResult.Error != nil {
+		titleText.Text = fmt.Sprintf(":warning: *%s %s*", "Error:", *testResult.Error)
+
+		if testResult.IsDedup {
+			titleText.Text = fmt.Sprintf(":warning: *%s %s*", "Error (deduplicated):", *testResult.Error)
+		}
 	}
 
 	if testResult.Recovered {
 		titleText.Text = ":white_check_mark: *Error Recovered*"
+
+		if testResult.DowntimeSeconds > 0 {
+			titleText.Text += fmt.Sprintf(" after %s of downtime", time.Duration(testResult.DowntimeSeconds)*time.Second)
+		}
 	}
 
 	title := SlackBlock{
@@ -144,6 +334,15 @@ func (bridge *SlackBridge) process(msg []byte) {
 		},
 	}
 
+	// Define Worker
+	if testResult.Worker != "" {
+		tag.Elements = append(tag.Elements, SlackElement{
+			Text:  fmt.Sprintf("Worker : %s", testResult.Worker),
+			Emoji: true,
+			Type:  "plain_text",
+		})
+	}
+
 	divider := SlackBlock{
 		Type: "divider",
 	}
@@ -151,7 +350,7 @@ func (bridge *SlackBridge) process(msg []byte) {
 	body := SlackRequestBody{
 		Username:  "Overseer",
 		IconEmoji: ":eyes:",
-		Channel:   bridge.slackChannel,
+		Channel:   bridge.channelFor(testResult.Tag),
 		Blocks: []SlackBlock{
 			title,
 			tag,
@@ -161,31 +360,7 @@ func (bridge *SlackBridge) process(msg []byte) {
 	}
 
 	if testResult.Details != nil {
-		title := SlackBlock{
-			Type: "section",
-			Text: &SlackText{
-				Text: "*Details*",
-				Type: "mrkdwn",
-			},
-		}
-
-		detail := SlackBlock{
-			Type: "section",
-			Text: &SlackText{
-				Text: *testResult.Details,
-				Type: "mrkdwn",
-			},
-		}
-
-		attachment := SlackAttachment{
-			Color: "#a9a9a9",
-			Blocks: []SlackBlock{
-				title,
-				detail,
-			},
-		}
-
-		body.Attachments = append(body.Attachments, attachment)
+		body.Attachments = append(body.Attachments, bridge.detailsAttachments(*testResult.Details)...)
 	}
 
 	info := SlackBlock{
@@ -195,6 +370,9 @@ func (bridge *SlackBridge) process(msg []byte) {
 			Text: fmt.Sprintf("Input: %s\nTarget: %s\nType: %s", testResult.Input, testResult.Target, testResult.Type),
 		},
 	}
+	if testResult.Name != "" {
+		info.Text.Text = fmt.Sprintf("Name: *%s*\n%s", testResult.Name, info.Text.Text)
+	}
 	body.Blocks = append(body.Blocks, info)
 
 	dateElement := SlackElement{
@@ -211,13 +389,69 @@ func (bridge *SlackBridge) process(msg []byte) {
 	}
 	body.Blocks = append(body.Blocks, date)
 
+	//
+	// The test might want to be notified to specific channels.
+	//
+	channels := testResult.NotifyDestinations("slack")
+	if len(channels) == 0 {
+		channels = []string{bridge.channelFor(testResult.Tag)}
+	}
+
+	for _, channel := range channels {
+		body.Channel = channel
+		bridge.send(body)
+	}
+}
+
+//
+// Post the given message to our webhook, retrying if Slack is rate-limiting
+// us or failing.
+//
+func (bridge *SlackBridge) send(body SlackRequestBody) {
 	slackBody, _ := json.Marshal(body)
 	fmt.Printf("%s \n", string(slackBody))
 
+	delay := bridge.retryDelay
+	for attempt := 0; ; attempt++ {
+		wait, err := bridge.post(slackBody)
+		if err == nil {
+			return
+		}
+
+		if wait < 0 || attempt >= bridge.maxRetries {
+			if attempt > 0 {
+				fmt.Printf("Giving up sending to Slack after %d attempts: %s\n", attempt+1, err.Error())
+			} else {
+				fmt.Printf("Failed sending to Slack: %s\n", err.Error())
+			}
+			return
+		}
+
+		//
+		// Back off exponentially, unless Slack told us how long to
+		// wait.
+		//
+		if wait == 0 {
+			wait = delay
+			delay *= 2
+		}
+
+		fmt.Printf("Failed sending to Slack: %s, retrying in %s\n", err.Error(), wait)
+		time.Sleep(wait)
+	}
+}
+
+//
+// Post the given payload to our webhook once.
+//
+// On failure the returned duration is how long to wait before retrying:
+// zero if the default backoff applies, negative if the request should not
+// be retried at all.
+//
+func (bridge *SlackBridge) post(slackBody []byte) (time.Duration, error) {
 	req, err := http.NewRequest(http.MethodPost, bridge.slackWebhook, bytes.NewBuffer(slackBody))
 	if err != nil {
-		fmt.Printf("Failed to send req to slack %s\n", err.Error())
-		return
+		return -1, fmt.Errorf("failed to send req to slack %s", err.Error())
 	}
 
 	req.Header.Add("Content-Type", "application/json")
@@ -225,18 +459,45 @@ func (bridge *SlackBridge) process(msg []byte) {
 	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		fmt.Printf("Failed to get response from slack %s\n", err.Error())
-		return
+		return -1, fmt.Errorf("failed to get response from slack %s", err.Error())
 	}
 
 	defer resp.Body.Close()
 
 	buf := new(bytes.Buffer)
 	buf.ReadFrom(resp.Body)
-	if buf.String() != "ok" {
-		fmt.Printf("Non-ok response returned from Slack. Code %v, Message %s\n", resp.StatusCode, buf.String())
-		return
+	if buf.String() == "ok" {
+		return 0, nil
 	}
+
+	err = fmt.Errorf("non-ok response returned from Slack. Code %v, Message %s", resp.StatusCode, buf.String())
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return -1, err
+	}
+
+	return retryAfter(resp.Header.Get("Retry-After")), err
+}
+
+//
+// Parse the given Retry-After header, either a number of seconds or a
+// date, returning zero if it is missing or invalid.
+//
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
 }
 
 //
@@ -251,15 +512,31 @@ func main() {
 	redisPass := flag.String("redis-pass", "", "Specify the password of the redis queue.")
 	redisDB := flag.Int("redis-db", 0, "Specify the database-number for redis.")
 	redisQueueKey := flag.String("redis-queue-key", "overseer.results", "Specify the redis queue key to use.")
+	redisConnectRetries := flag.Int("redis-connect-retries", 0, "How many times to retry the initial redis connection before giving up, e.g. while redis is starting.")
+	redisConnectBackoff := flag.Duration("redis-connect-backoff", time.Second, "The time to wait before retrying the initial redis connection, doubled after each attempt, up to 30s.")
 
 	slackWebhook := flag.String("slack-webhook", "https://hooks.slack.com/services/T1234/Bxxx/xxx", "Slack Webhook URL")
 	slackChannel := flag.String("slack-channel", "", "Slack Channel Name")
+	channelMapList := flag.String("channel-map", "", "Route the results of tagged tests to specific channels, e.g. 'team-a=#alerts-a,team-b=#alerts-b'")
+	maxRetries := flag.Int("max-retries", 3, "How many times to retry sending a message when Slack is rate-limiting us (429) or failing (5xx)")
+	detailsMode := flag.String("details-mode", "chunk", "How to send details too long for a single Slack block: 'chunk' splits them across multiple attachments, 'truncate' cuts them")
 
 	sendTestSuccess := flag.Bool("send-test-success", false, "Send also test results when successful")
 	sendTestRecovered := flag.Bool("send-test-recovered", false, "Send also test results when a test recovers from failure (valid only when used together with deduplication rules)")
 
 	flag.Parse()
 
+	if *detailsMode != "chunk" && *detailsMode != "truncate" {
+		fmt.Printf("Invalid details-mode '%s', must be 'chunk' or 'truncate'\n", *detailsMode)
+		os.Exit(1)
+	}
+
+	channelMap, err := parseChannelMap(*channelMapList)
+	if err != nil {
+		fmt.Printf("Invalid channel-map: %s\n", err.Error())
+		os.Exit(1)
+	}
+
 	//
 	// Create the redis client
 	//
@@ -272,7 +549,11 @@ func main() {
 	//
 	// And run a ping, just to make sure it worked.
 	//
-	_, err := r.Ping().Result()
+	err = utils.PingRedis(r, *redisConnectRetries, utils.RetryBackoff{
+		Exponential: true,
+		Delay:       *redisConnectBackoff,
+		MaxDelay:    30 * time.Second,
+	})
 	if err != nil {
 		fmt.Printf("Redis connection failed: %s\n", err.Error())
 		os.Exit(1)
@@ -281,6 +562,10 @@ func main() {
 	bridge := SlackBridge{
 		slackWebhook:      *slackWebhook,
 		slackChannel:      *slackChannel,
+		channelMap:        channelMap,
+		maxRetries:        *maxRetries,
+		retryDelay:        time.Second,
+		DetailsMode:       *detailsMode,
 		SendTestRecovered: *sendTestRecovered,
 		SendTestSuccess:   *sendTestSuccess,
 	}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+func TestSplitDetails(t *testing.T) {
+
+	// Short details are kept as they are
+	chunks := splitDetails("line 1\nline 2\n", 100)
+	if len(chunks) != 1 || chunks[0] != "line 1\nline 2\n" {
+		t.Fatalf("unexpected chunks: %q", chunks)
+	}
+
+	// Long details are split on newlines
+	details := strings.Repeat("0123456789\n", 30)
+	chunks = splitDetails(details, 100)
+	if strings.Join(chunks, "") != details {
+		t.Fatalf("chunks don't add up to the original details")
+	}
+	for _, chunk := range chunks {
+		if len(chunk) > 100 {
+			t.Fatalf("chunk too long: %d", len(chunk))
+		}
+		if !strings.HasSuffix(chunk, "\n") {
+			t.Fatalf("chunk not split on a newline: %q", chunk)
+		}
+	}
+
+	// Single lines longer than the limit are split anyway
+	details = strings.Repeat("x", 250)
+	chunks = splitDetails(details, 100)
+	if len(chunks) != 3 || strings.Join(chunks, "") != details {
+		t.Fatalf("unexpected chunks for a long line: %d", len(chunks))
+	}
+}
+
+func TestDetailsAttachments(t *testing.T) {
+	details := strings.Repeat("0123456789\n", 1000)
+
+	bridge := SlackBridge{DetailsMode: "truncate"}
+	attachments := bridge.detailsAttachments(details)
+	if len(attachments) != 1 {
+		t.Fatalf("expected a single attachment when truncating, got %d", len(attachments))
+	}
+	text := attachments[0].Blocks[1].Text.Text
+	if len(text) > slackSectionTextLimit || !strings.HasSuffix(text, truncatedSuffix) {
+		t.Fatalf("details were not truncated correctly")
+	}
+
+	bridge = SlackBridge{DetailsMode: "chunk"}
+	attachments = bridge.detailsAttachments(details)
+	if len(attachments) != 4 {
+		t.Fatalf("expected 4 attachments when chunking, got %d", len(attachments))
+	}
+}
+
+func TestParseChannelMap(t *testing.T) {
+	channels, err := parseChannelMap("team-a=#alerts-a, team-b = #alerts-b,")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(channels) != 2 || channels["team-a"] != "#alerts-a" || channels["team-b"] != "#alerts-b" {
+		t.Errorf("unexpected mapping %v", channels)
+	}
+
+	if channels, err = parseChannelMap(""); err != nil || len(channels) != 0 {
+		t.Errorf("expected an empty mapping, got %v (%v)", channels, err)
+	}
+
+	for _, invalid := range []string{"team-a", "team-a=", "=#alerts", "team-a=#alerts-a,team-b"} {
+		if _, err = parseChannelMap(invalid); err == nil {
+			t.Errorf("expected '%s' to be invalid", invalid)
+		}
+	}
+}
+
+func TestChannelFor(t *testing.T) {
+	bridge := &SlackBridge{
+		slackChannel: "#alerts",
+		channelMap:   map[string]string{"team-a": "#alerts-a", "team-b": "#alerts-b"},
+	}
+
+	tests := map[string]string{
+		"team-a": "#alerts-a",
+		"team-b": "#alerts-b",
+		"team-c": "#alerts",
+		"":       "#alerts",
+	}
+	for tag, expected := range tests {
+		if channel := bridge.channelFor(tag); channel != expected {
+			t.Errorf("expected tag '%s' to go to %s, got %s", tag, expected, channel)
+		}
+	}
+
+	// Without a mapping everything goes to the default channel.
+	bridge.channelMap = nil
+	if channel := bridge.channelFor("team-a"); channel != "#alerts" {
+		t.Errorf("expected the default channel, got %s", channel)
+	}
+}
+
+func TestProcessRecovered(t *testing.T) {
+	var body SlackRequestBody
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	result := test.Result{
+		Input:           "example.com must run http with dedup 5m",
+		Target:          "example.com",
+		Type:            "http",
+		Recovered:       true,
+		DowntimeSeconds: 330,
+	}
+	msg, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("failed to encode result: %s", err)
+	}
+
+	bridge := &SlackBridge{slackWebhook: server.URL, slackChannel: "#alerts", SendTestRecovered: true}
+	bridge.process(msg)
+
+	if len(body.Blocks) == 0 {
+		t.Fatalf("expected a message to be sent")
+	}
+	if title := body.Blocks[0].Text.Text; title != ":white_check_mark: *Error Recovered* after 5m30s of downtime" {
+		t.Errorf("unexpected title %q", title)
+	}
+}
+
+func TestProcessSchemaVersion(t *testing.T) {
+	sent := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = true
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	errorText := "connection refused"
+	result := test.Result{
+		SchemaVersion: test.ResultSchemaVersion + 1,
+		Input:         "example.com must run http",
+		Target:        "example.com",
+		Type:          "http",
+		Error:         &errorText,
+	}
+	msg, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("failed to encode result: %s", err)
+	}
+
+	// Results from a newer worker are skipped, rather than crashing.
+	bridge := &SlackBridge{slackWebhook: server.URL, slackChannel: "#alerts"}
+	bridge.process(msg)
+
+	if sent {
+		t.Errorf("expected no message to be sent")
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&attempts, 1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("rate_limited"))
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("rate_limited"))
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	bridge := &SlackBridge{slackWebhook: server.URL, maxRetries: 3, retryDelay: 10 * time.Millisecond}
+	bridge.send(SlackRequestBody{Username: "Overseer"})
+	if attempts != 3 {
+		t.Errorf("expected the message to be delivered at the third attempt, got %d attempts", attempts)
+	}
+
+}
+
+func TestSendGivesUp(t *testing.T) {
+	var attempts int32
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(status)
+		w.Write([]byte("failure"))
+	}))
+	defer server.Close()
+
+	bridge := &SlackBridge{slackWebhook: server.URL, maxRetries: 2, retryDelay: 10 * time.Millisecond}
+	bridge.send(SlackRequestBody{Username: "Overseer"})
+	if attempts != 3 {
+		t.Errorf("expected to give up after 3 attempts, got %d", attempts)
+	}
+
+	// Client errors are not retried.
+	atomic.StoreInt32(&attempts, 0)
+	status = http.StatusBadRequest
+	bridge.send(SlackRequestBody{Username: "Overseer"})
+	if attempts != 1 {
+		t.Errorf("expected client errors not to be retried, got %d attempts", attempts)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if wait := retryAfter("5"); wait != 5*time.Second {
+		t.Errorf("expected 5s, got %s", wait)
+	}
+	if wait := retryAfter(""); wait != 0 {
+		t.Errorf("expected no wait, got %s", wait)
+	}
+	if wait := retryAfter("soon"); wait != 0 {
+		t.Errorf("expected no wait, got %s", wait)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if wait := retryAfter(date); wait <= 50*time.Second || wait > time.Minute {
+		t.Errorf("expected about a minute, got %s", wait)
+	}
+}
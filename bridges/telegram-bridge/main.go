@@ -0,0 +1,304 @@
+//
+// This is the telegram bridge, which should be built like so:
+//
+//     go build .
+//
+// Once built launch it as follows:
+//
+//     $ ./telegram-bridge -telegram-token=123456:bot-token -telegram-chat=-100123456
+//
+// When a test fails a message will be sent to the given chat, via a
+// Telegram bot.
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	"github.com/go-redis/redis"
+)
+
+// telegramAPI is the base URL of the Telegram bot API.
+const telegramAPI = "https://api.telegram.org"
+
+// telegramMessageLimit is the maximum length Telegram accepts for the
+// text of a message.
+const telegramMessageLimit = 4096
+
+// truncatedSuffix is appended to the details which had to be cut.
+const truncatedSuffix = "\n... (truncated)"
+
+// TelegramRequestBody is the payload of the sendMessage method
+type TelegramRequestBody struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+// TelegramResponse is the reply of the bot API
+type TelegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description,omitempty"`
+}
+
+// TelegramBridge ...
+type TelegramBridge struct {
+	telegramToken string
+	telegramChat  string
+
+	client *http.Client
+
+	SendTestSuccess   bool
+	SendTestRecovered bool
+}
+
+//
+// Build the text of the message describing the given result, truncating
+// the details so that it fits in a single Telegram message.
+//
+func (bridge *TelegramBridge) formatMessage(testResult *test.Result) string {
+
+	// Define Title
+	title := "✅ Test passed"
+	if testResult.Error != nil {
+		title = fmt.Sprintf("⚠️ Error: %s", *testResult.Error)
+
+		if testResult.IsDedup {
+			title = fmt.Sprintf("⚠️ Error (deduplicated): %s", *testResult.Error)
+		}
+	}
+
+	if testResult.Recovered {
+		title = "✅ Error Recovered"
+	}
+
+	// Define Tag
+	tag := "None"
+	if testResult.Tag != "" {
+		tag = testResult.Tag
+	}
+
+	// Define Name
+	name := ""
+	if testResult.Name != "" {
+		name = fmt.Sprintf("Name: %s\n", testResult.Name)
+	}
+
+	text := fmt.Sprintf("%s\n\n%sTag: %s\nInput: %s\nTarget: %s\nType: %s\n%s",
+		title,
+		name,
+		tag,
+		testResult.Input,
+		testResult.Target,
+		testResult.Type,
+		time.Unix(testResult.Time, 0).UTC().String())
+
+	// Define Worker
+	if testResult.Worker != "" {
+		text += fmt.Sprintf("\nWorker: %s", testResult.Worker)
+	}
+
+	if testResult.Details == nil {
+		return truncate(text, telegramMessageLimit)
+	}
+
+	//
+	// The details get whatever room is left.
+	//
+	text += "\n\nDetails:\n"
+	room := telegramMessageLimit - len([]rune(text))
+	if room <= len(truncatedSuffix) {
+		return truncate(text, telegramMessageLimit)
+	}
+
+	return text + truncate(*testResult.Details, room)
+}
+
+//
+// Truncate the given text to size, marking it as truncated.
+//
+func truncate(text string, size int) string {
+	runes := []rune(text)
+	if len(runes) <= size {
+		return text
+	}
+	return string(runes[:size-len(truncatedSuffix)]) + truncatedSuffix
+}
+
+//
+// Send the given text to the given chat.
+//
+func (bridge *TelegramBridge) send(chat string, text string) error {
+	body, err := json.Marshal(TelegramRequestBody{
+		ChatID:                chat,
+		Text:                  text,
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/bot%s/sendMessage", telegramAPI, bridge.telegramToken)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := bridge.client.Do(req)
+	if err != nil {
+		// Don't leak the token, which is part of the URL.
+		return fmt.Errorf("%s", strings.Replace(err.Error(), bridge.telegramToken, "<token>", -1))
+	}
+	defer resp.Body.Close()
+
+	var reply TelegramResponse
+	if err = json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("invalid response from Telegram, code %v: %s", resp.StatusCode, err.Error())
+	}
+	if !reply.OK {
+		return fmt.Errorf("non-ok response returned from Telegram, code %v: %s", resp.StatusCode, reply.Description)
+	}
+
+	return nil
+}
+
+//
+// Given a JSON string decode it and post it via telegram if it describes
+// a test-failure.
+//
+func (bridge *TelegramBridge) process(msg []byte) {
+	testResult, err := test.ResultFromJSON(msg)
+	if _, ok := err.(*test.SchemaVersionError); ok {
+		// Sent by a newer worker, we can't make sense of it.
+		fmt.Printf("Skipping result: %s\n", err.Error())
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	// The test might want to be notified elsewhere
+	if !testResult.IsNotifyTarget("telegram") {
+		return
+	}
+
+	// If the test passed then we don't care, unless otherwise defined
+	shouldSend := true
+	if testResult.Error == nil {
+		shouldSend = false
+
+		if bridge.SendTestSuccess {
+			shouldSend = true
+		}
+
+		if bridge.SendTestRecovered && testResult.Recovered {
+			shouldSend = true
+		}
+	}
+
+	if !shouldSend {
+		return
+	}
+
+	fmt.Printf("Processing result: %+v\n", testResult)
+
+	//
+	// The test might want to be notified to specific chats.
+	//
+	chats := testResult.NotifyDestinations("telegram")
+	if len(chats) == 0 {
+		chats = []string{bridge.telegramChat}
+	}
+
+	text := bridge.formatMessage(testResult)
+	for _, chat := range chats {
+		if err = bridge.send(chat, text); err != nil {
+			fmt.Printf("Failed to send message to Telegram: %s\n", err.Error())
+		}
+	}
+}
+
+//
+// Entry Point
+//
+func main() {
+
+	//
+	// Parse our flags
+	//
+	redisHost := flag.String("redis-host", "127.0.0.1:6379", "Specify the address of the redis queue.")
+	redisPass := flag.String("redis-pass", "", "Specify the password of the redis queue.")
+	redisDB := flag.Int("redis-db", 0, "Specify the database-number for redis.")
+	redisQueueKey := flag.String("redis-queue-key", "overseer.results", "Specify the redis queue key to use.")
+
+	telegramToken := flag.String("telegram-token", "", "The token of the Telegram bot")
+	telegramChat := flag.String("telegram-chat", "", "The id of the Telegram chat to send messages to")
+
+	sendTestSuccess := flag.Bool("send-test-success", false, "Send also test results when successful")
+	sendTestRecovered := flag.Bool("send-test-recovered", false, "Send also test results when a test recovers from failure (valid only when used together with deduplication rules)")
+
+	flag.Parse()
+
+	//
+	// Sanity-check.
+	//
+	if *telegramToken == "" || *telegramChat == "" {
+		fmt.Printf("Usage: telegram-bridge -telegram-token=123456:bot-token -telegram-chat=-100123456 [-redis-host=127.0.0.1:6379] [-redis-pass=foo]\n")
+		os.Exit(1)
+	}
+
+	//
+	// Create the redis client
+	//
+	r := redis.NewClient(&redis.Options{
+		Addr:     *redisHost,
+		Password: *redisPass,
+		DB:       *redisDB,
+	})
+
+	//
+	// And run a ping, just to make sure it worked.
+	//
+	_, err := r.Ping().Result()
+	if err != nil {
+		fmt.Printf("Redis connection failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	bridge := TelegramBridge{
+		telegramToken:     *telegramToken,
+		telegramChat:      *telegramChat,
+		client:            &http.Client{Timeout: 10 * time.Second},
+		SendTestRecovered: *sendTestRecovered,
+		SendTestSuccess:   *sendTestSuccess,
+	}
+
+	for {
+
+		//
+		// Get test-results
+		//
+		msg, _ := r.BLPop(0, *redisQueueKey).Result()
+
+		//
+		// If they were non-empty, process them.
+		//
+		//   msg[0] will be "overseer.results"
+		//
+		//   msg[1] will be the value removed from the list.
+		//
+		if len(msg) >= 1 {
+			bridge.process([]byte(msg[1]))
+		}
+	}
+}
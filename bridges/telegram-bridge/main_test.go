@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// roundTripFunc mocks the Telegram API.
+type roundTripFunc func(req *http.Request) *http.Response
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req), nil
+}
+
+// newTestBridge returns a bridge recording the messages it sends, and
+// replying with the given body.
+func newTestBridge(reply string, sent *[]TelegramRequestBody, paths *[]string) *TelegramBridge {
+	return &TelegramBridge{
+		telegramToken: "123:token",
+		telegramChat:  "-100",
+		client: &http.Client{Transport: roundTripFunc(func(req *http.Request) *http.Response {
+			var body TelegramRequestBody
+			json.NewDecoder(req.Body).Decode(&body)
+			*sent = append(*sent, body)
+			*paths = append(*paths, req.URL.String())
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(reply)),
+				Header:     make(http.Header),
+			}
+		})},
+	}
+}
+
+func resultJSON(t *testing.T, result test.Result) []byte {
+	msg, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("failed to encode result: %s", err)
+	}
+	return msg
+}
+
+func TestFormatMessage(t *testing.T) {
+	errorText := "connection refused"
+	details := "Some details"
+	bridge := &TelegramBridge{}
+
+	result := &test.Result{
+		Input:   "example.com must run http",
+		Target:  "example.com",
+		Type:    "http",
+		Tag:     "prod",
+		Error:   &errorText,
+		Details: &details,
+	}
+
+	text := bridge.formatMessage(result)
+	for _, expected := range []string{"Error: connection refused", "Tag: prod", "Input: example.com must run http", "Target: example.com", "Type: http", "Details:\nSome details"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("expected '%s' in the message, got %s", expected, text)
+		}
+	}
+
+	if strings.Contains(text, "Name:") {
+		t.Errorf("expected no name in the message, got %s", text)
+	}
+	if strings.Contains(text, "Worker:") {
+		t.Errorf("expected no worker in the message, got %s", text)
+	}
+	result.Worker = "worker-eu-1"
+	if text = bridge.formatMessage(result); !strings.Contains(text, "Worker: worker-eu-1") {
+		t.Errorf("expected the worker in the message, got %s", text)
+	}
+
+	result.Name = "Checkout API health"
+	if text = bridge.formatMessage(result); !strings.Contains(text, "Name: Checkout API health\nTag: prod") {
+		t.Errorf("expected the name in the message, got %s", text)
+	}
+
+	result.IsDedup = true
+	if text = bridge.formatMessage(result); !strings.Contains(text, "Error (deduplicated): connection refused") {
+		t.Errorf("expected the message to be marked as deduplicated, got %s", text)
+	}
+
+	result.Error = nil
+	result.Recovered = true
+	result.Tag = ""
+	text = bridge.formatMessage(result)
+	if !strings.Contains(text, "Error Recovered") || !strings.Contains(text, "Tag: None") {
+		t.Errorf("expected the message to be marked as recovered, got %s", text)
+	}
+}
+
+func TestFormatMessageTruncation(t *testing.T) {
+	errorText := "failed"
+	details := strings.Repeat("0123456789\n", 1000)
+	bridge := &TelegramBridge{}
+
+	text := bridge.formatMessage(&test.Result{Error: &errorText, Details: &details})
+	if len([]rune(text)) != telegramMessageLimit {
+		t.Errorf("expected the message to be %d characters, got %d", telegramMessageLimit, len([]rune(text)))
+	}
+	if !strings.HasSuffix(text, truncatedSuffix) || !strings.Contains(text, "Error: failed") {
+		t.Errorf("expected the details to be truncated, got %s", text)
+	}
+
+	// Multi-byte characters count as one.
+	details = strings.Repeat("é", 5000)
+	text = bridge.formatMessage(&test.Result{Error: &errorText, Details: &details})
+	if len([]rune(text)) != telegramMessageLimit {
+		t.Errorf("expected the message to be %d characters, got %d", telegramMessageLimit, len([]rune(text)))
+	}
+}
+
+func TestProcess(t *testing.T) {
+	var sent []TelegramRequestBody
+	var paths []string
+	bridge := newTestBridge(`{"ok":true}`, &sent, &paths)
+
+	errorText := "connection refused"
+	bridge.process(resultJSON(t, test.Result{Input: "example.com must run http", Error: &errorText}))
+	if len(sent) != 1 {
+		t.Fatalf("expected a message to be sent, got %d", len(sent))
+	}
+	if paths[0] != "https://api.telegram.org/bot123:token/sendMessage" {
+		t.Errorf("unexpected API URL %s", paths[0])
+	}
+	if sent[0].ChatID != "-100" || !strings.Contains(sent[0].Text, "connection refused") {
+		t.Errorf("unexpected message %+v", sent[0])
+	}
+
+	// Successes and recoveries are only sent if required.
+	bridge.process(resultJSON(t, test.Result{Input: "example.com must run http"}))
+	bridge.process(resultJSON(t, test.Result{Input: "example.com must run http", Recovered: true}))
+	if len(sent) != 1 {
+		t.Fatalf("expected successes not to be sent, got %d messages", len(sent))
+	}
+
+	bridge.SendTestRecovered = true
+	bridge.process(resultJSON(t, test.Result{Input: "example.com must run http"}))
+	bridge.process(resultJSON(t, test.Result{Input: "example.com must run http", Recovered: true}))
+	if len(sent) != 2 || !strings.Contains(sent[1].Text, "Error Recovered") {
+		t.Fatalf("expected the recovery to be sent, got %+v", sent)
+	}
+
+	bridge.SendTestSuccess = true
+	bridge.process(resultJSON(t, test.Result{Input: "example.com must run http"}))
+	if len(sent) != 3 || !strings.Contains(sent[2].Text, "Test passed") {
+		t.Fatalf("expected the success to be sent, got %+v", sent)
+	}
+}
+
+func TestProcessSchemaVersion(t *testing.T) {
+	var sent []TelegramRequestBody
+	var paths []string
+	bridge := newTestBridge(`{"ok":true}`, &sent, &paths)
+
+	// Results from a newer worker are skipped, rather than crashing.
+	errorText := "connection refused"
+	bridge.process(resultJSON(t, test.Result{SchemaVersion: test.ResultSchemaVersion + 1, Input: "example.com must run http", Error: &errorText}))
+	if len(sent) != 0 {
+		t.Fatalf("expected no message to be sent, got %+v", sent)
+	}
+}
+
+func TestSendFailure(t *testing.T) {
+	var sent []TelegramRequestBody
+	var paths []string
+	bridge := newTestBridge(`{"ok":false,"description":"Bad Request: chat not found"}`, &sent, &paths)
+
+	err := bridge.send("-100", "hello")
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("expected the API error to be reported, got %v", err)
+	}
+}
+
+func TestProcessNotifyTargets(t *testing.T) {
+	var sent []TelegramRequestBody
+	var paths []string
+	bridge := newTestBridge(`{"ok":true}`, &sent, &paths)
+
+	errorText := "connection refused"
+	bridge.process(resultJSON(t, test.Result{Error: &errorText, NotifyTargets: []string{"slack"}}))
+	if len(sent) != 0 {
+		t.Fatalf("expected results for other bridges to be skipped, got %+v", sent)
+	}
+
+	bridge.process(resultJSON(t, test.Result{Error: &errorText, NotifyTargets: []string{"slack", "telegram:-200", "telegram:-300"}}))
+	if len(sent) != 2 || sent[0].ChatID != "-200" || sent[1].ChatID != "-300" {
+		t.Fatalf("expected the message to be sent to the given chats, got %+v", sent)
+	}
+}
@@ -17,6 +17,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -42,10 +43,20 @@ var r *redis.Client
 //
 func process(msg []byte) {
 	testResult, err := test.ResultFromJSON(msg)
+	if _, ok := err.(*test.SchemaVersionError); ok {
+		// Sent by a newer worker, we can't make sense of it.
+		fmt.Printf("Skipping result: %s\n", err.Error())
+		return
+	}
 	if err != nil {
 		panic(err)
 	}
 
+	// The test might want to be notified elsewhere
+	if !testResult.IsNotifyTarget("webhook") {
+		return
+	}
+
 	// If the test passed then we don't care, unless otherwise defined
 	shouldSend := true
 	if testResult.Error == nil {
@@ -66,7 +77,17 @@ func process(msg []byte) {
 
 	fmt.Printf("Processing result: %+v\n", testResult)
 
-	res, err := http.Post(*webhookURL, "application/json", bytes.NewBuffer(msg))
+	//
+	// The result might have been compressed by the worker, so post
+	// it as plain JSON again.
+	//
+	payload, err := json.Marshal(testResult)
+	if err != nil {
+		fmt.Printf("Failed to encode the result: %s\n", err.Error())
+		return
+	}
+
+	res, err := http.Post(*webhookURL, "application/json", bytes.NewBuffer(payload))
 	if err != nil {
 		fmt.Printf("Failed to execute webhook request: %s\n", err.Error())
 		return
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// newWebhook returns a webhook endpoint recording the bodies posted to
+// it, and points the bridge at it.
+func newWebhook(t *testing.T, bodies *[][]byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content-type %s", r.Header.Get("Content-Type"))
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		*bodies = append(*bodies, body)
+	}))
+
+	url := server.URL
+	sendSuccess := false
+	sendRecovered := false
+	webhookURL = &url
+	sendTestSuccess = &sendSuccess
+	sendTestRecovered = &sendRecovered
+
+	return server
+}
+
+func TestProcess(t *testing.T) {
+	var bodies [][]byte
+	server := newWebhook(t, &bodies)
+	defer server.Close()
+
+	errorText := "connection refused"
+	msg, err := json.Marshal(test.Result{
+		Input:  "example.com must run http",
+		Target: "example.com",
+		Type:   "http",
+		Error:  &errorText,
+	})
+	if err != nil {
+		t.Fatalf("failed to encode result: %s", err)
+	}
+	compressed, err := test.CompressResult(msg)
+	if err != nil {
+		t.Fatalf("failed to compress result: %s", err)
+	}
+
+	// Compressed results are posted as JSON too.
+	process(msg)
+	process(compressed)
+	if len(bodies) != 2 {
+		t.Fatalf("expected two webhooks, got %d", len(bodies))
+	}
+	for _, body := range bodies {
+		var posted test.Result
+		if err = json.Unmarshal(body, &posted); err != nil {
+			t.Fatalf("expected a JSON body, got %q: %s", body, err)
+		}
+		if posted.Input != "example.com must run http" || posted.Error == nil || *posted.Error != errorText {
+			t.Errorf("unexpected result posted %+v", posted)
+		}
+	}
+
+	// Passing tests are ignored.
+	msg, _ = json.Marshal(test.Result{Input: "example.com must run http", Target: "example.com", Type: "http"})
+	process(msg)
+	if len(bodies) != 2 {
+		t.Errorf("expected no webhook for a passing test, got %d", len(bodies))
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/cmaster11/overseer/parser"
@@ -25,6 +26,10 @@ type enqueueCmd struct {
 	RedisSocket      string
 	RedisDialTimeout time.Duration
 	_r               *redis.Client
+
+	// Tests with a priority, pushed to the head of the queue
+	// once all the files have been parsed.
+	_prioritized []test.Test
 }
 
 //
@@ -84,10 +89,37 @@ func (p *enqueueCmd) SetFlags(f *flag.FlagSet) {
 // has been successfully parsed.
 //
 func (p *enqueueCmd) enqueueTest(tst test.Test) error {
+	if tst.Priority > 0 {
+		p._prioritized = append(p._prioritized, tst)
+		return nil
+	}
+
 	_, err := p._r.RPush("overseer.jobs", tst.Input).Result()
 	return err
 }
 
+//
+// Push the prioritized tests to the head of the queue.
+//
+// They are pushed in ascending priority order, so that the tests with
+// the highest priority end up at the very head, and will be fetched first
+// by the workers.
+//
+func (p *enqueueCmd) enqueuePrioritized() error {
+	sort.SliceStable(p._prioritized, func(i, j int) bool {
+		return p._prioritized[i].Priority < p._prioritized[j].Priority
+	})
+
+	for _, tst := range p._prioritized {
+		if _, err := p._r.LPush("overseer.jobs", tst.Input).Result(); err != nil {
+			return err
+		}
+	}
+
+	p._prioritized = nil
+	return nil
+}
+
 //
 // Entry-point.
 //
@@ -152,5 +184,10 @@ func (p *enqueueCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{
 		}
 	}
 
+	if err = p.enqueuePrioritized(); err != nil {
+		fmt.Printf("Error enqueuing prioritized tests: %s\n", err.Error())
+		return subcommands.ExitFailure
+	}
+
 	return subcommands.ExitSuccess
 }
@@ -162,11 +162,12 @@ func (p *k8sEventWatcherCmd) onEvent(event *v1.Event, eventFilter *k8seventwatch
 	input := fmt.Sprintf("%s [%s]", target, eventFilter.String())
 
 	testResult := &test.Result{
-		Input:  input,
-		Target: target,
-		Time:   event.CreationTimestamp.Unix(),
-		Type:   "k8s-event",
-		Tag:    p.Tag,
+		SchemaVersion: test.ResultSchemaVersion,
+		Input:         input,
+		Target:        target,
+		Time:          event.CreationTimestamp.Unix(),
+		Type:          "k8s-event",
+		Tag:           p.Tag,
 	}
 
 	eventFilterString := eventFilter.ToYAML()
@@ -0,0 +1,67 @@
+// Lint
+//
+// The lint sub-command validates test-files, reporting all their invalid
+// tests, without running any of them.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/cmaster11/overseer/parser"
+	"github.com/google/subcommands"
+)
+
+type lintCmd struct {
+}
+
+//
+// Glue
+//
+func (*lintCmd) Name() string     { return "lint" }
+func (*lintCmd) Synopsis() string { return "Validate test-files, without running them." }
+func (*lintCmd) Usage() string {
+	return `lint file1 [file2 .. fileN] :
+  Parse the given test-files, reporting every invalid test found in them,
+  e.g. an unknown protocol or an argument which doesn't validate, along
+  with the file and line it comes from.
+
+  No test is executed, and the exit-code is non-zero if any test is
+  invalid, so that broken files can be rejected by CI.
+`
+}
+
+//
+// Flag setup.
+//
+func (p *lintCmd) SetFlags(f *flag.FlagSet) {
+}
+
+//
+// Entry-point.
+//
+func (p *lintCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+
+	if len(f.Args()) == 0 {
+		fmt.Printf("No test-file given\n")
+		return subcommands.ExitUsageError
+	}
+
+	invalid := 0
+	for _, file := range f.Args() {
+
+		count, errs := parser.New().LintFile(file)
+		for _, err := range errs {
+			fmt.Printf("%s\n", err.Error())
+		}
+		fmt.Printf("%s: %d valid test(s), %d error(s)\n", file, count, len(errs))
+
+		invalid += len(errs)
+	}
+
+	if invalid > 0 {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
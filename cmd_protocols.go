@@ -0,0 +1,49 @@
+// Protocols
+//
+// The protocols sub-command shows the reference of our protocol-tests.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cmaster11/overseer/protocols"
+	"github.com/google/subcommands"
+)
+
+type protocolsCmd struct {
+}
+
+//
+// Glue
+//
+func (*protocolsCmd) Name() string     { return "protocols" }
+func (*protocolsCmd) Synopsis() string { return "List the protocol-tests and their arguments." }
+func (*protocolsCmd) Usage() string {
+	return `protocols [protocol ..] :
+  List the protocol-tests we support, with the arguments they accept and
+  an example of their usage.
+
+  Without arguments all of them are listed.
+`
+}
+
+//
+// Flag setup.
+//
+func (p *protocolsCmd) SetFlags(f *flag.FlagSet) {
+}
+
+//
+// Entry-point.
+//
+func (p *protocolsCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+
+	if err := protocols.WriteReference(os.Stdout, f.Args()...); err != nil {
+		fmt.Printf("%s\n", err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
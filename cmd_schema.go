@@ -0,0 +1,74 @@
+// Schema
+//
+// The schema sub-command shows the JSON Schema of the jobs and results
+// stored in redis.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/cmaster11/overseer/test"
+	"github.com/google/subcommands"
+)
+
+type schemaCmd struct {
+}
+
+//
+// Glue
+//
+func (*schemaCmd) Name() string     { return "schema" }
+func (*schemaCmd) Synopsis() string { return "Show the JSON Schema of tests and results." }
+func (*schemaCmd) Usage() string {
+	return `schema [test|result] :
+  Show the JSON Schema describing parsed tests, and/or test results as
+  they are published to redis.
+
+  Without arguments both documents are shown, as an object with the
+  keys "test" and "result".
+`
+}
+
+//
+// Flag setup.
+//
+func (p *schemaCmd) SetFlags(f *flag.FlagSet) {
+}
+
+//
+// Entry-point.
+//
+func (p *schemaCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+
+	schemas := map[string]map[string]interface{}{
+		"test":   test.JSONSchema(test.Test{}, "Test"),
+		"result": test.JSONSchema(test.Result{}, "Result"),
+	}
+
+	var doc interface{} = schemas
+	if len(f.Args()) > 0 {
+		if len(f.Args()) > 1 {
+			fmt.Printf("Only one schema can be shown at a time\n")
+			return subcommands.ExitUsageError
+		}
+
+		schema, ok := schemas[f.Args()[0]]
+		if !ok {
+			fmt.Printf("Unknown schema '%s', expected 'test' or 'result'\n", f.Args()[0])
+			return subcommands.ExitUsageError
+		}
+		doc = schema
+	}
+
+	j, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		fmt.Printf("Failed to encode the schema: %s\n", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	fmt.Fprintf(out, "%s\n", j)
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,174 @@
+// Status
+//
+// The status sub-command shows the state of the central redis queues.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/cmaster11/overseer/utils"
+	"github.com/go-redis/redis"
+	"github.com/google/subcommands"
+)
+
+type statusCmd struct {
+	RedisDB          int
+	RedisHost        string
+	RedisPassword    string
+	RedisSocket      string
+	RedisDialTimeout time.Duration
+	_r               *redis.Client
+}
+
+//
+// Glue
+//
+func (*statusCmd) Name() string     { return "status" }
+func (*statusCmd) Synopsis() string { return "Show the state of the redis queues" }
+func (*statusCmd) Usage() string {
+	return `status :
+  Show the number of pending jobs and results, the number of
+  deduplicated tests, and how many workers appear to be active.
+`
+}
+
+//
+// Flag setup.
+//
+func (p *statusCmd) SetFlags(f *flag.FlagSet) {
+
+	//
+	// Create the default options here
+	//
+	// This is done so we can load defaults via a configuration-file
+	// if present.
+	//
+	var defaults statusCmd
+	defaults.RedisHost = "localhost:6379"
+	defaults.RedisPassword = ""
+	defaults.RedisDB = 0
+	defaults.RedisSocket = ""
+	defaults.RedisDialTimeout = 5 * time.Second
+
+	//
+	// If we have a configuration file then load it
+	//
+	if len(os.Getenv("OVERSEER")) > 0 {
+		cfg, err := ioutil.ReadFile(os.Getenv("OVERSEER"))
+		if err == nil {
+			err = json.Unmarshal(cfg, &defaults)
+			if err != nil {
+				fmt.Printf("WARNING: Error loading overseer.json - %s\n",
+					err.Error())
+			}
+		} else {
+			fmt.Printf("WARNING: Failed to read configuration-file - %s\n", err.Error())
+		}
+	}
+
+	f.IntVar(&p.RedisDB, "redis-db", defaults.RedisDB, "Specify the database-number for redis.")
+	f.StringVar(&p.RedisHost, "redis-host", defaults.RedisHost, "Specify the address of the redis queue.")
+	f.StringVar(&p.RedisPassword, "redis-pass", defaults.RedisPassword, "Specify the password for the redis queue.")
+	f.StringVar(&p.RedisSocket, "redis-socket", defaults.RedisSocket, "If set, will be used for the redis connections.")
+	f.DurationVar(&p.RedisDialTimeout, "redis-timeout", defaults.RedisDialTimeout, "Redis connection timeout.")
+}
+
+//
+// Count the keys matching the given pattern, using SCAN so that
+// we don't block the redis-server on large databases.
+//
+func (p *statusCmd) countKeys(pattern string) (int, error) {
+	count := 0
+	var cursor uint64
+
+	for {
+		keys, next, err := p._r.Scan(cursor, pattern, 1000).Result()
+		if err != nil {
+			return 0, err
+		}
+		count += len(keys)
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return count, nil
+}
+
+//
+// Entry-point.
+//
+func (p *statusCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+
+	//
+	// Connect to the redis-host.
+	//
+	if p.RedisSocket != "" {
+		p._r = redis.NewClient(&redis.Options{
+			Network:     "unix",
+			Addr:        p.RedisSocket,
+			Password:    p.RedisPassword,
+			DB:          p.RedisDB,
+			DialTimeout: p.RedisDialTimeout,
+		})
+	} else {
+		p._r = redis.NewClient(&redis.Options{
+			Addr:        p.RedisHost,
+			Password:    p.RedisPassword,
+			DB:          p.RedisDB,
+			DialTimeout: p.RedisDialTimeout,
+		})
+	}
+
+	//
+	// And run a ping, just to make sure it worked.
+	//
+	_, err := p._r.Ping().Result()
+	if err != nil {
+		fmt.Printf("Redis connection failed: %s\n", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	jobs, err := p._r.LLen("overseer.jobs").Result()
+	if err != nil {
+		fmt.Printf("Failed to get the length of the jobs queue: %s\n", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	results, err := p._r.LLen("overseer.results").Result()
+	if err != nil {
+		fmt.Printf("Failed to get the length of the results queue: %s\n", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	dedup, err := p.countKeys("overseer.dedup-cache.*")
+	if err != nil {
+		fmt.Printf("Failed to count the dedup keys: %s\n", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	workers, err := p.countKeys(utils.WorkerHeartbeatPrefix + "*")
+	if err != nil {
+		fmt.Printf("Failed to count the worker heartbeats: %s\n", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	fmt.Printf("Pending jobs:    %d\n", jobs)
+	fmt.Printf("Pending results: %d\n", results)
+	fmt.Printf("Dedup entries:   %d\n", dedup)
+	if workers > 0 {
+		fmt.Printf("Active workers:  %d\n", workers)
+	} else {
+		fmt.Printf("Active workers:  none seen (no heartbeats found)\n")
+	}
+
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,162 @@
+// Test One
+//
+// The test-one sub-command runs a single test, given on the command-line,
+// and shows its result, without any redis-server involved.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cmaster11/overseer/parser"
+	"github.com/cmaster11/overseer/protocols"
+	"github.com/cmaster11/overseer/sinks"
+	"github.com/cmaster11/overseer/test"
+	"github.com/cmaster11/overseer/utils"
+	"github.com/google/subcommands"
+)
+
+type testOneCmd struct {
+	// The format of the result: "text", "json" or "tap"
+	Format string
+
+	// Are IPv4 and IPv6 tests enabled?
+	IPv4 bool
+	IPv6 bool
+
+	// Should failing tests be retried, how many times, and how long
+	// should we wait in between?
+	Retry      bool
+	RetryCount uint
+	RetryDelay time.Duration
+
+	// How long should the test run for?
+	Timeout time.Duration
+
+	// Should the test be verbose?
+	Verbose bool
+
+	// Where the result is written, stdout unless testing.
+	_out io.Writer
+}
+
+//
+// Glue
+//
+func (*testOneCmd) Name() string     { return "test-one" }
+func (*testOneCmd) Synopsis() string { return "Run a single test, without redis." }
+func (*testOneCmd) Usage() string {
+	return `test-one [flags] 'TARGET must run PROTOCOL [with ...]' :
+  Run the single test given, just like a worker would, and show its
+  result, along with the time it took.
+
+  No redis-server is needed, which makes this handy to debug a test, or
+  to smoke-test a deployment from CI: the exit-code is non-zero if the
+  test failed.
+`
+}
+
+//
+// Flag setup.
+//
+func (p *testOneCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.Format, "format", "text", fmt.Sprintf("The format of the result, one of %s.", strings.Join(test.ResultFormats, ", ")))
+	f.BoolVar(&p.IPv4, "4", true, "Enable IPv4 tests.")
+	f.BoolVar(&p.IPv6, "6", true, "Enable IPv6 tests.")
+	f.BoolVar(&p.Retry, "retry", false, "Should a failing test be retried a few times before regarding it as a failure.")
+	f.UintVar(&p.RetryCount, "retry-count", 5, "How many times to retry the test, if -retry is given.")
+	f.DurationVar(&p.RetryDelay, "retry-delay", 5*time.Second, "The time to sleep between failing tests.")
+	f.DurationVar(&p.Timeout, "timeout", 10*time.Second, "The timeout of the test.")
+	f.BoolVar(&p.Verbose, "verbose", false, "Show more output.")
+}
+
+//
+// Entry-point.
+//
+func (p *testOneCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+
+	out := p._out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	if !test.IsResultFormat(p.Format) {
+		fmt.Fprintf(out, "Invalid -format '%s', must be one of %s\n", p.Format, strings.Join(test.ResultFormats, ", "))
+		return subcommands.ExitUsageError
+	}
+
+	//
+	// The test might have been given as a single argument, or not
+	// quoted at all.
+	//
+	line := strings.Join(f.Args(), " ")
+	tst, err := parser.New().ParseLine(line, nil)
+	if err == parser.ErrEmptyLine {
+		fmt.Fprintf(out, "No test given\n")
+		return subcommands.ExitUsageError
+	}
+	if err != nil {
+		fmt.Fprintf(out, "Error parsing the test: %s\n", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	logger, err := utils.NewLogger("text", p.Verbose, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(out, "%s\n", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	//
+	// Run the test via a worker without redis, which keeps the
+	// results in memory for us to show.
+	//
+	results := sinks.NewMemorySink()
+	worker := &workerCmd{
+		IPv4:        p.IPv4,
+		IPv6:        p.IPv6,
+		Retry:       p.Retry,
+		RetryCount:  p.RetryCount,
+		_backoff:    utils.RetryBackoff{Delay: p.RetryDelay},
+		_log:        logger,
+		_sinks:      []sinks.ResultSink{results},
+		_workerName: utils.WorkerName(""),
+	}
+
+	var opts test.Options
+	opts.Verbose = p.Verbose
+	opts.Timeout = p.Timeout
+	opts.HTTPMaxSize = protocols.DefaultHTTPMaxSize
+
+	start := time.Now()
+	errTest := worker.runTest(1, tst, opts)
+	elapsed := time.Since(start)
+
+	//
+	// Show the results, one per target the test ran against.
+	//
+	collected, durations := results.Results()
+	if err = test.WriteResults(out, p.Format, collected); err != nil {
+		fmt.Fprintf(out, "Error writing the results: %s\n", err.Error())
+		return subcommands.ExitFailure
+	}
+
+	if p.Format == "text" {
+		for i, result := range collected {
+			fmt.Fprintf(out, "%s took %s\n", result.Target, durations[i].Round(time.Millisecond))
+		}
+		fmt.Fprintf(out, "Completed in %s\n", elapsed.Round(time.Millisecond))
+	}
+
+	if errTest != nil {
+		if len(collected) == 0 {
+			fmt.Fprintf(out, "Test failed: %s\n", errTest.Error())
+		}
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+)
+
+// runTestOne runs the test-one sub-command with the given arguments,
+// returning its exit-status and output.
+func runTestOne(t *testing.T, args ...string) (subcommands.ExitStatus, string) {
+	var out strings.Builder
+	cmd := &testOneCmd{_out: &out}
+
+	f := flag.NewFlagSet("test-one", flag.ContinueOnError)
+	cmd.SetFlags(f)
+	if err := f.Parse(args); err != nil {
+		t.Fatalf("failed to parse the flags: %s", err)
+	}
+
+	status := cmd.Execute(context.Background(), f)
+	return status, out.String()
+}
+
+func TestTestOne(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("all good"))
+	}))
+	defer server.Close()
+
+	status, out := runTestOne(t, server.URL+" must run http with content 'all good'")
+	if status != subcommands.ExitSuccess {
+		t.Errorf("expected the test to pass, got status %d: %s", status, out)
+	}
+	if !strings.Contains(out, "PASS "+server.URL+" must run http with content 'all good'") {
+		t.Errorf("expected the result to be shown, got %q", out)
+	}
+	if !strings.Contains(out, "127.0.0.1 took ") || !strings.Contains(out, "Completed in ") {
+		t.Errorf("expected the timings to be shown, got %q", out)
+	}
+
+	// The test might not have been quoted.
+	status, out = runTestOne(t, "-format", "tap", server.URL, "must", "run", "http", "with", "content", "'missing'")
+	if status != subcommands.ExitFailure {
+		t.Errorf("expected the test to fail, got status %d: %s", status, out)
+	}
+	if !strings.Contains(out, "not ok 1 - "+server.URL+" must run http with content 'missing'") {
+		t.Errorf("expected the failure to be shown, got %q", out)
+	}
+	if strings.Contains(out, "Completed in ") {
+		t.Errorf("expected no timings outside of the text format, got %q", out)
+	}
+}
+
+func TestTestOneErrors(t *testing.T) {
+	status, out := runTestOne(t)
+	if status != subcommands.ExitUsageError || out != "No test given\n" {
+		t.Errorf("expected a usage error without a test, got status %d: %q", status, out)
+	}
+
+	status, out = runTestOne(t, "-format", "xml", "example.com must run http")
+	if status != subcommands.ExitUsageError || !strings.HasPrefix(out, "Invalid -format 'xml'") {
+		t.Errorf("expected a usage error for the format, got status %d: %q", status, out)
+	}
+
+	status, out = runTestOne(t, "example.com must run nothing")
+	if status != subcommands.ExitFailure || !strings.HasPrefix(out, "Error parsing the test: ") {
+		t.Errorf("expected the invalid test to fail, got status %d: %q", status, out)
+	}
+
+	status, out = runTestOne(t, "example.com must run ping with hosts-file /tmp/hosts.txt")
+	if status != subcommands.ExitFailure || !strings.Contains(out, "can't be run on their own") {
+		t.Errorf("expected the hosts-file test to be refused, got status %d: %q", status, out)
+	}
+}
@@ -5,22 +5,26 @@ package main
 
 import (
 	"context"
+	"crypto/md5"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
-	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cmaster11/overseer/metrics"
 	"github.com/cmaster11/overseer/parser"
 	"github.com/cmaster11/overseer/protocols"
+	"github.com/cmaster11/overseer/sinks"
 	"github.com/cmaster11/overseer/test"
 	"github.com/cmaster11/overseer/utils"
 	"github.com/go-redis/redis"
@@ -49,6 +53,15 @@ type workerCmd struct {
 	// Prior to retrying a failed test how long should we pause?
 	RetryDelay time.Duration
 
+	// Should the pause be always the same ("fixed"), or double after each attempt ("exponential")?
+	RetryBackoff string
+
+	// The maximum pause, with exponential backoff
+	RetryMaxDelay time.Duration
+
+	// The fraction [0-1] by which pauses are randomly increased or decreased
+	RetryJitter float64
+
 	// Default deduplication duration
 	DedupDuration time.Duration
 
@@ -67,26 +80,167 @@ type workerCmd struct {
 	// Redis connection timeout
 	RedisDialTimeout time.Duration
 
+	// How many times to retry the initial redis connection, and how long to wait before the first retry
+	RedisConnectRetries int
+	RedisConnectBackoff time.Duration
+
+	// The (optional) redis-hosts jobs and results are spread across, instead of the redis-host.
+	RedisHosts string
+
+	// The (optional) sentinels to ask for the address of the redis-master, instead of using the redis-host.
+	RedisSentinels string
+
+	// The name of the redis-master monitored by the sentinels.
+	RedisMaster string
+
+	// The (optional) nodes of the redis cluster to use, instead of the redis-host.
+	RedisCluster string
+
+	// Should we connect to redis via TLS?
+	RedisTLS bool
+
+	// The (optional) PEM files of the CA certificates to trust, and of the client certificate and key, for redis TLS
+	RedisTLSCA   string
+	RedisTLSCert string
+	RedisTLSKey  string
+
+	// Should we skip the verification of the redis certificate?
+	RedisTLSInsecure bool
+
 	// Tag applied to all results
 	Tag string
 
+	// Prefix prepended to the tag of all results
+	TagPrefix string
+
+	// The name identifying us in the results, the hostname by default
+	WorkerName string
+
 	// How long should tests run for?
 	Timeout time.Duration
 
 	// Should the testing, and the tests, be verbose?
 	Verbose bool
 
+	// The format of our messages, "text" or "json"
+	LogFormat string
+
 	// Default period test sleep, if not overridden by specific test setting
 	PeriodTestSleep time.Duration
 
 	// Default period test threshold percentage, if not overridden by specific test setting
 	PeriodTestThreshold float32
 
+	// Limits for the idle connections of the HTTP transports
+	HTTPMaxIdleConns        int
+	HTTPMaxIdleConnsPerHost int
+	HTTPIdleConnTimeout     time.Duration
+
+	// Default maximum size of the HTTP response bodies
+	HTTPMaxSize int64
+
+	// After how many consecutive failures should a test be moved to the quarantine queue? 0 disables quarantine.
+	QuarantineAfter uint
+
+	// Should this worker process the quarantine queue, instead of the main one?
+	QuarantineWorker bool
+
+	// How long should a quarantine worker pause between jobs?
+	QuarantineDelay time.Duration
+
+	// Should we exit once the queue is empty, instead of waiting for more jobs?
+	Once bool
+
+	// If > 0, shut down gracefully once the worker has been running for this long
+	MaxRuntime time.Duration
+
+	// Should results be gzipped before being stored in redis?
+	CompressResults bool
+
+	// If > 0, identical results pushed within this window are coalesced into the first one
+	CoalesceWindow time.Duration
+
+	// Should we refuse to start if the test-file contains invalid tests?
+	Strict bool
+
+	// The test-file validated at startup in strict-mode
+	TestFile string
+
+	// The (optional) address to serve Prometheus metrics on
+	MetricsAddr string
+
+	// Where the metrics of the tests are sent: "graphite", as configured
+	// by the environment, or "influxdb"
+	MetricsBackend string
+
+	// The InfluxDB server, database and token to send metrics to
+	InfluxURL   string
+	InfluxDB    string
+	InfluxToken string
+
+	// Should we run the on-fail-exec commands of failing tests?
+	AllowExec bool
+
+	// The (optional) maximum number of concurrent tests of each type, e.g. "http=50,dns=5"
+	TypeLimits string
+
+	// The (optional) redis list which jobs which can't be parsed are pushed to
+	DeadLetterQueue string
+
+	// The maximum size of a job, in bytes, bigger ones are rejected without parsing them. 0 disables the limit.
+	MaxJobSize int
+
+	// How often should each worker announce it is alive, via its heartbeat key? 0 disables the heartbeats.
+	HeartbeatInterval time.Duration
+
+	// The (optional) comma-separated redis lists the results are pushed to, instead of overseer.results
+	ResultsQueues string
+
+	// The (optional) SQLite database the results are recorded in too
+	SQLiteResults string
+
+	// If > 0, the results queues are trimmed to this many results, and expire after this long without new results
+	ResultsMaxLen int64
+	ResultsTTL    time.Duration
+
 	// The handle to our redis-server
-	_r *redis.Client
+	_r redis.UniversalClient
 
-	// The handle to our graphite-server
-	_g *graphite.Graphite
+	// The handles to all our redis-servers, when using redis-hosts, and
+	// the order in which we fetch jobs from them
+	_shards   []redis.UniversalClient
+	_rotation []int
+	_next     uint64
+
+	// Where the metrics of our tests are sent, if anywhere
+	_metrics metricsSink
+
+	// The statistics exposed to Prometheus, if enabled
+	_prom *metrics.Prometheus
+
+	// The concurrency limits of the test types, if any
+	_limits *utils.TypeLimiter
+
+	// How long to sleep between retries
+	_backoff utils.RetryBackoff
+
+	// The number of failed tests, used in once-mode for our exit-code
+	_failures uint64
+
+	// Where our messages are written
+	_log *utils.Logger
+
+	// The number of jobs fetched by each worker, for the heartbeats
+	_processed []uint64
+
+	// The redis lists the results are pushed to
+	_resultsQueues []string
+
+	// Where the results are recorded, besides redis
+	_sinks []sinks.ResultSink
+
+	// The name identifying us, as resolved from WorkerName
+	_workerName string
 }
 
 //
@@ -97,12 +251,44 @@ func (*workerCmd) Synopsis() string { return "Fetch jobs from the central queue
 func (*workerCmd) Usage() string {
 	return `worker :
   Execute tests pulled from the central redis queue, until terminated.
+
+  With -once the worker exits as soon as the queue is empty instead, with
+  a non-zero exit-code if any test failed.
+
+  With -max-runtime the worker shuts down gracefully, as if interrupted,
+  once it has been running for the given duration.
+
+  With -strict the worker parses the given -test-file before starting, and
+  refuses to start if it references unknown test types or invalid
+  arguments.
 `
 }
 
+// metricsSink is where the metrics of our tests are sent, keyed by name.
+type metricsSink interface {
+	Send(values map[string]string) error
+}
+
+// graphiteSink sends metrics to a carbon-server.
+type graphiteSink struct {
+	g *graphite.Graphite
+}
+
+// Send sends the given metrics, recorded now.
+func (s graphiteSink) Send(values map[string]string) error {
+	return s.g.SendMetrics(metrics.GraphiteBatch(values, time.Now()))
+}
+
 // MetricsFromEnvironment sets up a carbon connection from the environment
-// if suitable values are found
+// if suitable values are found, or a StatsD one if METRICS_PROTOCOL is
+// "statsd".
 func (p *workerCmd) MetricsFromEnvironment() {
+	p.metricsFromEnvironment(os.Getenv("METRICS_PROTOCOL"))
+}
+
+// metricsFromEnvironment sets up a connection to the metrics-host found in
+// the environment, if any, speaking the given protocol.
+func (p *workerCmd) metricsFromEnvironment(protocol string) {
 
 	//
 	// Get the hostname to connect to.
@@ -117,27 +303,43 @@ func (p *workerCmd) MetricsFromEnvironment() {
 		return
 	}
 
+	// Setup the protocol to use
+	if protocol == "" {
+		protocol = "udp"
+	}
+
 	// Split the into Host + Port
 	ho, pr, err := net.SplitHostPort(host)
 	if err != nil {
 		// If that failed we assume the port was missing
 		ho = host
 		pr = "2003"
+		if protocol == "statsd" {
+			pr = "8125"
+		}
 	}
 
-	// Setup the protocol to use
-	protocol := os.Getenv("METRICS_PROTOCOL")
-	if protocol == "" {
-		protocol = "udp"
+	// StatsD is always spoken over UDP
+	if protocol == "statsd" {
+		s, errStatsD := metrics.NewStatsD(net.JoinHostPort(ho, pr))
+		if errStatsD != nil {
+			fmt.Printf("Error setting up metrics - skipping - %s\n", errStatsD.Error())
+			return
+		}
+		p._metrics = s
+		return
 	}
 
 	// Ensure that the port is an integer
 	port, err := strconv.Atoi(pr)
 	if err == nil {
-		p._g, err = graphite.GraphiteFactory(protocol, ho, port, "")
+		var g *graphite.Graphite
+		g, err = graphite.GraphiteFactory(protocol, ho, port, "")
 
 		if err != nil {
 			fmt.Printf("Error setting up metrics - skipping - %s\n", err.Error())
+		} else {
+			p._metrics = graphiteSink{g: g}
 		}
 	} else {
 		fmt.Printf("Error setting up metrics - failed to convert port to number - %s\n", err.Error())
@@ -147,8 +349,17 @@ func (p *workerCmd) MetricsFromEnvironment() {
 
 // verbose shows a message only if we're running verbosely
 func (p *workerCmd) verbose(txt string) {
-	if p.Verbose {
-		fmt.Print(txt)
+	p._log.Debug(utils.LogFields{}, "%s", txt)
+}
+
+// logFields returns the fields describing the given test, run by the
+// given worker, for our messages.
+func (p *workerCmd) logFields(workerIdx uint, tst test.Test) utils.LogFields {
+	return utils.LogFields{
+		Worker:   workerIdx,
+		TestType: tst.Type,
+		Target:   tst.Target,
+		Tag:      p.resultTag(tst),
 	}
 }
 
@@ -168,16 +379,60 @@ func (p *workerCmd) SetFlags(f *flag.FlagSet) {
 	defaults.Retry = true
 	defaults.RetryCount = 5
 	defaults.RetryDelay = 5 * time.Second
+	defaults.RetryBackoff = "fixed"
+	defaults.RetryMaxDelay = 5 * time.Minute
+	defaults.RetryJitter = 0
 	defaults.DedupDuration = 0
 	defaults.Tag = ""
+	defaults.TagPrefix = ""
+	defaults.WorkerName = ""
 	defaults.Timeout = 10 * time.Second
 	defaults.Verbose = false
+	defaults.LogFormat = "text"
 	defaults.RedisHost = "localhost:6379"
 	defaults.RedisDB = 0
 	defaults.RedisPassword = ""
 	defaults.RedisDialTimeout = 5 * time.Second
+	defaults.RedisConnectRetries = 0
+	defaults.RedisConnectBackoff = time.Second
+	defaults.RedisHosts = ""
+	defaults.RedisSentinels = ""
+	defaults.RedisMaster = ""
+	defaults.RedisCluster = ""
+	defaults.RedisTLS = false
+	defaults.RedisTLSCA = ""
+	defaults.RedisTLSCert = ""
+	defaults.RedisTLSKey = ""
+	defaults.RedisTLSInsecure = false
 	defaults.PeriodTestSleep = 5 * time.Second
 	defaults.PeriodTestThreshold = 0
+	defaults.HTTPMaxIdleConns = 100
+	defaults.HTTPMaxIdleConnsPerHost = 2
+	defaults.HTTPIdleConnTimeout = 0
+	defaults.HTTPMaxSize = protocols.DefaultHTTPMaxSize
+	defaults.QuarantineAfter = 0
+	defaults.QuarantineWorker = false
+	defaults.QuarantineDelay = 30 * time.Second
+	defaults.Once = false
+	defaults.MaxRuntime = 0
+	defaults.CompressResults = false
+	defaults.CoalesceWindow = 0
+	defaults.Strict = false
+	defaults.TestFile = ""
+	defaults.MetricsAddr = ""
+	defaults.MetricsBackend = "graphite"
+	defaults.InfluxURL = ""
+	defaults.InfluxDB = "overseer"
+	defaults.InfluxToken = ""
+	defaults.AllowExec = false
+	defaults.TypeLimits = ""
+	defaults.DeadLetterQueue = "overseer.deadletter"
+	defaults.MaxJobSize = 0
+	defaults.HeartbeatInterval = 30 * time.Second
+	defaults.ResultsQueues = ""
+	defaults.ResultsMaxLen = 0
+	defaults.ResultsTTL = 0
+	defaults.SQLiteResults = ""
 
 	//
 	// If we have a configuration file then load it
@@ -201,9 +456,11 @@ func (p *workerCmd) SetFlags(f *flag.FlagSet) {
 	//
 	// Worker
 	f.UintVar(&p.Parallel, "parallel", defaults.Parallel, "Number of parallel tests the worker can be handled at the same time.")
+	f.StringVar(&p.TypeLimits, "type-limits", defaults.TypeLimits, "Comma-separated limits of the tests of a type which can run at the same time, e.g. 'http=50,dns=5'. Unlisted types are only limited by -parallel.")
 
 	// Verbose
 	f.BoolVar(&p.Verbose, "verbose", defaults.Verbose, "Show more output.")
+	f.StringVar(&p.LogFormat, "log-format", defaults.LogFormat, "The format of the messages of the worker, 'text' or 'json', one object per line.")
 
 	// Protocols
 	f.BoolVar(&p.IPv4, "4", defaults.IPv4, "Enable IPv4 tests.")
@@ -216,6 +473,9 @@ func (p *workerCmd) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&p.Retry, "retry", defaults.Retry, "Should failing tests be retried a few times before raising a notification.")
 	f.UintVar(&p.RetryCount, "retry-count", defaults.RetryCount, "How many times to retry a test, before regarding it as a failure.")
 	f.DurationVar(&p.RetryDelay, "retry-delay", defaults.RetryDelay, "The time to sleep between failing tests.")
+	f.StringVar(&p.RetryBackoff, "retry-backoff", defaults.RetryBackoff, "How the time to sleep changes between retries: 'fixed', or 'exponential' to double it after each attempt.")
+	f.DurationVar(&p.RetryMaxDelay, "retry-max-delay", defaults.RetryMaxDelay, "The maximum time to sleep between retries, with exponential backoff.")
+	f.Float64Var(&p.RetryJitter, "retry-jitter", defaults.RetryJitter, "Randomly increase or decrease the time to sleep between retries by up to this fraction, e.g. 0.2 for 20%.")
 
 	f.DurationVar(&p.DedupDuration, "dedup", defaults.DedupDuration, "The maximum duration of a deduplication.")
 
@@ -225,38 +485,110 @@ func (p *workerCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&p.RedisPassword, "redis-pass", defaults.RedisPassword, "Specify the password for the redis queue.")
 	f.StringVar(&p.RedisSocket, "redis-socket", defaults.RedisSocket, "If set, will be used for the redis connections.")
 	f.DurationVar(&p.RedisDialTimeout, "redis-timeout", defaults.RedisDialTimeout, "Redis connection timeout.")
+	f.IntVar(&p.RedisConnectRetries, "redis-connect-retries", defaults.RedisConnectRetries, "How many times to retry the initial redis connection before giving up, e.g. while redis is starting.")
+	f.DurationVar(&p.RedisConnectBackoff, "redis-connect-backoff", defaults.RedisConnectBackoff, "The time to wait before retrying the initial redis connection, doubled after each attempt, up to 30s.")
+	f.StringVar(&p.RedisHosts, "redis-hosts", defaults.RedisHosts, "Comma-separated redis addresses, optionally weighted as host:port*2, to fetch jobs from in turn (overrides -redis-host). Results stay on the host each test hashes to.")
+	f.StringVar(&p.RedisSentinels, "redis-sentinels", defaults.RedisSentinels, "Comma-separated addresses of the redis sentinels, e.g. 'host1:26379,host2:26379', to find the -redis-master through (overrides -redis-host).")
+	f.StringVar(&p.RedisMaster, "redis-master", defaults.RedisMaster, "The name of the redis master monitored by the -redis-sentinels.")
+	f.StringVar(&p.RedisCluster, "redis-cluster", defaults.RedisCluster, "Comma-separated addresses of some nodes of a redis cluster, e.g. 'host1:6379,host2:6379', to use instead of -redis-host.")
+	f.BoolVar(&p.RedisTLS, "redis-tls", defaults.RedisTLS, "Connect to redis via TLS, implied by the other -redis-tls flags.")
+	f.StringVar(&p.RedisTLSCA, "redis-tls-ca", defaults.RedisTLSCA, "The PEM file of the CA certificates to verify the redis certificate with, instead of the system ones.")
+	f.StringVar(&p.RedisTLSCert, "redis-tls-cert", defaults.RedisTLSCert, "The PEM file of the client certificate to authenticate to redis with.")
+	f.StringVar(&p.RedisTLSKey, "redis-tls-key", defaults.RedisTLSKey, "The PEM file of the key of the -redis-tls-cert.")
+	f.BoolVar(&p.RedisTLSInsecure, "redis-tls-insecure", defaults.RedisTLSInsecure, "Don't verify the redis certificate.")
 
 	// Tag
 	f.StringVar(&p.Tag, "tag", defaults.Tag, "Specify the tag to add to all test-results.")
+	f.StringVar(&p.TagPrefix, "tag-prefix", defaults.TagPrefix, "Specify a prefix for the tag of all test-results, including the ones with a per-test tag.")
+	f.StringVar(&p.WorkerName, "worker-name", defaults.WorkerName, "Specify the name identifying this worker in the test-results, instead of the hostname.")
 
 	// Period test
 	f.DurationVar(&p.PeriodTestSleep, "period-test-sleep", defaults.PeriodTestSleep, "The sleeping interval between subsequent tests in a period-test.")
 	f.Var(utils.NewPercentageValue(defaults.PeriodTestThreshold, &p.PeriodTestThreshold), "period-test-threshold", "The percentage of failures need to trigger an alert in a period-test.")
+
+	// HTTP transports
+	f.IntVar(&p.HTTPMaxIdleConns, "http-max-idle-conns", defaults.HTTPMaxIdleConns, "The maximum number of idle HTTP connections kept by each HTTP test (0 for no limit).")
+	f.IntVar(&p.HTTPMaxIdleConnsPerHost, "http-max-idle-conns-per-host", defaults.HTTPMaxIdleConnsPerHost, "The maximum number of idle HTTP connections kept per host by each HTTP test.")
+	f.DurationVar(&p.HTTPIdleConnTimeout, "http-idle-conn-timeout", defaults.HTTPIdleConnTimeout, "How long idle HTTP connections are kept open (0 closes them as soon as each test completes).")
+	f.Int64Var(&p.HTTPMaxSize, "http-max-size", defaults.HTTPMaxSize, "The maximum size of the HTTP response bodies, in bytes, unless a test sets its max-size.")
+
+	// Quarantine
+	f.UintVar(&p.QuarantineAfter, "quarantine-after", defaults.QuarantineAfter, "Move tests which failed this many consecutive times to the quarantine queue, which needs a worker started with -quarantine-worker (0 to disable).")
+	f.BoolVar(&p.QuarantineWorker, "quarantine-worker", defaults.QuarantineWorker, "Fetch jobs from the quarantine queue, instead of the main one.")
+	f.DurationVar(&p.QuarantineDelay, "quarantine-delay", defaults.QuarantineDelay, "The time a quarantine worker sleeps between jobs.")
+
+	// Batch mode
+	f.BoolVar(&p.Once, "once", defaults.Once, "Exit once the queue is empty, with a non-zero exit-code if any test failed.")
+	f.DurationVar(&p.MaxRuntime, "max-runtime", defaults.MaxRuntime, "Shut down gracefully after running for this long, e.g. to fit in a cron slot (0 to run forever).")
+
+	// Results
+	f.BoolVar(&p.CompressResults, "compress-results", defaults.CompressResults, "Gzip the test-results stored in redis, to save memory when they carry large details.")
+	f.DurationVar(&p.CoalesceWindow, "coalesce-window", defaults.CoalesceWindow, "Push only the first of the identical results of a test within this window (0 to disable).")
+
+	// Jobs which can't be parsed
+	f.StringVar(&p.DeadLetterQueue, "deadletter-queue", defaults.DeadLetterQueue, "The redis list jobs which can't be parsed are pushed to, along with the error (empty to just drop them).")
+	f.IntVar(&p.MaxJobSize, "max-job-size", defaults.MaxJobSize, "Reject jobs bigger than this many bytes, to the dead-letter queue, without parsing them (0 for no limit).")
+	f.StringVar(&p.ResultsQueues, "results-queues", defaults.ResultsQueues, "Comma-separated redis lists to push each result to, instead of overseer.results, e.g. to feed several bridges.")
+	f.Int64Var(&p.ResultsMaxLen, "results-max-len", defaults.ResultsMaxLen, "Trim the results queues to their newest N results, so that they can't grow forever if no bridge consumes them (0 for no limit).")
+	f.DurationVar(&p.ResultsTTL, "results-ttl", defaults.ResultsTTL, "Expire the results queues once no result was pushed for this long (0 to disable).")
+	f.StringVar(&p.SQLiteResults, "sqlite-results", defaults.SQLiteResults, "The (optional) SQLite database to record every result in too, for offline analysis.")
+	f.DurationVar(&p.HeartbeatInterval, "heartbeat-interval", defaults.HeartbeatInterval, "How often each worker announces it is alive, via a redis key expiring after three intervals (0 to disable).")
+
+	// Validation
+	f.BoolVar(&p.Strict, "strict", defaults.Strict, "Refuse to start if the -test-file contains unknown test types or invalid arguments.")
+	f.StringVar(&p.TestFile, "test-file", defaults.TestFile, "The file of the tests this worker will be given, validated at startup in strict-mode.")
+
+	// Metrics
+	f.StringVar(&p.MetricsAddr, "metrics-addr", defaults.MetricsAddr, "If set, e.g. to ':9100', serve Prometheus metrics on this address, at /metrics.")
+	f.StringVar(&p.MetricsBackend, "metrics-backend", defaults.MetricsBackend, "Where the metrics of the tests are sent: 'graphite', configured by the METRICS environment variables, 'statsd', sent to the METRICS host (port 8125 by default), or 'influxdb'.")
+	f.StringVar(&p.InfluxURL, "influx-url", defaults.InfluxURL, "The InfluxDB server to send metrics to, with -metrics-backend influxdb, e.g. 'http://localhost:8086'.")
+	f.StringVar(&p.InfluxDB, "influx-db", defaults.InfluxDB, "The InfluxDB database, or bucket, to send metrics to.")
+	f.StringVar(&p.InfluxToken, "influx-token", defaults.InfluxToken, "The (optional) token to authenticate to InfluxDB with.")
+
+	// Hooks
+	f.BoolVar(&p.AllowExec, "allow-exec", defaults.AllowExec, "Run the on-fail-exec commands of failing tests. Anybody who can enqueue tests can then run commands on this worker.")
 }
 
-// notify is used to store the result of a test in our redis queue.
-func (p *workerCmd) notify(testDefinition test.Test, resultError error, details *string) error {
+// validateTestFile parses our test-file, returning the first invalid
+// test found in it.
+func (p *workerCmd) validateTestFile() error {
+	if p.TestFile == "" {
+		fmt.Printf("WARNING: -strict has no effect without a -test-file\n")
+		return nil
+	}
 
-	//
-	// If we don't have a redis-server then return immediately.
-	//
-	// (This shouldn't happen, as without a redis-handle we can't
-	// fetch jobs to execute.)
-	//
-	if p._r == nil {
+	count := 0
+	err := parser.New().ParseFile(p.TestFile, func(test.Test) error {
+		count++
 		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("invalid test-file %s: %s", p.TestFile, err.Error())
 	}
 
+	p.verbose(fmt.Sprintf("Validated %d test(s) in %s\n", count, p.TestFile))
+	return nil
+}
+
+// notify is used to store the result of a test, which took the given
+// time to run, in our redis queue and in our result sinks.
+func (p *workerCmd) notify(testDefinition test.Test, resultError error, details *string, duration time.Duration) error {
+
 	//
 	// The message we'll publish will be a JSON hash
 	//
 	testResult := &test.Result{
-		Input:   testDefinition.Input,
-		Target:  testDefinition.Target,
-		Time:    time.Now().Unix(),
-		Type:    testDefinition.Type,
-		Tag:     p.Tag,
-		Details: details,
+		SchemaVersion: test.ResultSchemaVersion,
+		Input:         testDefinition.Input,
+		Target:        testDefinition.Target,
+		Time:          time.Now().Unix(),
+		Type:          testDefinition.Type,
+		Tag:           p.resultTag(testDefinition),
+		Name:          testDefinition.Name,
+		Worker:        p._workerName,
+		Details:       details,
+
+		NotifyTargets: testDefinition.NotifyTargets,
 	}
 
 	//
@@ -269,6 +601,30 @@ func (p *workerCmd) notify(testDefinition test.Test, resultError error, details
 		testResult.Error = &errorString
 	}
 
+	// Keep the whole history in the sinks, before deduplicating.
+	for _, sink := range p._sinks {
+		if err := sink.Record(testResult, duration); err != nil {
+			p._log.Error(p.logFields(0, testDefinition), "Failed to record the test-result: %s", err.Error())
+		}
+	}
+
+	//
+	// If we don't have a redis-server then we're done.
+	//
+	// (This only happens when running tests locally, via test-one, as
+	// otherwise we couldn't fetch jobs to execute.)
+	//
+	if p._r == nil {
+		return nil
+	}
+
+	// Drop results identical to one which was pushed moments ago, e.g. by the retries of a period-test.
+	if p.isCoalesced(testResult) {
+		p._log.Debug(p.logFields(0, testDefinition), "Skipping result (coalesced, window %s) for test `%s` (%s)",
+			p.CoalesceWindow, testDefinition.Input, testDefinition.Target)
+		return nil
+	}
+
 	// If test has a deduplication rule, avoid re-triggering a notification if not needed, or clean the dedup cache if needed.
 	if testDefinition.DedupDuration != nil {
 
@@ -279,6 +635,11 @@ func (p *workerCmd) notify(testDefinition test.Test, resultError error, details
 			// anytime soon.
 			p.setDeduplicationCacheTime(hash, *testDefinition.DedupDuration*10)
 
+			// Remember when the test started failing, to tell for how long it was down once it recovers.
+			if err := utils.RecordFailure(p.redisFor(hash), hash, time.Now(), *testDefinition.DedupDuration*10); err != nil {
+				p._log.Error(p.logFields(0, testDefinition), "Failed to record the first failure: %s", err.Error())
+			}
+
 			lastAlertTime := p.getDeduplicationLastAlertTime(hash)
 
 			// With dedup, we don't want to trigger same notification, unless we just passed the dedup duration
@@ -289,9 +650,11 @@ func (p *workerCmd) notify(testDefinition test.Test, resultError error, details
 
 				if diffLastAlert < dedupDurationSeconds {
 					// There is no need to trigger the notification, because not enough time has passed since the last one
-					p.verbose(fmt.Sprintf("Skipping notification (dedup, last notif %s ago) for test `%s` (%s)\n",
+					p._log.Debug(p.logFields(0, testDefinition), "Skipping notification (dedup, last notif %s ago) for test `%s` (%s)",
 						time.Duration(diffLastAlert)*time.Second,
-						testDefinition.Input, testDefinition.Target))
+						testDefinition.Input, testDefinition.Target)
+					p._prom.Inc(metrics.ResultsDeduplicated, testDefinition.Type)
+					p.sendMetrics(p.logFields(0, testDefinition), map[string]string{metrics.TestMetric(testDefinition, metrics.TestDedup): "1"})
 					return nil
 				}
 
@@ -312,8 +675,16 @@ func (p *workerCmd) notify(testDefinition test.Test, resultError error, details
 				p.clearDeduplicationLastAlertTime(hash)
 				testResult.Recovered = true
 
-				p.verbose(fmt.Sprintf("Test recovered: `%s` (%s)\n",
-					testDefinition.Input, testDefinition.Target))
+				downtime, err := utils.RecoverFailure(p.redisFor(hash), hash, time.Now())
+				if err != nil {
+					p._log.Error(p.logFields(0, testDefinition), "Failed to get the first failure: %s", err.Error())
+				}
+				testResult.DowntimeSeconds = int64(downtime / time.Second)
+				p._prom.Inc(metrics.ResultsRecovered, testDefinition.Type)
+				p.sendMetrics(p.logFields(0, testDefinition), map[string]string{metrics.TestMetric(testDefinition, metrics.TestRecovered): "1"})
+
+				p._log.Debug(p.logFields(0, testDefinition), "Test recovered after %s: `%s` (%s)",
+					downtime, testDefinition.Input, testDefinition.Target)
 			}
 
 		}
@@ -325,22 +696,73 @@ func (p *workerCmd) notify(testDefinition test.Test, resultError error, details
 	//
 	j, err := json.Marshal(testResult)
 	if err != nil {
-		fmt.Printf("Failed to encode test-result to JSON: %s", err.Error())
+		p._log.Error(p.logFields(0, testDefinition), "Failed to encode test-result to JSON: %s", err.Error())
 		return err
 	}
 
+	if p.CompressResults {
+		j, err = test.CompressResult(j)
+		if err != nil {
+			p._log.Error(p.logFields(0, testDefinition), "Failed to compress test-result: %s", err.Error())
+			return err
+		}
+	}
+
 	//
 	// Publish the message to the queue.
 	//
-	_, err = p._r.RPush("overseer.results", j).Result()
+	err = utils.PushResult(p.redisFor(testResult.Hash()), p._resultsQueues, j, p.ResultsMaxLen, p.ResultsTTL)
 	if err != nil {
-		fmt.Printf("Result addition failed: %s\n", err)
+		p._log.Error(p.logFields(0, testDefinition), "Result addition failed: %s", err)
 		return err
 	}
 
 	return nil
 }
 
+// resultTag returns the tag for the results of the given test: the
+// per-test tag if present, the worker one otherwise, joined to the
+// tag-prefix.
+func (p *workerCmd) resultTag(testDefinition test.Test) string {
+	tag := p.Tag
+	if testDefinition.Tag != "" {
+		tag = testDefinition.Tag
+	}
+
+	if p.TagPrefix == "" {
+		return tag
+	}
+	if tag == "" {
+		return p.TagPrefix
+	}
+	return p.TagPrefix + "-" + tag
+}
+
+// isCoalesced returns true if an identical result, for the same test and
+// with the same outcome, was already pushed within the coalesce-window.
+//
+// Otherwise it records the given result as the first one of a new window.
+func (p *workerCmd) isCoalesced(testResult *test.Result) bool {
+	if p._r == nil || p.CoalesceWindow <= 0 {
+		return false
+	}
+
+	outcome := ""
+	if testResult.Error != nil {
+		outcome = *testResult.Error
+	}
+
+	key := fmt.Sprintf("overseer.coalesce.%s", utils.GetMD5Hash(testResult.Hash()+outcome))
+	first, err := p.redisFor(testResult.Hash()).SetNX(key, time.Now().Unix(), p.CoalesceWindow).Result()
+	if err != nil {
+		// Better a duplicate result than a lost one
+		p._log.Error(utils.LogFields{TestType: testResult.Type, Target: testResult.Target, Tag: testResult.Tag}, "Failed to set coalesce key: %s", err)
+		return false
+	}
+
+	return !first
+}
+
 func (p *workerCmd) getDeduplicationCacheKey(hash string) string {
 	return fmt.Sprintf("overseer.dedup-cache.%s", hash)
 }
@@ -351,14 +773,14 @@ func (p *workerCmd) getDeduplicationCacheTime(hash string) *int64 {
 	}
 
 	cacheKey := p.getDeduplicationCacheKey(hash)
-	cacheTime, err := p._r.Get(cacheKey).Int64()
+	cacheTime, err := p.redisFor(hash).Get(cacheKey).Int64()
 	if err != nil {
 		if err == redis.Nil {
 			// Key just does not exist
 			return nil
 		}
 
-		fmt.Printf("Failed to get dedup cache key: %s\n", err)
+		p._log.Error(utils.LogFields{}, "Failed to get dedup cache key: %s", err)
 		return nil
 	}
 
@@ -371,9 +793,9 @@ func (p *workerCmd) setDeduplicationCacheTime(hash string, expiry time.Duration)
 	}
 
 	cacheKey := p.getDeduplicationCacheKey(hash)
-	_, err := p._r.Set(cacheKey, time.Now().Unix(), expiry).Result()
+	_, err := p.redisFor(hash).Set(cacheKey, time.Now().Unix(), expiry).Result()
 	if err != nil {
-		fmt.Printf("Failed to set dedup cache key: %s\n", err)
+		p._log.Error(utils.LogFields{}, "Failed to set dedup cache key: %s", err)
 	}
 }
 
@@ -383,9 +805,9 @@ func (p *workerCmd) clearDeduplicationCacheTime(hash string) {
 	}
 
 	cacheKey := p.getDeduplicationCacheKey(hash)
-	_, err := p._r.Del(cacheKey).Result()
+	_, err := p.redisFor(hash).Del(cacheKey).Result()
 	if err != nil {
-		fmt.Printf("Failed to clear dedup cache key: %s\n", err)
+		p._log.Error(utils.LogFields{}, "Failed to clear dedup cache key: %s", err)
 	}
 }
 
@@ -399,14 +821,14 @@ func (p *workerCmd) getDeduplicationLastAlertTime(hash string) *int64 {
 	}
 
 	cacheKey := p.getDeduplicationLastAlertKey(hash)
-	cacheTime, err := p._r.Get(cacheKey).Int64()
+	cacheTime, err := p.redisFor(hash).Get(cacheKey).Int64()
 	if err != nil {
 		if err == redis.Nil {
 			// Key just does not exist
 			return nil
 		}
 
-		fmt.Printf("Failed to get dedup last alert key: %s\n", err)
+		p._log.Error(utils.LogFields{}, "Failed to get dedup last alert key: %s", err)
 		return nil
 	}
 
@@ -419,9 +841,9 @@ func (p *workerCmd) setDeduplicationLastAlertTime(hash string, expiry time.Durat
 	}
 
 	cacheKey := p.getDeduplicationLastAlertKey(hash)
-	_, err := p._r.Set(cacheKey, time.Now().Unix(), expiry).Result()
+	_, err := p.redisFor(hash).Set(cacheKey, time.Now().Unix(), expiry).Result()
 	if err != nil {
-		fmt.Printf("Failed to set dedup last alert key: %s\n", err)
+		p._log.Error(utils.LogFields{}, "Failed to set dedup last alert key: %s", err)
 	}
 }
 
@@ -431,52 +853,279 @@ func (p *workerCmd) clearDeduplicationLastAlertTime(hash string) {
 	}
 
 	cacheKey := p.getDeduplicationLastAlertKey(hash)
-	_, err := p._r.Del(cacheKey).Result()
+	_, err := p.redisFor(hash).Del(cacheKey).Result()
 	if err != nil {
-		fmt.Printf("Failed to clear dedup last alert key: %s\n", err)
+		p._log.Error(utils.LogFields{}, "Failed to clear dedup last alert key: %s", err)
 	}
 }
 
-// alphaNumeric removes all non alpha-numeric characters from the
-// given string, and returns it.  We replace the characters that
-// are invalid with `_`.
-func (p *workerCmd) alphaNumeric(input string) string {
-	//
-	// Remove non alphanumeric
-	//
-	reg, err := regexp.Compile("[^A-Za-z0-9]+")
+// redisFor returns the redis-server storing the results, and state, of
+// the test identified by the given key.  The same test always goes to
+// the same server, so that e.g. deduplication keeps working.
+func (p *workerCmd) redisFor(key string) redis.UniversalClient {
+	if len(p._shards) <= 1 {
+		return p._r
+	}
+	return p._shards[utils.StableIndex(key, len(p._shards))]
+}
+
+// nextRedis returns the redis-server to fetch the next job from, going
+// through them in turn.
+func (p *workerCmd) nextRedis() redis.UniversalClient {
+	if len(p._rotation) <= 1 {
+		return p._r
+	}
+	next := atomic.AddUint64(&p._next, 1) - 1
+	return p._shards[p._rotation[next%uint64(len(p._rotation))]]
+}
+
+// connectRedis creates the handles to our redis-server(s), and ensures
+// we can talk to them.
+func (p *workerCmd) connectRedis() error {
+	options := utils.RedisOptions{
+		Host:        p.RedisHost,
+		Socket:      p.RedisSocket,
+		Sentinels:   p.RedisSentinels,
+		MasterName:  p.RedisMaster,
+		Cluster:     p.RedisCluster,
+		Password:    p.RedisPassword,
+		DB:          p.RedisDB,
+		DialTimeout: p.RedisDialTimeout,
+		TLS:         p.RedisTLS,
+		TLSCA:       p.RedisTLSCA,
+		TLSCert:     p.RedisTLSCert,
+		TLSKey:      p.RedisTLSKey,
+		TLSInsecure: p.RedisTLSInsecure,
+	}
+
+	if p.RedisHosts == "" {
+		r, err := options.Client()
+		if err != nil {
+			return err
+		}
+		p._r = r
+
+		//
+		// And run a ping, just to make sure it worked.
+		//
+		return utils.PingRedis(p._r, p.RedisConnectRetries, p.redisConnectBackoff())
+	}
+
+	if p.RedisSentinels != "" || p.RedisCluster != "" {
+		return fmt.Errorf("-redis-hosts can't be used together with -redis-sentinels or -redis-cluster")
+	}
+
+	hosts, err := utils.ParseRedisHosts(p.RedisHosts)
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	for _, host := range hosts {
+		options.Host = host.Addr
+		r, errClient := options.Client()
+		if errClient != nil {
+			return errClient
+		}
+
+		if err = utils.PingRedis(r, p.RedisConnectRetries, p.redisConnectBackoff()); err != nil {
+			return fmt.Errorf("%s: %s", host.Addr, err.Error())
+		}
+
+		p._shards = append(p._shards, r)
 	}
-	return reg.ReplaceAllString(input, "_")
+
+	p._r = p._shards[0]
+	p._rotation = utils.WeightedRotation(hosts)
+	return nil
 }
 
-// formatMetrics Format a test for metrics submission.
-//
-// This is a little weird because ideally we'd want to submit to the
-// metrics-host :
-//
-//    overseer.$testType.$testTarget.$key => value
-//
-// But of course the target might not be what we think it is for all
-// cases - i.e. A DNS test the target is the name of the nameserver rather
-// than the thing to lookup, which is the natural target.
-//
-func (p *workerCmd) formatMetrics(tst test.Test, key string) string {
+// heartbeats returns the heartbeats of our workers, keyed by their redis
+// key.
+func (p *workerCmd) heartbeats() map[string]utils.Heartbeat {
+	beats := make(map[string]utils.Heartbeat)
+	for i := range p._processed {
+		beats[utils.HeartbeatKey(p._workerName, uint(i+1))] = utils.Heartbeat{
+			Tag:           p.Tag,
+			JobsProcessed: atomic.LoadUint64(&p._processed[i]),
+		}
+	}
+	return beats
+}
 
-	prefix := "overseer.test."
+// redisConnectBackoff returns how long to wait between the attempts to
+// connect to redis.
+func (p *workerCmd) redisConnectBackoff() utils.RetryBackoff {
+	return utils.RetryBackoff{
+		Exponential: true,
+		Delay:       p.RedisConnectBackoff,
+		MaxDelay:    30 * time.Second,
+	}
+}
 
-	//
-	// Special-case for the DNS-test
-	//
-	if tst.Type == "dns" {
-		return prefix + ".dns." + p.alphaNumeric(tst.Arguments["lookup"]) + "." + key
+// jobsQueue returns the name of the queue this worker fetches jobs from.
+func (p *workerCmd) jobsQueue() string {
+	if p.QuarantineWorker {
+		return "overseer.jobs.quarantine"
 	}
+	return "overseer.jobs"
+}
 
-	//
-	// Otherwise we have a normal test.
-	//
-	return prefix + tst.Type + "." + p.alphaNumeric(tst.Target) + "." + key
+func (p *workerCmd) getConsecutiveFailuresKey(input string) string {
+	return fmt.Sprintf("overseer.consecutive-failures.%x", md5.Sum([]byte(input)))
+}
+
+// isQuarantined returns true if the given job has failed too many
+// consecutive times, and should be moved to the quarantine queue.
+func (p *workerCmd) isQuarantined(input string) bool {
+	if p._r == nil || p.QuarantineAfter == 0 {
+		return false
+	}
+
+	failures, err := p.redisFor(input).Get(p.getConsecutiveFailuresKey(input)).Uint64()
+	if err != nil {
+		if err != redis.Nil {
+			p._log.Error(utils.LogFields{}, "Failed to get consecutive failures key: %s", err)
+		}
+		return false
+	}
+
+	return failures >= uint64(p.QuarantineAfter)
+}
+
+// recordConsecutiveFailures keeps track of how many times in a row the
+// given job has failed, resetting the count once it passes.  It returns
+// the number of consecutive failures, 0 if unknown.
+func (p *workerCmd) recordConsecutiveFailures(input string, failed bool) uint64 {
+	if p._r == nil || p.QuarantineAfter == 0 {
+		return 0
+	}
+
+	key := p.getConsecutiveFailuresKey(input)
+	r := p.redisFor(input)
+
+	if !failed {
+		if _, err := r.Del(key).Result(); err != nil {
+			p._log.Error(utils.LogFields{}, "Failed to clear consecutive failures key: %s", err)
+		}
+		return 0
+	}
+
+	failures, err := r.Incr(key).Result()
+	if err != nil {
+		p._log.Error(utils.LogFields{}, "Failed to increment consecutive failures key: %s", err)
+		return 0
+	}
+
+	// Don't keep counters of tests which are not scheduled anymore forever
+	if _, err := r.Expire(key, 24*time.Hour).Result(); err != nil {
+		p._log.Error(utils.LogFields{}, "Failed to set consecutive failures key expiry: %s", err)
+	}
+	return uint64(failures)
+}
+
+// notifyPops notifies a single result for a test which was run against
+// multiple PoPs, listing the ones which failed.
+func (p *workerCmd) notifyPops(tst test.Test, pops []string, results map[string]error) {
+	var failures []string
+	var lines []string
+
+	for _, pop := range pops {
+		if err := results[pop]; err != nil {
+			failures = append(failures, pop)
+			lines = append(lines, fmt.Sprintf("- %s: %s", pop, err.Error()))
+		} else {
+			lines = append(lines, fmt.Sprintf("- %s: OK", pop))
+		}
+	}
+
+	tstCopy := tst
+	tstCopy.Target = strings.Join(pops, ",")
+	tstCopy.Input = tst.Sanitize()
+
+	var result error
+	if len(failures) > 0 {
+		result = fmt.Errorf("%d PoPs failed out of %d: %s", len(failures), len(pops), strings.Join(failures, ", "))
+	}
+
+	details := fmt.Sprintf("PoP results:\n%s", strings.Join(lines, "\n"))
+	// The PoPs ran at the same time, so there is no single duration.
+	p.notify(tstCopy, result, &details, 0)
+}
+
+// runOnFailExec runs the on-fail-exec command of the given failed test,
+// if the worker allows it, and returns the details of the result with
+// the output of the command appended.
+func (p *workerCmd) runOnFailExec(tst test.Test, result error, details *string) *string {
+	var note string
+
+	if !p.AllowExec {
+		p._log.Warn(p.logFields(0, tst), "Not running the on-fail-exec command of '%s', the worker was started without -allow-exec", tst.Input)
+		note = "on-fail-exec not run: the worker was started without -allow-exec"
+	} else {
+		p.verbose(fmt.Sprintf("Running on-fail-exec command %s\n", tst.OnFailExec))
+
+		env := map[string]string{
+			"OVERSEER_INPUT":  tst.Input,
+			"OVERSEER_TARGET": tst.Target,
+			"OVERSEER_TYPE":   tst.Type,
+			"OVERSEER_ERROR":  result.Error(),
+			"OVERSEER_TAG":    p.resultTag(tst),
+		}
+
+		output, err := utils.RunHook(tst.OnFailExec, env, p.Timeout)
+		if err != nil {
+			note = fmt.Sprintf("on-fail-exec %s failed: %s\n%s", tst.OnFailExec, err.Error(), output)
+		} else {
+			note = fmt.Sprintf("on-fail-exec %s output:\n%s", tst.OnFailExec, output)
+		}
+	}
+
+	if details != nil && *details != "" {
+		note = *details + "\n\n" + note
+	}
+	return &note
+}
+
+// sendMetrics submits the given values, keyed by metric name, to our
+// metrics-host, if we have one.
+func (p *workerCmd) sendMetrics(fields utils.LogFields, values map[string]string) {
+	if p._metrics == nil {
+		return
+	}
+
+	v := os.Getenv("METRICS_VERBOSE")
+	if v != "" {
+		for _, name := range metrics.SortedNames(values) {
+			p._log.Info(fields, "%s %s", name, values[name])
+		}
+	}
+
+	if err := p._metrics.Send(values); err != nil {
+		p._log.Error(fields, "Failed to send metrics: %s", err.Error())
+	}
+}
+
+// deadLetter pushes the given job, which couldn't be parsed, to the
+// dead-letter queue, if any, so that it doesn't get lost.
+func (p *workerCmd) deadLetter(workerIdx uint, job string, parseErr error) {
+	if p.DeadLetterQueue == "" {
+		return
+	}
+
+	worker := fmt.Sprintf("%s/W%d", p._workerName, workerIdx)
+
+	if err := utils.PushDeadLetter(p.redisFor(job), p.DeadLetterQueue, job, parseErr, worker); err != nil {
+		p._log.Error(utils.LogFields{Worker: workerIdx, Tag: p.Tag}, "failed to push job `%s` to the dead-letter queue: %v", job, err)
+	}
+}
+
+// runLimited runs the given test, against the given target, once the
+// concurrency limit of its type allows it.
+func (p *workerCmd) runLimited(tmp protocols.ProtocolTest, tst test.Test, target string, opts test.Options) error {
+	p._limits.Acquire(tst.Type)
+	defer p._limits.Release(tst.Type)
+
+	return tmp.RunTest(tst, target, opts)
 }
 
 // runTest is really the core of our application, as it is responsible
@@ -484,10 +1133,10 @@ func (p *workerCmd) formatMetrics(tst test.Test, key string) string {
 // the notification with the result.
 func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) error {
 
-	workerPrefix := fmt.Sprintf("[W%d] ", workerIdx)
+	fields := p.logFields(workerIdx, tst)
 
 	// Create a map for metric-recording.
-	metrics := map[string]string{}
+	values := map[string]string{}
 
 	// If there are no deduplication rules, assign the default worker one. Unless the test is a period-test
 	if tst.DedupDuration == nil && tst.PeriodTestDuration == nil && p.DedupDuration > 0 {
@@ -505,6 +1154,9 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 	// Look for a suitable protocol handler
 	//
 	tmp := protocols.ProtocolHandler(testType)
+	if tmp == nil {
+		return fmt.Errorf("unknown test-type '%s' in input '%s'", testType, tst.Input)
+	}
 
 	//
 	// Each test will be executed for each address-family, so we need to
@@ -512,8 +1164,10 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 	//
 	var targets []string
 
-	// If we're dealing with hostname-based testing, then resolve hostnames
-	if tmp.ShouldResolveHostname() {
+	// If we've been given the addresses to test, use them instead of resolving the target
+	if tmp.ShouldResolveHostname() && len(tst.Pops) > 0 {
+		targets = append(targets, tst.Pops...)
+	} else if tmp.ShouldResolveHostname() {
 
 		//
 		// If the first argument looks like an URI then get the host
@@ -544,12 +1198,12 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 			//
 			// Notify the world about our DNS-failure.
 			//
-			p.notify(tst, fmt.Errorf("failed to resolve name %s", testTarget), nil)
+			p.notify(tst, fmt.Errorf("failed to resolve name %s", testTarget), nil, time.Since(timeA))
 
 			//
 			// Otherwise we're done.
 			//
-			fmt.Printf(workerPrefix+"WARNING: Failed to resolve %s for %s test!\n", testTarget, testType)
+			p._log.Warn(fields, "Failed to resolve %s for %s test!", testTarget, testType)
 			return err
 		}
 
@@ -559,25 +1213,34 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 		diff := fmt.Sprintf("%f", float64(duration)/float64(time.Millisecond))
 
 		// Record time in our metric hash
-		metrics["overseer.dns."+p.alphaNumeric(testTarget)+".duration"] = diff
+		values["overseer.dns."+metrics.AlphaNumeric(testTarget)+".duration"] = diff
 
 		//
 		// We'll run the test against each of the resulting IPv4 and
-		// IPv6 addresess - ignoring any IP-protocol which is disabled.
+		// IPv6 addresess - ignoring any IP-protocol which is disabled,
+		// globally or for this test.
 		//
 		// Save the results in our `targets` array, unless disabled.
 		//
-		for _, ip := range ips {
-			if ip.To4() != nil {
-				if p.IPv4 {
-					targets = append(targets, ip.String())
-				}
-			}
-			if ip.To16() != nil && ip.To4() == nil {
-				if p.IPv6 {
-					targets = append(targets, ip.String())
-				}
-			}
+		ipv4, ipv6 := p.IPv4, p.IPv6
+		switch tst.IPVersion {
+		case 4:
+			ipv4, ipv6 = true, false
+		case 6:
+			ipv4, ipv6 = false, true
+		}
+		targets = utils.SelectAddresses(ips, ipv4, ipv6)
+
+		//
+		// If the test asked for an address family the target doesn't
+		// have, that's a failure rather than nothing to test.
+		//
+		if tst.IPVersion != 0 && len(targets) == 0 {
+			tst.Input = tst.Sanitize()
+			err = fmt.Errorf("no IPv%d address found for %s", tst.IPVersion, testTarget)
+			p.notify(tst, err, nil, time.Since(timeA))
+			p._log.Warn(fields, "No IPv%d address found for %s for %s test!", tst.IPVersion, testTarget, testType)
+			return err
 		}
 
 	} else {
@@ -589,7 +1252,15 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 		targets = targets[:tst.MaxTargetsCount]
 	}
 
+	// Did the test fail against any of the targets?
+	failedLock := &sync.Mutex{}
+	failed := false
+
+	// When testing PoPs we notify a single result, for all of them.
+	popResults := make(map[string]error)
+
 	testEndFn := func(startTime time.Time, target string, attempts uint, result error, details *string) {
+
 		//
 		// Now the test is complete we can record the time it
 		// took to carry out, and the number of attempts it
@@ -598,8 +1269,29 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 		timeB := time.Now()
 		duration := timeB.Sub(startTime)
 		diff := fmt.Sprintf("%f", float64(duration)/float64(time.Millisecond))
-		metrics[p.formatMetrics(tst, "duration")] = diff
-		metrics[p.formatMetrics(tst, "attempts")] = fmt.Sprintf("%d", attempts)
+
+		failedLock.Lock()
+		if result != nil {
+			failed = true
+		}
+		if len(tst.Pops) > 0 {
+			popResults[target] = result
+		}
+		values[metrics.TestMetric(tst, metrics.TestDuration)] = diff
+		values[metrics.TestMetric(tst, metrics.TestAttempts)] = fmt.Sprintf("%d", attempts)
+		values[metrics.TestMetric(tst, metrics.TestOutcome(result))] = "1"
+		failedLock.Unlock()
+
+		p._prom.Inc(metrics.TestsExecuted, tst.Type)
+		p._prom.ObserveDuration(tst.Type, duration)
+		if result != nil {
+			p._prom.Inc(metrics.TestsFailed, tst.Type)
+		} else {
+			p._prom.Inc(metrics.TestsPassed, tst.Type)
+		}
+		if tst.PeriodTestDuration == nil && attempts > 1 {
+			p._prom.Add(metrics.TestsRetried, tst.Type, uint64(attempts-1))
+		}
 
 		//
 		// Post the result of the test to the notifier.
@@ -624,11 +1316,26 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 		//
 		tstCopy.Input = tst.Sanitize()
 
+		//
+		// PoP results are aggregated once all of them are done.
+		//
+		if len(tst.Pops) > 0 {
+			return
+		}
+
+		//
+		// The test might want a command to collect more details
+		// about the failure.
+		//
+		if result != nil && tst.OnFailExec != "" {
+			details = p.runOnFailExec(tstCopy, result, details)
+		}
+
 		//
 		// Now we can trigger the notification with our updated
 		// copy of the test.
 		//
-		p.notify(tstCopy, result, details)
+		p.notify(tstCopy, result, details, duration)
 	}
 
 	wg := &sync.WaitGroup{}
@@ -638,7 +1345,7 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 	//
 	for _, target := range targets {
 		wg.Add(1)
-		go func() {
+		go func(target string) {
 
 			// Is this a period test?
 			if tst.PeriodTestDuration != nil {
@@ -652,7 +1359,7 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 					periodTestThreshold = *tst.PeriodTestThreshold
 				}
 
-				p.verbose(fmt.Sprintf(workerPrefix+"Running '%s' period-test (duration: %s, sleep: %s, threshold: %.0f%%) against %s (%s)\n", testType, periodTestDuration, periodTestSleep, periodTestThreshold, testTarget, target))
+				p._log.Debug(fields, "Running '%s' period-test (duration: %s, sleep: %s, threshold: %.0f%%) against %s (%s)", testType, periodTestDuration, periodTestSleep, periodTestThreshold, testTarget, target)
 
 				// Start time
 				timeStart := time.Now()
@@ -666,17 +1373,17 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 				for time.Now().Before(timeEnd) {
 					iteration++
 					iterationStartTime := time.Now()
-					err := tmp.RunTest(tst, target, opts)
+					err := p.runLimited(tmp, tst, target, opts)
 					iterationDuration := time.Since(iterationStartTime)
 					iterationElapsedString := fmt.Sprintf("%.2fms", float64(iterationDuration)/float64(time.Millisecond))
 					if err != nil {
 						countFail++
-						p.verbose(fmt.Sprintf(workerPrefix+"Period-test (test %d failed, took %s): %s\n", iteration, iterationElapsedString, err.Error()))
+						p._log.Debug(fields, "Period-test (test %d failed, took %s): %s", iteration, iterationElapsedString, err.Error())
 						errString := fmt.Sprintf("test %d failed, took %s: %s", iteration, iterationElapsedString, err.Error())
 						errorStrings = append(errorStrings, errString)
 					} else {
 						countSuccess++
-						p.verbose(fmt.Sprintf(workerPrefix+"Period-test (test %d success, took %s)\n", iteration, iterationElapsedString))
+						p._log.Debug(fields, "Period-test (test %d success, took %s)", iteration, iterationElapsedString)
 					}
 
 					time.Sleep(periodTestSleep)
@@ -697,9 +1404,9 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 				}
 				if errPercentage > periodTestThreshold {
 					result = fmt.Errorf("%d tests failed out of %d (%.2f%%)", countFail, totalAttempts, errPercentage*100)
-					p.verbose(fmt.Sprintf(workerPrefix+"Test failed: %s\n", result.Error()))
+					p._log.Debug(fields, "Test failed: %s", result.Error())
 				} else {
-					p.verbose(fmt.Sprintf(workerPrefix+"Test passed: %d tests failed out of %d (%.2f%%)\n", countFail, totalAttempts, errPercentage*100))
+					p._log.Debug(fields, "Test passed: %d tests failed out of %d (%.2f%%)", countFail, totalAttempts, errPercentage*100)
 				}
 
 				testEndFn(timeStart, target, totalAttempts, result, failuresString)
@@ -707,7 +1414,7 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 				return
 			}
 
-			p.verbose(fmt.Sprintf(workerPrefix+"Running '%s' test against %s (%s)\n", testType, testTarget, target))
+			p._log.Debug(fields, "Running '%s' test against %s (%s)", testType, testTarget, target)
 
 			//
 			// We'll repeat failing tests up to five times by default
@@ -757,13 +1464,13 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 				//
 				// Run the test
 				//
-				result = tmp.RunTest(tst, target, opts)
+				result = p.runLimited(tmp, tst, target, opts)
 
 				//
 				// If the test passed then we're good.
 				//
 				if result == nil {
-					p.verbose(fmt.Sprintf(workerPrefix+"[%d/%d] - Test passed.\n", attempt, maxAttempts))
+					p._log.Debug(fields, "[%d/%d] - Test passed.", attempt, maxAttempts)
 
 					// break out of loop
 					attempt = maxAttempts + 1
@@ -776,32 +1483,37 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 					// It will be repeated before a notifier
 					// is invoked.
 					//
-					p.verbose(fmt.Sprintf(workerPrefix+"[%d/%d] Test failed: %s\n", attempt, maxAttempts, result.Error()))
+					p._log.Debug(fields, "[%d/%d] Test failed: %s", attempt, maxAttempts, result.Error())
 
 					// If there are no more retries, do not wait
 					if maxAttempts-attempt > 0 {
 						//
 						// Sleep before retrying the failing test.
 						//
-						p.verbose(fmt.Sprintf(workerPrefix+"Sleeping for %s before retrying\n", p.RetryDelay.String()))
+						delay := p._backoff.Wait(attempt)
+						p._log.Debug(fields, "Sleeping for %s before retrying", delay.String())
 
-						time.Sleep(p.RetryDelay)
+						time.Sleep(delay)
 					}
 				}
 			}
 
 			testEndFn(timeA, target, c, result, nil)
 			wg.Done()
-		}()
+		}(target)
 	}
 
 	wg.Wait()
 
+	if len(tst.Pops) > 0 {
+		p.notifyPops(tst, targets, popResults)
+	}
+
 	//
-	// If we have a metric-host we can now submit each of the values
-	// to it.
+	// If we have a metric-host we can now submit all the values to
+	// it, in a single batch.
 	//
-	// There will be three results for each test:
+	// There will be four results for each test:
 	//
 	//  1.  The DNS-lookup-time of the target.
 	//
@@ -810,15 +1522,12 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 	//  3.  The number of attempts (retries, really) before the
 	//      test was completed.
 	//
-	if p._g != nil {
-		for key, val := range metrics {
-			v := os.Getenv("METRICS_VERBOSE")
-			if v != "" {
-				fmt.Printf("%s %s\n", key, val)
-			}
+	//  4.  A count of one for its success, or its failure.
+	//
+	p.sendMetrics(fields, values)
 
-			p._g.SimpleSend(key, val)
-		}
+	if failed {
+		return fmt.Errorf("test failed")
 	}
 
 	return nil
@@ -829,6 +1538,17 @@ func (p *workerCmd) runTest(workerIdx uint, tst test.Test, opts test.Options) er
 //
 func (p *workerCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
 
+	//
+	// Setup where our messages are written.
+	//
+	logger, err := utils.NewLogger(p.LogFormat, p.Verbose, os.Stdout)
+	if err != nil {
+		fmt.Printf("Invalid -log-format: %s\n", err.Error())
+		return subcommands.ExitFailure
+	}
+	p._log = logger
+	p._workerName = utils.WorkerName(p.WorkerName)
+
 	// Sanity check
 	if p.Parallel == 0 {
 		fmt.Printf("Number of parallel workers must be > 0")
@@ -836,29 +1556,70 @@ func (p *workerCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 	}
 
 	//
-	// Connect to the redis-host.
+	// Setup the pauses between retries.
 	//
-	if p.RedisSocket != "" {
-		p._r = redis.NewClient(&redis.Options{
-			Network:     "unix",
-			Addr:        p.RedisSocket,
-			Password:    p.RedisPassword,
-			DB:          p.RedisDB,
-			DialTimeout: p.RedisDialTimeout,
-		})
-	} else {
-		p._r = redis.NewClient(&redis.Options{
-			Addr:        p.RedisHost,
-			Password:    p.RedisPassword,
-			DB:          p.RedisDB,
-			DialTimeout: p.RedisDialTimeout,
-		})
+	if p.RetryBackoff != "fixed" && p.RetryBackoff != "exponential" {
+		fmt.Printf("Invalid -retry-backoff '%s', must be 'fixed' or 'exponential'\n", p.RetryBackoff)
+		return subcommands.ExitFailure
+	}
+	if p.RetryJitter < 0 || p.RetryJitter > 1 {
+		fmt.Printf("Invalid -retry-jitter %g, must be between 0 and 1\n", p.RetryJitter)
+		return subcommands.ExitFailure
+	}
+	p._backoff = utils.RetryBackoff{
+		Exponential: p.RetryBackoff == "exponential",
+		Delay:       p.RetryDelay,
+		MaxDelay:    p.RetryMaxDelay,
+		Jitter:      p.RetryJitter,
 	}
 
 	//
-	// And run a ping, just to make sure it worked.
+	// Setup the queues our results are pushed to.
+	//
+	p._resultsQueues = nil
+	for _, queue := range strings.Split(p.ResultsQueues, ",") {
+		queue = strings.TrimSpace(queue)
+		if queue != "" {
+			p._resultsQueues = append(p._resultsQueues, queue)
+		}
+	}
+	if len(p._resultsQueues) == 0 {
+		p._resultsQueues = []string{"overseer.results"}
+	}
+
 	//
-	_, err := p._r.Ping().Result()
+	// Setup the concurrency limits of the test types, if any.
+	//
+	if p.TypeLimits != "" {
+		limits, err := utils.ParseTypeLimits(p.TypeLimits)
+		if err != nil {
+			fmt.Printf("Invalid -type-limits: %s\n", err.Error())
+			return subcommands.ExitFailure
+		}
+		for testType := range limits {
+			if protocols.ProtocolHandler(testType) == nil {
+				fmt.Printf("Invalid -type-limits: unknown test type '%s'\n", testType)
+				return subcommands.ExitFailure
+			}
+		}
+		p._limits = utils.NewTypeLimiter(limits)
+	}
+
+	//
+	// In strict-mode ensure the tests we'll be given are valid, before
+	// doing anything else.
+	//
+	if p.Strict {
+		if err := p.validateTestFile(); err != nil {
+			fmt.Printf("Refusing to start: %s\n", err.Error())
+			return subcommands.ExitFailure
+		}
+	}
+
+	//
+	// Connect to the redis-host(s).
+	//
+	err = p.connectRedis()
 	if err != nil {
 		fmt.Printf("Redis connection failed: %s\n", err.Error())
 		return subcommands.ExitFailure
@@ -867,7 +1628,56 @@ func (p *workerCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 	//
 	// Setup our metrics-connection, if enabled
 	//
-	p.MetricsFromEnvironment()
+	switch p.MetricsBackend {
+	case "graphite":
+		p.MetricsFromEnvironment()
+	case "statsd":
+		p.metricsFromEnvironment("statsd")
+	case "influxdb":
+		influx, errInflux := metrics.NewInfluxDB(p.InfluxURL, p.InfluxDB, p.InfluxToken)
+		if errInflux != nil {
+			fmt.Printf("Error setting up metrics: %s\n", errInflux.Error())
+			return subcommands.ExitFailure
+		}
+		p._metrics = influx
+	default:
+		fmt.Printf("Unknown metrics backend '%s', must be 'graphite', 'statsd' or 'influxdb'\n", p.MetricsBackend)
+		return subcommands.ExitFailure
+	}
+
+	//
+	// Setup the sinks recording our results, if any.
+	//
+	if p.SQLiteResults != "" {
+		sink, errSink := sinks.NewSQLiteSink(p.SQLiteResults)
+		if errSink != nil {
+			fmt.Printf("Failed to open the SQLite results database: %s\n", errSink.Error())
+			return subcommands.ExitFailure
+		}
+		defer sink.Close()
+		p._sinks = append(p._sinks, sink)
+	}
+
+	//
+	// Serve Prometheus metrics, if enabled, until we're done.
+	//
+	if p.MetricsAddr != "" {
+		p._prom = metrics.NewPrometheus()
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", p._prom)
+		server := &http.Server{Addr: p.MetricsAddr, Handler: mux}
+
+		listener, errListen := net.Listen("tcp", p.MetricsAddr)
+		if errListen != nil {
+			fmt.Printf("Failed to serve metrics: %s\n", errListen.Error())
+			return subcommands.ExitFailure
+		}
+		go server.Serve(listener)
+		defer server.Shutdown(context.Background())
+
+		fmt.Printf("Serving Prometheus metrics on http://%s/metrics\n", listener.Addr())
+	}
 
 	//
 	// Setup the options passed to each test, by copying our
@@ -876,6 +1686,10 @@ func (p *workerCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 	var opts test.Options
 	opts.Verbose = p.Verbose
 	opts.Timeout = p.Timeout
+	opts.HTTPMaxIdleConns = p.HTTPMaxIdleConns
+	opts.HTTPMaxIdleConnsPerHost = p.HTTPMaxIdleConnsPerHost
+	opts.HTTPIdleConnTimeout = p.HTTPIdleConnTimeout
+	opts.HTTPMaxSize = p.HTTPMaxSize
 
 	//
 	// Create a parser for our input
@@ -884,9 +1698,10 @@ func (p *workerCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 
 	// We want a graceful shutdown, e.g. if a long-running test is active at the moment we need to wait for it to
 	// complete before brutally exiting!
-	shouldExit := sync.NewCond(&sync.Mutex{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	onSignalInterrupt(func() {
-		shouldExit.Broadcast()
+		cancel()
 
 		// If there is a second interrupt, immediately exit
 		onSignalInterrupt(func() {
@@ -894,6 +1709,31 @@ func (p *workerCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 		})
 	})
 
+	// Bound the total runtime, if required, with the same graceful
+	// shutdown.
+	if p.MaxRuntime > 0 {
+		timer := time.AfterFunc(p.MaxRuntime, func() {
+			fmt.Printf("Maximum runtime of %s reached, shutting down\n", p.MaxRuntime)
+			cancel()
+		})
+		defer timer.Stop()
+	}
+
+	//
+	// Announce our workers are alive, until they have all exited.
+	//
+	p._processed = make([]uint64, p.Parallel)
+	heartbeatCtx, stopHeartbeats := context.WithCancel(context.Background())
+	heartbeatsDone := make(chan struct{})
+	if p.HeartbeatInterval > 0 {
+		go func() {
+			defer close(heartbeatsDone)
+			utils.RunHeartbeats(heartbeatCtx, p._r, p.HeartbeatInterval, p.heartbeats, p._log)
+		}()
+	} else {
+		close(heartbeatsDone)
+	}
+
 	wg := &sync.WaitGroup{}
 	var idx uint
 	for idx = 1; idx <= p.Parallel; idx++ {
@@ -901,71 +1741,167 @@ func (p *workerCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			p.workerLoop(workerIdx, shouldExit, &opts, parse)
+			p.workerLoop(ctx, workerIdx, &opts, parse)
 		}()
 	}
 
 	wg.Wait()
 
+	stopHeartbeats()
+	<-heartbeatsDone
+
+	//
+	// In once-mode let the caller know if anything failed.
+	//
+	if p.Once {
+		failures := atomic.LoadUint64(&p._failures)
+		if failures > 0 {
+			fmt.Printf("%d test(s) failed\n", failures)
+			return subcommands.ExitFailure
+		}
+	}
+
 	return subcommands.ExitSuccess
 }
 
-func (p *workerCmd) workerLoop(workerIdx uint, shouldExit *sync.Cond, opts *test.Options, parse *parser.Parser) {
-	fmt.Printf("worker %d started [tag=%s]\n", workerIdx, p.Tag)
-
-	exitLock := &sync.Mutex{}
-	exit := false
+// workerLoop fetches jobs from the queue and runs them, one at a time,
+// until the given context is cancelled.
+//
+// Jobs are fetched by a separate goroutine, so that we can stop waiting
+// for new jobs as soon as we're asked to exit.  That goroutine owns the
+// popped job until it has been handed over to us: if we're exiting it
+// pushes the job back to the queue, so that it doesn't get lost.
+func (p *workerCmd) workerLoop(ctx context.Context, workerIdx uint, opts *test.Options, parse *parser.Parser) {
+	fields := utils.LogFields{Worker: workerIdx, Tag: p.Tag}
+	p._log.Info(fields, "worker %d started [tag=%s]", workerIdx, p.Tag)
+
+	// Signalled by us whenever we're ready to run a new job.
+	workerAvailableChan := make(chan struct{})
+
+	// Jobs fetched from the queue, handed over to us along with the
+	// redis-server they came from.
+	type fetchedJob struct {
+		r          redis.UniversalClient
+		testObject []string
+	}
+	testObjectChan := make(chan fetchedJob)
+
+	// Puts a job we're not going to run back into its queue.
+	requeue := func(r redis.UniversalClient, testObject []string) {
+		if len(testObject) >= 2 {
+			// Requeue! Let's not lose the test
+			if _, err := r.RPush(testObject[0], testObject[1]).Result(); err != nil {
+				p._log.Error(fields, "failed to requeue job `%s`: %v", testObject[1], err)
+			} else {
+				p._log.Info(fields, "job requeued: %s", testObject[1])
+			}
+		} else {
+			p._log.Error(fields, "Popped unsupported value: %v", testObject)
+		}
+	}
 
-	workerAvailableChan := make(chan bool)
-	testObjectChan := make(chan []string)
+	// Closed once the fetching goroutine is gone.
+	fetcherDone := make(chan struct{})
 
 	go func() {
-		shouldExit.L.Lock()
-		defer shouldExit.L.Unlock()
-		shouldExit.Wait()
-
-		exitLock.Lock()
-		defer exitLock.Unlock()
-		exit = true
-		close(testObjectChan)
-		close(workerAvailableChan)
-	}()
+		defer close(fetcherDone)
 
-	go func() {
-		for <-workerAvailableChan {
-			exitLock.Lock()
-			if exit {
-				exitLock.Unlock()
+		for {
+			select {
+			case <-workerAvailableChan:
+			case <-ctx.Done():
 				return
 			}
-			exitLock.Unlock()
-
-			// Get a job.
-			testObject, _ := p._r.BLPop(0, "overseer.jobs").Result()
-
-			exitLock.Lock()
-			if exit {
-				exitLock.Unlock()
-				if len(testObject) >= 1 {
-					// Requeue! Let's not lose the test
-					if _, err := p._r.RPush("overseer.jobs", testObject[1]).Result(); err != nil {
-						fmt.Printf("failed to requeue job `%s`: %v\n", testObject[1], err)
-					} else {
-						fmt.Printf("job requeued: %s\n", testObject[1])
+
+			//
+			// Get a job, waiting at most a second at a time so
+			// that we can notice if we need to exit.
+			//
+			var testObject []string
+			var r redis.UniversalClient
+			empty := 0
+			for testObject == nil {
+				r = p.nextRedis()
+				result, err := r.BLPop(time.Second, p.jobsQueue()).Result()
+				if err != nil && err != redis.Nil {
+					p._log.Error(fields, "Failed to fetch job: %v", err)
+					select {
+					case <-time.After(time.Second):
+					case <-ctx.Done():
+						return
 					}
-				} else {
-					fmt.Printf("Popped unsupported value: %v\n", testObject)
+					continue
+				}
+				if err == nil {
+					testObject = result
+					continue
+				}
+
+				// In once-mode an empty queue means we're done,
+				// once we've looked at all of them.
+				empty++
+				if p.Once && empty >= len(p._rotation) {
+					return
 				}
+
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+			}
+
+			select {
+			case testObjectChan <- fetchedJob{r: r, testObject: testObject}:
+			case <-ctx.Done():
+				requeue(r, testObject)
 				return
 			}
-			exitLock.Unlock()
-			testObjectChan <- testObject
 		}
 	}()
 
+	// Once asked to exit, wait for the fetcher to requeue any job it
+	// might be holding.
+	exit := func() {
+		<-fetcherDone
+		p._log.Info(fields, "Worker %d exiting", workerIdx)
+	}
+
 	// Wait for jobs
-	workerAvailableChan <- true
-	for testObject := range testObjectChan {
+	for {
+		select {
+		case workerAvailableChan <- struct{}{}:
+		case <-fetcherDone:
+			exit()
+			return
+		case <-ctx.Done():
+			exit()
+			return
+		}
+
+		var fetched fetchedJob
+		select {
+		case fetched = <-testObjectChan:
+		case <-fetcherDone:
+			exit()
+			return
+		case <-ctx.Done():
+			exit()
+			return
+		}
+
+		//
+		// The job might have been handed over just as we were asked
+		// to exit, as select picks at random among the ready cases.
+		//
+		testObject := fetched.testObject
+		if ctx.Err() != nil {
+			requeue(fetched.r, testObject)
+			exit()
+			return
+		}
+		atomic.AddUint64(&p._processed[workerIdx-1], 1)
+
 		//
 		// Parse it
 		//
@@ -973,27 +1909,74 @@ func (p *workerCmd) workerLoop(workerIdx uint, shouldExit *sync.Cond, opts *test
 		//
 		//   testObject[1] will be the value removed from the list.
 		//
-		if len(testObject) >= 1 {
+		// Unless it's too big to be a sane test, in which case it's
+		// dead-lettered as-is, along with its size.
+		//
+		if len(testObject) >= 2 && p.MaxJobSize > 0 && len(testObject[1]) > p.MaxJobSize {
+			err := fmt.Errorf("job of %d bytes is bigger than the maximum of %d", len(testObject[1]), p.MaxJobSize)
+			p._log.Error(fields, "Rejecting job from queue: %s", err.Error())
+			p.deadLetter(workerIdx, testObject[1], err)
+		} else if len(testObject) >= 2 {
 			var job test.Test
 			job, err := parse.ParseLine(testObject[1], nil)
 
-			if err == nil {
-				p.runTest(workerIdx, job, *opts)
+			//
+			// Lines such as macro definitions parse fine, but hold
+			// no test for us to run.
+			//
+			if err == nil && protocols.ProtocolHandler(job.Type) == nil {
+				err = fmt.Errorf("no test to run in job '%s'", testObject[1])
+			}
+
+			if err == nil && !p.QuarantineWorker && p.isQuarantined(testObject[1]) {
+				//
+				// This test keeps failing, so move it away to
+				// not steal time from the healthy ones.
+				//
+				// Any copy already waiting in the quarantine queue
+				// is removed first, so that it doesn't grow forever
+				// if the tests are enqueued faster than they are
+				// processed.
+				//
+				r := p.redisFor(testObject[1])
+				if _, err = r.LRem("overseer.jobs.quarantine", 0, testObject[1]).Result(); err != nil {
+					p._log.Error(p.logFields(workerIdx, job), "failed to remove quarantined copies of job `%s`: %v", testObject[1], err)
+				}
+				if _, err = r.RPush("overseer.jobs.quarantine", testObject[1]).Result(); err != nil {
+					p._log.Error(p.logFields(workerIdx, job), "failed to quarantine job `%s`: %v", testObject[1], err)
+				} else {
+					p._log.Debug(p.logFields(workerIdx, job), "Job quarantined: %s", testObject[1])
+				}
+			} else if err == nil {
+				errTest := p.runTest(workerIdx, job, *opts)
+				failures := p.recordConsecutiveFailures(testObject[1], errTest != nil)
+				if errTest != nil {
+					atomic.AddUint64(&p._failures, 1)
+				}
+
+				//
+				// Quarantined tests are only executed again by the
+				// quarantine workers, so make sure it's noticed.
+				//
+				if !p.QuarantineWorker && p.QuarantineAfter > 0 && failures == uint64(p.QuarantineAfter) {
+					p._log.Warn(p.logFields(workerIdx, job), "Test failed %d consecutive times, it will be moved to the quarantine queue, which is only processed by workers started with -quarantine-worker: %s", failures, job.Sanitize())
+				}
+
+				if p.QuarantineWorker {
+					select {
+					case <-time.After(p.QuarantineDelay):
+					case <-ctx.Done():
+					}
+				}
+			} else if err == parser.ErrEmptyLine {
+				p._log.Debug(fields, "Ignoring empty job from queue: %q", testObject[1])
 			} else {
-				fmt.Printf("Error parsing job from queue: %s - %s\n", testObject[1], err.Error())
+				p._log.Error(fields, "Error parsing job from queue: %s - %s", testObject[1], err.Error())
+				p.deadLetter(workerIdx, testObject[1], err)
 			}
 		} else {
-			fmt.Printf("Popped unsupported value: %v\n", testObject)
+			p._log.Error(fields, "Popped unsupported value: %v", testObject)
 		}
 
-		exitLock.Lock()
-		if exit {
-			exitLock.Unlock()
-			break
-		}
-		exitLock.Unlock()
-		workerAvailableChan <- true
 	}
-
-	fmt.Printf("Worker %d exiting\n", workerIdx)
 }
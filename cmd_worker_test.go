@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/cmaster11/overseer/parser"
+	"github.com/cmaster11/overseer/protocols"
+	"github.com/cmaster11/overseer/sinks"
+	"github.com/cmaster11/overseer/test"
+	"github.com/cmaster11/overseer/utils"
+	"github.com/go-redis/redis"
+	"github.com/google/subcommands"
+)
+
+// popTest is a protocol-test which fails against a single target,
+// recording every target it was run against.
+type popTest struct {
+	failing string
+
+	lock    sync.Mutex
+	targets []string
+}
+
+func (s *popTest) Arguments() map[string]string { return map[string]string{} }
+func (s *popTest) Example() string              { return "" }
+func (s *popTest) ShouldResolveHostname() bool  { return true }
+
+func (s *popTest) RunTest(tst test.Test, target string, opts test.Options) error {
+	s.lock.Lock()
+	s.targets = append(s.targets, target)
+	s.lock.Unlock()
+
+	if target == s.failing {
+		return fmt.Errorf("connection refused")
+	}
+	return nil
+}
+
+// newLocalWorker returns a worker without redis, which keeps its results
+// in memory.
+func newLocalWorker(t *testing.T) (*workerCmd, *sinks.MemorySink) {
+	logger, err := utils.NewLogger("text", false, &strings.Builder{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	results := sinks.NewMemorySink()
+	return &workerCmd{
+		IPv4:        true,
+		IPv6:        true,
+		_log:        logger,
+		_sinks:      []sinks.ResultSink{results},
+		_workerName: "test",
+	}, results
+}
+
+func TestRunTestUnknownType(t *testing.T) {
+	worker, results := newLocalWorker(t)
+
+	for _, tst := range []test.Test{{}, {Target: "example.com", Type: "nothing"}} {
+		err := worker.runTest(1, tst, test.Options{})
+		if err == nil || !strings.Contains(err.Error(), "unknown test-type") {
+			t.Errorf("expected the test %+v to be refused, got %v", tst, err)
+		}
+	}
+
+	if collected, _ := results.Results(); len(collected) != 0 {
+		t.Errorf("expected no results, got %d", len(collected))
+	}
+}
+
+func TestRunTestPops(t *testing.T) {
+	probe := &popTest{failing: "10.0.0.2"}
+	protocols.Register("pop-test", func() protocols.ProtocolTest { return probe })
+
+	worker, results := newLocalWorker(t)
+
+	pops := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
+	tst := test.Test{
+		Target: "example.com",
+		Type:   "pop-test",
+		Input:  "example.com must run pop-test with pop 10.0.0.1 with pop 10.0.0.2 with pop 10.0.0.3",
+		Pops:   pops,
+	}
+	if err := worker.runTest(1, tst, test.Options{}); err == nil {
+		t.Fatalf("expected the test to fail")
+	}
+
+	// Each PoP was tested exactly once.
+	sort.Strings(probe.targets)
+	if strings.Join(probe.targets, ",") != strings.Join(pops, ",") {
+		t.Errorf("expected the test to run against %v, got %v", pops, probe.targets)
+	}
+
+	// A single result is notified, for all the PoPs.
+	collected, _ := results.Results()
+	if len(collected) != 1 {
+		t.Fatalf("expected a single result, got %d", len(collected))
+	}
+	result := collected[0]
+
+	if result.Target != "10.0.0.1,10.0.0.2,10.0.0.3" {
+		t.Errorf("unexpected target %s", result.Target)
+	}
+	if result.Error == nil {
+		t.Fatalf("expected the result to be a failure")
+	}
+	if *result.Error != "1 PoPs failed out of 3: 10.0.0.2" {
+		t.Errorf("unexpected error: %s", *result.Error)
+	}
+	if result.Details == nil {
+		t.Fatalf("expected the PoP results in the details")
+	}
+	expected := "PoP results:\n- 10.0.0.1: OK\n- 10.0.0.2: connection refused\n- 10.0.0.3: OK"
+	if *result.Details != expected {
+		t.Errorf("expected details %q, got %q", expected, *result.Details)
+	}
+}
+
+// fakeTest is a protocol-test whose outcome, and duration, depend on its
+// target, recording how many times each target was tested.
+type fakeTest struct {
+	lock   sync.Mutex
+	errors map[string]string
+	delays map[string]time.Duration
+	runs   map[string]int
+}
+
+var fake = &fakeTest{}
+
+func init() {
+	protocols.Register("fake", func() protocols.ProtocolTest { return fake })
+}
+
+func (s *fakeTest) Arguments() map[string]string { return map[string]string{} }
+func (s *fakeTest) Example() string              { return "" }
+func (s *fakeTest) ShouldResolveHostname() bool  { return false }
+
+func (s *fakeTest) RunTest(tst test.Test, target string, opts test.Options) error {
+	s.lock.Lock()
+	s.runs[target]++
+	message := s.errors[target]
+	delay := s.delays[target]
+	s.lock.Unlock()
+
+	time.Sleep(delay)
+
+	if message != "" {
+		return fmt.Errorf("%s", message)
+	}
+	return nil
+}
+
+// reset makes every target pass, straight away.
+func (s *fakeTest) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.errors = make(map[string]string)
+	s.delays = make(map[string]time.Duration)
+	s.runs = make(map[string]int)
+}
+
+// fail makes the tests of the given target fail with the given error, or
+// pass if it's empty.
+func (s *fakeTest) fail(target string, message string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.errors[target] = message
+}
+
+// slow makes the tests of the given target take the given time.
+func (s *fakeTest) slow(target string, delay time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.delays[target] = delay
+}
+
+// runCount returns how many times the given target was tested.
+func (s *fakeTest) runCount(target string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.runs[target]
+}
+
+// newRedis returns a redis-server, holding the given jobs.
+func newRedis(t *testing.T, jobs ...string) *miniredis.Miniredis {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start redis: %s", err)
+	}
+	for _, job := range jobs {
+		s.Push("overseer.jobs", job)
+	}
+	return s
+}
+
+// executeWorker runs the worker sub-command, with a single worker which
+// doesn't retry failing tests, against the given redis-server, returning
+// its exit-status.
+func executeWorker(ctx context.Context, t *testing.T, s *miniredis.Miniredis, args ...string) subcommands.ExitStatus {
+	cmd := &workerCmd{}
+
+	f := flag.NewFlagSet("worker", flag.ContinueOnError)
+	cmd.SetFlags(f)
+	args = append([]string{"-redis-host", s.Addr(), "-parallel", "1", "-retry=false", "-heartbeat-interval", "0"}, args...)
+	if err := f.Parse(args); err != nil {
+		t.Fatalf("failed to parse the flags: %s", err)
+	}
+
+	return cmd.Execute(ctx, f)
+}
+
+// listLength returns the length of the given redis list, 0 if missing.
+func listLength(s *miniredis.Miniredis, key string) int {
+	list, _ := s.List(key)
+	return len(list)
+}
+
+func TestQuarantine(t *testing.T) {
+	fake.reset()
+	fake.fail("broken.example.com", "connection refused")
+
+	job := "broken.example.com must run fake"
+	s := newRedis(t, job, job, job)
+	defer s.Close()
+
+	// The third copy of the job is quarantined, rather than run.
+	executeWorker(context.Background(), t, s, "-once", "-quarantine-after", "2")
+	if n := fake.runCount("broken.example.com"); n != 2 {
+		t.Errorf("expected the job to run twice, got %d", n)
+	}
+	if n := listLength(s, "overseer.jobs"); n != 0 {
+		t.Errorf("expected the jobs queue to be empty, got %d jobs", n)
+	}
+	quarantined, _ := s.List("overseer.jobs.quarantine")
+	if len(quarantined) != 1 || quarantined[0] != job {
+		t.Errorf("expected the job to be quarantined, got %v", quarantined)
+	}
+
+	// Once quarantined it's not run anymore, even by a fresh worker.
+	s.Push("overseer.jobs", job)
+	executeWorker(context.Background(), t, s, "-once", "-quarantine-after", "2")
+	if n := fake.runCount("broken.example.com"); n != 2 {
+		t.Errorf("expected the quarantined job not to run, got %d runs", n)
+	}
+	if n := listLength(s, "overseer.jobs.quarantine"); n != 1 {
+		t.Errorf("expected a single quarantined copy, got %d", n)
+	}
+
+	// The quarantine workers still run it.
+	executeWorker(context.Background(), t, s, "-once", "-quarantine-after", "2", "-quarantine-worker", "-quarantine-delay", "0")
+	if n := fake.runCount("broken.example.com"); n != 3 {
+		t.Errorf("expected the quarantine worker to run the job, got %d runs", n)
+	}
+}
+
+func TestQuarantineReset(t *testing.T) {
+	fake.reset()
+	fake.fail("flaky.example.com", "connection refused")
+
+	job := "flaky.example.com must run fake"
+	s := newRedis(t, job)
+	defer s.Close()
+
+	executeWorker(context.Background(), t, s, "-once", "-quarantine-after", "2")
+
+	// A pass resets the count of the consecutive failures.
+	fake.fail("flaky.example.com", "")
+	s.Push("overseer.jobs", job)
+	executeWorker(context.Background(), t, s, "-once", "-quarantine-after", "2")
+
+	fake.fail("flaky.example.com", "connection refused")
+	s.Push("overseer.jobs", job)
+	s.Push("overseer.jobs", job)
+	executeWorker(context.Background(), t, s, "-once", "-quarantine-after", "2")
+
+	if n := fake.runCount("flaky.example.com"); n != 4 {
+		t.Errorf("expected the job to run four times, got %d", n)
+	}
+	if n := listLength(s, "overseer.jobs.quarantine"); n != 0 {
+		t.Errorf("expected no quarantined job, got %d", n)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	fake.reset()
+	fake.fail("a.example.com", "connection refused")
+	fake.fail("b.example.com", "connection refused")
+
+	a := "a.example.com must run fake"
+	s := newRedis(t, a, a, "b.example.com must run fake")
+	defer s.Close()
+
+	// Identical failures within the window are pushed once, unlike
+	// those of other targets.
+	executeWorker(context.Background(), t, s, "-once", "-coalesce-window", "1m")
+	if n := fake.runCount("a.example.com"); n != 2 {
+		t.Errorf("expected the job to run twice, got %d", n)
+	}
+	if n := listLength(s, "overseer.results"); n != 2 {
+		t.Errorf("expected two results, got %d", n)
+	}
+
+	// Failures with another error are pushed too.
+	fake.fail("a.example.com", "connection timed out")
+	s.Push("overseer.jobs", a)
+	executeWorker(context.Background(), t, s, "-once", "-coalesce-window", "1m")
+	if n := listLength(s, "overseer.results"); n != 3 {
+		t.Errorf("expected the new error to be pushed, got %d results", n)
+	}
+
+	// Once the window is over the same failure is pushed again.
+	s.FastForward(time.Minute)
+	s.Push("overseer.jobs", a)
+	executeWorker(context.Background(), t, s, "-once", "-coalesce-window", "1m")
+	if n := listLength(s, "overseer.results"); n != 4 {
+		t.Errorf("expected the failure to be pushed after the window, got %d results", n)
+	}
+}
+
+func TestWorkerLoopRequeue(t *testing.T) {
+	fake.reset()
+
+	job := "example.com must run fake"
+	s := newRedis(t)
+	defer s.Close()
+
+	worker, _ := newLocalWorker(t)
+	worker._processed = make([]uint64, 1)
+
+	//
+	// Ask the worker to exit just as a job has been fetched, while
+	// it's being handed over.
+	//
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer r.Close()
+	r.WrapProcess(func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			err := old(cmd)
+			if cmd.Name() == "blpop" && err == nil {
+				cancel()
+			}
+			return err
+		}
+	})
+	worker._r = r
+
+	s.Push("overseer.jobs", job)
+	worker.workerLoop(ctx, 1, &test.Options{}, parser.New())
+
+	if n := fake.runCount("example.com"); n != 0 {
+		t.Errorf("expected the job not to run, got %d runs", n)
+	}
+	jobs, _ := s.List("overseer.jobs")
+	if len(jobs) != 1 || jobs[0] != job {
+		t.Errorf("expected the job to be requeued, got %v", jobs)
+	}
+}
+
+func TestOnce(t *testing.T) {
+	fake.reset()
+	fake.fail("broken.example.com", "connection refused")
+
+	s := newRedis(t)
+	defer s.Close()
+
+	// An empty queue is a success.
+	if status := executeWorker(context.Background(), t, s, "-once"); status != subcommands.ExitSuccess {
+		t.Errorf("expected an empty queue to succeed, got status %d", status)
+	}
+
+	// Passing tests are a success too.
+	s.Push("overseer.jobs", "example.com must run fake")
+	if status := executeWorker(context.Background(), t, s, "-once"); status != subcommands.ExitSuccess {
+		t.Errorf("expected the passing test to succeed, got status %d", status)
+	}
+
+	// Any failing test is a failure, once the queue is empty.
+	s.Push("overseer.jobs", "broken.example.com must run fake")
+	s.Push("overseer.jobs", "example.com must run fake")
+	if status := executeWorker(context.Background(), t, s, "-once"); status != subcommands.ExitFailure {
+		t.Errorf("expected the failing test to fail, got status %d", status)
+	}
+	if n := fake.runCount("example.com"); n != 2 {
+		t.Errorf("expected the whole queue to be processed, got %d runs", n)
+	}
+	if n := listLength(s, "overseer.jobs"); n != 0 {
+		t.Errorf("expected the jobs queue to be empty, got %d jobs", n)
+	}
+}
+
+func TestMaxRuntime(t *testing.T) {
+	fake.reset()
+	fake.slow("slow.example.com", 500*time.Millisecond)
+
+	pending := "example.com must run fake"
+	s := newRedis(t, "slow.example.com must run fake", pending)
+	defer s.Close()
+
+	// The running test completes, the pending ones are left queued.
+	start := time.Now()
+	status := executeWorker(context.Background(), t, s, "-max-runtime", "200ms")
+	if status != subcommands.ExitSuccess {
+		t.Errorf("expected the worker to exit cleanly, got status %d", status)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected the worker to stop after its max-runtime, took %s", elapsed)
+	}
+
+	if n := fake.runCount("slow.example.com"); n != 1 {
+		t.Errorf("expected the running test to complete, got %d runs", n)
+	}
+	if n := listLength(s, "overseer.results"); n != 1 {
+		t.Errorf("expected the result of the running test, got %d results", n)
+	}
+	if n := fake.runCount("example.com"); n != 0 {
+		t.Errorf("expected the pending job not to run, got %d runs", n)
+	}
+	jobs, _ := s.List("overseer.jobs")
+	if len(jobs) != 1 || jobs[0] != pending {
+		t.Errorf("expected the pending job to be left queued, got %v", jobs)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	fake.reset()
+
+	dir, err := ioutil.TempDir("", "overseer")
+	if err != nil {
+		t.Fatalf("failed to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, invalid := range []string{"example.com must run nothing", "example.com must walk"} {
+		file := filepath.Join(dir, "tests.txt")
+		if err = ioutil.WriteFile(file, []byte("example.com must run fake\n"+invalid+"\n"), 0644); err != nil {
+			t.Fatalf("failed to write the test-file: %s", err)
+		}
+
+		s := newRedis(t, "example.com must run fake", invalid)
+
+		// In strict-mode the worker refuses to start.
+		status := executeWorker(context.Background(), t, s, "-once", "-strict", "-test-file", file)
+		if status != subcommands.ExitFailure {
+			t.Errorf("expected %q to fail in strict-mode, got status %d", invalid, status)
+		}
+		if n := listLength(s, "overseer.jobs"); n != 2 {
+			t.Errorf("expected no job to be fetched in strict-mode, got %d left", n)
+		}
+
+		// Otherwise the invalid job is dead-lettered.
+		status = executeWorker(context.Background(), t, s, "-once", "-test-file", file)
+		if status != subcommands.ExitSuccess {
+			t.Errorf("expected %q not to fail the worker, got status %d", invalid, status)
+		}
+		letters, _ := s.List("overseer.deadletter")
+		if len(letters) != 1 {
+			t.Fatalf("expected %q to be dead-lettered, got %v", invalid, letters)
+		}
+		var letter utils.DeadLetter
+		if err = json.Unmarshal([]byte(letters[0]), &letter); err != nil || letter.Raw != invalid {
+			t.Errorf("unexpected dead letter %s (%v)", letters[0], err)
+		}
+
+		s.Close()
+	}
+
+	if n := fake.runCount("example.com"); n != 2 {
+		t.Errorf("expected the valid job to run outside of strict-mode, got %d runs", n)
+	}
+}
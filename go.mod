@@ -3,28 +3,36 @@ module github.com/cmaster11/overseer
 go 1.13
 
 require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/alicebob/miniredis v2.5.0+incompatible
 	github.com/cmaster11/k8s-event-watcher v0.0.8
+	github.com/eclipse/paho.mqtt.golang v1.3.5
 	github.com/emersion/go-imap v1.0.0-beta.2
+	github.com/go-asn1-ber/asn1-ber v1.5.1
+	github.com/go-ldap/ldap/v3 v3.3.0
 	github.com/go-redis/redis v6.15.2+incompatible
 	github.com/go-sql-driver/mysql v1.4.1
+	github.com/gomodule/redigo v2.0.0+incompatible // indirect
 	github.com/google/subcommands v1.0.1
-	github.com/jlaffaye/ftp v0.0.0-20190126081051-8019e6774408
+	github.com/gorilla/websocket v1.4.2
+	github.com/jlaffaye/ftp v0.1.0
 	github.com/lib/pq v1.0.0
 	github.com/marpaia/graphite-golang v0.0.0-20171231172105-134b9af18cf3
+	github.com/mattn/go-sqlite3 v1.14.0
 	github.com/miekg/dns v1.1.6
-	github.com/mitchellh/mapstructure v1.1.2
+	github.com/mmcdole/gofeed v1.0.0
 	github.com/onsi/ginkgo v1.8.0 // indirect
 	github.com/onsi/gomega v1.5.0 // indirect
+	github.com/pion/stun v0.3.5
 	github.com/robfig/cron v0.0.0-20180505203441-b41be1df6967
 	github.com/simia-tech/go-pop3 v0.0.0-20150626094726-c9c20550a244
 	github.com/skx/golang-metrics v0.0.0-20180606065905-85a4b4e0641f
-	golang.org/x/lint v0.0.0-20191125180803-fdd1cda4f05f // indirect
-	golang.org/x/sys v0.0.0-20191010194322-b09406accb47 // indirect
-	golang.org/x/text v0.3.2 // indirect
-	golang.org/x/tools v0.0.0-20200505023115-26f46d2f7ef8 // indirect
+	github.com/yuin/gopher-lua v0.0.0-20200816102855-ee81675732da // indirect
+	go.mongodb.org/mongo-driver v1.8.4
+	golang.org/x/crypto v0.0.0-20201216223049-8b5274cf687f
+	google.golang.org/grpc v1.29.1
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 	gopkg.in/yaml.v2 v2.2.4
-	honnef.co/go/tools v0.0.1-2020.1.3 // indirect
 	k8s.io/api v0.0.0-20190620084959-7cf5895f2711
 	k8s.io/apimachinery v0.0.0-20190612205821-1799e75a0719
 	k8s.io/client-go v0.0.0-20190620085101-78d2af792bab
@@ -21,6 +21,11 @@ func main() {
 	subcommands.Register(&dumpCmd{}, "")
 	subcommands.Register(&enqueueCmd{}, "")
 	subcommands.Register(&examplesCmd{}, "")
+	subcommands.Register(&lintCmd{}, "")
+	subcommands.Register(&protocolsCmd{}, "")
+	subcommands.Register(&schemaCmd{}, "")
+	subcommands.Register(&statusCmd{}, "")
+	subcommands.Register(&testOneCmd{}, "")
 	subcommands.Register(&versionCmd{}, "")
 	subcommands.Register(&workerCmd{}, "")
 	subcommands.Register(&k8sEventWatcherCmd{}, "")
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/marpaia/graphite-golang"
+)
+
+// SortedNames returns the names of the given values, keyed by metric
+// name, sorted.
+func SortedNames(values map[string]string) []string {
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GraphiteBatch returns the given values, keyed by metric name, as a
+// batch of Graphite metrics recorded at the given time, sorted by name.
+func GraphiteBatch(values map[string]string, now time.Time) []graphite.Metric {
+	names := SortedNames(values)
+
+	batch := make([]graphite.Metric, 0, len(names))
+	for _, name := range names {
+		batch = append(batch, graphite.NewMetric(name, values[name], now.Unix()))
+	}
+	return batch
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marpaia/graphite-golang"
+)
+
+func TestGraphiteBatch(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	batch := GraphiteBatch(map[string]string{
+		"overseer.test.http.example_com.duration": "12.5",
+		"overseer.dns.example_com.duration":       "1.2",
+		"overseer.test.http.example_com.attempts": "1",
+	}, now)
+
+	expected := []graphite.Metric{
+		{Name: "overseer.dns.example_com.duration", Value: "1.2", Timestamp: 1600000000},
+		{Name: "overseer.test.http.example_com.attempts", Value: "1", Timestamp: 1600000000},
+		{Name: "overseer.test.http.example_com.duration", Value: "12.5", Timestamp: 1600000000},
+	}
+	if !reflect.DeepEqual(batch, expected) {
+		t.Errorf("expected %v, got %v", expected, batch)
+	}
+
+	if batch = GraphiteBatch(nil, now); len(batch) != 0 {
+		t.Errorf("expected an empty batch, got %v", batch)
+	}
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// InfluxDB writes metrics to an InfluxDB server, via the HTTP API and the
+// line protocol.
+type InfluxDB struct {
+	// The address of the server, e.g. "http://localhost:8086".
+	URL string
+
+	// The database, or bucket, to write to.
+	Database string
+
+	// The (optional) token to authenticate with.
+	Token string
+
+	// The client used to talk to the server.
+	Client *http.Client
+}
+
+// NewInfluxDB returns a client writing metrics to the given database of
+// the InfluxDB server at the given address.
+func NewInfluxDB(address string, database string, token string) (*InfluxDB, error) {
+	if _, err := url.Parse(address); err != nil || address == "" {
+		return nil, fmt.Errorf("invalid InfluxDB URL '%s'", address)
+	}
+	if database == "" {
+		return nil, fmt.Errorf("the InfluxDB database is required")
+	}
+
+	return &InfluxDB{
+		URL:      strings.TrimSuffix(address, "/"),
+		Database: database,
+		Token:    token,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// influxPoint is a line of the line protocol being built: the fields of a
+// measurement, with a given set of tags.
+type influxPoint struct {
+	series string
+	fields []string
+}
+
+// InfluxLines returns the given values, keyed by metric name, as lines of
+// the InfluxDB line protocol recorded at the given time.
+//
+// The metrics of the tests become fields of the overseer_test
+// measurement, tagged with the type and the target of the test, e.g.:
+//
+//    overseer_test,type=http,target=https_example_com_ attempts=1i,duration=12.3 1600000000
+//
+// The DNS lookups become the overseer_dns measurement, tagged with the
+// target, and any other metric a measurement of its own.  Counters are
+// written as integers, everything else as floats.
+func InfluxLines(values map[string]string, now time.Time) []string {
+	var points []*influxPoint
+	bySeries := make(map[string]*influxPoint)
+
+	for _, name := range SortedNames(values) {
+		series, field := influxSeries(name)
+
+		value := values[name]
+		if testCounters[field] {
+			value += "i"
+		}
+
+		point, ok := bySeries[series]
+		if !ok {
+			point = &influxPoint{series: series}
+			bySeries[series] = point
+			points = append(points, point)
+		}
+		point.fields = append(point.fields, field+"="+value)
+	}
+
+	lines := make([]string, 0, len(points))
+	for _, point := range points {
+		lines = append(lines, fmt.Sprintf("%s %s %d", point.series, strings.Join(point.fields, ","), now.Unix()))
+	}
+	return lines
+}
+
+// influxSeries splits the given metric name into the measurement and tags
+// it belongs to, and the name of its field.
+func influxSeries(name string) (string, string) {
+	parts := strings.Split(name, ".")
+	field := parts[len(parts)-1]
+
+	switch {
+	// overseer.test..dns.$lookup.$key
+	case len(parts) == 6 && parts[0] == "overseer" && parts[1] == "test" && parts[2] == "":
+		return "overseer_test,type=" + parts[3] + ",target=" + parts[4], field
+
+	// overseer.test.$type.$target.$key
+	case len(parts) == 5 && parts[0] == "overseer" && parts[1] == "test":
+		return "overseer_test,type=" + parts[2] + ",target=" + parts[3], field
+
+	// overseer.dns.$target.$key
+	case len(parts) == 4 && parts[0] == "overseer" && parts[1] == "dns":
+		return "overseer_dns,target=" + parts[2], field
+	}
+
+	return AlphaNumeric(name), "value"
+}
+
+// Send writes the given values, keyed by metric name, recorded now.
+func (i *InfluxDB) Send(values map[string]string) error {
+	lines := InfluxLines(values, time.Now())
+	if len(lines) == 0 {
+		return nil
+	}
+
+	query := url.Values{"db": {i.Database}, "precision": {"s"}}
+	req, err := http.NewRequest("POST", i.URL+"/write?"+query.Encode(), strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if i.Token != "" {
+		req.Header.Set("Authorization", "Token "+i.Token)
+	}
+
+	response, err := i.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("InfluxDB write failed with status %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfluxLines(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	lines := InfluxLines(map[string]string{
+		"overseer.dns.example_com.duration":       "1.200000",
+		"overseer.test.http.example_com.duration": "12.300000",
+		"overseer.test.http.example_com.attempts": "1",
+		"overseer.test.http.example_com.success":  "1",
+		"overseer.test..dns.steve_fi.failure":     "1",
+		"something.else":                          "3",
+	}, now)
+
+	expected := []string{
+		"overseer_dns,target=example_com duration=1.200000 1600000000",
+		"overseer_test,type=dns,target=steve_fi failure=1i 1600000000",
+		"overseer_test,type=http,target=example_com attempts=1i,duration=12.300000,success=1i 1600000000",
+		"something_else value=3 1600000000",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+}
+
+func TestInfluxDBSend(t *testing.T) {
+	var body, query, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/write" {
+			http.NotFound(w, r)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		query = r.URL.RawQuery
+		auth = r.Header.Get("Authorization")
+		if auth != "Token s3cr3t" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	i, err := NewInfluxDB(server.URL+"/", "overseer", "s3cr3t")
+	if err != nil {
+		t.Fatalf("failed to create the client: %s", err)
+	}
+
+	err = i.Send(map[string]string{
+		"overseer.test.http.example_com.duration": "12.3",
+		"overseer.test.http.example_com.attempts": "1",
+	})
+	if err != nil {
+		t.Fatalf("failed to send: %s", err)
+	}
+
+	if !strings.HasPrefix(body, "overseer_test,type=http,target=example_com attempts=1i,duration=12.3 ") {
+		t.Errorf("unexpected body %q", body)
+	}
+	if query != "db=overseer&precision=s" {
+		t.Errorf("unexpected query %q", query)
+	}
+
+	i.Token = "wrong"
+	err = i.Send(map[string]string{"overseer.test.http.example_com.attempts": "1"})
+	if err == nil || !strings.Contains(err.Error(), "InfluxDB write failed with status 401: unauthorized") {
+		t.Errorf("expected the failed write to be reported, got %v", err)
+	}
+
+	if _, err = NewInfluxDB(server.URL, "", ""); err == nil {
+		t.Errorf("expected the missing database to be reported")
+	}
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"regexp"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// The metrics recorded for each test, as sent to Graphite or StatsD.
+const (
+	TestDuration  = "duration"
+	TestAttempts  = "attempts"
+	TestSuccess   = "success"
+	TestFailure   = "failure"
+	TestDedup     = "dedup"
+	TestRecovered = "recovered"
+)
+
+// testCounters are the metrics of each test which count events, rather
+// than measuring durations.
+var testCounters = map[string]bool{
+	TestAttempts:  true,
+	TestSuccess:   true,
+	TestFailure:   true,
+	TestDedup:     true,
+	TestRecovered: true,
+}
+
+// nonAlphaNumeric matches the characters which aren't valid in the names
+// of our metrics.
+var nonAlphaNumeric = regexp.MustCompile("[^A-Za-z0-9]+")
+
+// AlphaNumeric removes all non alpha-numeric characters from the
+// given string, and returns it.  We replace the characters that
+// are invalid with `_`.
+func AlphaNumeric(input string) string {
+	return nonAlphaNumeric.ReplaceAllString(input, "_")
+}
+
+// TestMetric returns the name of the given metric of a test.
+//
+// This is a little weird because ideally we'd want to submit to the
+// metrics-host :
+//
+//    overseer.$testType.$testTarget.$key => value
+//
+// But of course the target might not be what we think it is for all
+// cases - i.e. A DNS test the target is the name of the nameserver rather
+// than the thing to lookup, which is the natural target.
+//
+func TestMetric(tst test.Test, key string) string {
+
+	prefix := "overseer.test."
+
+	//
+	// Special-case for the DNS-test
+	//
+	if tst.Type == "dns" {
+		return prefix + ".dns." + AlphaNumeric(tst.Arguments["lookup"]) + "." + key
+	}
+
+	//
+	// Otherwise we have a normal test.
+	//
+	return prefix + tst.Type + "." + AlphaNumeric(tst.Target) + "." + key
+}
+
+// TestOutcome returns the metric counting the given result of a test:
+// success or failure.
+func TestOutcome(result error) string {
+	if result != nil {
+		return TestFailure
+	}
+	return TestSuccess
+}
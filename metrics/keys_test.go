@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+func TestTestMetric(t *testing.T) {
+	web := test.Test{Type: "http", Target: "https://example.com/"}
+	dns := test.Test{Type: "dns", Target: "8.8.8.8", Arguments: map[string]string{"lookup": "steve.fi"}}
+
+	tests := []struct {
+		found    string
+		expected string
+	}{
+		{TestMetric(web, TestDuration), "overseer.test.http.https_example_com_.duration"},
+		{TestMetric(web, TestOutcome(nil)), "overseer.test.http.https_example_com_.success"},
+		{TestMetric(web, TestOutcome(errors.New("failed"))), "overseer.test.http.https_example_com_.failure"},
+		{TestMetric(web, TestDedup), "overseer.test.http.https_example_com_.dedup"},
+		{TestMetric(web, TestRecovered), "overseer.test.http.https_example_com_.recovered"},
+		{TestMetric(dns, TestOutcome(nil)), "overseer.test..dns.steve_fi.success"},
+	}
+
+	for _, tst := range tests {
+		if tst.found != tst.expected {
+			t.Errorf("expected %s, got %s", tst.expected, tst.found)
+		}
+	}
+}
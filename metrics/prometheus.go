@@ -0,0 +1,183 @@
+// Package metrics keeps track of the tests run by a worker, and exposes
+// the statistics in the Prometheus text format, or sends them to Graphite,
+// StatsD or InfluxDB.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DurationBuckets are the upper bounds, in seconds, of the buckets of the
+// test-duration histogram.
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// The counters we keep, for each test type.
+const (
+	TestsExecuted       = "overseer_tests_executed_total"
+	TestsPassed         = "overseer_tests_passed_total"
+	TestsFailed         = "overseer_tests_failed_total"
+	TestsRetried        = "overseer_tests_retried_total"
+	ResultsDeduplicated = "overseer_results_deduplicated_total"
+	ResultsRecovered    = "overseer_results_recovered_total"
+)
+
+// counterHelp describes each of our counters.
+var counterHelp = map[string]string{
+	TestsExecuted:       "Tests executed, against each of their targets.",
+	TestsPassed:         "Tests which passed.",
+	TestsFailed:         "Tests which failed, after any retry.",
+	TestsRetried:        "Retries of failing tests.",
+	ResultsDeduplicated: "Results of failing tests not notified, due to deduplication.",
+	ResultsRecovered:    "Results notified as recovered from a previous failure.",
+}
+
+// durationHistogram is the name of our test-duration histogram.
+const durationHistogram = "overseer_test_duration_seconds"
+
+// histogram holds the observations of a single test type.
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// Prometheus holds the statistics of a worker.
+//
+// All the methods can be invoked on a nil object, in which case they do
+// nothing, so that callers don't need to check if metrics are enabled.
+type Prometheus struct {
+	mutex sync.Mutex
+
+	// counters holds the value of each counter, per test type
+	counters map[string]map[string]uint64
+
+	// durations holds the duration histogram, per test type
+	durations map[string]*histogram
+}
+
+// NewPrometheus returns an empty set of statistics.
+func NewPrometheus() *Prometheus {
+	return &Prometheus{
+		counters:  make(map[string]map[string]uint64),
+		durations: make(map[string]*histogram),
+	}
+}
+
+// Add increments the given counter, for the given test type.
+func (m *Prometheus) Add(counter string, testType string, value uint64) {
+	if m == nil || value == 0 {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.counters[counter] == nil {
+		m.counters[counter] = make(map[string]uint64)
+	}
+	m.counters[counter][testType] += value
+}
+
+// Inc increments the given counter by one, for the given test type.
+func (m *Prometheus) Inc(counter string, testType string) {
+	m.Add(counter, testType, 1)
+}
+
+// ObserveDuration records the time a test of the given type took.
+func (m *Prometheus) ObserveDuration(testType string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	h := m.durations[testType]
+	if h == nil {
+		h = &histogram{buckets: make([]uint64, len(DurationBuckets))}
+		m.durations[testType] = h
+	}
+
+	seconds := duration.Seconds()
+	for i, bound := range DurationBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// ServeHTTP writes our statistics in the Prometheus text format.
+func (m *Prometheus) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, m.String())
+}
+
+// String returns our statistics in the Prometheus text format.
+func (m *Prometheus) String() string {
+	if m == nil {
+		return ""
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var out strings.Builder
+
+	var names []string
+	for name := range counterHelp {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(&out, "# HELP %s %s\n", name, counterHelp[name])
+		fmt.Fprintf(&out, "# TYPE %s counter\n", name)
+		for _, testType := range sortedKeys(m.counters[name]) {
+			fmt.Fprintf(&out, "%s{type=\"%s\"} %d\n", name, escapeLabel(testType), m.counters[name][testType])
+		}
+	}
+
+	fmt.Fprintf(&out, "# HELP %s The time taken to run tests, including any retry.\n", durationHistogram)
+	fmt.Fprintf(&out, "# TYPE %s histogram\n", durationHistogram)
+
+	var types []string
+	for testType := range m.durations {
+		types = append(types, testType)
+	}
+	sort.Strings(types)
+
+	for _, testType := range types {
+		h := m.durations[testType]
+		label := escapeLabel(testType)
+		for i, bound := range DurationBuckets {
+			fmt.Fprintf(&out, "%s_bucket{type=\"%s\",le=\"%g\"} %d\n", durationHistogram, label, bound, h.buckets[i])
+		}
+		fmt.Fprintf(&out, "%s_bucket{type=\"%s\",le=\"+Inf\"} %d\n", durationHistogram, label, h.count)
+		fmt.Fprintf(&out, "%s_sum{type=\"%s\"} %g\n", durationHistogram, label, h.sum)
+		fmt.Fprintf(&out, "%s_count{type=\"%s\"} %d\n", durationHistogram, label, h.count)
+	}
+
+	return out.String()
+}
+
+// sortedKeys returns the keys of the given map, sorted.
+func sortedKeys(values map[string]uint64) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escapeLabel escapes the given label value, as the text format requires.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
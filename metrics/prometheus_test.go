@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape returns the output of the /metrics handler.
+func scrape(t *testing.T, m *Prometheus) string {
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %s", recorder.Header().Get("Content-Type"))
+	}
+
+	body, _ := ioutil.ReadAll(recorder.Body)
+	return string(body)
+}
+
+func TestCounters(t *testing.T) {
+	m := NewPrometheus()
+
+	out := scrape(t, m)
+	if !strings.Contains(out, "# TYPE overseer_tests_executed_total counter") || strings.Contains(out, `type="http"`) {
+		t.Errorf("expected empty counters, got\n%s", out)
+	}
+
+	m.Inc(TestsExecuted, "http")
+	m.Inc(TestsExecuted, "http")
+	m.Inc(TestsPassed, "http")
+	m.Inc(TestsFailed, "http")
+	m.Add(TestsRetried, "http", 4)
+	m.Inc(TestsExecuted, "ssh")
+	m.Inc(ResultsDeduplicated, "ssh")
+	m.Inc(ResultsRecovered, "ssh")
+
+	out = scrape(t, m)
+	for _, expected := range []string{
+		`overseer_tests_executed_total{type="http"} 2`,
+		`overseer_tests_executed_total{type="ssh"} 1`,
+		`overseer_tests_passed_total{type="http"} 1`,
+		`overseer_tests_failed_total{type="http"} 1`,
+		`overseer_tests_retried_total{type="http"} 4`,
+		`overseer_results_deduplicated_total{type="ssh"} 1`,
+		`overseer_results_recovered_total{type="ssh"} 1`,
+	} {
+		if !strings.Contains(out, expected+"\n") {
+			t.Errorf("expected '%s' in\n%s", expected, out)
+		}
+	}
+}
+
+func TestDurations(t *testing.T) {
+	m := NewPrometheus()
+	m.ObserveDuration("http", 20*time.Millisecond)
+	m.ObserveDuration("http", 3*time.Second)
+	m.ObserveDuration("http", 2*time.Minute)
+
+	out := scrape(t, m)
+	for _, expected := range []string{
+		"# TYPE overseer_test_duration_seconds histogram",
+		`overseer_test_duration_seconds_bucket{type="http",le="0.01"} 0`,
+		`overseer_test_duration_seconds_bucket{type="http",le="0.025"} 1`,
+		`overseer_test_duration_seconds_bucket{type="http",le="5"} 2`,
+		`overseer_test_duration_seconds_bucket{type="http",le="60"} 2`,
+		`overseer_test_duration_seconds_bucket{type="http",le="+Inf"} 3`,
+		`overseer_test_duration_seconds_sum{type="http"} 123.02`,
+		`overseer_test_duration_seconds_count{type="http"} 3`,
+	} {
+		if !strings.Contains(out, expected+"\n") {
+			t.Errorf("expected '%s' in\n%s", expected, out)
+		}
+	}
+}
+
+func TestNil(t *testing.T) {
+	var m *Prometheus
+	m.Inc(TestsExecuted, "http")
+	m.ObserveDuration("http", time.Second)
+	if m.String() != "" {
+		t.Errorf("expected no output for disabled metrics")
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if escaped := escapeLabel("a\"b\\c\nd"); escaped != `a\"b\\c\nd` {
+		t.Errorf("unexpected escaping %s", escaped)
+	}
+}
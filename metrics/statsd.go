@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"net"
+	"strings"
+)
+
+// statsDMaxPacket is the largest payload sent in a single datagram, so
+// that it isn't fragmented on a typical network.
+const statsDMaxPacket = 1432
+
+// StatsD sends metrics to a StatsD server, e.g. a Datadog agent, over
+// UDP.
+type StatsD struct {
+	conn net.Conn
+}
+
+// NewStatsD returns a client sending metrics to the StatsD server at the
+// given address, as host:port.
+func NewStatsD(address string) (*StatsD, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsD{conn: conn}, nil
+}
+
+// StatsDLines returns the given values, keyed by metric name, as StatsD
+// lines sorted by name.
+//
+// The attempts and the other counts of events are counters, while
+// everything else, i.e. the durations in milliseconds, are timers.
+func StatsDLines(values map[string]string) []string {
+	names := SortedNames(values)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		kind := "ms"
+		if testCounters[name[strings.LastIndex(name, ".")+1:]] {
+			kind = "c"
+		}
+		lines = append(lines, name+":"+values[name]+"|"+kind)
+	}
+	return lines
+}
+
+// Send sends the given values, keyed by metric name, joining as many
+// lines as possible in each datagram.
+func (s *StatsD) Send(values map[string]string) error {
+	var packet []byte
+	for _, line := range StatsDLines(values) {
+		if len(packet) > 0 && len(packet)+1+len(line) > statsDMaxPacket {
+			if _, err := s.conn.Write(packet); err != nil {
+				return err
+			}
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+
+	if len(packet) > 0 {
+		_, err := s.conn.Write(packet)
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection to the server.
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsDLines(t *testing.T) {
+	lines := StatsDLines(map[string]string{
+		"overseer.test.http.example_com.duration": "12.500000",
+		"overseer.dns.example_com.duration":       "1.200000",
+		"overseer.test.http.example_com.attempts": "2",
+		"overseer.test.http.example_com.failure":  "1",
+	})
+
+	expected := []string{
+		"overseer.dns.example_com.duration:1.200000|ms",
+		"overseer.test.http.example_com.attempts:2|c",
+		"overseer.test.http.example_com.duration:12.500000|ms",
+		"overseer.test.http.example_com.failure:1|c",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+}
+
+func TestStatsDSend(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	defer listener.Close()
+
+	s, err := NewStatsD(listener.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("failed to create the client: %s", err)
+	}
+	defer s.Close()
+
+	// read returns the datagrams received, until none arrive for a while.
+	read := func() []string {
+		var packets []string
+		buf := make([]byte, 65536)
+		for {
+			listener.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, _, errRead := listener.ReadFrom(buf)
+			if errRead != nil {
+				return packets
+			}
+			packets = append(packets, string(buf[:n]))
+		}
+	}
+
+	err = s.Send(map[string]string{
+		"overseer.test.http.example_com.duration": "12.500000",
+		"overseer.test.http.example_com.attempts": "1",
+	})
+	if err != nil {
+		t.Fatalf("failed to send: %s", err)
+	}
+
+	packets := read()
+	expected := "overseer.test.http.example_com.attempts:1|c\noverseer.test.http.example_com.duration:12.500000|ms"
+	if len(packets) != 1 || packets[0] != expected {
+		t.Errorf("expected %q, got %q", expected, packets)
+	}
+
+	// Many values are split across datagrams, without breaking lines.
+	values := map[string]string{}
+	for i := 0; i < 100; i++ {
+		values[fmt.Sprintf("overseer.test.http.host%03d_example_com.duration", i)] = "10.000000"
+	}
+	if err = s.Send(values); err != nil {
+		t.Fatalf("failed to send: %s", err)
+	}
+
+	packets = read()
+	if len(packets) < 2 {
+		t.Fatalf("expected multiple datagrams, got %d", len(packets))
+	}
+	var lines []string
+	for _, packet := range packets {
+		if len(packet) > statsDMaxPacket {
+			t.Errorf("datagram of %d bytes is too large", len(packet))
+		}
+		lines = append(lines, strings.Split(packet, "\n")...)
+	}
+	if !reflect.DeepEqual(lines, StatsDLines(values)) {
+		t.Errorf("expected all the lines to be received in order, got %v", lines)
+	}
+}
@@ -18,24 +18,62 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/cmaster11/overseer/protocols"
 	"github.com/cmaster11/overseer/test"
 	"github.com/cmaster11/overseer/utils"
 )
 
+// ErrEmptyLine is returned by ParseLine for lines holding no test, being
+// blank or only a comment, which are meant to be ignored.
+var ErrEmptyLine = errors.New("empty line")
+
+// notifyTarget matches the targets of the "notify" argument, as "bridge"
+// or "bridge:destination".
+var notifyTarget = regexp.MustCompile(`^[a-z0-9-]+(:\S+)?$`)
+
+// includeDirective matches the lines including another file of tests.
+var includeDirective = regexp.MustCompile(`^include\s+('[^']+'|"[^"]+"|\S+)$`)
+
+// hostsFileArgument matches the "hosts-file" argument, which is removed
+// from the tests it expands to.
+var hostsFileArgument = regexp.MustCompile(`\s+with\s+hosts-file\s+('[^']*'|"[^"]*"|\S+)`)
+
+// hostsFile holds the hosts listed by a hosts-file, as of the time it
+// was read.
+type hostsFile struct {
+	modTime time.Time
+	size    int64
+	hosts   []string
+}
+
 // Parser holds our parser-state.
 type Parser struct {
 	// Storage for defined macros.
 	//
 	// Macros comprise of a name and a list of hostnames.
 	MACROS map[string][]string
+
+	// The hosts-files referenced by the tests, which are read again
+	// once they change.
+	hostsFiles map[string]hostsFile
+	hostsLock  sync.Mutex
+
+	// When linting, the invalid lines are collected here, rather than
+	// stopping the parsing.
+	linting    bool
+	lintErrors []error
 }
 
 // ParsedTest is the function-signature of a callback function
@@ -46,6 +84,7 @@ type ParsedTest func(x test.Test) error
 func New() *Parser {
 	m := new(Parser)
 	m.MACROS = make(map[string][]string)
+	m.hostsFiles = make(map[string]hostsFile)
 	return m
 }
 
@@ -72,7 +111,43 @@ func (s *Parser) executable(path string) (bool, error) {
 
 // ParseFile processes the filename specified, invoking the supplied
 // callback for every test-case which has been successfully parsed.
+//
+// Files named *.yaml or *.yml are parsed as YAML, see ParseYAML.
+//
+// Other files can include more tests from further files, with lines
+// like:
+//
+//    include path/to/other.txt
+//
+// Relative paths are relative to the directory of the including file,
+// and errors are reported along with the file and line they come from.
 func (s *Parser) ParseFile(filename string, cb ParsedTest) error {
+	return s.parseFile(filename, cb, nil)
+}
+
+// parseFile processes the filename specified, which was included by the
+// given chain of files, if any.
+func (s *Parser) parseFile(filename string, cb ParsedTest, including []string) error {
+
+	//
+	// Make sure we're not including ourselves, directly or not.
+	//
+	if filename != "-" {
+		abs, err := filepath.Abs(filename)
+		if err != nil {
+			return err
+		}
+		for i, parent := range including {
+			if parent == abs {
+				return fmt.Errorf("include cycle: %s", strings.Join(append(including[i:], abs), " -> "))
+			}
+		}
+		including = append(including, abs)
+	}
+
+	if strings.HasSuffix(filename, ".yaml") || strings.HasSuffix(filename, ".yml") {
+		return s.parseYAMLFile(filename, cb)
+	}
 
 	// This is the scanner we'll use
 	var scanner *bufio.Scanner
@@ -117,6 +192,13 @@ func (s *Parser) ParseFile(filename string, cb ParsedTest) error {
 	//
 	line := ""
 
+	//
+	// The number of the line we're reading, and of the one the current
+	// test started on, for our errors.
+	//
+	lineNo := 0
+	startNo := 0
+
 	//
 	// Loop
 	//
@@ -128,6 +210,11 @@ func (s *Parser) ParseFile(filename string, cb ParsedTest) error {
 		tmp := scanner.Text()
 		tmp = strings.TrimSpace(tmp)
 
+		lineNo++
+		if line == "" {
+			startNo = lineNo
+		}
+
 		//
 		// Append to our existing line.
 		//
@@ -145,16 +232,25 @@ func (s *Parser) ParseFile(filename string, cb ParsedTest) error {
 		//
 		// OK we've either got a line that doesn't end
 		// with this, or we'll add
-		line = strings.TrimSpace(line)
+		line = stripComment(line)
 
 		//
-		// If the line wasn't empty, and didn't start with
-		// a comment then process it.
+		// If the line wasn't empty, or only a comment, then
+		// process it.
 		//
-		if (line != "") && (!strings.HasPrefix(line, "#")) {
-			_, err := s.ParseLine(line, cb)
+		if line != "" {
+			var err error
+			if include := includeDirective.FindStringSubmatch(line); include != nil {
+				err = s.parseFile(s.includePath(filename, include[1]), cb, including)
+			} else {
+				_, err = s.ParseLine(line, cb)
+			}
 			if err != nil {
-				return err
+				err = fmt.Errorf("%s:%d: %s", filename, startNo, err.Error())
+				if !s.linting {
+					return err
+				}
+				s.lintErrors = append(s.lintErrors, err)
 			}
 		}
 
@@ -177,6 +273,69 @@ func (s *Parser) ParseFile(filename string, cb ParsedTest) error {
 	return nil
 }
 
+// LintFile parses the given file just like ParseFile, without invoking
+// any callback, but rather than stopping at the first invalid test it
+// returns the errors of all of them, along with the number of valid tests
+// found.
+//
+// The errors mention the file and line they come from, including the ones
+// found in the files included.
+func (s *Parser) LintFile(filename string) (int, []error) {
+	s.linting = true
+	s.lintErrors = nil
+	defer func() {
+		s.linting = false
+	}()
+
+	count := 0
+	err := s.ParseFile(filename, func(test.Test) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		s.lintErrors = append(s.lintErrors, err)
+	}
+
+	return count, s.lintErrors
+}
+
+// includePath returns the path of the file included by the given one,
+// relative to its directory unless absolute.
+func (s *Parser) includePath(including string, path string) string {
+	path = s.TrimQuotes(path, '\'')
+	path = s.TrimQuotes(path, '"')
+
+	if filepath.IsAbs(path) || including == "-" {
+		return path
+	}
+	return filepath.Join(filepath.Dir(including), path)
+}
+
+// stripComment removes the comment from the given line, if any, along
+// with the leading and trailing space.
+//
+// Comments either take the whole line, or follow the test after some
+// space, as in "example.com must run ping # Comment": a "#" within a
+// quoted argument-value, or without any space before it, is kept.
+func stripComment(line string) string {
+	var quote rune
+	prev := ' '
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '\'' || c == '"') && unicode.IsSpace(prev):
+			quote = c
+		case c == '#' && unicode.IsSpace(prev):
+			return strings.TrimSpace(line[:i])
+		}
+		prev = c
+	}
+	return strings.TrimSpace(line)
+}
+
 // ParseLine parses a single line of text, and invokes the supplied callback
 // function if a valid test was found.
 func (s *Parser) ParseLine(input string, cb ParsedTest) (test.Test, error) {
@@ -186,6 +345,14 @@ func (s *Parser) ParseLine(input string, cb ParsedTest) (test.Test, error) {
 	//
 	var result test.Test
 
+	//
+	// Blank lines and comments hold no test.
+	//
+	input = stripComment(input)
+	if input == "" {
+		return result, ErrEmptyLine
+	}
+
 	//
 	// Our input will contain lines of two forms:
 	//
@@ -256,6 +423,19 @@ func (s *Parser) ParseLine(input string, cb ParsedTest) (test.Test, error) {
 		return result, fmt.Errorf("unknown test-type '%s' in input '%s'", testType, input)
 	}
 
+	//
+	// Does this test run against the hosts listed in a file?
+	//
+	// If so we expand it for each of them, just as for a macro, which
+	// requires a callback to receive the expanded tests.
+	//
+	if filename := s.ParseArguments(input)["hosts-file"]; filename != "" {
+		if cb == nil {
+			return result, fmt.Errorf("the hosts-file argument expands into several tests, which can't be run on their own, in input '%s'", input)
+		}
+		return result, s.expandHostsFile(input, testTarget, filename, cb)
+	}
+
 	//
 	// Is this target a macro?
 	//
@@ -429,6 +609,76 @@ func (s *Parser) ParseLine(input string, cb ParsedTest) (test.Test, error) {
 
 			result.MaxTargetsCount = int(maxTargets)
 
+			// We don't want to pass a non-test var to the actual test
+			delete(result.Arguments, arg)
+			continue
+		case "ip-version":
+			if val != "4" && val != "6" {
+				return result, fmt.Errorf("argument '%s' for test-type '%s' in input '%s' must be 4 or 6", arg, testType, input)
+			}
+
+			result.IPVersion, _ = strconv.Atoi(val)
+
+			// We don't want to pass a non-test var to the actual test
+			delete(result.Arguments, arg)
+			continue
+		case "priority":
+			priority, err := strconv.ParseInt(val, 10, 32)
+			if err != nil {
+				return result, fmt.Errorf("non-numeric argument '%s' for test-type '%s' in input '%s'", arg, testType, input)
+			}
+			if priority < 1 || priority > 10 {
+				return result, fmt.Errorf("argument '%s' for test-type '%s' in input '%s' must be between 1 and 10", arg, testType, input)
+			}
+
+			result.Priority = int(priority)
+
+			// We don't want to pass a non-test var to the actual test
+			delete(result.Arguments, arg)
+			continue
+		case "pops":
+			for _, pop := range strings.Split(val, ",") {
+				pop = strings.TrimSpace(pop)
+				if net.ParseIP(pop) == nil {
+					return result, fmt.Errorf("invalid IP address '%s' in argument '%s' for test-type '%s' in input '%s'", pop, arg, testType, input)
+				}
+				result.Pops = append(result.Pops, pop)
+			}
+
+			// We don't want to pass a non-test var to the actual test
+			delete(result.Arguments, arg)
+			continue
+		case "tag":
+			result.Tag = val
+
+			// We don't want to pass a non-test var to the actual test
+			delete(result.Arguments, arg)
+			continue
+		case "name":
+			result.Name = val
+
+			// We don't want to pass a non-test var to the actual test
+			delete(result.Arguments, arg)
+			continue
+		case "notify":
+			for _, target := range strings.Split(val, ",") {
+				target = strings.TrimSpace(target)
+				if !notifyTarget.MatchString(target) {
+					return result, fmt.Errorf("invalid notification target '%s' in argument '%s' for test-type '%s' in input '%s'", target, arg, testType, input)
+				}
+				result.NotifyTargets = append(result.NotifyTargets, target)
+			}
+
+			// We don't want to pass a non-test var to the actual test
+			delete(result.Arguments, arg)
+			continue
+		case "on-fail-exec":
+			if !filepath.IsAbs(val) {
+				return result, fmt.Errorf("argument '%s' for test-type '%s' in input '%s' must be an absolute path", arg, testType, input)
+			}
+
+			result.OnFailExec = val
+
 			// We don't want to pass a non-test var to the actual test
 			delete(result.Arguments, arg)
 			continue
@@ -468,6 +718,74 @@ func (s *Parser) ParseLine(input string, cb ParsedTest) (test.Test, error) {
 	return result, nil
 }
 
+// expandHostsFile parses the given test once for each host listed in the
+// given file, with the host replacing the "{host}" placeholder of the
+// target, or the whole target if it has none.
+func (s *Parser) expandHostsFile(input string, testTarget string, filename string, cb ParsedTest) error {
+	hosts, err := s.readHostsFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read the hosts-file in input '%s': %s", input, err.Error())
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("the hosts-file %s in input '%s' lists no hosts", filename, input)
+	}
+
+	rest := strings.TrimPrefix(hostsFileArgument.ReplaceAllString(input, ""), testTarget)
+
+	for _, host := range hosts {
+		target := host
+		if strings.Contains(testTarget, "{host}") {
+			target = strings.Replace(testTarget, "{host}", host, -1)
+		}
+
+		if _, err = s.ParseLine(target+rest, cb); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readHostsFile returns the hosts listed in the given file, one per line,
+// ignoring empty lines and comments.
+//
+// The file is only read again once it changes.
+func (s *Parser) readHostsFile(filename string) ([]string, error) {
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Jobs are parsed by many workers at the same time.
+	s.hostsLock.Lock()
+	defer s.hostsLock.Unlock()
+
+	cached, ok := s.hostsFiles[filename]
+	if ok && cached.modTime.Equal(stat.ModTime()) && cached.size == stat.Size() {
+		return cached.hosts, nil
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			hosts = append(hosts, line)
+		}
+	}
+
+	if s.hostsFiles == nil {
+		s.hostsFiles = make(map[string]hostsFile)
+	}
+	s.hostsFiles[filename] = hostsFile{modTime: stat.ModTime(), size: stat.Size(), hosts: hosts}
+
+	return hosts, nil
+}
+
 // TrimQuotes removes matching quotes from around a string, if present.
 //
 // For example `'steve'` becomes `steve`, but `'steve` stays unchanged,
@@ -520,8 +838,13 @@ func (s *Parser) ParseArguments(input string) map[string]string {
 		// finally "bar".  We skip this because of the non-empty
 		// test here, which means the last value is kept.
 		//
+		// Arguments which can be given multiple times keep all their
+		// values instead, in order.
+		//
 		if res[name] == "" {
 			res[name] = value
+		} else if test.RepeatableArguments[name] {
+			res[name] = value + test.ArgumentSeparator + res[name]
 		}
 
 		// Continue matching the tail of the string.
@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cmaster11/overseer/test"
+	"gopkg.in/yaml.v2"
+)
+
+// yamlWith matches the values which would be mistaken for the start of
+// another argument in the line form of a test.
+var yamlWith = regexp.MustCompile(`\swith\s`)
+
+// yamlTest is a test, as written in YAML:
+//
+//    - target: https://example.com/
+//      type: http
+//      arguments:
+//        status: 200
+//        header:
+//          - 'X-Api-Key: abc'
+//          - 'Accept: application/json'
+//      retries: 3
+//      dedup: 5m
+//      tag: prod
+//
+// Any argument can be given via the arguments, the other fields are just
+// a more readable way to write the common ones.
+type yamlTest struct {
+	Target    string                 `yaml:"target"`
+	Type      string                 `yaml:"type"`
+	Arguments map[string]interface{} `yaml:"arguments"`
+	Retries   *uint                  `yaml:"retries"`
+	Dedup     string                 `yaml:"dedup"`
+	Timeout   string                 `yaml:"timeout"`
+	Tag       string                 `yaml:"tag"`
+	Name      string                 `yaml:"name"`
+	Period    *yamlPeriod            `yaml:"period"`
+}
+
+// yamlPeriod is the definition of a period-test, in YAML.
+type yamlPeriod struct {
+	Duration  string `yaml:"duration"`
+	Sleep     string `yaml:"sleep"`
+	Threshold string `yaml:"threshold"`
+}
+
+// line returns the test in its canonical line form, with the arguments
+// sorted and quoted.
+func (y yamlTest) line() (string, error) {
+	if y.Target == "" || strings.ContainsAny(y.Target, " \t\n") {
+		return "", fmt.Errorf("invalid target '%s'", y.Target)
+	}
+	if y.Type == "" || strings.ContainsAny(y.Type, " \t\n") {
+		return "", fmt.Errorf("invalid type '%s' for target '%s'", y.Type, y.Target)
+	}
+
+	args := make(map[string][]string)
+	for name, value := range y.Arguments {
+		switch v := value.(type) {
+		case []interface{}:
+			if len(v) > 1 && !test.RepeatableArguments[name] {
+				return "", fmt.Errorf("argument '%s' for target '%s' can't be repeated", name, y.Target)
+			}
+			for _, item := range v {
+				args[name] = append(args[name], fmt.Sprint(item))
+			}
+		case map[interface{}]interface{}:
+			return "", fmt.Errorf("argument '%s' for target '%s' must be a value or a list of values", name, y.Target)
+		default:
+			args[name] = []string{fmt.Sprint(v)}
+		}
+	}
+
+	// The common arguments, which have their own fields.
+	fields := map[string]string{
+		"dedup":   y.Dedup,
+		"timeout": y.Timeout,
+		"tag":     y.Tag,
+		"name":    y.Name,
+	}
+	if y.Retries != nil {
+		fields["retries"] = fmt.Sprint(*y.Retries)
+	}
+	if y.Period != nil {
+		fields["pt-duration"] = y.Period.Duration
+		fields["pt-sleep"] = y.Period.Sleep
+		fields["pt-threshold"] = y.Period.Threshold
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if _, ok := args[name]; ok {
+			return "", fmt.Errorf("argument '%s' for target '%s' is given twice", name, y.Target)
+		}
+		args[name] = []string{value}
+	}
+
+	var names []string
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	line := fmt.Sprintf("%s must run %s", y.Target, y.Type)
+	for _, name := range names {
+		for _, value := range args[name] {
+			quoted, err := quoteArgument(value)
+			if err != nil {
+				return "", fmt.Errorf("argument '%s' for target '%s' %s", name, y.Target, err.Error())
+			}
+			line += fmt.Sprintf(" with %s %s", name, quoted)
+		}
+	}
+	return line, nil
+}
+
+// quoteArgument quotes the given value of an argument so that it is
+// parsed back as it is.
+func quoteArgument(value string) (string, error) {
+	if strings.Contains(value, "\n") {
+		return "", fmt.Errorf("can't contain a newline")
+	}
+	if yamlWith.MatchString(value) {
+		return "", fmt.Errorf("can't contain ' with '")
+	}
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'", nil
+	}
+	if !strings.Contains(value, "\"") {
+		return "\"" + value + "\"", nil
+	}
+	return "", fmt.Errorf("can't contain both single and double quotes")
+}
+
+// ParseYAML parses the tests defined in the given YAML document, a list of
+// tests, returning them just as if they had been written as lines.
+//
+// Each test is converted to its canonical line form, which becomes its
+// Input, and then parsed as such: macros and hosts-files are expanded
+// as usual.
+func (s *Parser) ParseYAML(data []byte) ([]test.Test, error) {
+	var definitions []yamlTest
+	if err := yaml.UnmarshalStrict(data, &definitions); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %s", err.Error())
+	}
+
+	var tests []test.Test
+	collect := func(tst test.Test) error {
+		tests = append(tests, tst)
+		return nil
+	}
+
+	for i, definition := range definitions {
+		line, err := definition.line()
+		if err != nil {
+			return nil, fmt.Errorf("test %d: %s", i+1, err.Error())
+		}
+		if _, err = s.ParseLine(line, collect); err != nil {
+			return nil, fmt.Errorf("test %d: %s", i+1, err.Error())
+		}
+	}
+
+	return tests, nil
+}
+
+// parseYAMLFile parses the tests defined in the given YAML file, invoking
+// the supplied callback for each of them.
+func (s *Parser) parseYAMLFile(filename string, cb ParsedTest) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("error opening %s - %s", filename, err.Error())
+	}
+
+	tests, err := s.ParseYAML(data)
+	if err != nil {
+		return fmt.Errorf("error parsing %s - %s", filename, err.Error())
+	}
+
+	if cb != nil {
+		for _, tst := range tests {
+			if err = cb(tst); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// Test that YAML tests are parsed exactly as their line form.
+func TestYAMLRoundTrip(t *testing.T) {
+	yaml := `
+- target: https://example.com/
+  type: http
+  arguments:
+    status: 200
+    content: "it's ok"
+    header:
+      - 'X-Api-Key: abc'
+      - 'Accept: application/json'
+  retries: 3
+  dedup: 5m
+  tag: prod
+  name: Home page
+
+- target: example.com
+  type: ping
+  timeout: 10s
+  period:
+    duration: 1m
+    sleep: 10s
+    threshold: 20%
+`
+	lines := []string{
+		`https://example.com/ must run http with content "it's ok" with dedup '5m' with header 'X-Api-Key: abc' with header 'Accept: application/json' with name 'Home page' with retries '3' with status '200' with tag 'prod'`,
+		`example.com must run ping with pt-duration '1m' with pt-sleep '10s' with pt-threshold '20%' with timeout '10s'`,
+	}
+
+	tests, err := New().ParseYAML([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Error parsing YAML: %s", err.Error())
+	}
+	if len(tests) != len(lines) {
+		t.Fatalf("Expected %d tests, got %d", len(lines), len(tests))
+	}
+
+	for i, line := range lines {
+		expected, errLine := New().ParseLine(line, nil)
+		if errLine != nil {
+			t.Fatalf("Error parsing %s - %s", line, errLine.Error())
+		}
+		if !reflect.DeepEqual(tests[i], expected) {
+			t.Errorf("YAML test %d differs from its line:\n%+v\n%+v", i+1, tests[i], expected)
+		}
+	}
+
+	if *tests[0].MaxRetries != 3 || tests[0].Tag != "prod" || tests[0].Name != "Home page" || len(tests[0].ArgumentValues("header")) != 2 {
+		t.Errorf("Unexpected test %+v", tests[0])
+	}
+}
+
+// Test that YAML files are expanded like any other file.
+func TestYAMLFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overseer")
+	if err != nil {
+		t.Fatalf("Error creating temporary-directory %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	hosts := filepath.Join(dir, "hosts.txt")
+	ioutil.WriteFile(hosts, []byte("a.example.com\nb.example.com\n"), 0644)
+
+	file := filepath.Join(dir, "tests.yaml")
+	ioutil.WriteFile(file, []byte(`
+- target: https://{host}/
+  type: http
+  arguments:
+    hosts-file: `+hosts+`
+`), 0644)
+
+	var targets []string
+	err = New().ParseFile(file, func(tst test.Test) error {
+		targets = append(targets, tst.Target)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error parsing %s - %s", file, err.Error())
+	}
+
+	if !reflect.DeepEqual(targets, []string{"https://a.example.com/", "https://b.example.com/"}) {
+		t.Errorf("Unexpected targets %v", targets)
+	}
+}
+
+// Test that invalid YAML tests are reported.
+func TestYAMLInvalid(t *testing.T) {
+	tests := map[string]string{
+		"- target: example.com\n  type: nope\n":                                          "unknown test-type 'nope'",
+		"- target: example.com\n  type: http\n  retry: 3\n":                              "field retry not found",
+		"- target: example.com\n  type: http\n  arguments:\n    status: [200, 301]\n":    "argument 'status' for target 'example.com' can't be repeated",
+		"- target: example.com\n  type: http\n  tag: a\n  arguments:\n    tag: b\n":      "argument 'tag' for target 'example.com' is given twice",
+		"- target: example.com\n  type: http\n  arguments:\n    content: \"a with b\"\n": "can't contain ' with '",
+		"- target: example.com\n  type: http\n  arguments:\n    content: \"'\\\"\"\n":    "can't contain both single and double quotes",
+		"- type: http\n": "test 1: invalid target ''",
+		"- target: example.com\n  type: http\n- target: example.com\n  type: http\n  foo: 1\n": "field foo not found",
+	}
+
+	for yaml, expected := range tests {
+		_, err := New().ParseYAML([]byte(yaml))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an error containing '%s' parsing %q, got %v", expected, yaml, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package protocols
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// certificateKey returns the type, and size in bits, of the public key
+// of the given certificate.
+func certificateKey(cert *x509.Certificate) (string, int) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	}
+	return "unknown", 0
+}
+
+// checkCertificateKey ensures the leaf certificate of the given TLS
+// connection-state uses the key-type, and at least the number of bits,
+// required by the `key-type` and `min-key-bits` arguments of the test.
+func checkCertificateKey(tst test.Test, state *tls.ConnectionState) error {
+
+	if tst.Arguments["key-type"] == "" && tst.Arguments["min-key-bits"] == "" {
+		return nil
+	}
+
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("certificate key requirements given, but the connection is not using TLS")
+	}
+
+	keyType, bits := certificateKey(state.PeerCertificates[0])
+
+	if tst.Arguments["key-type"] != "" && !strings.EqualFold(tst.Arguments["key-type"], keyType) {
+		return fmt.Errorf("certificate key is %s (%d bits), not %s", keyType, bits, tst.Arguments["key-type"])
+	}
+
+	if tst.Arguments["min-key-bits"] != "" {
+		minBits, err := strconv.Atoi(tst.Arguments["min-key-bits"])
+		if err != nil {
+			return err
+		}
+		if bits < minBits {
+			return fmt.Errorf("certificate %s key has %d bits, less than %d", keyType, bits, minBits)
+		}
+	}
+
+	return nil
+}
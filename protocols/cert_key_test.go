@@ -0,0 +1,54 @@
+package protocols
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+func TestCertificateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	rsaState := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{PublicKey: &rsaKey.PublicKey}}}
+	ecdsaState := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{PublicKey: &ecdsaKey.PublicKey}}}
+
+	type TestCase struct {
+		state *tls.ConnectionState
+		args  map[string]string
+		fail  bool
+	}
+
+	tests := []TestCase{
+		{rsaState, map[string]string{}, false},
+		{rsaState, map[string]string{"key-type": "RSA"}, false},
+		{rsaState, map[string]string{"key-type": "rsa", "min-key-bits": "1024"}, false},
+		{rsaState, map[string]string{"min-key-bits": "2048"}, true},
+		{rsaState, map[string]string{"key-type": "ECDSA"}, true},
+		{ecdsaState, map[string]string{"key-type": "ECDSA", "min-key-bits": "256"}, false},
+		{ecdsaState, map[string]string{"min-key-bits": "384"}, true},
+		{nil, map[string]string{"key-type": "RSA"}, true},
+	}
+
+	for _, tc := range tests {
+		err := checkCertificateKey(test.Test{Arguments: tc.args}, tc.state)
+		if tc.fail && err == nil {
+			t.Errorf("expected %v to fail", tc.args)
+		}
+		if !tc.fail && err != nil {
+			t.Errorf("expected %v to pass, got %s", tc.args, err)
+		}
+	}
+}
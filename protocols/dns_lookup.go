@@ -0,0 +1,123 @@
+package protocols
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// dnsClient is the dnsResolver which performs real DNS queries.
+type dnsClient struct {
+}
+
+// Lookup will perform a DNS query, using the servername-specified, or the
+// first nameserver of the system if that is empty.
+func (c *dnsClient) Lookup(server string, name string, ltype string, timeout time.Duration) ([]string, error) {
+
+	var results []string
+
+	if server == "" {
+		config, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the system nameserver: %s", err.Error())
+		}
+		if len(config.Servers) == 0 {
+			return nil, fmt.Errorf("no nameserver configured in /etc/resolv.conf")
+		}
+		server = config.Servers[0]
+	}
+
+	r, err := c.query(server, dns.Fqdn(name), ltype, timeout)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	if r.Rcode == dns.RcodeNameError {
+		return nil, fmt.Errorf("no such domain %s", dns.Fqdn(name))
+	}
+
+	for _, entry := range r.Answer {
+
+		//
+		// Lookup the value
+		//
+		switch ent := entry.(type) {
+		case *dns.A:
+			a := ent.A
+			results = append(results, a.String())
+		case *dns.AAAA:
+			aaaa := ent.AAAA
+			results = append(results, aaaa.String())
+		case *dns.CNAME:
+			results = append(results, ent.Target)
+		case *dns.MX:
+			mxName := ent.Mx
+			mxPrio := ent.Preference
+			results = append(results, fmt.Sprintf("%d %s", mxPrio, mxName))
+		case *dns.NS:
+			nameserver := ent.Ns
+			results = append(results, nameserver)
+		case *dns.TXT:
+			txt := ent.Txt
+			results = append(results, txt[0])
+		}
+	}
+	return results, nil
+}
+
+// Given a name & type to lookup perform the request against the named
+// DNS-server.
+func (c *dnsClient) query(server string, qname string, lookupType string, timeout time.Duration) (*dns.Msg, error) {
+
+	// Here we have a map of DNS type-names.
+	var StringToType = map[string]uint16{
+		"A":     dns.TypeA,
+		"AAAA":  dns.TypeAAAA,
+		"CNAME": dns.TypeCNAME,
+		"MX":    dns.TypeMX,
+		"NS":    dns.TypeNS,
+		"TXT":   dns.TypeTXT,
+	}
+
+	qtype := StringToType[lookupType]
+	if qtype == 0 {
+		return nil, fmt.Errorf("unsupported record to lookup '%s'", lookupType)
+	}
+
+	m := &dns.Msg{
+		MsgHdr: dns.MsgHdr{
+			RecursionDesired: true,
+		},
+		Question: make([]dns.Question, 1),
+	}
+	m.SetQuestion(qname, qtype)
+
+	client := &dns.Client{
+		ReadTimeout: timeout,
+	}
+
+	//
+	// Default to connecting to an IPv4-address
+	//
+	address := fmt.Sprintf("%s:%d", server, 53)
+
+	//
+	// If we find a ":" we know it is an IPv6 address though
+	//
+	if strings.Contains(server, ":") {
+		address = fmt.Sprintf("[%s]:%d", server, 53)
+	}
+
+	//
+	// Run the lookup
+	//
+	r, _, err := client.Exchange(m, address)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil || r.Rcode == dns.RcodeNameError || r.Rcode == dns.RcodeSuccess {
+		return r, err
+	}
+	return nil, nil
+}
@@ -1,14 +1,26 @@
 // DNS Tester
 //
-// The DNS tester allows you to confirm that the specified DNS server
-// returns the results you expect.  It is invoked with input like this:
+// The DNS tester allows you to confirm that DNS lookups return the results
+// you expect.  It is invoked with input like this:
 //
-//    ns.example.com must run dns with lookup test.example.com with type A with result '1.2.3.4'
+//    example.com must run dns with type A with expected '1.2.3.4'
+//
+// This test ensures that the DNS lookup of an A record for `example.com`
+// returns the value 1.2.3.4, along with any others.  Multiple values can
+// be listed, separated by commas, and all of them must be present.
+//
+// Lookups are supported for A, AAAA, CNAME, MX, NS, and TXT records.
+//
+// By default the first nameserver of the system is queried, you can query
+// a specific one instead:
+//
+//    example.com must run dns with type MX with expected '10 mx.example.com' with nameserver 8.8.8.8
 //
-// This test ensures that the DNS lookup of an A record for `test.example.com`
-// returns the single value 1.2.3.4
+// Alternatively the target can be the DNS server to test, in which case
+// the name to lookup is specified separately, and the sorted results must
+// be exactly the ones given:
 //
-// Lookups are supported for A, AAAA, MX, NS, and TXT records.
+//    ns.example.com must run dns with lookup test.example.com with type A with result '1.2.3.4'
 //
 
 package protocols
@@ -21,111 +33,18 @@ import (
 	"time"
 
 	"github.com/cmaster11/overseer/test"
-	"github.com/miekg/dns"
 )
 
-// DNSTest is our object.
-type DNSTest struct {
+// dnsResolver performs DNS lookups of a given record-type against a
+// nameserver, returning the values found.
+type dnsResolver interface {
+	Lookup(server string, name string, ltype string, timeout time.Duration) ([]string, error)
 }
 
-var (
-	localm *dns.Msg
-	localc *dns.Client
-)
-
-// lookup will perform a DNS query, using the servername-specified.
-// It returns an array of maps of the response.
-func (s *DNSTest) lookup(server string, name string, ltype string, timeout time.Duration) ([]string, error) {
-
-	var results []string
-
-	var err error
-	localm = &dns.Msg{
-		MsgHdr: dns.MsgHdr{
-			RecursionDesired: true,
-		},
-		Question: make([]dns.Question, 1),
-	}
-	localc = &dns.Client{
-		ReadTimeout: timeout,
-	}
-	r, err := s.localQuery(server, dns.Fqdn(name), ltype)
-	if err != nil || r == nil {
-		return nil, err
-	}
-	if r.Rcode == dns.RcodeNameError {
-		return nil, fmt.Errorf("no such domain %s", dns.Fqdn(name))
-	}
-
-	for _, entry := range r.Answer {
-
-		//
-		// Lookup the value
-		//
-		switch ent := entry.(type) {
-		case *dns.A:
-			a := ent.A
-			results = append(results, a.String())
-		case *dns.AAAA:
-			aaaa := ent.AAAA
-			results = append(results, aaaa.String())
-		case *dns.MX:
-			mxName := ent.Mx
-			mxPrio := ent.Preference
-			results = append(results, fmt.Sprintf("%d %s", mxPrio, mxName))
-		case *dns.NS:
-			nameserver := ent.Ns
-			results = append(results, nameserver)
-		case *dns.TXT:
-			txt := ent.Txt
-			results = append(results, txt[0])
-		}
-	}
-	return results, nil
-}
-
-// Given a name & type to lookup perform the request against the named
-// DNS-server.
-func (s *DNSTest) localQuery(server string, qname string, lookupType string) (*dns.Msg, error) {
-
-	// Here we have a map of DNS type-names.
-	var StringToType = map[string]uint16{
-		"A":    dns.TypeA,
-		"AAAA": dns.TypeAAAA,
-		"MX":   dns.TypeMX,
-		"NS":   dns.TypeNS,
-		"TXT":  dns.TypeTXT,
-	}
-
-	qtype := StringToType[lookupType]
-	if qtype == 0 {
-		return nil, fmt.Errorf("unsupported record to lookup '%s'", lookupType)
-	}
-	localm.SetQuestion(qname, qtype)
-
-	//
-	// Default to connecting to an IPv4-address
-	//
-	address := fmt.Sprintf("%s:%d", server, 53)
-
-	//
-	// If we find a ":" we know it is an IPv6 address though
-	//
-	if strings.Contains(server, ":") {
-		address = fmt.Sprintf("[%s]:%d", server, 53)
-	}
-
-	//
-	// Run the lookup
-	//
-	r, _, err := localc.Exchange(localm, address)
-	if err != nil {
-		return nil, err
-	}
-	if r == nil || r.Rcode == dns.RcodeNameError || r.Rcode == dns.RcodeSuccess {
-		return r, err
-	}
-	return nil, nil
+// DNSTest is our object.
+type DNSTest struct {
+	// resolver performs the lookups, if nil we use real DNS queries.
+	resolver dnsResolver
 }
 
 // Arguments returns the names of arguments which this protocol-test
@@ -134,16 +53,18 @@ func (s *DNSTest) localQuery(server string, qname string, lookupType string) (*d
 func (s *DNSTest) Arguments() map[string]string {
 
 	known := map[string]string{
-		"type":   "A|AAAA|MX|NS|TXT",
-		"lookup": ".*",
-		"result": ".*",
+		"type":       "A|AAAA|CNAME|MX|NS|TXT",
+		"lookup":     ".*",
+		"result":     ".*",
+		"expected":   ".*",
+		"nameserver": ".*",
 	}
 	return known
 }
 
 // ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
 func (s *DNSTest) ShouldResolveHostname() bool {
-	return true
+	return false
 }
 
 // Example returns sample usage-instructions for self-documentation purposes.
@@ -151,38 +72,110 @@ func (s *DNSTest) Example() string {
 	str := `
 DNS Tester
 ----------
- The DNS tester allows you to confirm that the specified DNS server
- returns the results you expect.  It is invoked with input like this:
+ The DNS tester allows you to confirm that DNS lookups return the results
+ you expect.  It is invoked with input like this:
 
-    ns.example.com must run dns with lookup test.example.com with type A with result '1.2.3.4'
+    example.com must run dns with type A with expected '1.2.3.4'
+
+ This test ensures that the DNS lookup of an A record for 'example.com'
+ returns the value 1.2.3.4, along with any others.  Multiple values can
+ be listed, separated by commas, and all of them must be present.
+
+ Lookups are supported for A, AAAA, CNAME, MX, NS, and TXT records.
+
+ By default the first nameserver of the system is queried, you can query
+ a specific one instead:
 
- This test ensures that the DNS lookup of an A record for 'test.example.com'
- returns the single value 1.2.3.4
+    example.com must run dns with type MX with expected '10 mx.example.com' with nameserver 8.8.8.8
 
- Lookups are supported for A, AAAA, MX, NS, and TXT records.  If you expect
- there to be zero returning records, perhaps because you're ensuring that a
- service is IPv4-only you can specify that you require an empty result:
+ Alternatively the target can be the DNS server to test, in which case
+ the name to lookup is specified separately, and the sorted results must
+ be exactly the ones given:
+
+    ns.example.com must run dns with lookup test.example.com with type A with result '1.2.3.4'
+
+ If you expect there to be zero returning records, perhaps because you're
+ ensuring that a service is IPv4-only you can specify that you require an
+ empty result:
 
     rache.ns.cloudflare.com must run dns with lookup alert.steve.fi with type AAAA with result ''
 `
 	return str
 }
 
+// dnsNormalize returns a value found in a DNS answer, or expected by the
+// user, in a form suitable for comparison.
+func dnsNormalize(ltype string, value string) string {
+	value = strings.TrimSpace(value)
+	if ltype == "TXT" {
+		return value
+	}
+	return strings.TrimSuffix(strings.ToLower(value), ".")
+}
+
 // RunTest is the part of our API which is invoked to actually execute a
 // test against the given target.
 //
-// In this case we make a DNS-lookup against the named host, and compare
-// the result with what the user specified.
-// look for a response which appears to be an FTP-server.
+// In this case we make a DNS-lookup, and compare the result with what
+// the user specified.
 func (s *DNSTest) RunTest(tst test.Test, target string, opts test.Options) error {
 
-	if tst.Arguments["lookup"] == "" {
-		return errors.New("no value to lookup specified")
-	}
 	if tst.Arguments["type"] == "" {
 		return errors.New("no record-type to lookup")
 	}
 
+	resolver := s.resolver
+	if resolver == nil {
+		resolver = &dnsClient{}
+	}
+
+	//
+	// If we've been given a name to lookup then the target is the
+	// DNS server we're testing.
+	//
+	if tst.Arguments["lookup"] != "" {
+		return s.testServer(resolver, tst, target, opts)
+	}
+
+	//
+	// Otherwise the target is the name to lookup.
+	//
+	res, err := resolver.Lookup(tst.Arguments["nameserver"], target, tst.Arguments["type"], opts.Timeout)
+	if err != nil {
+		return err
+	}
+
+	if len(res) == 0 {
+		return fmt.Errorf("no %s records found for %s", tst.Arguments["type"], target)
+	}
+
+	found := make(map[string]bool)
+	for _, value := range res {
+		found[dnsNormalize(tst.Arguments["type"], value)] = true
+	}
+
+	var missing []string
+	for _, value := range strings.Split(tst.Arguments["expected"], ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		if !found[dnsNormalize(tst.Arguments["type"], value)] {
+			missing = append(missing, strings.TrimSpace(value))
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(res)
+		return fmt.Errorf("expected DNS result to contain '%s', but found '%s'", strings.Join(missing, ","), strings.Join(res, ","))
+	}
+
+	return nil
+}
+
+// testServer makes a DNS-lookup against the named host, and compares the
+// result with what the user specified.
+func (s *DNSTest) testServer(resolver dnsResolver, tst test.Test, target string, opts test.Options) error {
+
 	//
 	// NOTE:
 	// "result" must also be specified, but it is valid to set that
@@ -192,7 +185,7 @@ func (s *DNSTest) RunTest(tst test.Test, target string, opts test.Options) error
 	//
 	// Run the lookup
 	//
-	res, err := s.lookup(target, tst.Arguments["lookup"], tst.Arguments["type"], opts.Timeout)
+	res, err := resolver.Lookup(target, tst.Arguments["lookup"], tst.Arguments["type"], opts.Timeout)
 	if err != nil {
 		return err
 	}
@@ -210,7 +203,6 @@ func (s *DNSTest) RunTest(tst test.Test, target string, opts test.Options) error
 	}
 
 	return nil
-
 }
 
 // Register our protocol-tester.
@@ -0,0 +1,121 @@
+package protocols
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// stubResolver returns canned answers, keyed by record-type.
+type stubResolver struct {
+	answers map[string][]string
+
+	// The last query we received.
+	server string
+	name   string
+}
+
+func (r *stubResolver) Lookup(server string, name string, ltype string, timeout time.Duration) ([]string, error) {
+	r.server = server
+	r.name = name
+	if ltype == "SOA" {
+		return nil, errors.New("unsupported record to lookup 'SOA'")
+	}
+	return r.answers[ltype], nil
+}
+
+var stubAnswers = map[string][]string{
+	"A":     {"1.2.3.4", "5.6.7.8"},
+	"AAAA":  {"2001:db8::1"},
+	"CNAME": {"www.example.net."},
+	"MX":    {"10 mx1.example.com.", "20 mx2.example.com."},
+	"NS":    {"ns1.example.com.", "ns2.example.com."},
+	"TXT":   {"v=spf1 -all"},
+}
+
+func runDNSTest(resolver dnsResolver, target string, args map[string]string) error {
+	tst := test.Test{Target: target, Type: "dns", Arguments: args}
+	return (&DNSTest{resolver: resolver}).RunTest(tst, target, test.Options{Timeout: time.Second})
+}
+
+func TestDNSExpected(t *testing.T) {
+	resolver := &stubResolver{answers: stubAnswers}
+
+	tests := map[string]string{
+		"A":     "5.6.7.8,1.2.3.4",
+		"AAAA":  "2001:db8::1",
+		"CNAME": "www.example.net",
+		"MX":    "10 mx1.example.com",
+		"NS":    "NS2.example.com.",
+		"TXT":   "v=spf1 -all",
+	}
+
+	for ltype, expected := range tests {
+		err := runDNSTest(resolver, "example.com", map[string]string{"type": ltype, "expected": expected})
+		if err != nil {
+			t.Errorf("expected %s lookup to match '%s', got %s", ltype, expected, err)
+		}
+	}
+
+	if resolver.server != "" || resolver.name != "example.com" {
+		t.Errorf("unexpected query of %s against '%s'", resolver.name, resolver.server)
+	}
+}
+
+func TestDNSMismatch(t *testing.T) {
+	resolver := &stubResolver{answers: stubAnswers}
+
+	tests := map[string]string{
+		"A":     "1.2.3.4,9.9.9.9",
+		"AAAA":  "2001:db8::2",
+		"CNAME": "example.net",
+		"MX":    "30 mx1.example.com",
+		"NS":    "ns3.example.com",
+		"TXT":   "V=SPF1 -all",
+	}
+
+	for ltype, expected := range tests {
+		err := runDNSTest(resolver, "example.com", map[string]string{"type": ltype, "expected": expected})
+		if err == nil {
+			t.Errorf("expected %s lookup not to match '%s'", ltype, expected)
+		}
+	}
+
+	// An empty answer fails too.
+	resolver = &stubResolver{answers: map[string][]string{}}
+	if err := runDNSTest(resolver, "example.com", map[string]string{"type": "A"}); err == nil {
+		t.Errorf("expected an empty answer to fail")
+	}
+}
+
+func TestDNSNameserver(t *testing.T) {
+	resolver := &stubResolver{answers: stubAnswers}
+
+	err := runDNSTest(resolver, "example.com", map[string]string{"type": "A", "expected": "1.2.3.4", "nameserver": "8.8.8.8"})
+	if err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+	if resolver.server != "8.8.8.8" {
+		t.Errorf("expected the query to go to 8.8.8.8, not '%s'", resolver.server)
+	}
+}
+
+func TestDNSServerLookup(t *testing.T) {
+	resolver := &stubResolver{answers: stubAnswers}
+
+	// The target is the server, and the result must match exactly.
+	err := runDNSTest(resolver, "ns.example.com", map[string]string{"type": "A", "lookup": "test.example.com", "result": "1.2.3.4,5.6.7.8"})
+	if err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+	if resolver.server != "ns.example.com" || resolver.name != "test.example.com" {
+		t.Errorf("unexpected query of %s against '%s'", resolver.name, resolver.server)
+	}
+
+	err = runDNSTest(resolver, "ns.example.com", map[string]string{"type": "A", "lookup": "test.example.com", "result": "1.2.3.4"})
+	if err == nil {
+		t.Errorf("expected a partial result not to match")
+	}
+}
@@ -0,0 +1,349 @@
+// Docker Registry Tester
+//
+// The docker-registry tester ensures that a remote container registry
+// implements the Docker Registry HTTP API V2, by fetching its /v2/
+// endpoint:
+//
+//    https://registry.example.com/ must run docker-registry
+//
+// The registry must reply with a 200 or 401 status, or announce the
+// API version via the Docker-Distribution-Api-Version header.
+//
+// To also ensure an image can be pulled you can check that the manifest
+// of a repository exists, for the given image tag, `latest` by default:
+//
+//    https://registry.example.com/ must run docker-registry with repository 'library/alpine' [with image-tag '3.12']
+//
+// Private registries can be tested with credentials, which are sent via
+// basic authentication, or exchanged for a bearer token if the registry
+// asks for one:
+//
+//    https://registry.example.com/ must run docker-registry with repository 'team/app' with username 'monitor' with password 'secret'
+//
+// If you need to disable failures due to expired, broken, or
+// otherwise bogus SSL certificates you can do so via the tls setting:
+//
+//    https://registry.example.com/ must run docker-registry with tls insecure
+//
+
+package protocols
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// dockerRegistryAPIVersion is the value of the
+// Docker-Distribution-Api-Version header announced by V2 registries.
+const dockerRegistryAPIVersion = "registry/2.0"
+
+// dockerManifestTypes are the media types we accept for manifests.
+var dockerManifestTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// dockerChallengeParam matches a single parameter of a WWW-Authenticate
+// challenge, such as `realm="https://auth.example.com/token"`.
+var dockerChallengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// DockerRegistryTest is our object
+type DockerRegistryTest struct {
+}
+
+// Arguments returns the names of arguments which this protocol-test
+// understands, along with corresponding regular-expressions to validate
+// their values.
+func (s *DockerRegistryTest) Arguments() map[string]string {
+	known := map[string]string{
+		"repository": `^[a-z0-9]+([._/-][a-z0-9]+)*$`,
+		"image-tag":  `^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`,
+		"username":   ".*",
+		"password":   ".*",
+		"tls":        "insecure",
+	}
+	return known
+}
+
+// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
+func (s *DockerRegistryTest) ShouldResolveHostname() bool {
+	return true
+}
+
+// Example returns sample usage-instructions for self-documentation purposes.
+func (s *DockerRegistryTest) Example() string {
+	str := `
+Docker Registry Tester
+----------------------
+ The docker-registry tester ensures that a remote container registry
+ implements the Docker Registry HTTP API V2, by fetching its /v2/
+ endpoint:
+
+    https://registry.example.com/ must run docker-registry
+
+ The registry must reply with a 200 or 401 status, or announce the
+ API version via the Docker-Distribution-Api-Version header.
+
+ To also ensure an image can be pulled you can check that the manifest
+ of a repository exists, for the given image tag, 'latest' by default:
+
+    https://registry.example.com/ must run docker-registry with repository 'library/alpine' [with image-tag '3.12']
+
+ Private registries can be tested with credentials, which are sent via
+ basic authentication, or exchanged for a bearer token if the registry
+ asks for one:
+
+    https://registry.example.com/ must run docker-registry with repository 'team/app' with username 'monitor' with password 'secret'
+
+ If you need to disable failures due to expired, broken, or
+ otherwise bogus SSL certificates you can do so via the tls setting:
+
+    https://registry.example.com/ must run docker-registry with tls insecure
+`
+	return str
+}
+
+// RunTest is the part of our API which is invoked to actually execute a
+// test against the given target.
+//
+// In this case we fetch /v2/ from the resolved IP address and, if a
+// repository was given, the manifest of the image.
+func (s *DockerRegistryTest) RunTest(tst test.Test, target string, opts test.Options) error {
+
+	u, err := url.Parse(tst.Target)
+	if err != nil {
+		return err
+	}
+
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+
+	//
+	// Connect to the IP we've been given, rather than to the
+	// result of a new lookup of the hostname.
+	//
+	address := fmt.Sprintf("%s:%s", target, port)
+	if strings.Contains(target, ":") {
+		address = fmt.Sprintf("[%s]:%s", target, port)
+	}
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+	if tst.Arguments["tls"] == "insecure" {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	defer tr.CloseIdleConnections()
+
+	timeout := opts.Timeout
+	if tst.Timeout != nil {
+		timeout = *tst.Timeout
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: tr,
+	}
+
+	username := tst.Arguments["username"]
+	password := tst.Arguments["password"]
+
+	//
+	// Does the registry speak the V2 API?
+	//
+	base := strings.TrimSuffix(u.String(), "/")
+	response, err := s.get(client, "GET", base+"/v2/", username, password, "")
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusUnauthorized &&
+		response.Header.Get("Docker-Distribution-Api-Version") != dockerRegistryAPIVersion {
+		return fmt.Errorf("%s/v2/ doesn't look like a Docker registry, status code was %d not 200 or 401", base, response.StatusCode)
+	}
+
+	//
+	// Credentials we sent directly, which were refused.
+	//
+	if response.StatusCode == http.StatusUnauthorized && username != "" &&
+		strings.HasPrefix(strings.ToLower(response.Header.Get("WWW-Authenticate")), "basic") {
+		return fmt.Errorf("the registry refused the credentials of '%s'", username)
+	}
+
+	repository := tst.Arguments["repository"]
+	if repository == "" {
+		return nil
+	}
+
+	tag := "latest"
+	if tst.Arguments["image-tag"] != "" {
+		tag = tst.Arguments["image-tag"]
+	}
+
+	//
+	// Does the manifest of the image exist?
+	//
+	manifest := fmt.Sprintf("%s/v2/%s/manifests/%s", base, repository, tag)
+	response, err = s.get(client, "HEAD", manifest, username, password, "")
+	if err != nil {
+		return err
+	}
+
+	//
+	// The registry might require a token, which we fetch from the
+	// authorization server it tells us about.
+	//
+	challenge := response.Header.Get("WWW-Authenticate")
+	if response.StatusCode == http.StatusUnauthorized && strings.HasPrefix(strings.ToLower(challenge), "bearer") {
+
+		//
+		// The authorization server lives on a different host, so it
+		// can't use our IP-pinning transport.
+		//
+		tokenClient := &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tr.TLSClientConfig},
+		}
+
+		token, errToken := s.token(tokenClient, challenge, repository, username, password)
+		if errToken != nil {
+			return errToken
+		}
+
+		response, err = s.get(client, "HEAD", manifest, "", "", token)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("the manifest of %s:%s was not found", repository, tag)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("not allowed to fetch the manifest of %s:%s, status code was %d", repository, tag, response.StatusCode)
+	}
+
+	return fmt.Errorf("failed to fetch the manifest of %s:%s, status code was %d", repository, tag, response.StatusCode)
+}
+
+// get performs the given request, authenticating via either basic
+// authentication or the given bearer token, and discards the body.
+func (s *DockerRegistryTest) get(client *http.Client, method, target, username, password, token string) (*http.Response, error) {
+
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", "overseer/probe")
+	req.Header.Set("Accept", strings.Join(dockerManifestTypes, ", "))
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	return response, nil
+}
+
+// token fetches a pull token for the given repository, from the
+// authorization server described by the given bearer challenge.
+func (s *DockerRegistryTest) token(client *http.Client, challenge, repository, username, password string) (string, error) {
+
+	params := make(map[string]string)
+	for _, match := range dockerChallengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+
+	if params["realm"] == "" {
+		return "", fmt.Errorf("the registry asked for a token, without a realm: %s", challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", err
+	}
+
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull", repository))
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "overseer/probe")
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	response, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch registry token: %s", err.Error())
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch registry token: status code was %d", response.StatusCode)
+	}
+
+	var payload struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&payload); err != nil {
+		return "", fmt.Errorf("failed to parse registry token: %s", err.Error())
+	}
+
+	if payload.Token != "" {
+		return payload.Token, nil
+	}
+	if payload.AccessToken != "" {
+		return payload.AccessToken, nil
+	}
+
+	return "", fmt.Errorf("failed to fetch registry token: no token returned")
+}
+
+//
+// Register our protocol-tester.
+//
+func init() {
+	Register("docker-registry", func() ProtocolTest {
+		return &DockerRegistryTest{}
+	})
+}
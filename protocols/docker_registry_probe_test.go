@@ -0,0 +1,100 @@
+package protocols
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// newRegistryServer returns a registry serving the manifest of
+// library/alpine:latest, which requires a bearer token.
+func newRegistryServer(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Docker-Distribution-Api-Version", dockerRegistryAPIVersion)
+		challenge := `Bearer realm="` + server.URL + `/token",service="registry.test"`
+
+		switch {
+		case r.URL.Path == "/token":
+			if r.URL.Query().Get("scope") != "repository:library/alpine:pull" || r.URL.Query().Get("service") != "registry.test" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"token": "secret-token"}`))
+		case r.URL.Path == "/v2/":
+			w.Header().Set("WWW-Authenticate", challenge)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.Header.Get("Authorization") != "Bearer secret-token":
+			w.Header().Set("WWW-Authenticate", challenge)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v2/library/alpine/manifests/latest":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+func TestDockerRegistry(t *testing.T) {
+	server := newRegistryServer(t)
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(target string, args map[string]string) error {
+		tst := test.Test{Target: target, Type: "docker-registry", Arguments: args}
+		return (&DockerRegistryTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run(server.URL, map[string]string{}); err != nil {
+		t.Errorf("expected the registry to be detected, got %s", err)
+	}
+
+	if err := run(server.URL+"/", map[string]string{"repository": "library/alpine"}); err != nil {
+		t.Errorf("expected the manifest to be found, got %s", err)
+	}
+
+	err := run(server.URL, map[string]string{"repository": "library/alpine", "image-tag": "missing"})
+	if err == nil || !strings.Contains(err.Error(), "library/alpine:missing was not found") {
+		t.Errorf("expected the missing tag to be reported, got %v", err)
+	}
+
+	// Not a registry at all.
+	other := httptest.NewServer(http.NotFoundHandler())
+	defer other.Close()
+	if err = run(other.URL, map[string]string{}); err == nil || !strings.Contains(err.Error(), "doesn't look like a Docker registry") {
+		t.Errorf("expected a plain web server to fail, got %v", err)
+	}
+}
+
+func TestDockerRegistryBasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "monitor" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	tst := test.Test{Target: server.URL, Type: "docker-registry", Arguments: map[string]string{
+		"repository": "team/app",
+		"username":   "monitor",
+		"password":   "secret",
+	}}
+	if err := (&DockerRegistryTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the credentials to be accepted, got %s", err)
+	}
+
+	tst.Arguments["password"] = "wrong"
+	err := (&DockerRegistryTest{}).RunTest(tst, "127.0.0.1", opts)
+	if err == nil || !strings.Contains(err.Error(), "refused the credentials") {
+		t.Errorf("expected the wrong credentials to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+// Feed Tester
+//
+// The feed tester fetches a remote RSS/Atom feed, and ensures that it
+// is well-formed.
+//
+// This test is invoked via input like so:
+//
+//    https://blog.steve.fi/index.rss must run feed
+//
+// To also ensure the feed is regularly updated you can specify the
+// maximum age of its newest item:
+//
+//    https://blog.steve.fi/index.rss must run feed with max-age 24h
+//
+// As for the http tests, at most max-size bytes of the feed are read,
+// the worker -http-max-size by default:
+//
+//    https://blog.steve.fi/index.rss must run feed with max-size 5MB
+//
+// If you need to disable failures due to expired, broken, or
+// otherwise bogus SSL certificates you can do so via the tls setting:
+//
+//    https://expired.badssl.com/feed must run feed with tls insecure
+//
+
+package protocols
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	"github.com/mmcdole/gofeed"
+)
+
+// FEEDTest is our object
+type FEEDTest struct {
+}
+
+// Arguments returns the names of arguments which this protocol-test
+// understands, along with corresponding regular-expressions to validate
+// their values.
+func (s *FEEDTest) Arguments() map[string]string {
+	known := map[string]string{
+		"max-age":    `^[+]?([0-9]*(\.[0-9]*)?[a-z]+)+$`,
+		"max-size":   `^[0-9]+(\.[0-9]+)?([kKMG]?B)?$`,
+		"tls":        "insecure",
+		"user-agent": ".*",
+	}
+	return known
+}
+
+// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
+func (s *FEEDTest) ShouldResolveHostname() bool {
+	return true
+}
+
+// Example returns sample usage-instructions for self-documentation purposes.
+func (s *FEEDTest) Example() string {
+	str := `
+Feed Tester
+-----------
+ The feed tester fetches a remote RSS/Atom feed, and ensures that it
+ is well-formed.
+
+ This test is invoked via input like so:
+
+    https://blog.steve.fi/index.rss must run feed
+
+ To also ensure the feed is regularly updated you can specify the
+ maximum age of its newest item:
+
+    https://blog.steve.fi/index.rss must run feed with max-age 24h
+
+ As for the http tests, at most max-size bytes of the feed are read,
+ the worker -http-max-size by default:
+
+    https://blog.steve.fi/index.rss must run feed with max-size 5MB
+
+ If you need to disable failures due to expired, broken, or
+ otherwise bogus SSL certificates you can do so via the tls setting:
+
+    https://expired.badssl.com/feed must run feed with tls insecure
+`
+	return str
+}
+
+// RunTest is the part of our API which is invoked to actually execute a
+// test against the given target.
+//
+// In this case we fetch the feed from the resolved IP address, parse it,
+// and look at the date of its newest item.
+func (s *FEEDTest) RunTest(tst test.Test, target string, opts test.Options) error {
+
+	var maxAge time.Duration
+	var err error
+
+	if tst.Arguments["max-age"] != "" {
+		maxAge, err = time.ParseDuration(tst.Arguments["max-age"])
+		if err != nil {
+			return err
+		}
+	}
+
+	//
+	// How much of the feed are we willing to read?
+	//
+	maxSize := opts.HTTPMaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultHTTPMaxSize
+	}
+	if sizeString := tst.Arguments["max-size"]; sizeString != "" {
+		maxSize, err = parseSize(sizeString)
+		if err != nil {
+			return err
+		}
+	}
+
+	u, err := url.Parse(tst.Target)
+	if err != nil {
+		return err
+	}
+
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+
+	//
+	// Connect to the IP we've been given, rather than to the
+	// result of a new lookup of the hostname.
+	//
+	address := fmt.Sprintf("%s:%s", target, port)
+	if strings.Contains(target, ":") {
+		address = fmt.Sprintf("[%s]:%s", target, port)
+	}
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+	if tst.Arguments["tls"] == "insecure" {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	timeout := opts.Timeout
+	if tst.Timeout != nil {
+		timeout = *tst.Timeout
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: tr,
+	}
+
+	req, err := http.NewRequest("GET", tst.Target, nil)
+	if err != nil {
+		return err
+	}
+
+	if tst.Arguments["user-agent"] != "" {
+		req.Header.Set("User-Agent", tst.Arguments["user-agent"])
+	} else {
+		req.Header.Set("User-Agent", "overseer/probe")
+	}
+
+	response, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > maxSize {
+		return fmt.Errorf("feed exceeded max-size of %d bytes", maxSize)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code was %d not 200", response.StatusCode)
+	}
+
+	//
+	// Parse the feed, whichever format it is.
+	//
+	feed, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to parse feed: %s", err.Error())
+	}
+
+	if maxAge == 0 {
+		return nil
+	}
+
+	//
+	// Find the date of the newest item, falling back to the date of
+	// the feed itself if the items carry no dates.
+	//
+	var newest time.Time
+	for _, item := range feed.Items {
+		for _, date := range []*time.Time{item.PublishedParsed, item.UpdatedParsed} {
+			if date != nil && date.After(newest) {
+				newest = *date
+			}
+		}
+	}
+	if newest.IsZero() {
+		for _, date := range []*time.Time{feed.PublishedParsed, feed.UpdatedParsed} {
+			if date != nil && date.After(newest) {
+				newest = *date
+			}
+		}
+	}
+
+	if newest.IsZero() {
+		return fmt.Errorf("feed contains no dated items")
+	}
+
+	age := time.Since(newest)
+	if age > maxAge {
+		return fmt.Errorf("newest feed item is %s old, more than %s", age.Round(time.Minute), maxAge)
+	}
+
+	return nil
+}
+
+//
+// Register our protocol-tester.
+//
+func init() {
+	Register("feed", func() ProtocolTest {
+		return &FEEDTest{}
+	})
+}
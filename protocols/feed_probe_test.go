@@ -0,0 +1,98 @@
+package protocols
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// feedRSS returns an RSS feed, whose single item was published at the
+// given time.
+func feedRSS(published time.Time) string {
+	return `<?xml version="1.0"?>
+<rss version="2.0">
+  <channel>
+    <title>Example</title>
+    <link>https://example.com/</link>
+    <item>
+      <title>Hello</title>
+      <link>https://example.com/hello</link>
+      <pubDate>` + published.UTC().Format(time.RFC1123Z) + `</pubDate>
+    </item>
+  </channel>
+</rss>`
+}
+
+// feedAtom returns an Atom feed, whose single entry was updated at the
+// given time.
+func feedAtom(updated time.Time) string {
+	return `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Example</title>
+  <id>urn:example</id>
+  <updated>` + updated.UTC().Format(time.RFC3339) + `</updated>
+  <entry>
+    <title>Hello</title>
+    <id>urn:example:hello</id>
+    <updated>` + updated.UTC().Format(time.RFC3339) + `</updated>
+  </entry>
+</feed>`
+}
+
+func TestFeed(t *testing.T) {
+	now := time.Now()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.rss":
+			w.Write([]byte(feedRSS(now.Add(-time.Hour))))
+		case "/atom.xml":
+			w.Write([]byte(feedAtom(now.Add(-time.Hour))))
+		case "/stale.rss":
+			w.Write([]byte(feedRSS(now.Add(-72 * time.Hour))))
+		case "/broken.rss":
+			w.Write([]byte("<rss><channel><item>"))
+		case "/huge.rss":
+			w.Write([]byte(feedRSS(now) + strings.Repeat(" ", 4096)))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	host, _, _ := net.SplitHostPort(u.Host)
+
+	run := func(path string, args map[string]string) error {
+		tst := test.Test{Target: server.URL + path, Type: "feed", Arguments: args}
+		return (&FEEDTest{}).RunTest(tst, host, test.Options{Timeout: 2 * time.Second})
+	}
+
+	for _, path := range []string{"/index.rss", "/atom.xml"} {
+		if err := run(path, map[string]string{"max-age": "24h"}); err != nil {
+			t.Errorf("expected %s to pass, got %s", path, err)
+		}
+	}
+
+	failures := map[string]struct {
+		args     map[string]string
+		expected string
+	}{
+		"/stale.rss":   {map[string]string{"max-age": "24h"}, "newest feed item is 72h0m0s old, more than 24h0m0s"},
+		"/broken.rss":  {map[string]string{}, "failed to parse feed"},
+		"/missing.rss": {map[string]string{}, "status code was 404 not 200"},
+		"/huge.rss":    {map[string]string{"max-size": "1kB"}, "feed exceeded max-size of 1000 bytes"},
+	}
+	for path, failure := range failures {
+		err := run(path, failure.args)
+		if err == nil || !strings.Contains(err.Error(), failure.expected) {
+			t.Errorf("expected %q for %s, got %v", failure.expected, path, err)
+		}
+	}
+}
@@ -27,15 +27,29 @@
 // "content" parameter:
 //
 //    ftp://ftp.example.com/path/to/README.md must run ftp with content '2018'
+//
+// To verify logins work, specify the credentials without a file.  Once
+// authenticated the current directory is requested, via PWD, and the test
+// fails if the server rejects it:
+//
+//    host.example.com must run ftp with username 'user' with password 'secret'
+//
+// Explicit FTPS, upgrading the connection via AUTH TLS, is supported via
+// the "tls" parameter.  Use "insecure" to skip the certificate validation:
+//
+//    host.example.com must run ftp with tls true with username 'user' with password 'secret'
 
 package protocols
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cmaster11/overseer/test"
 	"github.com/jlaffaye/ftp"
@@ -53,6 +67,7 @@ func (s *FTPTest) Arguments() map[string]string {
 		"content":  ".*",
 		"password": ".*",
 		"port":     "^[0-9]+$",
+		"tls":      "^(true|false|insecure)$",
 		"username": ".*",
 	}
 	return known
@@ -95,6 +110,17 @@ FTP Tester
  "content" parameter:
 
     ftp://ftp.example.com/path/to/README.md must run ftp with content '2018'
+
+ To verify logins work, specify the credentials without a file.  Once
+ authenticated the current directory is requested, via PWD, and the test
+ fails if the server rejects it:
+
+    host.example.com must run ftp with username 'user' with password 'secret'
+
+ Explicit FTPS, upgrading the connection via AUTH TLS, is supported via
+ the "tls" parameter.  Use "insecure" to skip the certificate validation:
+
+    host.example.com must run ftp with tls true with username 'user' with password 'secret'
 `
 	return str
 }
@@ -126,6 +152,11 @@ func (s *FTPTest) RunTest(tst test.Test, target string, opts test.Options) error
 	//
 	file := "/"
 
+	//
+	// The hostname, used to validate the certificate via FTPS
+	//
+	hostname := tst.Target
+
 	//
 	// If we've been given an URI then we should update the
 	// port if it is non-standard, and possibly retrieve an
@@ -142,6 +173,7 @@ func (s *FTPTest) RunTest(tst test.Test, target string, opts test.Options) error
 
 		// Record the path to fetch.
 		file = u.Path
+		hostname = u.Hostname()
 
 		// Update the default port, if a port-number was given.
 		if u.Port() != "" {
@@ -161,7 +193,6 @@ func (s *FTPTest) RunTest(tst test.Test, target string, opts test.Options) error
 		}
 	}
 
-	fmt.Printf("Username: %s -> %s\n", username, password)
 	//
 	// If the user specified a different port update to use it.
 	//
@@ -187,10 +218,46 @@ func (s *FTPTest) RunTest(tst test.Test, target string, opts test.Options) error
 	}
 
 	//
-	// Make the connection.
+	// Explicit FTPS upgrades the control connection once connected.
 	//
+	var tlsConfig *tls.Config
+	if tst.Arguments["tls"] == "true" || tst.Arguments["tls"] == "insecure" {
+		tlsConfig = &tls.Config{
+			ServerName:         hostname,
+			InsecureSkipVerify: tst.Arguments["tls"] == "insecure",
+		}
+	}
+
+	//
+	// Our own dialer makes the deadline cover the whole exchange,
+	// rather than just the dial.  The first connection is the control
+	// one, the following ones are data connections, which need to be
+	// protected from the start.
+	//
+	dialed := false
+	options := []ftp.DialOption{
+		ftp.DialWithDialFunc(func(network, address string) (net.Conn, error) {
+			c, errDial := net.DialTimeout(network, address, opts.Timeout)
+			if errDial != nil {
+				return nil, errDial
+			}
+			if errDial = c.SetDeadline(time.Now().Add(opts.Timeout)); errDial != nil {
+				c.Close()
+				return nil, errDial
+			}
+			if dialed && tlsConfig != nil {
+				return tls.Client(c, tlsConfig), nil
+			}
+			dialed = true
+			return c, nil
+		}),
+	}
+	if tlsConfig != nil {
+		options = append(options, ftp.DialWithExplicitTLS(tlsConfig))
+	}
+
 	var conn *ftp.ServerConn
-	conn, err = ftp.DialTimeout(address, opts.Timeout)
+	conn, err = ftp.Dial(address, options...)
 	if err != nil {
 		return err
 	}
@@ -206,22 +273,37 @@ func (s *FTPTest) RunTest(tst test.Test, target string, opts test.Options) error
 		password = tst.Arguments["password"]
 	}
 
+	//
+	// Login if we have been given credentials, or a path/file to
+	// fetch via an URI input.
+	//
+	if file == "/" && tst.Arguments["username"] == "" && tst.Arguments["password"] == "" {
+		return nil
+	}
+
+	err = conn.Login(username, password)
+	if err != nil {
+		return fmt.Errorf("login failed: %s", err.Error())
+	}
+
+	//
+	// Ensure the server accepts commands once we're logged in.
+	//
+	dir, err := conn.CurrentDir()
+	if err != nil {
+		return fmt.Errorf("PWD failed: %s", err.Error())
+	}
+
+	if opts.Verbose {
+		fmt.Printf("\tLogged in as %s, current directory is %s\n", username, dir)
+	}
+
 	//
 	// If we have been given a path/file to fetch, via an URI
 	// input, then fetch it.
 	//
-	// Before attempting the fetch login.
-	//
 	if file != "/" {
 
-		//
-		// Login
-		//
-		err = conn.Login(username, password)
-		if err != nil {
-			return err
-		}
-
 		//
 		// Retrieve the file.
 		//
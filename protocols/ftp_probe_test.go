@@ -0,0 +1,137 @@
+package protocols
+
+import (
+	"crypto/tls"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// serveFTP returns a handler scripting the responses of an FTP server
+// which accepts the user "user" with the password "secret".  If tlsConfig
+// is set the server supports AUTH TLS, if pwd is false PWD is rejected.
+func serveFTP(tlsConfig *tls.Config, pwd bool) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 Mock FTP server ready")
+
+		user := ""
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			fields := strings.SplitN(line, " ", 2)
+			arg := ""
+			if len(fields) == 2 {
+				arg = fields[1]
+			}
+
+			switch fields[0] {
+			case "AUTH":
+				if tlsConfig == nil {
+					text.PrintfLine("502 Command not implemented")
+					continue
+				}
+				text.PrintfLine("234 Proceed with negotiation")
+				text = textproto.NewConn(tls.Server(conn, tlsConfig))
+			case "USER":
+				user = arg
+				text.PrintfLine("331 Password required for %s", user)
+			case "PASS":
+				if user == "user" && arg == "secret" {
+					text.PrintfLine("230 User logged in")
+				} else {
+					text.PrintfLine("530 Login incorrect")
+				}
+			case "FEAT":
+				text.PrintfLine("211-Features:")
+				text.PrintfLine(" UTF8")
+				text.PrintfLine("211 End")
+			case "TYPE", "OPTS", "PBSZ", "PROT":
+				text.PrintfLine("200 OK")
+			case "PWD":
+				if pwd {
+					text.PrintfLine("257 \"/home/user\" is the current directory")
+				} else {
+					text.PrintfLine("550 Permission denied")
+				}
+			case "QUIT":
+				text.PrintfLine("221 Goodbye")
+				return
+			default:
+				text.PrintfLine("502 Command not implemented")
+			}
+		}
+	}
+}
+
+func runFTPTest(t *testing.T, handler func(conn net.Conn), args map[string]string) error {
+	port, stop := startTCPServer(t, handler)
+	defer stop()
+
+	args["port"] = port
+	tst := test.Test{Target: "127.0.0.1", Type: "ftp", Arguments: args}
+	return (&FTPTest{}).RunTest(tst, "127.0.0.1", test.Options{Timeout: 2 * time.Second})
+}
+
+func TestFTPLogin(t *testing.T) {
+	if err := runFTPTest(t, serveFTP(nil, true), map[string]string{}); err != nil {
+		t.Errorf("expected the connection to succeed, got %s", err)
+	}
+	if err := runFTPTest(t, serveFTP(nil, true), map[string]string{"username": "user", "password": "secret"}); err != nil {
+		t.Errorf("expected the login to succeed, got %s", err)
+	}
+
+	err := runFTPTest(t, serveFTP(nil, true), map[string]string{"username": "user", "password": "wrong"})
+	if err == nil || !strings.Contains(err.Error(), "Login incorrect") {
+		t.Errorf("expected the login to fail, got %v", err)
+	}
+
+	err = runFTPTest(t, serveFTP(nil, false), map[string]string{"username": "user", "password": "secret"})
+	if err == nil || !strings.Contains(err.Error(), "PWD failed") {
+		t.Errorf("expected PWD to fail, got %v", err)
+	}
+}
+
+func TestFTPExplicitTLS(t *testing.T) {
+	config := &tls.Config{Certificates: []tls.Certificate{selfSignedCertificate(t, "ftp.example.com")}}
+	args := func(mode string) map[string]string {
+		return map[string]string{"username": "user", "password": "secret", "tls": mode}
+	}
+
+	if err := runFTPTest(t, serveFTP(config, true), args("insecure")); err != nil {
+		t.Errorf("expected the login over TLS to succeed, got %s", err)
+	}
+
+	// The certificate is self-signed.
+	if err := runFTPTest(t, serveFTP(config, true), args("true")); err == nil {
+		t.Errorf("expected the certificate validation to fail")
+	}
+
+	// Servers without TLS support.
+	if err := runFTPTest(t, serveFTP(nil, true), args("insecure")); err == nil {
+		t.Errorf("expected the TLS upgrade to fail")
+	}
+}
+
+func TestFTPTimeout(t *testing.T) {
+	port, stop := startTCPServer(t, func(conn net.Conn) {
+		// Never send the greeting.
+		time.Sleep(time.Second)
+	})
+	defer stop()
+
+	tst := test.Test{Target: "127.0.0.1", Type: "ftp", Arguments: map[string]string{"port": port}}
+	start := time.Now()
+	if err := (&FTPTest{}).RunTest(tst, "127.0.0.1", test.Options{Timeout: 200 * time.Millisecond}); err == nil {
+		t.Errorf("expected the test to time out")
+	}
+	if time.Since(start) > 900*time.Millisecond {
+		t.Errorf("expected the timeout to be honored, took %s", time.Since(start))
+	}
+}
@@ -0,0 +1,260 @@
+// Git Tester
+//
+// The git tester ensures that a git repository can be cloned via the
+// HTTP "smart" protocol, by fetching the advertisement of its refs, as
+// `git clone` and `git fetch` do:
+//
+//    https://git.example.com/team/app.git must run git
+//
+// To also ensure that a branch, or tag, exists you can use:
+//
+//    https://git.example.com/team/app.git must run git with ref 'refs/heads/main'
+//
+// Private repositories can be tested with credentials, which are sent
+// via basic authentication:
+//
+//    https://git.example.com/team/app.git must run git with username 'monitor' with password 'secret'
+//
+// If you need to disable failures due to expired, broken, or
+// otherwise bogus SSL certificates you can do so via the tls setting:
+//
+//    https://git.example.com/team/app.git must run git with tls insecure
+//
+
+package protocols
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// gitAdvertisementType is the content-type of the smart-protocol
+// advertisement of the refs.
+const gitAdvertisementType = "application/x-git-upload-pack-advertisement"
+
+// GitTest is our object
+type GitTest struct {
+}
+
+// Arguments returns the names of arguments which this protocol-test
+// understands, along with corresponding regular-expressions to validate
+// their values.
+func (s *GitTest) Arguments() map[string]string {
+	known := map[string]string{
+		"ref":      `^(HEAD|refs/\S+)$`,
+		"username": ".*",
+		"password": ".*",
+		"tls":      "insecure",
+	}
+	return known
+}
+
+// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
+func (s *GitTest) ShouldResolveHostname() bool {
+	return true
+}
+
+// Example returns sample usage-instructions for self-documentation purposes.
+func (s *GitTest) Example() string {
+	str := `
+Git Tester
+----------
+ The git tester ensures that a git repository can be cloned via the
+ HTTP "smart" protocol, by fetching the advertisement of its refs, as
+ 'git clone' and 'git fetch' do:
+
+    https://git.example.com/team/app.git must run git
+
+ To also ensure that a branch, or tag, exists you can use:
+
+    https://git.example.com/team/app.git must run git with ref 'refs/heads/main'
+
+ Private repositories can be tested with credentials, which are sent
+ via basic authentication:
+
+    https://git.example.com/team/app.git must run git with username 'monitor' with password 'secret'
+
+ If you need to disable failures due to expired, broken, or
+ otherwise bogus SSL certificates you can do so via the tls setting:
+
+    https://git.example.com/team/app.git must run git with tls insecure
+`
+	return str
+}
+
+// RunTest is the part of our API which is invoked to actually execute a
+// test against the given target.
+//
+// In this case we fetch the refs of the repository from the resolved IP
+// address.
+func (s *GitTest) RunTest(tst test.Test, target string, opts test.Options) error {
+
+	u, err := url.Parse(tst.Target)
+	if err != nil {
+		return err
+	}
+
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+
+	//
+	// Connect to the IP we've been given, rather than to the
+	// result of a new lookup of the hostname.
+	//
+	address := fmt.Sprintf("%s:%s", target, port)
+	if strings.Contains(target, ":") {
+		address = fmt.Sprintf("[%s]:%s", target, port)
+	}
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+	if tst.Arguments["tls"] == "insecure" {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	defer tr.CloseIdleConnections()
+
+	timeout := opts.Timeout
+	if tst.Timeout != nil {
+		timeout = *tst.Timeout
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: tr,
+	}
+
+	refsURL := strings.TrimSuffix(u.String(), "/") + "/info/refs?service=git-upload-pack"
+	req, err := http.NewRequest("GET", refsURL, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("User-Agent", "git/overseer-probe")
+	if tst.Arguments["username"] != "" {
+		req.SetBasicAuth(tst.Arguments["username"], tst.Arguments["password"])
+	}
+
+	response, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("not allowed to fetch the refs of the repository, status code was %d", response.StatusCode)
+	case http.StatusNotFound:
+		return fmt.Errorf("the repository was not found")
+	default:
+		return fmt.Errorf("failed to fetch the refs of the repository, status code was %d", response.StatusCode)
+	}
+
+	//
+	// Servers which only speak the "dumb" protocol reply with a
+	// plain list of refs.
+	//
+	if !strings.HasPrefix(response.Header.Get("Content-Type"), gitAdvertisementType) {
+		return fmt.Errorf("the server doesn't support the smart HTTP protocol, content-type was '%s'", response.Header.Get("Content-Type"))
+	}
+
+	refs, err := parseGitRefs(response.Body)
+	if err != nil {
+		return fmt.Errorf("invalid refs advertisement: %s", err.Error())
+	}
+
+	ref := tst.Arguments["ref"]
+	if ref == "" {
+		return nil
+	}
+
+	if _, ok := refs[ref]; !ok {
+		return fmt.Errorf("the ref %s doesn't exist", ref)
+	}
+
+	return nil
+}
+
+// parseGitRefs parses the advertisement of the refs of a repository,
+// sent in the pkt-line format, returning the object IDs of the refs.
+func parseGitRefs(body io.Reader) (map[string]string, error) {
+	refs := make(map[string]string)
+	reader := bufio.NewReader(body)
+
+	// The advertisement is made of a header, naming the service, and
+	// the list of refs, both followed by a flush-packet.
+	flushes := 0
+	for flushes < 2 {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.ParseUint(string(header), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pkt-line length '%s'", header)
+		}
+
+		if size == 0 {
+			flushes++
+			continue
+		}
+		if size < 4 {
+			return nil, fmt.Errorf("invalid pkt-line length %d", size)
+		}
+
+		line := make([]byte, size-4)
+		if _, err = io.ReadFull(reader, line); err != nil {
+			return nil, err
+		}
+
+		if flushes == 0 {
+			if !strings.HasPrefix(string(line), "# service=git-upload-pack") {
+				return nil, fmt.Errorf("unexpected header '%s'", strings.TrimSpace(string(line)))
+			}
+			continue
+		}
+
+		// The first ref is followed by the capabilities of the server.
+		text := strings.TrimSuffix(string(line), "\n")
+		if idx := strings.IndexByte(text, 0); idx != -1 {
+			text = text[:idx]
+		}
+
+		fields := strings.SplitN(text, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid ref line '%s'", text)
+		}
+		refs[fields[1]] = fields[0]
+	}
+
+	return refs, nil
+}
+
+//
+// Register our protocol-tester.
+//
+func init() {
+	Register("git", func() ProtocolTest {
+		return &GitTest{}
+	})
+}
@@ -0,0 +1,100 @@
+package protocols
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// pktLine encodes the given data as a git pkt-line.
+func pktLine(data string) string {
+	return fmt.Sprintf("%04x%s", len(data)+4, data)
+}
+
+// gitAdvertisement is the advertisement of a repository with two
+// branches.
+var gitAdvertisement = pktLine("# service=git-upload-pack\n") + "0000" +
+	pktLine("1111111111111111111111111111111111111111 HEAD\x00multi_ack side-band-64k symref=HEAD:refs/heads/main\n") +
+	pktLine("1111111111111111111111111111111111111111 refs/heads/main\n") +
+	pktLine("2222222222222222222222222222222222222222 refs/heads/develop\n") +
+	"0000"
+
+func TestParseGitRefs(t *testing.T) {
+	refs, err := parseGitRefs(strings.NewReader(gitAdvertisement))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(refs) != 3 || refs["HEAD"] != strings.Repeat("1", 40) || refs["refs/heads/develop"] != strings.Repeat("2", 40) {
+		t.Errorf("unexpected refs %v", refs)
+	}
+
+	for _, invalid := range []string{"", "zzzz", pktLine("# service=git-receive-pack\n") + "0000", pktLine("# service=git-upload-pack\n") + "0000" + pktLine("garbage\n") + "0000", "0002"} {
+		if _, err = parseGitRefs(strings.NewReader(invalid)); err == nil {
+			t.Errorf("expected '%s' to be invalid", invalid)
+		}
+	}
+}
+
+func TestGit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("service") != "git-upload-pack" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/team/app.git/info/refs":
+			w.Header().Set("Content-Type", gitAdvertisementType)
+			w.Write([]byte(gitAdvertisement))
+		case "/team/private.git/info/refs":
+			if username, password, ok := r.BasicAuth(); !ok || username != "monitor" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", gitAdvertisementType)
+			w.Write([]byte(gitAdvertisement))
+		case "/team/dumb.git/info/refs":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("1111111111111111111111111111111111111111\trefs/heads/main\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(path string, args map[string]string) error {
+		tst := test.Test{Target: server.URL + path, Type: "git", Arguments: args}
+		return (&GitTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run("/team/app.git", map[string]string{"ref": "refs/heads/main"}); err != nil {
+		t.Errorf("expected the ref to be found, got %s", err)
+	}
+
+	err := run("/team/app.git/", map[string]string{"ref": "refs/tags/v1.0"})
+	if err == nil || !strings.Contains(err.Error(), "the ref refs/tags/v1.0 doesn't exist") {
+		t.Errorf("expected the missing ref to be reported, got %v", err)
+	}
+
+	if err = run("/team/private.git", map[string]string{"username": "monitor", "password": "secret"}); err != nil {
+		t.Errorf("expected the credentials to be accepted, got %s", err)
+	}
+	if err = run("/team/private.git", map[string]string{}); err == nil || !strings.Contains(err.Error(), "status code was 401") {
+		t.Errorf("expected the missing credentials to be reported, got %v", err)
+	}
+
+	if err = run("/team/dumb.git", map[string]string{}); err == nil || !strings.Contains(err.Error(), "smart HTTP protocol") {
+		t.Errorf("expected the dumb protocol to be reported, got %v", err)
+	}
+
+	if err = run("/team/missing.git", map[string]string{}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected the missing repository to be reported, got %v", err)
+	}
+}
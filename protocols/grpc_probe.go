@@ -0,0 +1,243 @@
+// gRPC Tester
+//
+// The gRPC tester connects to a remote gRPC server and ensures that this
+// succeeds.
+//
+// This test is invoked via input like so:
+//
+//    host.example.com must run grpc [with port 50051]
+//
+// You can ensure that services are healthy, using the standard health
+// protocol (grpc.health.v1.Health/Check), by listing them separated by
+// commas.  The test fails unless each of them reports SERVING:
+//
+//    host.example.com must run grpc with service 'myservice'
+//
+// If the server exposes the reflection service you can instead ensure
+// that it has registered the services you expect:
+//
+//    host.example.com must run grpc with service 'pkg.MyService' with reflection true
+//
+// By default a plaintext connection is used, to use TLS specify:
+//
+//    with tls true
+//
+// If you need to disable failures due to expired, broken, or
+// otherwise bogus SSL certificates you can use:
+//
+//    with tls insecure
+//
+
+package protocols
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cmaster11/overseer/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	hpb "google.golang.org/grpc/health/grpc_health_v1"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// GRPCTest is our object
+type GRPCTest struct {
+}
+
+// Arguments returns the names of arguments which this protocol-test
+// understands, along with corresponding regular-expressions to validate
+// their values.
+func (s *GRPCTest) Arguments() map[string]string {
+	known := map[string]string{
+		"port":       "^[0-9]+$",
+		"service":    `^[A-Za-z0-9_.]+(,[A-Za-z0-9_.]+)*$`,
+		"tls":        "^(true|insecure)$",
+		"reflection": "^(true|false)$",
+	}
+	return known
+}
+
+// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
+func (s *GRPCTest) ShouldResolveHostname() bool {
+	return true
+}
+
+// Example returns sample usage-instructions for self-documentation purposes.
+func (s *GRPCTest) Example() string {
+	str := `
+gRPC Tester
+-----------
+ The gRPC tester connects to a remote gRPC server and ensures that this
+ succeeds.
+
+ This test is invoked via input like so:
+
+    host.example.com must run grpc [with port 50051]
+
+ You can ensure that services are healthy, using the standard health
+ protocol (grpc.health.v1.Health/Check), by listing them separated by
+ commas.  The test fails unless each of them reports SERVING:
+
+    host.example.com must run grpc with service 'myservice'
+
+ If the server exposes the reflection service you can instead ensure
+ that it has registered the services you expect:
+
+    host.example.com must run grpc with service 'pkg.MyService' with reflection true
+
+ By default a plaintext connection is used, to use TLS specify:
+
+    with tls true
+
+ If you need to disable failures due to expired, broken, or
+ otherwise bogus SSL certificates you can use:
+
+    with tls insecure
+`
+	return str
+}
+
+// RunTest is the part of our API which is invoked to actually execute a
+// test against the given target.
+//
+// In this case we make a gRPC connection to the specified host, and then
+// use the health, or reflection, service if required.
+func (s *GRPCTest) RunTest(tst test.Test, target string, opts test.Options) error {
+	var err error
+
+	//
+	// The default port to connect to.
+	//
+	port := 50051
+	if tst.Arguments["port"] != "" {
+		port, err = strconv.Atoi(tst.Arguments["port"])
+		if err != nil {
+			return err
+		}
+	}
+
+	//
+	// Default to connecting to an IPv4-address
+	//
+	address := fmt.Sprintf("%s:%d", target, port)
+
+	//
+	// If we find a ":" we know it is an IPv6 address though
+	//
+	if strings.Contains(target, ":") {
+		address = fmt.Sprintf("[%s]:%d", target, port)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	//
+	// Setup the transport security.
+	//
+	// We need to setup the hostname that the TLS certificate
+	// will verify upon, from our input-line.
+	//
+	security := grpc.WithInsecure()
+	switch tst.Arguments["tls"] {
+	case "true":
+		security = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{ServerName: tst.Target}))
+	case "insecure":
+		security = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true}))
+	}
+
+	//
+	// Connect, blocking until the connection is up.
+	//
+	conn, err := grpc.DialContext(ctx, address, security, grpc.WithBlock())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if tst.Arguments["service"] == "" {
+		return nil
+	}
+
+	services := strings.Split(tst.Arguments["service"], ",")
+	if tst.Arguments["reflection"] == "true" {
+		return s.checkServices(ctx, conn, services)
+	}
+	return s.checkHealth(ctx, conn, services)
+}
+
+// checkHealth uses the health service of the server to ensure the given
+// services are all serving.
+func (s *GRPCTest) checkHealth(ctx context.Context, conn *grpc.ClientConn, services []string) error {
+
+	client := hpb.NewHealthClient(conn)
+
+	for _, name := range services {
+		resp, err := client.Check(ctx, &hpb.HealthCheckRequest{Service: name})
+		if err != nil {
+			return fmt.Errorf("failed to check the health of service %s: %s", name, err.Error())
+		}
+		if resp.Status != hpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("service %s is %s", name, resp.Status)
+		}
+	}
+
+	return nil
+}
+
+// checkServices uses the reflection service of the server to ensure the
+// given services are all registered.
+func (s *GRPCTest) checkServices(ctx context.Context, conn *grpc.ClientConn, expected []string) error {
+
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to use the reflection service: %s", err.Error())
+	}
+	defer stream.CloseSend()
+
+	err = stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list services: %s", err.Error())
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		return fmt.Errorf("failed to list services: %s", err.Error())
+	}
+
+	if errResp := resp.GetErrorResponse(); errResp != nil {
+		return fmt.Errorf("failed to list services: %s", errResp.ErrorMessage)
+	}
+
+	registered := make(map[string]bool)
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		registered[service.Name] = true
+	}
+
+	var missing []string
+	for _, name := range expected {
+		if !registered[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("service(s) not registered: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+//
+// Register our protocol-tester.
+//
+func init() {
+	Register("grpc", func() ProtocolTest {
+		return &GRPCTest{}
+	})
+}
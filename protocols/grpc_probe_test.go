@@ -0,0 +1,72 @@
+package protocols
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	hpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// startGRPCServer starts a local gRPC server exposing the health and the
+// reflection services, returning its port.
+func startGRPCServer(t *testing.T) (*health.Server, string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+
+	server := grpc.NewServer()
+	healthServer := health.NewServer()
+	hpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+
+	go server.Serve(ln)
+
+	return healthServer, strconv.Itoa(ln.Addr().(*net.TCPAddr).Port), server.Stop
+}
+
+func TestGRPCHealth(t *testing.T) {
+	healthServer, port, stop := startGRPCServer(t)
+	defer stop()
+
+	healthServer.SetServingStatus("myservice", hpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("otherservice", hpb.HealthCheckResponse_NOT_SERVING)
+
+	opts := test.Options{Timeout: 5 * time.Second}
+	tst := test.Test{Target: "127.0.0.1", Type: "grpc", Arguments: map[string]string{"port": port, "service": "myservice"}}
+	if err := (&GRPCTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected myservice to be serving, got %s", err)
+	}
+
+	tst.Arguments["service"] = "myservice,otherservice"
+	if err := (&GRPCTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected otherservice not to be serving")
+	}
+
+	tst.Arguments["service"] = "unknown"
+	if err := (&GRPCTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected an unknown service to fail")
+	}
+}
+
+func TestGRPCReflection(t *testing.T) {
+	_, port, stop := startGRPCServer(t)
+	defer stop()
+
+	opts := test.Options{Timeout: 5 * time.Second}
+	tst := test.Test{Target: "127.0.0.1", Type: "grpc", Arguments: map[string]string{"port": port, "service": "grpc.health.v1.Health", "reflection": "true"}}
+	if err := (&GRPCTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the health service to be registered, got %s", err)
+	}
+
+	tst.Arguments["service"] = "pkg.MyService"
+	if err := (&GRPCTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected pkg.MyService not to be registered")
+	}
+}
@@ -0,0 +1,98 @@
+package protocols
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// jsonPathToken matches a single step of a JSONPath expression, which is
+// either `.name`, `['name']` or `[index]`.
+var jsonPathToken = regexp.MustCompile(`^(?:\.([^.\[\]]+)|\['([^']+)'\]|\[(\d+)\])`)
+
+// jsonPathLookup extracts the value found at the given path from a decoded
+// JSON document.
+//
+// Only a small subset of JSONPath is supported: the root `$`, followed
+// by any number of `.name`, `['name']` or `[index]` steps, for example:
+//
+//    $.items
+//    $.data.users[0].name
+//    $['m.server']
+//
+func jsonPathLookup(doc interface{}, path string) (interface{}, error) {
+
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSON path '%s': it must start with '$'", path)
+	}
+
+	current := doc
+	remaining := path[1:]
+
+	for remaining != "" {
+		match := jsonPathToken.FindStringSubmatch(remaining)
+		if match == nil {
+			return nil, fmt.Errorf("invalid JSON path '%s' near '%s'", path, remaining)
+		}
+		remaining = remaining[len(match[0]):]
+
+		if key := match[1] + match[2]; key != "" {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("JSON path '%s': '%s' is not an object", path, key)
+			}
+			current, ok = object[key]
+			if !ok {
+				return nil, fmt.Errorf("JSON path '%s': key '%s' not found", path, key)
+			}
+			continue
+		}
+
+		index, _ := strconv.Atoi(match[3])
+		array, ok := current.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("JSON path '%s': cannot use index %d on a non-array", path, index)
+		}
+		if index >= len(array) {
+			return nil, fmt.Errorf("JSON path '%s': index %d out of range (length %d)", path, index, len(array))
+		}
+		current = array[index]
+	}
+
+	return current, nil
+}
+
+// jsonDottedPath converts a dotted path, e.g. `db.healthy` or
+// `items.0.id`, into the equivalent JSONPath expression, where numeric
+// steps index arrays.
+func jsonDottedPath(dotted string) string {
+	path := "$"
+	for _, step := range strings.Split(dotted, ".") {
+		if _, err := strconv.Atoi(step); err == nil {
+			path += "[" + step + "]"
+		} else if strings.ContainsAny(step, "[]") {
+			path += "['" + step + "']"
+		} else {
+			path += "." + step
+		}
+	}
+	return path
+}
+
+// jsonScalarString returns the string form of a scalar JSON value, as it
+// would be written by a user, and false if the value is an object or an
+// array.
+func jsonScalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "null", true
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+	return "", false
+}
@@ -0,0 +1,57 @@
+package protocols
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONPathLookup(t *testing.T) {
+	var doc interface{}
+	err := json.Unmarshal([]byte(`{"m.server": "matrix.example.com:443", "items": [{"name": "a"}, {"id": 3, "ok": true}]}`), &doc)
+	if err != nil {
+		t.Fatalf("failed to parse JSON: %s", err)
+	}
+
+	tests := map[string]string{
+		"$['m.server']":    "matrix.example.com:443",
+		"$.items[0].name":  "a",
+		"$.items[1]['id']": "3",
+		"$.items[1].ok":    "true",
+	}
+
+	for path, expected := range tests {
+		value, errLookup := jsonPathLookup(doc, path)
+		if errLookup != nil {
+			t.Errorf("failed to lookup %s: %s", path, errLookup)
+			continue
+		}
+		found, ok := jsonScalarString(value)
+		if !ok || found != expected {
+			t.Errorf("expected %s at %s, found %v", expected, path, value)
+		}
+	}
+
+	for _, path := range []string{"$.m.server", "$.items[2]", "$['missing']", "items"} {
+		if _, errLookup := jsonPathLookup(doc, path); errLookup == nil {
+			t.Errorf("expected %s not to be found", path)
+		}
+	}
+
+	dotted := map[string]string{
+		"status":        "$.status",
+		"db.healthy":    "$.db.healthy",
+		"items.0.id":    "$.items[0].id",
+		"weird[key].10": "$['weird[key]'][10]",
+	}
+	for in, expected := range dotted {
+		if found := jsonDottedPath(in); found != expected {
+			t.Errorf("expected %s to be converted to %s, got %s", in, expected, found)
+		}
+	}
+
+	if value, _ := jsonPathLookup(doc, "$.items"); value != nil {
+		if _, ok := jsonScalarString(value); ok {
+			t.Errorf("expected an array not to be a scalar")
+		}
+	}
+}
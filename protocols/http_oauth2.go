@@ -0,0 +1,115 @@
+package protocols
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oauth2MaxResponseSize is the maximum size of a token response we read,
+// which is way more than any sane token needs.
+const oauth2MaxResponseSize = 64 * 1024
+
+// oauth2Token is an access-token obtained via the client-credentials grant.
+type oauth2Token struct {
+	AccessToken string
+	TokenType   string
+	Expiry      time.Time
+}
+
+// valid returns true if the token can still be used, leaving a small
+// margin so that we don't send a token which expires mid-request.
+func (t *oauth2Token) valid() bool {
+	return t.Expiry.IsZero() || time.Now().Add(30*time.Second).Before(t.Expiry)
+}
+
+// oauth2TokenCache holds the tokens we've fetched, keyed by the token-URL,
+// client-ID and scope, so that they can be reused for their lifetime
+// instead of being fetched for every single test.
+var oauth2TokenCache = struct {
+	sync.Mutex
+	tokens map[string]*oauth2Token
+}{tokens: make(map[string]*oauth2Token)}
+
+// oauth2ClientCredentialsToken returns a valid access-token for the given
+// client, using the cached one if possible.
+func oauth2ClientCredentialsToken(client *http.Client, tokenURL, clientID, clientSecret, scope string) (*oauth2Token, error) {
+
+	key := strings.Join([]string{tokenURL, clientID, scope}, "\x00")
+
+	oauth2TokenCache.Lock()
+	token := oauth2TokenCache.tokens[key]
+	oauth2TokenCache.Unlock()
+
+	if token != nil && token.valid() {
+		return token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "overseer/probe")
+	req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OAuth2 token: %s", err.Error())
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, oauth2MaxResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OAuth2 token response: %s", err.Error())
+	}
+	if len(body) > oauth2MaxResponseSize {
+		return nil, fmt.Errorf("invalid OAuth2 token response: bigger than %d bytes", oauth2MaxResponseSize)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch OAuth2 token: status code was %d", response.StatusCode)
+	}
+
+	var payload struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err = json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid OAuth2 token response: %s", err.Error())
+	}
+	if payload.AccessToken == "" {
+		return nil, fmt.Errorf("invalid OAuth2 token response: no access_token found")
+	}
+
+	token = &oauth2Token{
+		AccessToken: payload.AccessToken,
+		TokenType:   payload.TokenType,
+	}
+	if token.TokenType == "" || strings.EqualFold(token.TokenType, "bearer") {
+		token.TokenType = "Bearer"
+	}
+	if payload.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(payload.ExpiresIn) * time.Second)
+	}
+
+	oauth2TokenCache.Lock()
+	oauth2TokenCache.tokens[key] = token
+	oauth2TokenCache.Unlock()
+
+	return token, nil
+}
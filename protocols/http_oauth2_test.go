@@ -0,0 +1,141 @@
+package protocols
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// newTokenServer returns a token endpoint, which replies depending on the
+// client-ID, counting the tokens it was asked for.
+func newTokenServer(t *testing.T, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+
+		clientID, secret, ok := r.BasicAuth()
+		if !ok || r.FormValue("grant_type") != "client_credentials" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if secret != "secret" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch clientID {
+		case "overseer":
+			w.Write([]byte(`{"access_token":"t0ken","token_type":"bearer","expires_in":3600}`))
+		case "expiring":
+			w.Write([]byte(`{"access_token":"t0ken","token_type":"bearer","expires_in":10}`))
+		case "empty":
+			w.Write([]byte(`{"token_type":"bearer","expires_in":3600}`))
+		case "huge":
+			w.Write([]byte(`{"access_token":"` + strings.Repeat("x", 2*oauth2MaxResponseSize) + `"}`))
+		}
+	}))
+}
+
+func TestOAuth2ClientCredentialsToken(t *testing.T) {
+	var requests int32
+	server := newTokenServer(t, &requests)
+	defer server.Close()
+
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	token, err := oauth2ClientCredentialsToken(client, server.URL, "overseer", "secret", "read")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if token.AccessToken != "t0ken" || token.TokenType != "Bearer" {
+		t.Errorf("unexpected token %+v", token)
+	}
+
+	// The token is cached for its lifetime.
+	if _, err = oauth2ClientCredentialsToken(client, server.URL, "overseer", "secret", "read"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("expected the token to be cached, got %d requests", n)
+	}
+
+	// Other scopes get their own token.
+	if _, err = oauth2ClientCredentialsToken(client, server.URL, "overseer", "secret", "write"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("expected a token per scope, got %d requests", n)
+	}
+
+	// Tokens which are about to expire are fetched again.
+	for i := 0; i < 2; i++ {
+		if _, err = oauth2ClientCredentialsToken(client, server.URL, "expiring", "secret", ""); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 4 {
+		t.Errorf("expected the expiring token to be fetched again, got %d requests", n)
+	}
+
+	failures := map[string]string{
+		"overseer": "failed to fetch OAuth2 token: status code was 401",
+		"empty":    "invalid OAuth2 token response: no access_token found",
+		"huge":     "invalid OAuth2 token response: bigger than 65536 bytes",
+	}
+	for clientID, expected := range failures {
+		secret := "secret"
+		if clientID == "overseer" {
+			secret = "wrong"
+		}
+		_, err = oauth2ClientCredentialsToken(client, server.URL, clientID, secret, "other")
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected %q for %s, got %v", expected, clientID, err)
+		}
+	}
+}
+
+func TestHTTPOAuth2(t *testing.T) {
+	var requests int32
+	tokenServer := newTokenServer(t, &requests)
+	defer tokenServer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(args map[string]string) error {
+		args["oauth2-token-url"] = tokenServer.URL
+		tst := test.Test{Target: server.URL, Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run(map[string]string{"oauth2-client-id": "overseer", "oauth2-client-secret": "secret"}); err != nil {
+		t.Errorf("expected the token to be sent, got %s", err)
+	}
+
+	err := run(map[string]string{"oauth2-client-id": "overseer", "oauth2-client-secret": "wrong", "oauth2-scope": "admin"})
+	if err == nil || !strings.Contains(err.Error(), "status code was 401") {
+		t.Errorf("expected the token endpoint failure to be reported, got %v", err)
+	}
+
+	err = run(map[string]string{"oauth2-client-secret": "secret"})
+	if err == nil || !strings.Contains(err.Error(), "the oauth2-client-id argument is required") {
+		t.Errorf("expected the missing client-id to be reported, got %v", err)
+	}
+
+	err = run(map[string]string{"oauth2-client-id": "overseer", "oauth2-client-secret": "secret", "bearer": "t0ken"})
+	if err == nil || !strings.Contains(err.Error(), "can't be used along with the oauth2 arguments") {
+		t.Errorf("expected bearer and oauth2 to be exclusive, got %v", err)
+	}
+}
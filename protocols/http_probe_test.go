@@ -0,0 +1,969 @@
+package protocols
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestHTTPWellKnown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/matrix/server" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"m.server": "matrix.example.com:443"}`))
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	tst := test.Test{Target: server.URL + "/some/page", Type: "http", Arguments: map[string]string{
+		"well-known": "matrix/server",
+		"json-path":  "$['m.server']",
+		"json-value": "matrix.example.com:443",
+	}}
+
+	if err := (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the well-known JSON to match, got %s", err)
+	}
+
+	tst.Arguments["json-value"] = "other.example.com:443"
+	if err := (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected a different value not to match")
+	}
+
+	tst.Arguments["well-known"] = "nodeinfo"
+	if err := (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected a missing well-known path to fail")
+	}
+}
+
+func TestHTTPCheckETag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/no-etag" {
+			w.Write([]byte("hello"))
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if r.URL.Path == "/conditional" && r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	tst := test.Test{Target: server.URL + "/conditional", Type: "http", Arguments: map[string]string{"check-etag": "true"}}
+	if err := (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the conditional request to be honored, got %s", err)
+	}
+
+	tst.Target = server.URL + "/unconditional"
+	if err := (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected the conditional request not to be honored")
+	}
+
+	tst.Target = server.URL + "/no-etag"
+	if err := (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected a missing ETag to fail")
+	}
+}
+
+func TestHTTPFinalStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		case "/c":
+			w.Write([]byte("hello"))
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/broken":
+			http.Redirect(w, r, "/missing", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(path string, args map[string]string) error {
+		tst := test.Test{Target: server.URL + path, Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run("/a", map[string]string{"final-status": "200"}); err != nil {
+		t.Errorf("expected the chain to end with a 200, got %s", err)
+	}
+	if err := run("/a", map[string]string{"final-status": "2xx"}); err != nil {
+		t.Errorf("expected the chain to end with a 2xx, got %s", err)
+	}
+
+	err := run("/broken", map[string]string{"final-status": "200"})
+	if err == nil || !strings.Contains(err.Error(), "ended with status 404") || !strings.Contains(err.Error(), "/broken (302) -> "+server.URL+"/missing (404)") {
+		t.Errorf("expected the chain to be reported, got %v", err)
+	}
+
+	err = run("/loop", map[string]string{"final-status": "200", "follow-redirect": "3"})
+	if err == nil || !strings.Contains(err.Error(), "stopped after 3 redirects") {
+		t.Errorf("expected the hop limit to be reported, got %v", err)
+	}
+
+	// Without following the redirect can still be expected.
+	if err = run("/a", map[string]string{"final-status": "301", "follow-redirect": "false"}); err != nil {
+		t.Errorf("expected the redirect itself to be accepted, got %s", err)
+	}
+}
+
+func TestHTTPFinalURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.Redirect(w, r, "/sso", http.StatusFound)
+		case "/sso":
+			http.Redirect(w, r, "/home?welcome=1", http.StatusFound)
+		case "/open":
+			http.Redirect(w, r, r.URL.Query().Get("next"), http.StatusFound)
+		default:
+			w.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(path string, args map[string]string) error {
+		tst := test.Test{Target: server.URL + path, Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run("/login", map[string]string{"final-url": server.URL + "/home?welcome=1"}); err != nil {
+		t.Errorf("expected the chain to end at the home page, got %s", err)
+	}
+	if err := run("/login", map[string]string{"final-url": server.URL + "/home*", "follow-redirect": "true"}); err != nil {
+		t.Errorf("expected the chain to end below the home page, got %s", err)
+	}
+
+	err := run("/login", map[string]string{"final-url": server.URL + "/home"})
+	if err == nil || !strings.Contains(err.Error(), "redirect chain ended at "+server.URL+"/home?welcome=1 not "+server.URL+"/home: ") {
+		t.Errorf("expected the exact mismatch to be reported, got %v", err)
+	}
+
+	// An open redirect, leading elsewhere.
+	err = run("/open?next="+url.QueryEscape(server.URL+"/elsewhere"), map[string]string{"final-url": server.URL + "/home*"})
+	if err == nil || !strings.Contains(err.Error(), "-> "+server.URL+"/elsewhere (200)") {
+		t.Errorf("expected the chain to be reported, got %v", err)
+	}
+
+	// Without following, the chain ends at the target itself.
+	err = run("/login", map[string]string{"final-url": server.URL + "/home*", "follow-redirect": "false"})
+	if err == nil || !strings.Contains(err.Error(), "redirect chain ended at "+server.URL+"/login") {
+		t.Errorf("expected the unfollowed redirect to be reported, got %v", err)
+	}
+}
+
+func TestHTTPExpectRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		case "/c":
+			w.Write([]byte("hello"))
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(path string, args map[string]string) error {
+		tst := test.Test{Target: server.URL + path, Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run("/a", map[string]string{"expect-redirects": "2"}); err != nil {
+		t.Errorf("expected two redirects, got %s", err)
+	}
+	if err := run("/c", map[string]string{"expect-redirects": "0"}); err != nil {
+		t.Errorf("expected no redirects, got %s", err)
+	}
+
+	err := run("/b", map[string]string{"expect-redirects": "2"})
+	if err == nil || !strings.Contains(err.Error(), "expected 2 redirects, got 1: "+server.URL+"/b (302) -> "+server.URL+"/c (200)") {
+		t.Errorf("expected the missing redirect to be reported, got %v", err)
+	}
+
+	err = run("/loop", map[string]string{"expect-redirects": "2"})
+	if err == nil || !strings.Contains(err.Error(), "expected 2 redirects, got more than 10") {
+		t.Errorf("expected the redirect loop to be reported, got %v", err)
+	}
+
+	err = run("/a", map[string]string{"expect-redirects": "2", "follow-redirect": "1"})
+	if err == nil || !strings.Contains(err.Error(), "follow-redirect allows only 1") {
+		t.Errorf("expected the hop limit to be reported, got %v", err)
+	}
+}
+
+func TestHTTPNoRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api":
+			w.Write([]byte("hello"))
+		case "/private":
+			http.Redirect(w, r, "/login", http.StatusFound)
+		case "/login":
+			w.Write([]byte("please login"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(path string, args map[string]string) error {
+		tst := test.Test{Target: server.URL + path, Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run("/api", map[string]string{"no-redirect": "true"}); err != nil {
+		t.Errorf("expected the content to be served directly, got %s", err)
+	}
+
+	// The redirect is reported, even if redirects would be followed.
+	for _, args := range []map[string]string{{"no-redirect": "true"}, {"no-redirect": "true", "follow-redirect": "true"}} {
+		err := run("/private", args)
+		if err == nil || !strings.Contains(err.Error(), "expected no redirect, got a 302 redirect to /login") {
+			t.Errorf("expected the redirect to be reported with %v, got %v", args, err)
+		}
+	}
+
+	if err := run("/private", map[string]string{"no-redirect": "false", "follow-redirect": "true"}); err != nil {
+		t.Errorf("expected the redirect to be followed, got %s", err)
+	}
+}
+
+func TestHTTPHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("X-Api-Key") != "abc":
+			http.Error(w, "missing api key", http.StatusUnauthorized)
+		case strings.Join(r.Header["Accept"], ",") != "application/json,text/plain":
+			http.Error(w, "unexpected accept "+strings.Join(r.Header["Accept"], ","), http.StatusBadRequest)
+		case r.UserAgent() != "custom/1.0":
+			http.Error(w, "unexpected user-agent "+r.UserAgent(), http.StatusBadRequest)
+		case r.Host != "api.example.com":
+			http.Error(w, "unexpected host "+r.Host, http.StatusBadRequest)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(headers ...string) error {
+		tst := test.Test{Target: server.URL, Type: "http", Arguments: map[string]string{
+			"header": strings.Join(headers, test.ArgumentSeparator),
+		}}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run("X-Api-Key: abc", "accept: application/json", "Accept: text/plain", "User-Agent: custom/1.0", "Host: api.example.com"); err != nil {
+		t.Errorf("expected all the headers to be sent, got %s", err)
+	}
+
+	if err := run("Accept: application/json", "Accept: text/plain"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected the missing header to be reported, got %v", err)
+	}
+}
+
+func TestHTTPHeaderMatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(path string, matches []string, absent []string) error {
+		args := map[string]string{
+			"header-match":  strings.Join(matches, test.ArgumentSeparator),
+			"header-absent": strings.Join(absent, test.ArgumentSeparator),
+		}
+		tst := test.Test{Target: server.URL + path, Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	// Present.
+	if err := run("/", []string{"Cache-Control: max-age", "strict-transport-security: max-age=31536000", "Vary: Accept-Encoding", "Vary:"}, []string{"Set-Cookie"}); err != nil {
+		t.Errorf("expected the headers to match, got %s", err)
+	}
+
+	// Mismatch.
+	err := run("/", []string{"Cache-Control: no-store"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "response header Cache-Control was 'public, max-age=3600', expected it to contain 'no-store'") {
+		t.Errorf("expected the mismatch to be reported, got %v", err)
+	}
+
+	// Missing.
+	err = run("/", []string{"Cache-Control: max-age", "X-Frame-Options: DENY"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "response header X-Frame-Options is missing") {
+		t.Errorf("expected the missing header to be reported, got %v", err)
+	}
+
+	// Absent.
+	err = run("/login", nil, []string{"X-Powered-By", "set-cookie"})
+	if err == nil || !strings.Contains(err.Error(), "response header Set-Cookie should be absent, got 'session=abc'") {
+		t.Errorf("expected the unwanted header to be reported, got %v", err)
+	}
+}
+
+func TestHTTPJSONValues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/html" {
+			w.Write([]byte("<html>ok</html>"))
+			return
+		}
+		w.Write([]byte(`{"status":"ok","db":{"healthy":true,"latency":1.5},"items":[{"id":5},{"id":7}]}`))
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(path string, assertions ...string) error {
+		args := map[string]string{"json": strings.Join(assertions, test.ArgumentSeparator)}
+		tst := test.Test{Target: server.URL + path, Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run("/", "status=ok", "db.healthy=true", "db.latency=1.5", "items.0.id=5", "items.1.id=7"); err != nil {
+		t.Errorf("expected the values to match, got %s", err)
+	}
+
+	failures := map[string][]string{
+		"the value at JSON path 'db.healthy' is 'true', expected 'false'": {"status=ok", "db.healthy=false"},
+		"key 'missing' not found":                     {"db.missing=1"},
+		"index 2 out of range":                        {"items.2.id=5"},
+		"the value at JSON path 'db' is not a scalar": {"db=ok"},
+	}
+	for expected, assertions := range failures {
+		if err := run("/", assertions...); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %v to fail with '%s', got %v", assertions, expected, err)
+		}
+	}
+
+	if err := run("/html", "status=ok"); err == nil || !strings.Contains(err.Error(), "failed to parse response body as JSON") {
+		t.Errorf("expected the non-JSON body to be reported, got %v", err)
+	}
+}
+
+func TestHTTPJSONArrayLength(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"empty":[],"items":[1,2,3,4],"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(args map[string]string) error {
+		tst := test.Test{Target: server.URL, Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run(map[string]string{"json-path": "$.items", "json-min-length": "1", "json-max-length": "4"}); err != nil {
+		t.Errorf("expected the array length to be within the limits, got %s", err)
+	}
+	if err := run(map[string]string{"json-path": "$.empty", "json-max-length": "0"}); err != nil {
+		t.Errorf("expected the empty array to be within the limits, got %s", err)
+	}
+
+	failures := []struct {
+		args     map[string]string
+		expected string
+	}{
+		{map[string]string{"json-path": "$.empty", "json-min-length": "1"}, "the array at JSON path '$.empty' has 0 elements, expected at least 1"},
+		{map[string]string{"json-path": "$.items", "json-max-length": "3"}, "the array at JSON path '$.items' has 4 elements, expected at most 3"},
+		{map[string]string{"json-path": "$.status", "json-min-length": "1"}, "the value at JSON path '$.status' is not an array"},
+		{map[string]string{"json-min-length": "1"}, "the value at JSON path '$' is not an array"},
+		{map[string]string{"json-path": "$.missing", "json-min-length": "1"}, "JSON path '$.missing': key 'missing' not found"},
+	}
+	for _, failure := range failures {
+		if err := run(failure.args); err == nil || !strings.Contains(err.Error(), failure.expected) {
+			t.Errorf("expected %v to fail with '%s', got %v", failure.args, failure.expected, err)
+		}
+	}
+}
+
+func TestHTTPClientCertificate(t *testing.T) {
+	client := selfSignedCertificate(t, "overseer")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(mustParseCertificate(t, client.Certificate[0]))
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	server.StartTLS()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "overseer")
+	if err != nil {
+		t.Fatalf("failed to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyDER, err := x509.MarshalECPrivateKey(client.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatalf("failed to encode the key: %s", err)
+	}
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: client.Certificate[0]}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(args map[string]string) error {
+		args["tls"] = "insecure"
+		args["expiration"] = "any"
+		args["content"] = "hello overseer"
+		tst := test.Test{Target: server.URL, Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err = run(map[string]string{"client-cert": certFile, "client-key": keyFile}); err != nil {
+		t.Errorf("expected the client certificate to be accepted, got %s", err)
+	}
+
+	if err = run(map[string]string{}); err == nil {
+		t.Errorf("expected the request without a client certificate to fail")
+	}
+
+	err = run(map[string]string{"client-cert": filepath.Join(dir, "missing.pem"), "client-key": keyFile})
+	if err == nil || !strings.Contains(err.Error(), "failed to load the client certificate") {
+		t.Errorf("expected the unreadable certificate to be reported, got %v", err)
+	}
+
+	err = run(map[string]string{"client-cert": certFile})
+	if err == nil || !strings.Contains(err.Error(), "client-cert and client-key must be given together") {
+		t.Errorf("expected the missing key to be reported, got %v", err)
+	}
+}
+
+// mustParseCertificate parses the given DER-encoded certificate.
+func mustParseCertificate(t *testing.T, der []byte) *x509.Certificate {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+	return cert
+}
+
+// testCA returns a self-signed certificate authority, and its key.
+func testCA(t *testing.T, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+
+	return mustParseCertificate(t, der), key
+}
+
+func TestHTTPOCSPStaple(t *testing.T) {
+	ca, caKey := testCA(t, "Overseer CA")
+	other, otherKey := testCA(t, "Other CA")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+	leaf := mustParseCertificate(t, der)
+
+	staple := func(issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey, serial int64, status int, nextUpdate time.Duration) []byte {
+		response, err := ocsp.CreateResponse(issuer, issuer, ocsp.Response{
+			Status:       status,
+			SerialNumber: big.NewInt(serial),
+			ThisUpdate:   time.Now().Add(-time.Hour),
+			NextUpdate:   time.Now().Add(nextUpdate),
+			RevokedAt:    time.Now().Add(-time.Minute),
+		}, issuerKey)
+		if err != nil {
+			t.Fatalf("failed to create the OCSP response: %s", err)
+		}
+		return response
+	}
+	check := func(response []byte, chain ...*x509.Certificate) error {
+		return (&HTTPTest{}).checkOCSPStaple(&tls.ConnectionState{OCSPResponse: response, PeerCertificates: chain})
+	}
+
+	if err = check(staple(ca, caKey, 42, ocsp.Good, 48*time.Hour), leaf, ca); err != nil {
+		t.Errorf("expected the staple to be valid, got %s", err)
+	}
+
+	failures := []struct {
+		response []byte
+		chain    []*x509.Certificate
+		expected string
+	}{
+		{nil, []*x509.Certificate{leaf, ca}, "no OCSP response was stapled"},
+		{staple(ca, caKey, 42, ocsp.Good, 48*time.Hour), []*x509.Certificate{leaf}, "the server didn't send the issuer of its certificate"},
+		{staple(other, otherKey, 42, ocsp.Good, 48*time.Hour), []*x509.Certificate{leaf, ca}, "invalid stapled OCSP response"},
+		{staple(ca, caKey, 7, ocsp.Good, 48*time.Hour), []*x509.Certificate{leaf, ca}, "is for the certificate with serial 7, not 42"},
+		{staple(ca, caKey, 42, ocsp.Revoked, 48*time.Hour), []*x509.Certificate{leaf, ca}, "reports the certificate as revoked"},
+		{staple(ca, caKey, 42, ocsp.Unknown, 48*time.Hour), []*x509.Certificate{leaf, ca}, "reports the certificate status as unknown"},
+		{staple(ca, caKey, 42, ocsp.Good, time.Hour), []*x509.Certificate{leaf, ca}, "stapled OCSP response expires in 1h0m0s"},
+	}
+	for _, failure := range failures {
+		err = check(failure.response, failure.chain...)
+		if err == nil || !strings.Contains(err.Error(), failure.expected) {
+			t.Errorf("expected an error containing %q, got %v", failure.expected, err)
+		}
+	}
+}
+
+func TestHTTPProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello from " + r.Host))
+	}))
+	defer backend.Close()
+
+	//
+	// The proxy requires credentials, and forwards every request to
+	// the backend.
+	//
+	var proxied int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte("overseer:s3cr3t")) {
+			http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+			return
+		}
+		atomic.AddInt32(&proxied, 1)
+
+		req, _ := http.NewRequest(r.Method, backend.URL+r.URL.Path, nil)
+		req.Host = r.Host
+		response, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer response.Body.Close()
+		w.WriteHeader(response.StatusCode)
+		io.Copy(w, response.Body)
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	//
+	// The target doesn't resolve, and nothing listens on its port at
+	// the address we give: only the proxy can reach it.
+	//
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(proxy string) error {
+		args := map[string]string{"proxy": proxy, "content": "hello from app.internal"}
+		tst := test.Test{Target: "http://app.internal:1/status", Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run("http://overseer:s3cr3t@" + proxyURL.Host); err != nil {
+		t.Errorf("expected the request to go through the proxy, got %s", err)
+	}
+	if atomic.LoadInt32(&proxied) != 1 {
+		t.Errorf("expected one request to go through the proxy, got %d", proxied)
+	}
+
+	err := run("http://" + proxyURL.Host)
+	if err == nil || !strings.Contains(err.Error(), "status code was 407") {
+		t.Errorf("expected the missing proxy credentials to be reported, got %v", err)
+	}
+}
+
+func TestHTTPBearer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t.token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(args map[string]string) error {
+		tst := test.Test{Target: server.URL, Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run(map[string]string{"bearer": "s3cr3t.token"}); err != nil {
+		t.Errorf("expected the token to be sent, got %s", err)
+	}
+
+	if err := run(map[string]string{"bearer": "other"}); err == nil || !strings.Contains(err.Error(), "status code was 401") {
+		t.Errorf("expected the wrong token to be rejected, got %v", err)
+	}
+
+	err := run(map[string]string{"bearer": "s3cr3t.token", "username": "steve", "password": "bob"})
+	if err == nil || !strings.Contains(err.Error(), "can't be used along with username and password") {
+		t.Errorf("expected bearer and basic-auth to be exclusive, got %v", err)
+	}
+}
+
+func TestHTTPMaxSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stream 2MB, in chunks, without a Content-Length.
+		chunk := []byte(strings.Repeat("x", 1000))
+		for i := 0; i < 2000; i++ {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	run := func(opts test.Options, args map[string]string) error {
+		opts.Timeout = 5 * time.Second
+		tst := test.Test{Target: server.URL, Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run(test.Options{}, map[string]string{}); err != nil {
+		t.Errorf("expected the body to be within the default limit, got %s", err)
+	}
+	if err := run(test.Options{}, map[string]string{"max-size": "2MB"}); err != nil {
+		t.Errorf("expected the body to be within the limit, got %s", err)
+	}
+
+	err := run(test.Options{}, map[string]string{"max-size": "1MB"})
+	if err == nil || !strings.Contains(err.Error(), "response exceeded max-size of 1000000 bytes") {
+		t.Errorf("expected the large body to be reported, got %v", err)
+	}
+
+	// The default can be configured, and tests override it.
+	err = run(test.Options{HTTPMaxSize: 1024}, map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "response exceeded max-size of 1024 bytes") {
+		t.Errorf("expected the configured limit to be used, got %v", err)
+	}
+	if err = run(test.Options{HTTPMaxSize: 1024}, map[string]string{"max-size": "3MB"}); err != nil {
+		t.Errorf("expected the limit of the test to be used, got %s", err)
+	}
+}
+
+func TestHTTPRequireCompression(t *testing.T) {
+	gzipped := func(content string) []byte {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		writer.Write([]byte(content))
+		writer.Close()
+		return buf.Bytes()
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			http.Error(w, "gzip not accepted", http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/compressed":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped("hello " + strings.Repeat("x", 4096)))
+		case "/large":
+			w.Write([]byte("hello " + strings.Repeat("x", 4096)))
+		case "/small":
+			w.Write([]byte("hello"))
+		case "/bomb":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped(strings.Repeat("x", 2*1024*1024)))
+		case "/brotli":
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte("not really brotli"))
+		}
+	}))
+	defer server.Close()
+
+	run := func(path string, args map[string]string) error {
+		args["require-compression-over"] = "1024"
+		tst := test.Test{Target: server.URL + path, Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", test.Options{Timeout: 2 * time.Second})
+	}
+
+	// The content is looked for in the decompressed body.
+	if err := run("/compressed", map[string]string{"content": "hello"}); err != nil {
+		t.Errorf("expected the compressed response to pass, got %s", err)
+	}
+
+	err := run("/large", map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "response of 4102 bytes was served uncompressed (threshold 1024 bytes)") {
+		t.Errorf("expected the uncompressed response to be reported, got %v", err)
+	}
+
+	if err = run("/small", map[string]string{"content": "hello"}); err != nil {
+		t.Errorf("expected the small response to pass uncompressed, got %s", err)
+	}
+
+	// The decompressed body is limited, just like the raw one.
+	err = run("/bomb", map[string]string{"max-size": "1MB"})
+	if err == nil || !strings.Contains(err.Error(), "decompressed response exceeded max-size of 1000000 bytes") {
+		t.Errorf("expected the decompressed size to be limited, got %v", err)
+	}
+
+	err = run("/brotli", map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "unsupported Content-Encoding 'br'") {
+		t.Errorf("expected the unsupported encoding to be reported, got %v", err)
+	}
+}
+
+func TestHTTPCertificateIdentity(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{selfSignedCertificate(t, "example.com")}}
+	server.StartTLS()
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(target string, args map[string]string) error {
+		args["tls"] = "insecure"
+		args["expiration"] = "any"
+		tst := test.Test{Target: target, Type: "http", Arguments: args}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run(server.URL, map[string]string{"cert-issuer": "CN=example.com", "cert-cn": "example.com"}); err != nil {
+		t.Errorf("expected the certificate to match, got %s", err)
+	}
+
+	err := run(server.URL, map[string]string{"cert-issuer": "Let's Encrypt"})
+	if err == nil || !strings.Contains(err.Error(), "certificate issuer 'CN=example.com' doesn't include 'Let's Encrypt'") {
+		t.Errorf("expected the issuer mismatch to be reported, got %v", err)
+	}
+
+	err = run(server.URL, map[string]string{"cert-cn": "www.example.com"})
+	if err == nil || !strings.Contains(err.Error(), "certificate common name is 'example.com', expected 'www.example.com'") {
+		t.Errorf("expected the common name mismatch to be reported, got %v", err)
+	}
+
+	//
+	// The certificate is fetched from the address being tested, asking
+	// for the name of the target, which doesn't need to resolve.
+	//
+	named := selfSignedCertificate(t, "overseer.invalid")
+	other := selfSignedCertificate(t, "other.example.com")
+	sni := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	sni.TLS = &tls.Config{GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if hello.ServerName == "overseer.invalid" {
+			return &named, nil
+		}
+		return &other, nil
+	}}
+	sni.StartTLS()
+	defer sni.Close()
+
+	target := strings.Replace(sni.URL, "127.0.0.1", "overseer.invalid", 1)
+	if err = run(target, map[string]string{"cert-cn": "overseer.invalid"}); err != nil {
+		t.Errorf("expected the certificate of the target name to be checked, got %s", err)
+	}
+
+	//
+	// Through a proxy the certificate is the one the proxy got us,
+	// as nothing listens on the port of the target at our address.
+	//
+	sniURL, _ := url.Parse(sni.URL)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect || r.Host != "overseer.invalid:1" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		upstream, errDial := net.Dial("tcp", sniURL.Host)
+		if errDial != nil {
+			http.Error(w, errDial.Error(), http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+
+		conn, _, errHijack := w.(http.Hijacker).Hijack()
+		if errHijack != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go io.Copy(upstream, conn)
+		io.Copy(conn, upstream)
+	}))
+	defer proxy.Close()
+
+	err = run("https://overseer.invalid:1/", map[string]string{"cert-cn": "overseer.invalid", "proxy": proxy.URL})
+	if err != nil {
+		t.Errorf("expected the certificate to be checked through the proxy, got %s", err)
+	}
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+
+	err = run(plain.URL, map[string]string{"cert-cn": "example.com"})
+	if err == nil || !strings.Contains(err.Error(), "require a HTTPS target") {
+		t.Errorf("expected the plain HTTP target to be reported, got %v", err)
+	}
+}
+
+func TestHTTPExpect429After(t *testing.T) {
+	var mutex sync.Mutex
+	counts := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		counts[r.URL.Path]++
+		count := counts[r.URL.Path]
+		mutex.Unlock()
+
+		if r.URL.Path != "/unlimited" && count > 5 {
+			if r.URL.Path != "/no-retry-after" {
+				w.Header().Set("Retry-After", "60")
+			}
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(path string, threshold string) error {
+		tst := test.Test{Target: server.URL + path, Type: "http", Arguments: map[string]string{"expect-429-after": threshold}}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run("/limited", "5"); err != nil {
+		t.Errorf("expected the rate-limit to match, got %s", err)
+	}
+
+	err := run("/early", "10")
+	if err == nil || !strings.Contains(err.Error(), "rate-limited after only 5 requests") {
+		t.Errorf("expected the early rate-limit to be reported, got %v", err)
+	}
+
+	err = run("/unlimited", "5")
+	if err == nil || !strings.Contains(err.Error(), "not rate-limited") {
+		t.Errorf("expected the missing rate-limit to be reported, got %v", err)
+	}
+
+	err = run("/no-retry-after", "5")
+	if err == nil || !strings.Contains(err.Error(), "no Retry-After header") {
+		t.Errorf("expected the missing Retry-After to be reported, got %v", err)
+	}
+}
+
+func TestHTTPMaxHandshake(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(target string, max string) error {
+		tst := test.Test{Target: target, Type: "http", Arguments: map[string]string{"max-handshake": max, "tls": "insecure", "expiration": "any"}}
+		return (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err := run(server.URL, "10s"); err != nil {
+		t.Errorf("expected the handshake to be fast enough, got %s", err)
+	}
+
+	err := run(server.URL, "1ns")
+	if err == nil || !strings.Contains(err.Error(), "more than the maximum of 1ns") {
+		t.Errorf("expected the slow handshake to be reported, got %v", err)
+	}
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+
+	err = run(plain.URL, "10s")
+	if err == nil || !strings.Contains(err.Error(), "no TLS handshake was made") {
+		t.Errorf("expected the plain HTTP target to be reported, got %v", err)
+	}
+}
+
+func TestHTTPIdleConnections(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	// By default the idle connections are closed once the test is
+	// done, rather than lingering.
+	tst := test.Test{Target: server.URL, Type: "http", Arguments: map[string]string{}}
+	if err := (&HTTPTest{}).RunTest(tst, "127.0.0.1", test.Options{Timeout: 2 * time.Second}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Errorf("expected the idle connection to be closed")
+	}
+}
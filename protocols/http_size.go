@@ -0,0 +1,40 @@
+package protocols
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// DefaultHTTPMaxSize is the maximum size of the HTTP response bodies, in
+// bytes, unless configured otherwise.
+const DefaultHTTPMaxSize = 10 * 1000 * 1000
+
+// sizePattern matches a size such as `512KB`, `1MB` or `2048`, using
+// decimal prefixes.
+var sizePattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)(?:([kKMG]?)B)?$`)
+
+// parseSize converts the given size into bytes.
+func parseSize(size string) (int64, error) {
+
+	match := sizePattern.FindStringSubmatch(size)
+	if match == nil {
+		return 0, fmt.Errorf("invalid size '%s', expected e.g. 512KB, 1MB or 2048", size)
+	}
+
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	switch match[2] {
+	case "k", "K":
+		value *= 1000
+	case "M":
+		value *= 1000 * 1000
+	case "G":
+		value *= 1000 * 1000 * 1000
+	}
+
+	return int64(value), nil
+}
@@ -0,0 +1,35 @@
+package protocols
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"2048":   2048,
+		"2048B":  2048,
+		"512KB":  512 * 1000,
+		"1MB":    1000 * 1000,
+		"1.5MB":  1500 * 1000,
+		"1GB":    1000 * 1000 * 1000,
+		"100kB":  100 * 1000,
+		"0.5KB":  500,
+		"10MB":   DefaultHTTPMaxSize,
+		"12345B": 12345,
+	}
+
+	for in, expected := range tests {
+		out, err := parseSize(in)
+		if err != nil {
+			t.Errorf("failed to parse %s: %s", in, err)
+			continue
+		}
+		if out != expected {
+			t.Errorf("expected %s to be %d bytes, got %d", in, expected, out)
+		}
+	}
+
+	for _, in := range []string{"", "MB", "1 MB", "1Mb", "1TB", "-1MB"} {
+		if _, err := parseSize(in); err == nil {
+			t.Errorf("expected %s to be invalid", in)
+		}
+	}
+}
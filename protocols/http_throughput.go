@@ -0,0 +1,67 @@
+package protocols
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// throughputPattern matches a rate such as `5MBps` (bytes) or `40Mbps`
+// (bits), using decimal prefixes.
+var throughputPattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)([kKMG]?)(B|b)ps$`)
+
+// parseThroughput converts the given rate into bytes per second.
+func parseThroughput(rate string) (float64, error) {
+
+	match := throughputPattern.FindStringSubmatch(rate)
+	if match == nil {
+		return 0, fmt.Errorf("invalid throughput '%s', expected e.g. 500KBps, 5MBps or 40Mbps", rate)
+	}
+
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	switch match[2] {
+	case "k", "K":
+		value *= 1000
+	case "M":
+		value *= 1000 * 1000
+	case "G":
+		value *= 1000 * 1000 * 1000
+	}
+
+	if match[3] == "b" {
+		value /= 8
+	}
+
+	return value, nil
+}
+
+// formatThroughput returns a human-readable version of the given rate, in
+// bytes per second.
+func formatThroughput(rate float64) string {
+
+	units := []string{"Bps", "KBps", "MBps", "GBps"}
+
+	unit := 0
+	for rate >= 1000 && unit < len(units)-1 {
+		rate /= 1000
+		unit++
+	}
+
+	return fmt.Sprintf("%.2f%s", rate, units[unit])
+}
+
+// measureThroughput returns the rate at which the given number of bytes
+// were received, in the given time.
+func measureThroughput(size int, elapsed time.Duration) float64 {
+
+	if elapsed <= 0 {
+		elapsed = time.Nanosecond
+	}
+
+	return float64(size) / elapsed.Seconds()
+}
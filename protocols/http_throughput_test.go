@@ -0,0 +1,70 @@
+package protocols
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+func TestParseThroughput(t *testing.T) {
+	tests := map[string]float64{
+		"100Bps":  100,
+		"5MBps":   5000000,
+		"1.5KBps": 1500,
+		"2kBps":   2000,
+		"40Mbps":  5000000,
+		"1Gbps":   125000000,
+	}
+
+	for input, expected := range tests {
+		rate, err := parseThroughput(input)
+		if err != nil {
+			t.Errorf("failed to parse '%s': %s", input, err)
+			continue
+		}
+		if rate != expected {
+			t.Errorf("expected '%s' to be %f bytes per second, got %f", input, expected, rate)
+		}
+	}
+
+	for _, input := range []string{"", "5MB", "fast", "5TBps"} {
+		if _, err := parseThroughput(input); err == nil {
+			t.Errorf("expected '%s' to be invalid", input)
+		}
+	}
+
+	if formatted := formatThroughput(5250000); formatted != "5.25MBps" {
+		t.Errorf("unexpected formatting %s", formatted)
+	}
+}
+
+func TestHTTPMinThroughput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunk := []byte(strings.Repeat("x", 1000))
+		for i := 0; i < 5; i++ {
+			w.Write(chunk)
+			w.(http.Flusher).Flush()
+			if r.URL.Path == "/slow" {
+				time.Sleep(100 * time.Millisecond)
+			}
+		}
+	}))
+	defer server.Close()
+
+	opts := test.Options{Timeout: 5 * time.Second}
+	tst := test.Test{Target: server.URL + "/fast", Type: "http", Arguments: map[string]string{"min-throughput": "100KBps"}}
+	if err := (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the fast download to pass, got %s", err)
+	}
+
+	// 5000 bytes in half a second, at most.
+	tst.Target = server.URL + "/slow"
+	err := (&HTTPTest{}).RunTest(tst, "127.0.0.1", opts)
+	if err == nil || !strings.Contains(err.Error(), "throughput was") || !strings.Contains(err.Error(), "5000 bytes") {
+		t.Errorf("expected the slow download to fail, got %v", err)
+	}
+}
@@ -8,6 +8,12 @@
 //
 //    host.example.com must run imap [with username 'steve@steve' with password 'secret']
 //
+// Once logged in you can also ensure the number of messages in a mailbox
+// (INBOX by default) is within some bounds, for example to detect a stuck
+// mail-processing pipeline:
+//
+//    host.example.com must run imap with username 'alerts' with password 'secret' with mailbox 'INBOX' with max-messages 100
+//
 
 package protocols
 
@@ -30,9 +36,12 @@ type IMAPTest struct {
 // their values.
 func (s *IMAPTest) Arguments() map[string]string {
 	known := map[string]string{
-		"port":     "^[0-9]+$",
-		"username": ".*",
-		"password": ".*",
+		"port":         "^[0-9]+$",
+		"username":     ".*",
+		"password":     ".*",
+		"mailbox":      ".*",
+		"min-messages": "^[0-9]+$",
+		"max-messages": "^[0-9]+$",
 	}
 	return known
 }
@@ -55,6 +64,12 @@ IMAP Tester
  This test is invoked via input like so:
 
     host.example.com must run imap
+
+ Once logged in you can also ensure the number of messages in a mailbox
+ (INBOX by default) is within some bounds, for example to detect a stuck
+ mail-processing pipeline:
+
+    host.example.com must run imap with username 'alerts' with password 'secret' with mailbox 'INBOX' with max-messages 100
 `
 	return str
 }
@@ -109,6 +124,14 @@ func (s *IMAPTest) RunTest(tst test.Test, target string, opts test.Options) erro
 	}
 	defer con.Close()
 
+	//
+	// Counting messages requires a login
+	//
+	if (tst.Arguments["min-messages"] != "" || tst.Arguments["max-messages"] != "") &&
+		(tst.Arguments["username"] == "" || tst.Arguments["password"] == "") {
+		return fmt.Errorf("a username and password are required to check the number of messages")
+	}
+
 	//
 	// If we got username/password then use them
 	//
@@ -118,6 +141,12 @@ func (s *IMAPTest) RunTest(tst test.Test, target string, opts test.Options) erro
 			return err
 		}
 
+		// Check the number of messages, if we've been asked to.
+		err = imapCheckMessages(con, tst)
+		if err != nil {
+			return err
+		}
+
 		// Logout so that we don't keep the handle open.
 		err = con.Logout()
 		if err != nil {
@@ -128,6 +157,51 @@ func (s *IMAPTest) RunTest(tst test.Test, target string, opts test.Options) erro
 	return nil
 }
 
+// imapCheckMessages selects the mailbox of the test, INBOX by default,
+// and ensures the number of messages it contains is within the bounds
+// given via `min-messages` and `max-messages`.
+//
+// The connection must already be authenticated.
+func imapCheckMessages(con *client.Client, tst test.Test) error {
+
+	if tst.Arguments["min-messages"] == "" && tst.Arguments["max-messages"] == "" {
+		return nil
+	}
+
+	mailbox := tst.Arguments["mailbox"]
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+
+	// Select read-only, we don't want to change the state of any message
+	status, err := con.Select(mailbox, true)
+	if err != nil {
+		return err
+	}
+
+	if tst.Arguments["min-messages"] != "" {
+		min, err := strconv.ParseUint(tst.Arguments["min-messages"], 10, 32)
+		if err != nil {
+			return err
+		}
+		if uint64(status.Messages) < min {
+			return fmt.Errorf("mailbox %s contains %d messages, expected at least %d", mailbox, status.Messages, min)
+		}
+	}
+
+	if tst.Arguments["max-messages"] != "" {
+		max, err := strconv.ParseUint(tst.Arguments["max-messages"], 10, 32)
+		if err != nil {
+			return err
+		}
+		if uint64(status.Messages) > max {
+			return fmt.Errorf("mailbox %s contains %d messages, expected at most %d", mailbox, status.Messages, max)
+		}
+	}
+
+	return nil
+}
+
 //
 // Register our protocol-tester.
 //
@@ -11,6 +11,12 @@
 // Because IMAPS uses TLS it will test the validity of the certificate as
 // part of the test, if you wish to disable this add `with tls insecure`.
 //
+// Once logged in you can also ensure the number of messages in a mailbox
+// (INBOX by default) is within some bounds, for example to detect a stuck
+// mail-processing pipeline:
+//
+//    host.example.com must run imaps with username 'alerts' with password 'secret' with mailbox 'INBOX' with max-messages 100
+//
 
 package protocols
 
@@ -34,10 +40,13 @@ type IMAPSTest struct {
 // their values.
 func (s *IMAPSTest) Arguments() map[string]string {
 	known := map[string]string{
-		"port":     "^[0-9]+$",
-		"tls":      "insecure",
-		"username": ".*",
-		"password": ".*",
+		"port":         "^[0-9]+$",
+		"tls":          "insecure",
+		"username":     ".*",
+		"password":     ".*",
+		"mailbox":      ".*",
+		"min-messages": "^[0-9]+$",
+		"max-messages": "^[0-9]+$",
 	}
 	return known
 }
@@ -63,6 +72,12 @@ IMAPS Tester
 
  Because IMAPS uses TLS this test will ensure the validity of the certificate as
  part of the test, if you wish to disable this add "with tls insecure".
+
+ Once logged in you can also ensure the number of messages in a mailbox
+ (INBOX by default) is within some bounds, for example to detect a stuck
+ mail-processing pipeline:
+
+    host.example.com must run imaps with username 'alerts' with password 'secret' with mailbox 'INBOX' with max-messages 100
 `
 
 	return str
@@ -147,6 +162,14 @@ func (s *IMAPSTest) RunTest(tst test.Test, target string, opts test.Options) err
 	}
 	defer con.Close()
 
+	//
+	// Counting messages requires a login
+	//
+	if (tst.Arguments["min-messages"] != "" || tst.Arguments["max-messages"] != "") &&
+		(tst.Arguments["username"] == "" || tst.Arguments["password"] == "") {
+		return fmt.Errorf("a username and password are required to check the number of messages")
+	}
+
 	//
 	// If we got username/password then use them
 	//
@@ -156,6 +179,12 @@ func (s *IMAPSTest) RunTest(tst test.Test, target string, opts test.Options) err
 			return err
 		}
 
+		// Check the number of messages, if we've been asked to.
+		err = imapCheckMessages(con, tst)
+		if err != nil {
+			return err
+		}
+
 		// Logout so that we don't keep the handle open.
 		err = con.Logout()
 		if err != nil {
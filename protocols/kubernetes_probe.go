@@ -0,0 +1,281 @@
+// Kubernetes API Server Tester
+//
+// The kubernetes tester ensures that the API server of a Kubernetes
+// cluster is healthy, by fetching its /healthz and /livez endpoints,
+// which must both reply with a 200 status:
+//
+//    https://k8s.example.com:6443/ must run kubernetes
+//
+// Clusters which don't allow anonymous health-checks can be tested with
+// the bearer token of a service account, and with the CA certificate
+// which signed the certificate of the API server:
+//
+//    https://k8s.example.com:6443/ must run kubernetes with token 'eyJhbGciOi...' with ca-cert '/etc/overseer/k8s-ca.pem'
+//
+// Client certificates are supported as well, e.g. for a kubeadm admin:
+//
+//    https://k8s.example.com:6443/ must run kubernetes with client-cert '/etc/overseer/k8s.crt' with client-key '/etc/overseer/k8s.key'
+//
+// To also ensure the API server is ready to serve requests, and report
+// which of its checks (e.g. etcd) are failing if it isn't, you can use:
+//
+//    https://k8s.example.com:6443/ must run kubernetes with readyz true
+//
+// If you need to disable failures due to expired, broken, or
+// otherwise bogus SSL certificates you can do so via the tls setting:
+//
+//    https://k8s.example.com:6443/ must run kubernetes with tls insecure
+//
+
+package protocols
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// KubernetesTest is our object
+type KubernetesTest struct {
+}
+
+// Arguments returns the names of arguments which this protocol-test
+// understands, along with corresponding regular-expressions to validate
+// their values.
+func (s *KubernetesTest) Arguments() map[string]string {
+	known := map[string]string{
+		"token":       `^\S+$`,
+		"ca-cert":     ".*",
+		"client-cert": ".*",
+		"client-key":  ".*",
+		"readyz":      "^(true|false)$",
+		"tls":         "insecure",
+	}
+	return known
+}
+
+// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
+func (s *KubernetesTest) ShouldResolveHostname() bool {
+	return true
+}
+
+// Example returns sample usage-instructions for self-documentation purposes.
+func (s *KubernetesTest) Example() string {
+	str := `
+Kubernetes API Server Tester
+----------------------------
+ The kubernetes tester ensures that the API server of a Kubernetes
+ cluster is healthy, by fetching its /healthz and /livez endpoints,
+ which must both reply with a 200 status:
+
+    https://k8s.example.com:6443/ must run kubernetes
+
+ Clusters which don't allow anonymous health-checks can be tested with
+ the bearer token of a service account, and with the CA certificate
+ which signed the certificate of the API server:
+
+    https://k8s.example.com:6443/ must run kubernetes with token 'eyJhbGciOi...' with ca-cert '/etc/overseer/k8s-ca.pem'
+
+ Client certificates are supported as well, e.g. for a kubeadm admin:
+
+    https://k8s.example.com:6443/ must run kubernetes with client-cert '/etc/overseer/k8s.crt' with client-key '/etc/overseer/k8s.key'
+
+ To also ensure the API server is ready to serve requests, and report
+ which of its checks (e.g. etcd) are failing if it isn't, you can use:
+
+    https://k8s.example.com:6443/ must run kubernetes with readyz true
+
+ If you need to disable failures due to expired, broken, or
+ otherwise bogus SSL certificates you can do so via the tls setting:
+
+    https://k8s.example.com:6443/ must run kubernetes with tls insecure
+`
+	return str
+}
+
+// RunTest is the part of our API which is invoked to actually execute a
+// test against the given target.
+//
+// In this case we fetch the health endpoints from the resolved IP
+// address.
+func (s *KubernetesTest) RunTest(tst test.Test, target string, opts test.Options) error {
+
+	u, err := url.Parse(tst.Target)
+	if err != nil {
+		return err
+	}
+
+	port := "443"
+	if u.Scheme == "http" {
+		port = "80"
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+
+	tlsConfig, err := s.tlsConfig(tst)
+	if err != nil {
+		return err
+	}
+
+	//
+	// Connect to the IP we've been given, rather than to the
+	// result of a new lookup of the hostname.
+	//
+	address := fmt.Sprintf("%s:%s", target, port)
+	if strings.Contains(target, ":") {
+		address = fmt.Sprintf("[%s]:%s", target, port)
+	}
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+		TLSClientConfig: tlsConfig,
+	}
+	defer tr.CloseIdleConnections()
+
+	timeout := opts.Timeout
+	if tst.Timeout != nil {
+		timeout = *tst.Timeout
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: tr,
+	}
+
+	base := strings.TrimSuffix(u.String(), "/")
+	token := tst.Arguments["token"]
+
+	for _, endpoint := range []string{"/healthz", "/livez"} {
+		status, _, err := s.get(client, base+endpoint, token)
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("%s reported the API server as unhealthy, status code was %d not 200", endpoint, status)
+		}
+	}
+
+	if tst.Arguments["readyz"] != "true" {
+		return nil
+	}
+
+	status, body, err := s.get(client, base+"/readyz?verbose", token)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusOK {
+		return nil
+	}
+
+	failing := kubernetesFailingChecks(body)
+	if len(failing) == 0 {
+		return fmt.Errorf("/readyz reported the API server as not ready, status code was %d not 200", status)
+	}
+	return fmt.Errorf("/readyz reported the API server as not ready, failing checks: %s", strings.Join(failing, ", "))
+}
+
+// tlsConfig builds the TLS configuration of the test, from its CA and
+// client certificates.
+func (s *KubernetesTest) tlsConfig(tst test.Test) (*tls.Config, error) {
+	config := &tls.Config{}
+
+	if tst.Arguments["tls"] == "insecure" {
+		config.InsecureSkipVerify = true
+	}
+
+	if tst.Arguments["ca-cert"] != "" {
+		pem, err := ioutil.ReadFile(tst.Arguments["ca-cert"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CA certificate: %s", err.Error())
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", tst.Arguments["ca-cert"])
+		}
+	}
+
+	if tst.Arguments["client-cert"] != "" || tst.Arguments["client-key"] != "" {
+		if tst.Arguments["client-cert"] == "" || tst.Arguments["client-key"] == "" {
+			return nil, fmt.Errorf("client-cert and client-key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(tst.Arguments["client-cert"], tst.Arguments["client-key"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %s", err.Error())
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// get fetches the given URL, with the given bearer token if any, and
+// returns the status code and the (size-limited) body of the response.
+func (s *KubernetesTest) get(client *http.Client, target string, token string) (int, string, error) {
+
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return 0, "", err
+	}
+
+	req.Header.Set("User-Agent", "overseer/probe")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, 64*1024))
+	if err != nil {
+		return 0, "", err
+	}
+
+	return response.StatusCode, string(body), nil
+}
+
+// kubernetesFailingChecks returns the names of the failing checks listed
+// in the verbose output of a health endpoint, e.g. "etcd" for the line
+// "[-]etcd failed: reason withheld".
+func kubernetesFailingChecks(body string) []string {
+	var failing []string
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[-]") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "[-]"))
+		if len(fields) > 0 {
+			failing = append(failing, fields[0])
+		}
+	}
+
+	return failing
+}
+
+//
+// Register our protocol-tester.
+//
+func init() {
+	Register("kubernetes", func() ProtocolTest {
+		return &KubernetesTest{}
+	})
+}
@@ -0,0 +1,101 @@
+package protocols
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// newAPIServer returns a fake Kubernetes API server, which requires the
+// given token and reports the given readyz output.
+func newAPIServer(token string, livez int, readyz string) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/healthz":
+			w.Write([]byte("ok"))
+		case "/livez":
+			w.WriteHeader(livez)
+		case "/readyz":
+			if r.URL.Query()["verbose"] == nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if strings.Contains(readyz, "[-]") {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			w.Write([]byte(readyz))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestKubernetes(t *testing.T) {
+	server := newAPIServer("secret", http.StatusOK, "[+]ping ok\n[+]etcd ok\nreadyz check passed\n")
+	defer server.Close()
+
+	// Trust the certificate of the server.
+	ca, err := ioutil.TempFile("", "ca")
+	if err != nil {
+		t.Fatalf("failed to create the CA file: %s", err)
+	}
+	defer os.Remove(ca.Name())
+	pem.Encode(ca, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	ca.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(args map[string]string) error {
+		tst := test.Test{Target: server.URL, Type: "kubernetes", Arguments: args}
+		return (&KubernetesTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	if err = run(map[string]string{"token": "secret", "ca-cert": ca.Name(), "readyz": "true"}); err != nil {
+		t.Errorf("expected the API server to be healthy, got %s", err)
+	}
+
+	if err = run(map[string]string{"token": "secret"}); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("expected the untrusted certificate to fail, got %v", err)
+	}
+
+	err = run(map[string]string{"token": "wrong", "tls": "insecure"})
+	if err == nil || !strings.Contains(err.Error(), "/healthz reported the API server as unhealthy, status code was 401") {
+		t.Errorf("expected the wrong token to fail, got %v", err)
+	}
+
+	if err = run(map[string]string{"ca-cert": "/nonexistent/ca.pem"}); err == nil || !strings.Contains(err.Error(), "failed to read the CA certificate") {
+		t.Errorf("expected the missing CA certificate to be reported, got %v", err)
+	}
+}
+
+func TestKubernetesUnhealthy(t *testing.T) {
+	opts := test.Options{Timeout: 2 * time.Second}
+	run := func(server *httptest.Server) error {
+		tst := test.Test{Target: server.URL, Type: "kubernetes", Arguments: map[string]string{"token": "secret", "tls": "insecure", "readyz": "true"}}
+		return (&KubernetesTest{}).RunTest(tst, "127.0.0.1", opts)
+	}
+
+	notLive := newAPIServer("secret", http.StatusInternalServerError, "")
+	defer notLive.Close()
+	if err := run(notLive); err == nil || !strings.Contains(err.Error(), "/livez reported the API server as unhealthy") {
+		t.Errorf("expected /livez to fail, got %v", err)
+	}
+
+	notReady := newAPIServer("secret", http.StatusOK, "[+]ping ok\n[-]etcd failed: reason withheld\n[-]informer-sync failed: reason withheld\nreadyz check failed\n")
+	defer notReady.Close()
+	err := run(notReady)
+	if err == nil || !strings.Contains(err.Error(), "failing checks: etcd, informer-sync") {
+		t.Errorf("expected the failing checks to be reported, got %v", err)
+	}
+}
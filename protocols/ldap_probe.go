@@ -0,0 +1,239 @@
+// LDAP Tester
+//
+// The LDAP tester connects to a remote LDAP server, and ensures that
+// it is possible to bind to it with the given credentials.
+//
+// This test is invoked via input like so:
+//
+//    ldap.example.com must run ldap with bind-dn 'cn=monitor,dc=example,dc=com' with password 'secret'
+//
+// Without a bind-dn the test only ensures the server accepts connections.
+//
+// The default port is 389, to connect via LDAPS you can use the tls
+// setting, in which case the default port is 636:
+//
+//    ldap.example.com must run ldap with tls true [with port 636]
+//
+// Alternatively you can upgrade a plain connection via StartTLS:
+//
+//    ldap.example.com must run ldap with starttls true
+//
+// If you need to disable failures due to expired, broken, or otherwise
+// bogus SSL certificates you can use `with tls insecure`, which enables
+// LDAPS when StartTLS isn't requested.
+//
+// Once bound you can run a search, and the test will fail if no entries
+// are returned.  The filter defaults to `(objectClass=*)`:
+//
+//    ldap.example.com must run ldap with bind-dn 'cn=monitor,dc=example,dc=com' with password 'secret' with base-dn 'ou=people,dc=example,dc=com' with filter '(uid=steve)'
+//
+
+package protocols
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPTest is our object
+type LDAPTest struct {
+}
+
+// Arguments returns the names of arguments which this protocol-test
+// understands, along with corresponding regular-expressions to validate
+// their values.
+func (s *LDAPTest) Arguments() map[string]string {
+	known := map[string]string{
+		"port":     "^[0-9]+$",
+		"bind-dn":  ".*",
+		"password": ".*",
+		"tls":      "^(true|false|insecure)$",
+		"starttls": "^(true|false)$",
+		"base-dn":  ".*",
+		"filter":   `^\(.*\)$`,
+	}
+	return known
+}
+
+// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
+func (s *LDAPTest) ShouldResolveHostname() bool {
+	return true
+}
+
+// Example returns sample usage-instructions for self-documentation purposes.
+func (s *LDAPTest) Example() string {
+	str := `
+LDAP Tester
+-----------
+ The LDAP tester connects to a remote LDAP server, and ensures that
+ it is possible to bind to it with the given credentials.
+
+ This test is invoked via input like so:
+
+    ldap.example.com must run ldap with bind-dn 'cn=monitor,dc=example,dc=com' with password 'secret'
+
+ Without a bind-dn the test only ensures the server accepts connections.
+
+ The default port is 389, to connect via LDAPS you can use the tls
+ setting, in which case the default port is 636:
+
+    ldap.example.com must run ldap with tls true [with port 636]
+
+ Alternatively you can upgrade a plain connection via StartTLS:
+
+    ldap.example.com must run ldap with starttls true
+
+ If you need to disable failures due to expired, broken, or otherwise
+ bogus SSL certificates you can use 'with tls insecure', which enables
+ LDAPS when StartTLS isn't requested.
+
+ Once bound you can run a search, and the test will fail if no entries
+ are returned.  The filter defaults to '(objectClass=*)':
+
+    ldap.example.com must run ldap with bind-dn 'cn=monitor,dc=example,dc=com' with password 'secret' with base-dn 'ou=people,dc=example,dc=com' with filter '(uid=steve)'
+`
+	return str
+}
+
+// RunTest is the part of our API which is invoked to actually execute a
+// test against the given target.
+//
+// In this case we connect to the LDAP server, bind to it if we've been
+// given credentials, and then run a search if one was requested.
+func (s *LDAPTest) RunTest(tst test.Test, target string, opts test.Options) error {
+	var err error
+
+	startTLS := tst.Arguments["starttls"] == "true"
+	ldaps := !startTLS && (tst.Arguments["tls"] == "true" || tst.Arguments["tls"] == "insecure")
+
+	//
+	// The default port to connect to.
+	//
+	port := 389
+	if ldaps {
+		port = 636
+	}
+
+	//
+	// If the user specified a different port update to use it.
+	//
+	if tst.Arguments["port"] != "" {
+		port, err = strconv.Atoi(tst.Arguments["port"])
+		if err != nil {
+			return err
+		}
+	}
+
+	//
+	// The target might be an IPv4 or an IPv6 address.
+	//
+	address := net.JoinHostPort(target, strconv.Itoa(port))
+
+	// The default TLS configuration verifies the certificate
+	// matches the hostname of our target.
+	tlsconfig := &tls.Config{
+		ServerName: tst.Target,
+	}
+
+	// However if the user is being insecure then we'll validate
+	// nothing - allowing self-signed certificates, and hostname
+	// mismatches.
+	if tst.Arguments["tls"] == "insecure" {
+		tlsconfig = &tls.Config{
+			InsecureSkipVerify: true,
+		}
+	}
+
+	//
+	// Make the connection, with an explicit timeout.
+	//
+	d := &net.Dialer{Timeout: opts.Timeout}
+
+	var conn net.Conn
+	if ldaps {
+		conn, err = tls.DialWithDialer(d, "tcp", address, tlsconfig)
+	} else {
+		conn, err = d.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+
+	//
+	// Don't wait forever for a slow server.
+	//
+	err = conn.SetDeadline(time.Now().Add(opts.Timeout))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	client := ldap.NewConn(conn, ldaps)
+	client.Start()
+	client.SetTimeout(opts.Timeout)
+	defer client.Close()
+
+	if startTLS {
+		if err = client.StartTLS(tlsconfig); err != nil {
+			return fmt.Errorf("StartTLS failed: %s", err.Error())
+		}
+	}
+
+	if tst.Arguments["bind-dn"] != "" {
+		if err = client.Bind(tst.Arguments["bind-dn"], tst.Arguments["password"]); err != nil {
+			return fmt.Errorf("failed to bind as '%s': %s", tst.Arguments["bind-dn"], err.Error())
+		}
+	}
+
+	//
+	// If there's no search to run we're done.
+	//
+	if tst.Arguments["base-dn"] == "" {
+		if tst.Arguments["filter"] != "" {
+			return fmt.Errorf("a filter requires a base-dn to search")
+		}
+		return nil
+	}
+
+	filter := tst.Arguments["filter"]
+	if filter == "" {
+		filter = "(objectClass=*)"
+	}
+
+	//
+	// We only care whether anything matches, so one entry is enough.
+	//
+	request := ldap.NewSearchRequest(tst.Arguments["base-dn"],
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1,
+		int(opts.Timeout.Seconds()), false, filter, []string{"dn"}, nil)
+
+	result, err := client.Search(request)
+	if err != nil && !(ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) && result != nil && len(result.Entries) > 0) {
+		return fmt.Errorf("search failed: %s", err.Error())
+	}
+
+	if len(result.Entries) == 0 {
+		return fmt.Errorf("search of '%s' with filter '%s' returned no entries", tst.Arguments["base-dn"], filter)
+	}
+
+	if opts.Verbose {
+		fmt.Printf("\tSearch found entry '%s'\n", result.Entries[0].DN)
+	}
+
+	return nil
+}
+
+//
+// Register our protocol-tester.
+//
+func init() {
+	Register("ldap", func() ProtocolTest {
+		return &LDAPTest{}
+	})
+}
@@ -0,0 +1,141 @@
+package protocols
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// ldapResult builds an LDAP response message of the given application
+// tag, carrying the given result-code.
+func ldapResult(id int64, tag ber.Tag, code int64, message string) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "resultCode"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "diagnosticMessage"))
+	packet.AppendChild(response)
+
+	return packet
+}
+
+// ldapEntry builds a search-result entry message with the given DN.
+func ldapEntry(id int64, dn string) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, 4, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "objectName"))
+	entry.AppendChild(ber.NewSequence("attributes"))
+	packet.AppendChild(entry)
+
+	return packet
+}
+
+// serveLDAP is a minimal LDAP server, accepting binds from
+// "cn=monitor,dc=example,dc=com" with the password "secret", and
+// returning a single entry for searches below "dc=example,dc=com",
+// except for "ou=empty,dc=example,dc=com".
+func serveLDAP(conn net.Conn) {
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		id, _ := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+
+		var replies []*ber.Packet
+		switch request.Tag {
+		case 0:
+			// Bind request: version, name, simple password.
+			name, _ := request.Children[1].Value.(string)
+			password := request.Children[2].Data.String()
+			if name == "cn=monitor,dc=example,dc=com" && password == "secret" {
+				replies = append(replies, ldapResult(id, 1, 0, ""))
+			} else {
+				replies = append(replies, ldapResult(id, 1, 49, "invalid credentials"))
+			}
+		case 3:
+			// Search request: the base object comes first.
+			base, _ := request.Children[0].Value.(string)
+			if strings.HasSuffix(base, "dc=example,dc=com") && base != "ou=empty,dc=example,dc=com" {
+				replies = append(replies, ldapEntry(id, "uid=steve,"+base))
+			}
+			replies = append(replies, ldapResult(id, 5, 0, ""))
+		default:
+			// Unbind, or anything we don't understand.
+			return
+		}
+
+		for _, reply := range replies {
+			if _, err = conn.Write(reply.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestLDAPBind(t *testing.T) {
+	port, stop := startTCPServer(t, serveLDAP)
+	defer stop()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	probe := &LDAPTest{}
+
+	// Valid credentials.
+	tst := test.Test{Target: "127.0.0.1", Type: "ldap", Arguments: map[string]string{
+		"port":     port,
+		"bind-dn":  "cn=monitor,dc=example,dc=com",
+		"password": "secret",
+	}}
+	if err := probe.RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the bind to succeed, got %s", err)
+	}
+
+	// Invalid credentials.
+	tst.Arguments["password"] = "wrong"
+	err := probe.RunTest(tst, "127.0.0.1", opts)
+	if err == nil {
+		t.Fatalf("expected the bind to fail")
+	}
+	if !strings.Contains(err.Error(), "invalid credentials") {
+		t.Errorf("expected the server error to be reported, got %s", err)
+	}
+}
+
+func TestLDAPSearch(t *testing.T) {
+	port, stop := startTCPServer(t, serveLDAP)
+	defer stop()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	probe := &LDAPTest{}
+
+	tst := test.Test{Target: "127.0.0.1", Type: "ldap", Arguments: map[string]string{
+		"port":     port,
+		"bind-dn":  "cn=monitor,dc=example,dc=com",
+		"password": "secret",
+		"base-dn":  "ou=people,dc=example,dc=com",
+		"filter":   "(uid=steve)",
+	}}
+	if err := probe.RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the search to succeed, got %s", err)
+	}
+
+	// No entries found.
+	tst.Arguments["base-dn"] = "ou=empty,dc=example,dc=com"
+	err := probe.RunTest(tst, "127.0.0.1", opts)
+	if err == nil {
+		t.Fatalf("expected an empty search to fail")
+	}
+	if !strings.Contains(err.Error(), "returned no entries") {
+		t.Errorf("unexpected error %s", err)
+	}
+}
@@ -0,0 +1,213 @@
+// Memcached Tester
+//
+// The Memcached tester connects to a remote memcached server, and
+// ensures that it replies to the `version` command.
+//
+// This test is invoked via input like so:
+//
+//    host.example.com must run memcached [with port 11211]
+//
+// You can also ensure the server is able to store values, in which case a
+// random key is set, read back, and then deleted:
+//
+//    host.example.com must run memcached with set-get true
+//
+
+package protocols
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// MEMCACHEDTest is our object
+type MEMCACHEDTest struct {
+}
+
+// Arguments returns the names of arguments which this protocol-test
+// understands, along with corresponding regular-expressions to validate
+// their values.
+func (s *MEMCACHEDTest) Arguments() map[string]string {
+	known := map[string]string{
+		"port":    "^[0-9]+$",
+		"set-get": "^(true|false)$",
+	}
+	return known
+}
+
+// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
+func (s *MEMCACHEDTest) ShouldResolveHostname() bool {
+	return true
+}
+
+// Example returns sample usage-instructions for self-documentation purposes.
+func (s *MEMCACHEDTest) Example() string {
+	str := `
+Memcached Tester
+----------------
+ The Memcached tester connects to a remote memcached server, and
+ ensures that it replies to the 'version' command.
+
+ This test is invoked via input like so:
+
+    host.example.com must run memcached [with port 11211]
+
+ You can also ensure the server is able to store values, in which case a
+ random key is set, read back, and then deleted:
+
+    host.example.com must run memcached with set-get true
+`
+	return str
+}
+
+// memcachedCommand sends the given command, and returns the first line
+// of the response, without the trailing newline.
+func memcachedCommand(conn net.Conn, reader *bufio.Reader, command string) (string, error) {
+	_, err := conn.Write([]byte(command + "\r\n"))
+	if err != nil {
+		return "", err
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// RunTest is the part of our API which is invoked to actually execute a
+// test against the given target.
+//
+// In this case we make a TCP connection, defaulting to port 11211, and
+// ensure the server replies to the `version` command.
+func (s *MEMCACHEDTest) RunTest(tst test.Test, target string, opts test.Options) error {
+	var err error
+
+	//
+	// The default port to connect to.
+	//
+	port := 11211
+
+	//
+	// If the user specified a different port update to use it.
+	//
+	if tst.Arguments["port"] != "" {
+		port, err = strconv.Atoi(tst.Arguments["port"])
+		if err != nil {
+			return err
+		}
+	}
+
+	//
+	// Set an explicit timeout
+	//
+	d := net.Dialer{Timeout: opts.Timeout}
+
+	//
+	// The target might be an IPv4 or an IPv6 address.
+	//
+	address := net.JoinHostPort(target, strconv.Itoa(port))
+
+	//
+	// Make the TCP connection.
+	//
+	conn, err := d.Dial("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	//
+	// Don't wait forever for the remote host.
+	//
+	err = conn.SetDeadline(time.Now().Add(opts.Timeout))
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(conn)
+
+	version, err := memcachedCommand(conn, reader, "version")
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(version, "VERSION ") {
+		return fmt.Errorf("unexpected response to 'version': '%s'", version)
+	}
+
+	if opts.Verbose {
+		fmt.Printf("\tMemcached version is %s\n", strings.TrimPrefix(version, "VERSION "))
+	}
+
+	if tst.Arguments["set-get"] != "true" {
+		return nil
+	}
+
+	//
+	// Store a random key, with a short expiry in case we fail to
+	// delete it, and read it back.
+	//
+	key := "overseer-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	value := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	reply, err := memcachedCommand(conn, reader, fmt.Sprintf("set %s 0 60 %d\r\n%s", key, len(value), value))
+	if err != nil {
+		return err
+	}
+	if reply != "STORED" {
+		return fmt.Errorf("failed to set key '%s': '%s'", key, reply)
+	}
+
+	//
+	// Whatever happens next, clean up after ourselves.
+	//
+	defer memcachedCommand(conn, reader, "delete "+key)
+
+	reply, err = memcachedCommand(conn, reader, "get "+key)
+	if err != nil {
+		return err
+	}
+	if reply == "END" {
+		return fmt.Errorf("key '%s' was not found after being set", key)
+	}
+	if !strings.HasPrefix(reply, "VALUE "+key+" ") {
+		return fmt.Errorf("unexpected response to 'get': '%s'", reply)
+	}
+
+	//
+	// Read the data block, followed by the END marker.
+	//
+	data, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	end, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.TrimRight(end, "\r\n") != "END" {
+		return fmt.Errorf("unexpected end of 'get' response: '%s'", strings.TrimRight(end, "\r\n"))
+	}
+
+	data = strings.TrimRight(data, "\r\n")
+	if data != value {
+		return fmt.Errorf("key '%s' was set to '%s', but read back as '%s'", key, value, data)
+	}
+
+	return nil
+}
+
+//
+// Register our protocol-tester.
+//
+func init() {
+	Register("memcached", func() ProtocolTest {
+		return &MEMCACHEDTest{}
+	})
+}
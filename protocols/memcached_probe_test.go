@@ -0,0 +1,118 @@
+package protocols
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// serveMemcached returns a handler speaking enough of the memcached text
+// protocol for our tests.  If corrupt is true values are stored with an
+// extra character appended.
+func serveMemcached(store map[string]string, corrupt bool) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				return
+			}
+
+			switch fields[0] {
+			case "version":
+				conn.Write([]byte("VERSION 1.6.9\r\n"))
+			case "set":
+				data, _ := reader.ReadString('\n')
+				data = strings.TrimRight(data, "\r\n")
+				if corrupt {
+					data += "x"
+				}
+				store[fields[1]] = data
+				conn.Write([]byte("STORED\r\n"))
+			case "get":
+				if data, ok := store[fields[1]]; ok {
+					conn.Write([]byte(fmt.Sprintf("VALUE %s 0 %d\r\n%s\r\n", fields[1], len(data), data)))
+				}
+				conn.Write([]byte("END\r\n"))
+			case "delete":
+				delete(store, fields[1])
+				conn.Write([]byte("DELETED\r\n"))
+			default:
+				conn.Write([]byte("ERROR\r\n"))
+			}
+		}
+	}
+}
+
+func TestMemcachedVersion(t *testing.T) {
+	port, stop := startTCPServer(t, serveMemcached(map[string]string{}, false))
+	defer stop()
+
+	tst := test.Test{Target: "127.0.0.1", Type: "memcached", Arguments: map[string]string{"port": port}}
+	if err := (&MEMCACHEDTest{}).RunTest(tst, "127.0.0.1", test.Options{Timeout: 2 * time.Second}); err != nil {
+		t.Errorf("expected the version check to succeed, got %s", err)
+	}
+
+	// Something which isn't memcached.
+	port, stop = startTCPServer(t, func(conn net.Conn) {
+		conn.Write([]byte("-ERR unknown command 'version'\r\n"))
+	})
+	defer stop()
+
+	tst.Arguments["port"] = port
+	if err := (&MEMCACHEDTest{}).RunTest(tst, "127.0.0.1", test.Options{Timeout: 2 * time.Second}); err == nil {
+		t.Errorf("expected an invalid response to fail")
+	}
+}
+
+func TestMemcachedSetGet(t *testing.T) {
+	store := map[string]string{}
+	port, stop := startTCPServer(t, serveMemcached(store, false))
+	defer stop()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	tst := test.Test{Target: "127.0.0.1", Type: "memcached", Arguments: map[string]string{"port": port, "set-get": "true"}}
+	if err := (&MEMCACHEDTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected set-get to succeed, got %s", err)
+	}
+	if len(store) != 0 {
+		t.Errorf("expected the key to be deleted, found %v", store)
+	}
+
+	// Values which don't survive the round-trip.
+	port, stop = startTCPServer(t, serveMemcached(map[string]string{}, true))
+	defer stop()
+
+	tst.Arguments["port"] = port
+	err := (&MEMCACHEDTest{}).RunTest(tst, "127.0.0.1", opts)
+	if err == nil {
+		t.Fatalf("expected a mismatched value to fail")
+	}
+	if !strings.Contains(err.Error(), "read back as") {
+		t.Errorf("unexpected error %s", err)
+	}
+}
+
+func TestMemcachedRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+
+	tst := test.Test{Target: "127.0.0.1", Type: "memcached", Arguments: map[string]string{"port": port}}
+	if err = (&MEMCACHEDTest{}).RunTest(tst, "127.0.0.1", test.Options{Timeout: 2 * time.Second}); err == nil {
+		t.Errorf("expected a refused connection to fail")
+	}
+}
@@ -0,0 +1,65 @@
+package protocols
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoDriver implements mongoPinger via the official MongoDB driver.
+type mongoDriver struct{}
+
+// Ping connects to the deployment, sends it the `ping` command, and then
+// disconnects.
+func (d *mongoDriver) Ping(settings mongoSettings) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), settings.Timeout)
+	defer cancel()
+
+	clientOptions := options.Client().
+		SetHosts([]string{settings.Address}).
+		SetConnectTimeout(settings.Timeout).
+		SetServerSelectionTimeout(settings.Timeout).
+		SetAppName("overseer")
+
+	//
+	// Without a replica set we only talk to the given server, rather
+	// than discovering the others.
+	//
+	if settings.ReplicaSet != "" {
+		clientOptions.SetReplicaSet(settings.ReplicaSet)
+	} else {
+		clientOptions.SetDirect(true)
+	}
+
+	if settings.Username != "" {
+		clientOptions.SetAuth(options.Credential{
+			Username:   settings.Username,
+			Password:   settings.Password,
+			AuthSource: settings.Database,
+		})
+	}
+
+	if settings.TLS {
+		clientOptions.SetTLSConfig(&tls.Config{
+			ServerName:         settings.ServerName,
+			InsecureSkipVerify: settings.Insecure,
+		})
+	}
+
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+
+	err = client.Database(settings.Database).RunCommand(ctx, bson.D{{Key: "ping", Value: 1}}).Err()
+	if err != nil && mongo.IsTimeout(err) {
+		return fmt.Errorf("%w: %s", errMongoTimeout, err.Error())
+	}
+	return err
+}
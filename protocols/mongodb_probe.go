@@ -0,0 +1,198 @@
+// MongoDB Tester
+//
+// The MongoDB tester connects to a remote MongoDB server, and ensures
+// that it replies to the `ping` command.
+//
+// This test is invoked via input like so:
+//
+//    mongo.example.com must run mongodb [with port 27017] [with username 'app' with password 'secret'] [with database 'app']
+//
+// When credentials are given they are authenticated against the database
+// specified via `with database`, or "admin" by default, which is also
+// the database the `ping` command is sent to.
+//
+// By default only the given server is tested, to ensure the replica set it
+// belongs to is reachable, and has a primary, you can name it instead:
+//
+//    mongo.example.com must run mongodb with replica-set 'rs0'
+//
+// To connect via TLS you can use `with tls true`, and if you need to
+// disable failures due to expired, broken, or otherwise bogus SSL
+// certificates you can use `with tls insecure` instead.
+//
+
+package protocols
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// errMongoTimeout is returned, wrapped, by a mongoPinger when no server
+// could be reached within the timeout.
+var errMongoTimeout = errors.New("timed out")
+
+// mongoSettings contains everything needed to connect to a MongoDB
+// deployment.
+type mongoSettings struct {
+	Address    string
+	ServerName string
+	Username   string
+	Password   string
+	Database   string
+	ReplicaSet string
+	TLS        bool
+	Insecure   bool
+	Timeout    time.Duration
+}
+
+// mongoPinger connects to a MongoDB deployment, and sends it the `ping`
+// command.
+type mongoPinger interface {
+	Ping(settings mongoSettings) error
+}
+
+// MONGODBTest is our object
+type MONGODBTest struct {
+	// pinger talks to MongoDB, if nil we use the official driver.
+	pinger mongoPinger
+}
+
+// Arguments returns the names of arguments which this protocol-test
+// understands, along with corresponding regular-expressions to validate
+// their values.
+func (s *MONGODBTest) Arguments() map[string]string {
+	known := map[string]string{
+		"port":        "^[0-9]+$",
+		"username":    ".*",
+		"password":    ".*",
+		"database":    `^[^/\\. "$]+$`,
+		"replica-set": ".*",
+		"tls":         "^(true|false|insecure)$",
+	}
+	return known
+}
+
+// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
+func (s *MONGODBTest) ShouldResolveHostname() bool {
+	return true
+}
+
+// Example returns sample usage-instructions for self-documentation purposes.
+func (s *MONGODBTest) Example() string {
+	str := `
+MongoDB Tester
+--------------
+ The MongoDB tester connects to a remote MongoDB server, and ensures
+ that it replies to the 'ping' command.
+
+ This test is invoked via input like so:
+
+    mongo.example.com must run mongodb [with port 27017] [with username 'app' with password 'secret'] [with database 'app']
+
+ When credentials are given they are authenticated against the database
+ specified via 'with database', or "admin" by default, which is also
+ the database the 'ping' command is sent to.
+
+ By default only the given server is tested, to ensure the replica set it
+ belongs to is reachable, and has a primary, you can name it instead:
+
+    mongo.example.com must run mongodb with replica-set 'rs0'
+
+ To connect via TLS you can use 'with tls true', and if you need to
+ disable failures due to expired, broken, or otherwise bogus SSL
+ certificates you can use 'with tls insecure' instead.
+`
+	return str
+}
+
+// RunTest is the part of our API which is invoked to actually execute a
+// test against the given target.
+//
+// In this case we connect to the MongoDB server, authenticating if
+// required, and send it the `ping` command.
+func (s *MONGODBTest) RunTest(tst test.Test, target string, opts test.Options) error {
+	var err error
+
+	//
+	// The default port to connect to.
+	//
+	port := 27017
+
+	//
+	// If the user specified a different port update to use it.
+	//
+	if tst.Arguments["port"] != "" {
+		port, err = strconv.Atoi(tst.Arguments["port"])
+		if err != nil {
+			return err
+		}
+	}
+
+	if tst.Arguments["password"] != "" && tst.Arguments["username"] == "" {
+		return errors.New("a password requires a username")
+	}
+
+	//
+	// Default to connecting to an IPv4-address
+	//
+	address := fmt.Sprintf("%s:%d", target, port)
+
+	//
+	// If we find a ":" we know it is an IPv6 address though
+	//
+	if strings.Contains(target, ":") {
+		address = fmt.Sprintf("[%s]:%d", target, port)
+	}
+
+	database := tst.Arguments["database"]
+	if database == "" {
+		database = "admin"
+	}
+
+	settings := mongoSettings{
+		Address:    address,
+		ServerName: tst.Target,
+		Username:   tst.Arguments["username"],
+		Password:   tst.Arguments["password"],
+		Database:   database,
+		ReplicaSet: tst.Arguments["replica-set"],
+		TLS:        tst.Arguments["tls"] == "true" || tst.Arguments["tls"] == "insecure",
+		Insecure:   tst.Arguments["tls"] == "insecure",
+		Timeout:    opts.Timeout,
+	}
+
+	pinger := s.pinger
+	if pinger == nil {
+		pinger = &mongoDriver{}
+	}
+
+	start := time.Now()
+	err = pinger.Ping(settings)
+	if err != nil {
+		if errors.Is(err, errMongoTimeout) {
+			return fmt.Errorf("no MongoDB server was reachable within %s: %s", opts.Timeout, err.Error())
+		}
+		return fmt.Errorf("MongoDB ping failed: %s", err.Error())
+	}
+
+	if opts.Verbose {
+		fmt.Printf("\tMongoDB ping completed in %s\n", time.Since(start))
+	}
+
+	return nil
+}
+
+//
+// Register our protocol-tester.
+//
+func init() {
+	Register("mongodb", func() ProtocolTest {
+		return &MONGODBTest{}
+	})
+}
@@ -0,0 +1,96 @@
+package protocols
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// fakeMongoPinger accepts the user "app" with the password "secret", or
+// anonymous connections, and times out if slow is set.
+type fakeMongoPinger struct {
+	slow     bool
+	settings mongoSettings
+}
+
+func (f *fakeMongoPinger) Ping(settings mongoSettings) error {
+	f.settings = settings
+	if f.slow {
+		return fmt.Errorf("%w: server selection error: context deadline exceeded", errMongoTimeout)
+	}
+	if settings.Username != "" && (settings.Username != "app" || settings.Password != "secret") {
+		return errors.New("connection() error occurred during connection handshake: auth error: sasl conversation error: unable to authenticate using mechanism \"SCRAM-SHA-256\": (AuthenticationFailed) Authentication failed.")
+	}
+	return nil
+}
+
+func TestMongoDBPing(t *testing.T) {
+	pinger := &fakeMongoPinger{}
+	probe := &MONGODBTest{pinger: pinger}
+	opts := test.Options{Timeout: 5 * time.Second}
+
+	tst := test.Test{Target: "mongo.example.com", Type: "mongodb", Arguments: map[string]string{
+		"username":    "app",
+		"password":    "secret",
+		"database":    "app",
+		"replica-set": "rs0",
+		"tls":         "true",
+	}}
+	if err := probe.RunTest(tst, "10.0.0.1", opts); err != nil {
+		t.Fatalf("expected the ping to succeed, got %s", err)
+	}
+
+	expected := mongoSettings{
+		Address:    "10.0.0.1:27017",
+		ServerName: "mongo.example.com",
+		Username:   "app",
+		Password:   "secret",
+		Database:   "app",
+		ReplicaSet: "rs0",
+		TLS:        true,
+		Timeout:    5 * time.Second,
+	}
+	if pinger.settings != expected {
+		t.Errorf("unexpected settings %+v", pinger.settings)
+	}
+
+	// Defaults, and IPv6.
+	tst = test.Test{Target: "mongo.example.com", Type: "mongodb", Arguments: map[string]string{"port": "27018"}}
+	if err := probe.RunTest(tst, "2001:db8::1", opts); err != nil {
+		t.Fatalf("expected the ping to succeed, got %s", err)
+	}
+	if pinger.settings.Address != "[2001:db8::1]:27018" || pinger.settings.Database != "admin" || pinger.settings.TLS {
+		t.Errorf("unexpected settings %+v", pinger.settings)
+	}
+}
+
+func TestMongoDBFailures(t *testing.T) {
+	opts := test.Options{Timeout: 5 * time.Second}
+
+	// Invalid credentials.
+	tst := test.Test{Target: "mongo.example.com", Type: "mongodb", Arguments: map[string]string{
+		"username": "app",
+		"password": "wrong",
+	}}
+	err := (&MONGODBTest{pinger: &fakeMongoPinger{}}).RunTest(tst, "10.0.0.1", opts)
+	if err == nil || !strings.Contains(err.Error(), "Authentication failed") {
+		t.Errorf("expected the authentication to fail, got %v", err)
+	}
+
+	// Unreachable servers.
+	tst = test.Test{Target: "mongo.example.com", Type: "mongodb", Arguments: map[string]string{}}
+	err = (&MONGODBTest{pinger: &fakeMongoPinger{slow: true}}).RunTest(tst, "10.0.0.1", opts)
+	if err == nil || !strings.Contains(err.Error(), "no MongoDB server was reachable within 5s") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+
+	// A password without a username.
+	tst.Arguments["password"] = "secret"
+	if err = (&MONGODBTest{pinger: &fakeMongoPinger{}}).RunTest(tst, "10.0.0.1", opts); err == nil {
+		t.Errorf("expected a password without a username to fail")
+	}
+}
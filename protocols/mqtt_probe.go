@@ -0,0 +1,242 @@
+// MQTT Tester
+//
+// The MQTT tester connects to a remote MQTT broker, and ensures that the
+// connection is accepted.
+//
+// This test is invoked via input like so:
+//
+//    broker.example.com must run mqtt [with port 1883] [with username 'monitor' with password 'secret']
+//
+// To connect via TLS you can use the tls setting, in which case the
+// default port is 8883:
+//
+//    broker.example.com must run mqtt with tls true
+//
+// If you need to disable failures due to expired, broken, or otherwise
+// bogus SSL certificates you can use `with tls insecure` instead.
+//
+// You can also ensure that messages are delivered, by subscribing to a
+// topic and then publishing a message to it, which must be received
+// before the timeout expires:
+//
+//    broker.example.com must run mqtt with topic 'overseer/test' with publish 'ping'
+//
+
+package protocols
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTTTest is our object
+type MQTTTest struct {
+}
+
+// Arguments returns the names of arguments which this protocol-test
+// understands, along with corresponding regular-expressions to validate
+// their values.
+func (s *MQTTTest) Arguments() map[string]string {
+	known := map[string]string{
+		"port":     "^[0-9]+$",
+		"username": ".*",
+		"password": ".*",
+		"tls":      "^(true|false|insecure)$",
+		"topic":    `^[^#+]+$`,
+		"publish":  ".*",
+	}
+	return known
+}
+
+// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
+func (s *MQTTTest) ShouldResolveHostname() bool {
+	return true
+}
+
+// Example returns sample usage-instructions for self-documentation purposes.
+func (s *MQTTTest) Example() string {
+	str := `
+MQTT Tester
+-----------
+ The MQTT tester connects to a remote MQTT broker, and ensures that the
+ connection is accepted.
+
+ This test is invoked via input like so:
+
+    broker.example.com must run mqtt [with port 1883] [with username 'monitor' with password 'secret']
+
+ To connect via TLS you can use the tls setting, in which case the
+ default port is 8883:
+
+    broker.example.com must run mqtt with tls true
+
+ If you need to disable failures due to expired, broken, or otherwise
+ bogus SSL certificates you can use 'with tls insecure' instead.
+
+ You can also ensure that messages are delivered, by subscribing to a
+ topic and then publishing a message to it, which must be received
+ before the timeout expires:
+
+    broker.example.com must run mqtt with topic 'overseer/test' with publish 'ping'
+`
+	return str
+}
+
+// RunTest is the part of our API which is invoked to actually execute a
+// test against the given target.
+//
+// In this case we connect to the broker, and then publish a message and
+// wait for it to be delivered back to us, if required.
+func (s *MQTTTest) RunTest(tst test.Test, target string, opts test.Options) error {
+	var err error
+
+	useTLS := tst.Arguments["tls"] == "true" || tst.Arguments["tls"] == "insecure"
+
+	if (tst.Arguments["topic"] == "") != (tst.Arguments["publish"] == "") {
+		return errors.New("topic and publish must be used together")
+	}
+
+	//
+	// The default port to connect to.
+	//
+	port := 1883
+	if useTLS {
+		port = 8883
+	}
+
+	//
+	// If the user specified a different port update to use it.
+	//
+	if tst.Arguments["port"] != "" {
+		port, err = strconv.Atoi(tst.Arguments["port"])
+		if err != nil {
+			return err
+		}
+	}
+
+	//
+	// Default to connecting to an IPv4-address
+	//
+	address := fmt.Sprintf("%s:%d", target, port)
+
+	//
+	// If we find a ":" we know it is an IPv6 address though
+	//
+	if strings.Contains(target, ":") {
+		address = fmt.Sprintf("[%s]:%d", target, port)
+	}
+
+	options := mqtt.NewClientOptions()
+	options.SetClientID("overseer-" + strconv.FormatInt(time.Now().UnixNano(), 36))
+	options.SetCleanSession(true)
+	options.SetAutoReconnect(false)
+	options.SetConnectTimeout(opts.Timeout)
+	options.SetWriteTimeout(opts.Timeout)
+
+	if useTLS {
+		options.AddBroker("ssl://" + address)
+
+		// The default TLS configuration verifies the certificate
+		// matches the hostname of our target, unless the user is
+		// being insecure.
+		tlsconfig := &tls.Config{
+			ServerName: tst.Target,
+		}
+		if tst.Arguments["tls"] == "insecure" {
+			tlsconfig = &tls.Config{
+				InsecureSkipVerify: true,
+			}
+		}
+		options.SetTLSConfig(tlsconfig)
+	} else {
+		options.AddBroker("tcp://" + address)
+	}
+
+	if tst.Arguments["username"] != "" {
+		options.SetUsername(tst.Arguments["username"])
+		options.SetPassword(tst.Arguments["password"])
+	}
+
+	//
+	// Connect to the broker.
+	//
+	client := mqtt.NewClient(options)
+	if err = mqttWait(client.Connect(), opts.Timeout, "connect"); err != nil {
+		return err
+	}
+	defer client.Disconnect(250)
+
+	//
+	// If there's nothing to publish we're done.
+	//
+	if tst.Arguments["topic"] == "" {
+		return nil
+	}
+
+	topic := tst.Arguments["topic"]
+	payload := tst.Arguments["publish"]
+
+	//
+	// Subscribe before publishing, so that we can't miss our message.
+	//
+	received := make(chan struct{}, 1)
+	handler := func(_ mqtt.Client, msg mqtt.Message) {
+		if string(msg.Payload()) == payload {
+			select {
+			case received <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	start := time.Now()
+
+	if err = mqttWait(client.Subscribe(topic, 0, handler), opts.Timeout, "subscribe"); err != nil {
+		return err
+	}
+	defer client.Unsubscribe(topic)
+
+	if err = mqttWait(client.Publish(topic, 0, false, payload), opts.Timeout, "publish"); err != nil {
+		return err
+	}
+
+	select {
+	case <-received:
+	case <-time.After(opts.Timeout - time.Since(start)):
+		return fmt.Errorf("published message was not received on topic '%s' within %s", topic, opts.Timeout)
+	}
+
+	if opts.Verbose {
+		fmt.Printf("\tMessage received back in %s\n", time.Since(start))
+	}
+
+	return nil
+}
+
+// mqttWait waits for the given MQTT operation to complete, within the
+// timeout, and returns its error.
+func mqttWait(token mqtt.Token, timeout time.Duration, operation string) error {
+	if !token.WaitTimeout(timeout) {
+		return fmt.Errorf("timed out waiting for MQTT %s", operation)
+	}
+	if token.Error() != nil {
+		return fmt.Errorf("MQTT %s failed: %s", operation, token.Error().Error())
+	}
+	return nil
+}
+
+//
+// Register our protocol-tester.
+//
+func init() {
+	Register("mqtt", func() ProtocolTest {
+		return &MQTTTest{}
+	})
+}
@@ -0,0 +1,126 @@
+package protocols
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// serveMQTT is a minimal MQTT broker, for a single client, accepting
+// connections from "monitor" with the password "secret", or anonymous
+// ones.  Messages published to a topic the client subscribed to are
+// delivered back to it, except for those on "blackhole".
+func serveMQTT(conn net.Conn) {
+	subscribed := make(map[string]bool)
+
+	for {
+		packet, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		var reply packets.ControlPacket
+		switch p := packet.(type) {
+		case *packets.ConnectPacket:
+			connack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+			if p.Username != "" && (p.Username != "monitor" || string(p.Password) != "secret") {
+				connack.ReturnCode = packets.ErrRefusedNotAuthorised
+			}
+			reply = connack
+		case *packets.SubscribePacket:
+			suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			suback.MessageID = p.MessageID
+			for _, topic := range p.Topics {
+				subscribed[topic] = true
+				suback.ReturnCodes = append(suback.ReturnCodes, 0)
+			}
+			reply = suback
+		case *packets.UnsubscribePacket:
+			unsuback := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			unsuback.MessageID = p.MessageID
+			reply = unsuback
+		case *packets.PublishPacket:
+			if subscribed[p.TopicName] && p.TopicName != "blackhole" {
+				reply = p
+			}
+		case *packets.PingreqPacket:
+			reply = packets.NewControlPacket(packets.Pingresp)
+		default:
+			return
+		}
+
+		if reply != nil {
+			if err = reply.Write(conn); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestMQTTConnect(t *testing.T) {
+	port, stop := startTCPServer(t, serveMQTT)
+	defer stop()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	probe := &MQTTTest{}
+
+	// Anonymous connection.
+	tst := test.Test{Target: "127.0.0.1", Type: "mqtt", Arguments: map[string]string{"port": port}}
+	if err := probe.RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the connection to succeed, got %s", err)
+	}
+
+	// Valid credentials.
+	tst.Arguments["username"] = "monitor"
+	tst.Arguments["password"] = "secret"
+	if err := probe.RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the login to succeed, got %s", err)
+	}
+
+	// Invalid credentials.
+	tst.Arguments["password"] = "wrong"
+	err := probe.RunTest(tst, "127.0.0.1", opts)
+	if err == nil {
+		t.Fatalf("expected the login to fail")
+	}
+	if !strings.Contains(err.Error(), "MQTT connect failed") {
+		t.Errorf("unexpected error %s", err)
+	}
+}
+
+func TestMQTTPublish(t *testing.T) {
+	port, stop := startTCPServer(t, serveMQTT)
+	defer stop()
+
+	opts := test.Options{Timeout: time.Second}
+	probe := &MQTTTest{}
+
+	tst := test.Test{Target: "127.0.0.1", Type: "mqtt", Arguments: map[string]string{
+		"port":    port,
+		"topic":   "overseer/test",
+		"publish": "ping",
+	}}
+	if err := probe.RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the round-trip to succeed, got %s", err)
+	}
+
+	// Messages which are never delivered.
+	tst.Arguments["topic"] = "blackhole"
+	err := probe.RunTest(tst, "127.0.0.1", opts)
+	if err == nil {
+		t.Fatalf("expected the round-trip to fail")
+	}
+	if !strings.Contains(err.Error(), "was not received") {
+		t.Errorf("unexpected error %s", err)
+	}
+
+	// A topic without a message is an error.
+	delete(tst.Arguments, "publish")
+	if err = probe.RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected a topic without a message to fail")
+	}
+}
@@ -8,6 +8,12 @@
 // Specifying a username and password is mandatory, because otherwise we
 // cannot connect to the database.
 //
+// Instead of the password you can give the path of a secret stored in
+// HashiCorp Vault, as 'path#key', which is read when the test runs using
+// the VAULT_ADDR and VAULT_TOKEN environment variables:
+//
+//    host.example.com must run mysql with username 'root' with vault-path 'secret/data/db#password'
+//
 
 package protocols
 
@@ -31,9 +37,10 @@ type MYSQLTest struct {
 // their values.
 func (s *MYSQLTest) Arguments() map[string]string {
 	known := map[string]string{
-		"port":     "^[0-9]+$",
-		"username": ".*",
-		"password": ".*",
+		"port":       "^[0-9]+$",
+		"username":   ".*",
+		"password":   ".*",
+		"vault-path": `^[^#]+#.+$`,
 	}
 	return known
 }
@@ -55,6 +62,12 @@ MySQL Tester
 
  Specifying a username and password is mandatory, because otherwise we
  cannot connect to the database.
+
+ Instead of the password you can give the path of a secret stored in
+ HashiCorp Vault, as 'path#key', which is read when the test runs using
+ the VAULT_ADDR and VAULT_TOKEN environment variables:
+
+    host.example.com must run mysql with username 'root' with vault-path 'secret/data/db#password'
 `
 	return str
 }
@@ -64,8 +77,17 @@ MySQL Tester
 //
 // In this case we make a TCP connection to the host and attempt to login
 // with the specified username & password.
-func (s *MYSQLTest) RunTest(tst test.Test, target string, opts test.Options) error {
-	var err error
+func (s *MYSQLTest) RunTest(tst test.Test, target string, opts test.Options) (err error) {
+
+	//
+	// Read the password from Vault, if required, making sure it never
+	// shows up in our results.
+	//
+	tst, secret, err := vaultPassword(tst, opts.Timeout)
+	if err != nil {
+		return err
+	}
+	defer func() { err = censorSecretError(err, secret) }()
 
 	//
 	// The password might be blank, but the username is required.
@@ -130,7 +152,7 @@ func (s *MYSQLTest) RunTest(tst test.Test, target string, opts test.Options) err
 	// Show the DSN, if appropriate.
 	//
 	if opts.Verbose {
-		fmt.Printf("\tMySQL DSN is %s\n", dsn)
+		fmt.Printf("\tMySQL DSN is %s\n", censorSecret(dsn, secret))
 	}
 
 	//
@@ -1,86 +1,108 @@
 // Ping Tester
 //
-// The ping tester invokes the system 'ping' and 'ping6' binaries to carry
-// out a ping-test against a remote host.
+// The ping tester sends ICMP echo requests to a remote host, using raw
+// ICMP sockets, and ensures that it replies.
 //
-// If the system-binary reports a failure, via its exit-code, then this test
-// fails.
+// Opening raw ICMP sockets requires overseer to run as root, or with the
+// CAP_NET_RAW capability, otherwise this test fails with an error
+// explaining that.
 //
 // This test is invoked via input like so:
 //
 //    host.example.com must run ping
+//
+// By default 3 echo requests are sent, and the test fails only if none
+// of them receives a reply.  You can change the number of requests, and
+// the maximum percentage of them which may be lost:
+//
+//    8.8.8.8 must run ping with count 5 with loss 20
+//
+// The delay between each request defaults to one second, and can be
+// changed too:
+//
+//    8.8.8.8 must run ping with count 10 with interval 200ms
+//
 
 package protocols
 
 import (
-	"bytes"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"math/rand"
 	"net"
-	"os/exec"
-	"syscall"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cmaster11/overseer/test"
 )
 
+// pingSender sends a single ICMP echo request, returning the round-trip
+// time of its reply.
+type pingSender interface {
+	Ping(ip net.IP, seq int, timeout time.Duration) (time.Duration, error)
+}
+
 // PINGTest is our object.
 type PINGTest struct {
+	// sender is used to send the echo requests, if nil we use raw
+	// ICMP sockets.
+	sender pingSender
 }
 
-// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
-func (s *PINGTest) ShouldResolveHostname() bool {
-	return true
+// pingStats holds the outcome of a series of echo requests.
+type pingStats struct {
+	sent     int
+	received int
+	total    time.Duration
 }
 
-// RunCommand invokes an external binary and returns stdout/stderr/exit-code
-func (s *PINGTest) RunCommand(name string, args ...string) (stdout string, stderr string, exitCode int) {
-	var outbuf, errbuf bytes.Buffer
-	cmd := exec.Command(name, args...)
-	cmd.Stdout = &outbuf
-	cmd.Stderr = &errbuf
-
-	err := cmd.Run()
-	stdout = outbuf.String()
-	stderr = errbuf.String()
-
-	if err != nil {
-		// try to get the exit code
-		if exitError, ok := err.(*exec.ExitError); ok {
-			ws := exitError.Sys().(syscall.WaitStatus)
-			exitCode = ws.ExitStatus()
-		} else {
-			exitCode = 1
-			if stderr == "" {
-				stderr = err.Error()
-			}
-		}
-	} else {
-		// success, exitCode should be 0 if go is ok
-		ws := cmd.ProcessState.Sys().(syscall.WaitStatus)
-		exitCode = ws.ExitStatus()
+// loss returns the percentage of echo requests which didn't receive a
+// reply.
+func (st pingStats) loss() float64 {
+	if st.sent == 0 {
+		return 0
 	}
-	return
+	return float64(st.sent-st.received) * 100 / float64(st.sent)
 }
 
-// Ping4 runs a ping test against an IPv4 address, returning true
-// if the ping succeeded.
-func (s *PINGTest) Ping4(target string) bool {
+// mean returns the mean round-trip time of the replies received.
+func (st pingStats) mean() time.Duration {
+	if st.received == 0 {
+		return 0
+	}
+	return st.total / time.Duration(st.received)
+}
 
-	_, _, ret := s.RunCommand("ping4", "-c", "1", "-w", "4", "-W", "4", target)
-	return (ret == 0)
+// check returns an error if more than maxLoss percent of the echo
+// requests were lost, or if no reply was received at all.
+func (st pingStats) check(maxLoss float64) error {
+	if st.received == 0 {
+		return fmt.Errorf("no replies received, %d/%d packets lost", st.sent, st.sent)
+	}
+	if st.loss() > maxLoss {
+		return fmt.Errorf("%.1f%% packet loss (%d/%d lost) exceeds %g%%, mean rtt %s",
+			st.loss(), st.sent-st.received, st.sent, maxLoss, st.mean())
+	}
+	return nil
 }
 
-// Ping6 runs a ping test against an IPv6 address, returning true
-// if the ping succeeded.
-func (s *PINGTest) Ping6(target string) bool {
-	_, _, ret := s.RunCommand("ping6", "-c", "1", "-w", "4", "-W", "4", target)
-	return (ret == 0)
+// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
+func (s *PINGTest) ShouldResolveHostname() bool {
+	return true
 }
 
 // Arguments returns the names of arguments which this protocol-test
 // understands, along with corresponding regular-expressions to validate
 // their values.
 func (s *PINGTest) Arguments() map[string]string {
-	known := map[string]string{}
+	known := map[string]string{
+		"count":    "^[0-9]+$",
+		"loss":     `^[0-9]+(\.[0-9]+)?%?$`,
+		"interval": `^[+]?([0-9]*(\.[0-9]*)?[a-z]+)+$`,
+	}
 	return known
 }
 
@@ -89,15 +111,27 @@ func (s *PINGTest) Example() string {
 	str := `
 Ping Tester
 -----------
- The ping tester invokes the system 'ping' and 'ping6' binaries to carry
- out a ping-test against a remote host.
+ The ping tester sends ICMP echo requests to a remote host, using raw
+ ICMP sockets, and ensures that it replies.
 
- If the system-binary reports a failure, via its exit-code, then this test
- fails.
+ Opening raw ICMP sockets requires overseer to run as root, or with the
+ CAP_NET_RAW capability, otherwise this test fails with an error
+ explaining that.
 
  This test is invoked via input like so:
 
     host.example.com must run ping
+
+ By default 3 echo requests are sent, and the test fails only if none
+ of them receives a reply.  You can change the number of requests, and
+ the maximum percentage of them which may be lost:
+
+    8.8.8.8 must run ping with count 5 with loss 20
+
+ The delay between each request defaults to one second, and can be
+ changed too:
+
+    8.8.8.8 must run ping with count 10 with interval 200ms
 `
 	return str
 }
@@ -105,35 +139,185 @@ Ping Tester
 // RunTest is the part of our API which is invoked to actually execute a
 // test against the given target.
 //
-// In this case we run a ping-command with the appropriate binary depending
-// on the address-family of the target host.
+// In this case we send a number of echo requests to the target, and
+// look at how many of them were lost.
 func (s *PINGTest) RunTest(tst test.Test, target string, opts test.Options) error {
+	var err error
+
 	ip := net.ParseIP(target)
+	if ip == nil {
+		return errors.New("neither IPv4 nor IPv6 address")
+	}
 
-	//
-	// If the address is an IPv4 address.
-	//
-	if ip.To4() != nil {
-		if s.Ping4(target) {
-			return nil
+	count := 3
+	if tst.Arguments["count"] != "" {
+		count, err = strconv.Atoi(tst.Arguments["count"])
+		if err != nil {
+			return err
+		}
+		if count < 1 {
+			return errors.New("count must be at least 1")
 		}
-		return errors.New("failed to ping binary")
 	}
 
 	//
-	// If the address is an IPv6 address.
+	// By default we only fail if every request was lost.
 	//
-	if ip.To16() != nil && ip.To4() == nil {
-		if s.Ping6(target) {
-			return nil
+	maxLoss := 100.0
+	if tst.Arguments["loss"] != "" {
+		maxLoss, err = strconv.ParseFloat(strings.TrimSuffix(tst.Arguments["loss"], "%"), 64)
+		if err != nil {
+			return err
+		}
+	}
+
+	interval := time.Second
+	if tst.Arguments["interval"] != "" {
+		interval, err = time.ParseDuration(tst.Arguments["interval"])
+		if err != nil {
+			return err
 		}
-		return errors.New("failed to ping target")
 	}
 
+	sender := s.sender
+	if sender == nil {
+		sender = &icmpSender{id: rand.Intn(0xffff)}
+	}
+
+	stats, err := runPings(sender, ip, count, interval, opts.Timeout)
+	if err != nil {
+		return err
+	}
+
+	return stats.check(maxLoss)
+}
+
+// runPings sends count echo requests to the given address, waiting for
+// interval between each of them.
+//
+// Lost packets are counted, any other failure aborts the run.
+func runPings(sender pingSender, ip net.IP, count int, interval time.Duration, timeout time.Duration) (pingStats, error) {
+	var stats pingStats
+
+	for seq := 0; seq < count; seq++ {
+		if seq > 0 && interval > 0 {
+			time.Sleep(interval)
+		}
+
+		stats.sent++
+		rtt, err := sender.Ping(ip, seq, timeout)
+		if err != nil {
+			if err == errPingTimeout {
+				continue
+			}
+			return stats, err
+		}
+
+		stats.received++
+		stats.total += rtt
+	}
+
+	return stats, nil
+}
+
+// errPingTimeout is returned by a pingSender when no reply was received
+// in time, which counts as a lost packet.
+var errPingTimeout = errors.New("timeout waiting for echo reply")
+
+// icmpSender sends echo requests via raw ICMP sockets.
+type icmpSender struct {
+	// id is the identifier of our echo requests, used to match the
+	// replies we receive.
+	id int
+}
+
+// Ping sends a single echo request, and waits for its reply.
+func (p *icmpSender) Ping(ip net.IP, seq int, timeout time.Duration) (time.Duration, error) {
+
+	network := "ip4:icmp"
+	requestType, replyType := byte(8), byte(0)
+	if ip.To4() == nil {
+		network = "ip6:ipv6-icmp"
+		requestType, replyType = 128, 129
+	}
+
+	conn, err := net.ListenPacket(network, "")
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return 0, errors.New("raw ICMP sockets require running as root, or the CAP_NET_RAW capability")
+		}
+		return 0, err
+	}
+	defer conn.Close()
+
+	//
+	// Build the echo request: type, code, checksum, identifier,
+	// sequence number and a small payload.
+	//
+	msg := make([]byte, 8, 16)
+	msg[0] = requestType
+	binary.BigEndian.PutUint16(msg[4:], uint16(p.id))
+	binary.BigEndian.PutUint16(msg[6:], uint16(seq))
+	msg = append(msg, []byte("overseer")...)
+
 	//
-	// Unknown family, or otherwise bogus name.
+	// The kernel computes the checksum for ICMPv6.
 	//
-	return errors.New("neither IPv4 nor IPv6 address")
+	if ip.To4() != nil {
+		binary.BigEndian.PutUint16(msg[2:], icmpChecksum(msg))
+	}
+
+	start := time.Now()
+	err = conn.SetDeadline(start.Add(timeout))
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = conn.WriteTo(msg, &net.IPAddr{IP: ip})
+	if err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return 0, errPingTimeout
+			}
+			return 0, err
+		}
+
+		//
+		// Ignore anything which isn't the reply to our request.
+		//
+		if n < 8 || buf[0] != replyType {
+			continue
+		}
+		if addr, ok := peer.(*net.IPAddr); !ok || !addr.IP.Equal(ip) {
+			continue
+		}
+		if int(binary.BigEndian.Uint16(buf[4:])) != p.id || int(binary.BigEndian.Uint16(buf[6:])) != seq {
+			continue
+		}
+
+		return time.Since(start), nil
+	}
+}
+
+// icmpChecksum computes the internet checksum of an ICMP message.
+func icmpChecksum(msg []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(msg); i += 2 {
+		sum += uint32(msg[i])<<8 | uint32(msg[i+1])
+	}
+	if len(msg)%2 == 1 {
+		sum += uint32(msg[len(msg)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
 }
 
 //
@@ -0,0 +1,112 @@
+package protocols
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// fakePingSender replies to every request, except those whose sequence
+// number is listed in lost.
+type fakePingSender struct {
+	lost map[int]bool
+	rtt  time.Duration
+	err  error
+}
+
+func (f *fakePingSender) Ping(ip net.IP, seq int, timeout time.Duration) (time.Duration, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	if f.lost[seq] {
+		return 0, errPingTimeout
+	}
+	return f.rtt, nil
+}
+
+func runPingTest(sender pingSender, args map[string]string) error {
+	args["interval"] = "0s"
+	tst := test.Test{Target: "192.0.2.1", Type: "ping", Arguments: args}
+	probe := &PINGTest{sender: sender}
+	return probe.RunTest(tst, "192.0.2.1", test.Options{Timeout: time.Second})
+}
+
+func TestPingStats(t *testing.T) {
+	type TestCase struct {
+		stats   pingStats
+		maxLoss float64
+		fail    bool
+	}
+
+	tests := []TestCase{
+		{pingStats{sent: 5, received: 5, total: 50 * time.Millisecond}, 0, false},
+		{pingStats{sent: 5, received: 4, total: 40 * time.Millisecond}, 20, false},
+		{pingStats{sent: 5, received: 3, total: 30 * time.Millisecond}, 20, true},
+		{pingStats{sent: 3, received: 2, total: 20 * time.Millisecond}, 33.4, false},
+		{pingStats{sent: 3, received: 2, total: 20 * time.Millisecond}, 33.3, true},
+		{pingStats{sent: 3, received: 0}, 100, true},
+	}
+
+	for _, tc := range tests {
+		err := tc.stats.check(tc.maxLoss)
+		if tc.fail && err == nil {
+			t.Errorf("expected %+v to fail with max loss %g", tc.stats, tc.maxLoss)
+		}
+		if !tc.fail && err != nil {
+			t.Errorf("expected %+v to pass with max loss %g, got %s", tc.stats, tc.maxLoss, err)
+		}
+	}
+}
+
+func TestPingLoss(t *testing.T) {
+	sender := &fakePingSender{lost: map[int]bool{1: true}, rtt: 10 * time.Millisecond}
+
+	// One in five lost is 20%, which is allowed.
+	if err := runPingTest(sender, map[string]string{"count": "5", "loss": "20"}); err != nil {
+		t.Errorf("expected 20%% loss to be accepted, got %s", err)
+	}
+
+	// But not if the threshold is lower.
+	err := runPingTest(sender, map[string]string{"count": "5", "loss": "10%"})
+	if err == nil {
+		t.Fatalf("expected 20%% loss to fail with a 10%% threshold")
+	}
+	if !strings.Contains(err.Error(), "mean rtt 10ms") {
+		t.Errorf("expected the mean rtt in the error, got %s", err)
+	}
+}
+
+func TestPingDefaults(t *testing.T) {
+
+	// By default only losing every packet fails.
+	sender := &fakePingSender{lost: map[int]bool{0: true, 1: true}}
+	if err := runPingTest(sender, map[string]string{}); err != nil {
+		t.Errorf("expected partial loss to be accepted, got %s", err)
+	}
+
+	sender.lost[2] = true
+	if err := runPingTest(sender, map[string]string{}); err == nil {
+		t.Errorf("expected total loss to fail")
+	}
+
+	// Errors other than timeouts are reported as they are.
+	sender = &fakePingSender{err: errors.New("permission denied")}
+	if err := runPingTest(sender, map[string]string{}); err == nil || err.Error() != "permission denied" {
+		t.Errorf("expected the sender error to be returned, got %v", err)
+	}
+}
+
+func TestICMPChecksum(t *testing.T) {
+	msg := []byte{8, 0, 0, 0, 0x12, 0x34, 0, 1}
+	sum := icmpChecksum(msg)
+	msg[2], msg[3] = byte(sum>>8), byte(sum)
+
+	// The checksum of a message including its checksum is zero.
+	if icmpChecksum(msg) != 0 {
+		t.Errorf("invalid checksum %x", sum)
+	}
+}
@@ -5,22 +5,36 @@
 //
 // This test is invoked via input like so:
 //
-//    host.example.com must run psql with username 'postgres' with password 'mysecretpassword' [with port 5432] [with tls disable]
+//    host.example.com must run psql with username 'postgres' with password 'mysecretpassword' [with port 5432] [with sslmode disable]
 //
-// The `tls` setting may be used to configure how TLS is used, valid values
-// are "disable", "require", "verify-ca", or "verify-full".
+// The test is also available as "postgres".  Once connected a `SELECT 1`
+// query is executed, against the database specified via `with database`,
+// or the default one of the user.
+//
+// The `sslmode` setting may be used to configure how TLS is used, valid
+// values are "disable", "require", "verify-ca", or "verify-full".  The
+// older `tls` setting is an alias of it.
 //
 // Specifying a username and password is required, because otherwise we
 // cannot connect to the database.
 //
+// Instead of the password you can give the path of a secret stored in
+// HashiCorp Vault, as 'path#key', which is read when the test runs using
+// the VAULT_ADDR and VAULT_TOKEN environment variables:
+//
+//    host.example.com must run psql with username 'postgres' with vault-path 'secret/data/db#password'
+//
 
 package protocols
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cmaster11/overseer/test"
 	_ "github.com/lib/pq" // Don't need to import this
@@ -28,6 +42,9 @@ import (
 
 // PSQLTest is our object
 type PSQLTest struct {
+	// driver is the name of the database/sql driver to use, if empty
+	// we use "postgres".
+	driver string
 }
 
 // Arguments returns the names of arguments which this protocol-test
@@ -35,10 +52,13 @@ type PSQLTest struct {
 // their values.
 func (s *PSQLTest) Arguments() map[string]string {
 	known := map[string]string{
-		"port":     "^[0-9]+$",
-		"username": ".*",
-		"password": ".*",
-		"tls":      "^(disable|require|verify-ca|verify-full)$",
+		"port":       "^[0-9]+$",
+		"username":   ".*",
+		"password":   ".*",
+		"vault-path": `^[^#]+#.+$`,
+		"database":   ".*",
+		"sslmode":    "^(disable|require|verify-ca|verify-full)$",
+		"tls":        "^(disable|require|verify-ca|verify-full)$",
 	}
 	return known
 }
@@ -57,13 +77,24 @@ PSQL Tester
 
  This test is invoked via input like so:
 
-    host.example.com must run psql with username 'postgres' with password 'mysecretpassword'
+    host.example.com must run psql with username 'postgres' with password 'mysecretpassword' [with port 5432] [with sslmode disable]
 
- The 'tls' setting may be used to configure how TLS is used, valid values
- are "disable", "require", "verify-ca", or "verify-full".
+ The test is also available as "postgres".  Once connected a 'SELECT 1'
+ query is executed, against the database specified via 'with database',
+ or the default one of the user.
+
+ The 'sslmode' setting may be used to configure how TLS is used, valid
+ values are "disable", "require", "verify-ca", or "verify-full".  The
+ older 'tls' setting is an alias of it.
 
  Specifying a username and password is required, because otherwise we
  cannot connect to the database.
+
+ Instead of the password you can give the path of a secret stored in
+ HashiCorp Vault, as 'path#key', which is read when the test runs using
+ the VAULT_ADDR and VAULT_TOKEN environment variables:
+
+    host.example.com must run psql with username 'postgres' with vault-path 'secret/data/db#password'
 `
 	return str
 }
@@ -71,10 +102,19 @@ PSQL Tester
 // RunTest is the part of our API which is invoked to actually execute a
 // test against the given target.
 //
-// In this case we make a TCP connection to the database host and attempt
-// to login with the specified username & password.
-func (s *PSQLTest) RunTest(tst test.Test, target string, opts test.Options) error {
-	var err error
+// In this case we make a TCP connection to the database host, attempt
+// to login with the specified username & password, and run a query.
+func (s *PSQLTest) RunTest(tst test.Test, target string, opts test.Options) (err error) {
+
+	//
+	// Read the password from Vault, if required, making sure it never
+	// shows up in our results.
+	//
+	tst, secret, err := vaultPassword(tst, opts.Timeout)
+	if err != nil {
+		return err
+	}
+	defer func() { err = censorSecretError(err, secret) }()
 
 	//
 	// The password might be blank, but the username is required.
@@ -94,40 +134,85 @@ func (s *PSQLTest) RunTest(tst test.Test, target string, opts test.Options) erro
 		}
 	}
 
-	//
-	// The default SSL mode
-	//
-	ssl := "disable"
-	if tst.Arguments["tsl"] != "" {
-		ssl = tst.Arguments["tsl"]
-	}
-
 	//
 	// This is the string we'll use for the database connection.
 	//
-	connect := fmt.Sprintf("host=%s port='%d' user='%s' password='%s' connect_timeout='%d' sslmode='%s'", target, port, tst.Arguments["username"], tst.Arguments["password"], opts.Timeout, ssl)
+	connect := psqlConnectionString(tst, target, port, opts.Timeout)
 
 	//
 	// Show the config, if appropriate.
 	//
 	if opts.Verbose {
-		fmt.Printf("\tPSQL connection string is %s\n", connect)
+		fmt.Printf("\tPSQL connection string is %s\n", censorSecret(connect, secret))
 	}
 
 	//
 	// Connect to the database
 	//
-	db, err := sql.Open("postgres", connect)
+	driver := s.driver
+	if driver == "" {
+		driver = "postgres"
+	}
+
+	db, err := sql.Open(driver, connect)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
 	//
 	// And test that the connection actually worked.
 	//
-	err = db.Ping()
-	return err
+	var one int
+	err = db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+	if err != nil {
+		return err
+	}
+	if one != 1 {
+		return fmt.Errorf("'SELECT 1' returned %d", one)
+	}
+
+	return nil
+}
+
+// psqlConnectionString builds the connection string for the given test,
+// in the key/value format of the pq driver.
+func psqlConnectionString(tst test.Test, target string, port int, timeout time.Duration) string {
+
+	//
+	// Values are quoted, so quotes and backslashes need escaping.
+	//
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+	//
+	// The default SSL mode
+	//
+	ssl := "disable"
+	if tst.Arguments["tls"] != "" {
+		ssl = tst.Arguments["tls"]
+	}
+	if tst.Arguments["sslmode"] != "" {
+		ssl = tst.Arguments["sslmode"]
+	}
+
+	//
+	// The connection timeout is in seconds, and 0 means forever.
+	//
+	seconds := int(timeout.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	connect := fmt.Sprintf("host=%s port='%d' user='%s' password='%s' connect_timeout='%d' sslmode='%s'", target, port, quote.Replace(tst.Arguments["username"]), quote.Replace(tst.Arguments["password"]), seconds, ssl)
+
+	if tst.Arguments["database"] != "" {
+		connect += fmt.Sprintf(" dbname='%s'", quote.Replace(tst.Arguments["database"]))
+	}
+
+	return connect
 }
 
 //
@@ -137,4 +222,7 @@ func init() {
 	Register("psql", func() ProtocolTest {
 		return &PSQLTest{}
 	})
+	Register("postgres", func() ProtocolTest {
+		return &PSQLTest{}
+	})
 }
@@ -0,0 +1,119 @@
+package protocols
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// mockPQ is a database/sql driver which answers `SELECT 1`, or fails
+// to connect if the connection string contains "password='wrong'".
+type mockPQ struct {
+	// The last connection string used.
+	dsn string
+}
+
+type mockPQConn struct{}
+type mockPQStmt struct{}
+type mockPQRows struct{ done bool }
+
+var mockPQDriver = &mockPQ{}
+
+func init() {
+	sql.Register("mockpq", mockPQDriver)
+}
+
+func (d *mockPQ) Open(dsn string) (driver.Conn, error) {
+	d.dsn = dsn
+	if strings.Contains(dsn, "password='wrong'") {
+		return nil, errors.New("pq: password authentication failed")
+	}
+	return &mockPQConn{}, nil
+}
+
+func (c *mockPQConn) Prepare(query string) (driver.Stmt, error) {
+	if query != "SELECT 1" {
+		return nil, errors.New("unexpected query")
+	}
+	return &mockPQStmt{}, nil
+}
+func (c *mockPQConn) Close() error              { return nil }
+func (c *mockPQConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (s *mockPQStmt) Close() error  { return nil }
+func (s *mockPQStmt) NumInput() int { return 0 }
+func (s *mockPQStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s *mockPQStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &mockPQRows{}, nil
+}
+
+func (r *mockPQRows) Columns() []string { return []string{"?column?"} }
+func (r *mockPQRows) Close() error      { return nil }
+func (r *mockPQRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func TestPSQLLogin(t *testing.T) {
+	probe := &PSQLTest{driver: "mockpq"}
+	opts := test.Options{Timeout: 5 * time.Second}
+
+	tst := test.Test{Target: "db.example.com", Type: "postgres", Arguments: map[string]string{
+		"username": "steve",
+		"password": "secret",
+		"database": "app",
+		"sslmode":  "verify-full",
+	}}
+
+	if err := probe.RunTest(tst, "10.0.0.1", opts); err != nil {
+		t.Errorf("expected the login to succeed, got %s", err)
+	}
+
+	expected := "host=10.0.0.1 port='5432' user='steve' password='secret' connect_timeout='5' sslmode='verify-full' dbname='app'"
+	if mockPQDriver.dsn != expected {
+		t.Errorf("unexpected connection string %s", mockPQDriver.dsn)
+	}
+
+	tst.Arguments["password"] = "wrong"
+	if err := probe.RunTest(tst, "10.0.0.1", opts); err == nil {
+		t.Errorf("expected the login to fail")
+	}
+}
+
+func TestPSQLConnectionString(t *testing.T) {
+	tst := test.Test{Arguments: map[string]string{
+		"username": "steve",
+		"password": `it's\secret`,
+		"tls":      "require",
+	}}
+
+	connect := psqlConnectionString(tst, "10.0.0.1", 5433, 500*time.Millisecond)
+	expected := `host=10.0.0.1 port='5433' user='steve' password='it\'s\\secret' connect_timeout='1' sslmode='require'`
+	if connect != expected {
+		t.Errorf("unexpected connection string %s", connect)
+	}
+
+	// sslmode has precedence over tls.
+	tst.Arguments["sslmode"] = "disable"
+	if connect = psqlConnectionString(tst, "10.0.0.1", 5432, time.Second); !strings.Contains(connect, "sslmode='disable'") {
+		t.Errorf("unexpected connection string %s", connect)
+	}
+
+	// A username is required.
+	err := (&PSQLTest{driver: "mockpq"}).RunTest(test.Test{Arguments: map[string]string{}}, "10.0.0.1", test.Options{Timeout: time.Second})
+	if err == nil {
+		t.Errorf("expected a missing username to fail")
+	}
+}
@@ -7,6 +7,12 @@
 //
 //    host.example.com must run redis [with port 6379] [with password 'password']
 //
+// Instead of the password you can give the path of a secret stored in
+// HashiCorp Vault, as 'path#key', which is read when the test runs using
+// the VAULT_ADDR and VAULT_TOKEN environment variables:
+//
+//    host.example.com must run redis with vault-path 'secret/data/db#password'
+//
 
 package protocols
 
@@ -28,8 +34,9 @@ type REDISTest struct {
 // their values.
 func (s *REDISTest) Arguments() map[string]string {
 	known := map[string]string{
-		"port":     "^[0-9]+$",
-		"password": ".*",
+		"port":       "^[0-9]+$",
+		"password":   ".*",
+		"vault-path": `^[^#]+#.+$`,
 	}
 	return known
 }
@@ -50,6 +57,12 @@ Redis Tester
  This test is invoked via input like so:
 
     host.example.com must run redis
+
+ Instead of the password you can give the path of a secret stored in
+ HashiCorp Vault, as 'path#key', which is read when the test runs using
+ the VAULT_ADDR and VAULT_TOKEN environment variables:
+
+    host.example.com must run redis with vault-path 'secret/data/db#password'
 `
 	return str
 }
@@ -59,12 +72,17 @@ Redis Tester
 //
 // In this case we make a Redis-test against the given target.
 //
-func (s *REDISTest) RunTest(tst test.Test, target string, opts test.Options) error {
+func (s *REDISTest) RunTest(tst test.Test, target string, opts test.Options) (err error) {
 
 	//
-	// Predeclare our error
+	// Read the password from Vault, if required, making sure it never
+	// shows up in our results.
 	//
-	var err error
+	tst, secret, err := vaultPassword(tst, opts.Timeout)
+	if err != nil {
+		return err
+	}
+	defer func() { err = censorSecretError(err, secret) }()
 
 	//
 	// The default port to connect to.
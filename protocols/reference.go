@@ -0,0 +1,42 @@
+package protocols
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteReference writes the reference of the given protocol-tests, or of
+// all the registered ones if none is given: their name, the arguments
+// they accept, and their example.
+func WriteReference(w io.Writer, names ...string) error {
+	if len(names) == 0 {
+		names = Handlers()
+		sort.Strings(names)
+	}
+
+	for _, name := range names {
+		handler := ProtocolHandler(name)
+		if handler == nil {
+			return fmt.Errorf("unknown protocol-test '%s'", name)
+		}
+
+		var args []string
+		for arg := range handler.Arguments() {
+			args = append(args, arg)
+		}
+		sort.Strings(args)
+		if len(args) == 0 {
+			args = []string{"none"}
+		}
+
+		fmt.Fprintf(w, "%s\n%s\n", name, strings.Repeat("=", len(name)))
+		fmt.Fprintf(w, "Arguments: %s\n", strings.Join(args, ", "))
+		if _, err := fmt.Fprintf(w, "%s\n\n", strings.TrimRight(handler.Example(), "\n")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
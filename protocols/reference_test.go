@@ -0,0 +1,42 @@
+package protocols
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteReference(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteReference(&buf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	reference := "\n" + buf.String()
+
+	// Every registered protocol-test is listed.
+	for _, name := range Handlers() {
+		if !strings.Contains(reference, "\n"+name+"\n"+strings.Repeat("=", len(name))+"\n") {
+			t.Errorf("expected %s to be listed", name)
+		}
+	}
+	for _, name := range []string{"http", "redis", "xmpp", "imap", "pop3", "ssh", "dns", "tcp"} {
+		if !strings.Contains(reference, "\n"+name+"\n") {
+			t.Errorf("expected the built-in %s to be listed", name)
+		}
+	}
+
+	// Along with their arguments and example.
+	buf.Reset()
+	if err := WriteReference(&buf, "redis"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, expected := range []string{"redis\n=====\n", "Arguments: password, port, vault-path\n", "Redis Tester"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected '%s' in the reference, got %s", expected, buf.String())
+		}
+	}
+
+	if err := WriteReference(&buf, "htp"); err == nil || !strings.Contains(err.Error(), "unknown protocol-test 'htp'") {
+		t.Errorf("expected an error for an unknown protocol-test, got %v", err)
+	}
+}
@@ -19,6 +19,10 @@
 //
 //    host.example.com must run smtp [with port 587] with username 'steve@example.com' with password 'secret'  [with tls insecure]
 //
+// To ensure the server supports STARTTLS, and that the TLS handshake
+// succeeds, without logging in use:
+//
+//    host.example.com must run smtp with starttls true [with tls insecure]
 //
 
 package protocols
@@ -31,6 +35,7 @@ import (
 	"net/smtp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cmaster11/overseer/test"
 )
@@ -48,6 +53,7 @@ func (s *SMTPTest) Arguments() map[string]string {
 		"username": ".*",
 		"password": ".*",
 		"tls":      "insecure",
+		"starttls": "^(true|false)$",
 	}
 	return known
 }
@@ -80,6 +86,11 @@ SMTP Tester
  A complete example, testing a login, will look like this:
 
     host.example.com must run smtp [with port 587] with username 'steve@example.com' with password 's3cr3t'  [with tls insecure]
+
+ To ensure the server supports STARTTLS, and that the TLS handshake
+ succeeds, without logging in use:
+
+    host.example.com must run smtp with starttls true [with tls insecure]
 `
 	return str
 }
@@ -132,6 +143,15 @@ func (s *SMTPTest) RunTest(tst test.Test, target string, opts test.Options) erro
 		return err
 	}
 
+	//
+	// Don't wait forever for a slow server.
+	//
+	err = conn.SetDeadline(time.Now().Add(opts.Timeout))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
 	// The default TLS configuration verifies the certificate
 	// matches the hostname of our target.
 	tlsconfig := &tls.Config{
@@ -159,22 +179,29 @@ func (s *SMTPTest) RunTest(tst test.Test, target string, opts test.Options) erro
 		return err
 	}
 
+	login := tst.Arguments["username"] != "" && tst.Arguments["password"] != ""
+
 	//
 	// If we have a username & password then we have to
 	// try them - but this will require TLS so we'll start
 	// that first.
 	//
-	if tst.Arguments["username"] != "" &&
-		tst.Arguments["password"] != "" {
+	if login || tst.Arguments["starttls"] == "true" {
 
 		hasStartTLS, _ := client.Extension("STARTTLS")
 		if !hasStartTLS {
-			return errors.New("we cannot login without STARTTLS, and that was not advertised")
+			if login {
+				return errors.New("we cannot login without STARTTLS, and that was not advertised")
+			}
+			return errors.New("STARTTLS was required, but it was not advertised")
 		}
 
 		if err = client.StartTLS(tlsconfig); err != nil {
 			return err
 		}
+	}
+
+	if login {
 
 		//
 		// In the future we might try more options
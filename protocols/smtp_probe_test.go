@@ -0,0 +1,106 @@
+package protocols
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// serveMockSMTP answers a single SMTP session with canned responses,
+// advertising STARTTLS only if a TLS configuration is given.
+func serveMockSMTP(conn net.Conn, tlsConfig *tls.Config) {
+	defer conn.Close()
+
+	var rw net.Conn = conn
+	reader := bufio.NewReader(rw)
+	rw.Write([]byte("220 mock ESMTP\r\n"))
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(cmd, "EHLO"):
+			if tlsConfig != nil {
+				rw.Write([]byte("250-mock\r\n250-STARTTLS\r\n250 OK\r\n"))
+			} else {
+				rw.Write([]byte("250-mock\r\n250 OK\r\n"))
+			}
+		case cmd == "STARTTLS":
+			rw.Write([]byte("220 Ready to start TLS\r\n"))
+			tlsConn := tls.Server(rw, tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			rw = tlsConn
+			reader = bufio.NewReader(rw)
+		case cmd == "QUIT":
+			rw.Write([]byte("221 Bye\r\n"))
+			return
+		default:
+			rw.Write([]byte("250 OK\r\n"))
+		}
+	}
+}
+
+// startMockSMTP starts a mock SMTP server, returning its port.
+func startMockSMTP(t *testing.T, tlsConfig *tls.Config) (string, func()) {
+	return startTCPServer(t, func(conn net.Conn) {
+		serveMockSMTP(conn, tlsConfig)
+	})
+}
+
+// mockTLSConfig returns a TLS configuration using a self-signed certificate.
+func mockTLSConfig() *tls.Config {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+
+	return &tls.Config{Certificates: srv.TLS.Certificates}
+}
+
+func TestSMTPGreeting(t *testing.T) {
+	port, stop := startMockSMTP(t, nil)
+	defer stop()
+
+	tst := test.Test{Target: "localhost", Type: "smtp", Arguments: map[string]string{"port": port}}
+	if err := (&SMTPTest{}).RunTest(tst, "127.0.0.1", test.Options{Timeout: 2 * time.Second}); err != nil {
+		t.Errorf("expected the SMTP test to pass, got %s", err)
+	}
+}
+
+func TestSMTPStartTLS(t *testing.T) {
+	port, stop := startMockSMTP(t, mockTLSConfig())
+	defer stop()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+
+	tst := test.Test{Target: "localhost", Type: "smtp", Arguments: map[string]string{"port": port, "starttls": "true", "tls": "insecure"}}
+	if err := (&SMTPTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the STARTTLS test to pass, got %s", err)
+	}
+
+	// The certificate is self-signed, so it must fail verification
+	delete(tst.Arguments, "tls")
+	if err := (&SMTPTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected the STARTTLS test to fail verifying the certificate")
+	}
+}
+
+func TestSMTPStartTLSMissing(t *testing.T) {
+	port, stop := startMockSMTP(t, nil)
+	defer stop()
+
+	tst := test.Test{Target: "localhost", Type: "smtp", Arguments: map[string]string{"port": port, "starttls": "true"}}
+	err := (&SMTPTest{}).RunTest(tst, "127.0.0.1", test.Options{Timeout: 2 * time.Second})
+	if err == nil || !strings.Contains(err.Error(), "not advertised") {
+		t.Errorf("expected the test to fail as STARTTLS is not advertised, got %v", err)
+	}
+}
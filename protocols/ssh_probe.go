@@ -1,12 +1,26 @@
 // SSH Tester
 //
 // The SSH tester connects to a remote host and ensures that a response
-// is received that looks like an SSH-server banner.
+// is received that looks like an SSH-server banner, i.e. its
+// identification string, for version 2 of the protocol.
 //
 // This test is invoked via input like so:
 //
 //    host.example.com must run ssh [with port 22]
 //
+// You can ensure the banner contains a given string:
+//
+//    host.example.com must run ssh with banner 'OpenSSH'
+//
+// Or that the server runs at least a given version of OpenSSH:
+//
+//    host.example.com must run ssh with min-openssh 8.2
+//
+// To ensure the server isn't broken mid-handshake you can also perform
+// the key exchange, which stops short of authenticating:
+//
+//    host.example.com must run ssh with key-exchange true
+//
 
 package protocols
 
@@ -14,13 +28,21 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cmaster11/overseer/test"
+	"golang.org/x/crypto/ssh"
 )
 
+// opensshVersion matches the version of OpenSSH in an identification
+// string, such as "SSH-2.0-OpenSSH_7.4p1 Debian-10".
+var opensshVersion = regexp.MustCompile(`OpenSSH_(\d+)\.(\d+)`)
+
 // SSHTest is our object.
 type SSHTest struct {
 }
@@ -30,7 +52,10 @@ type SSHTest struct {
 // their values.
 func (s *SSHTest) Arguments() map[string]string {
 	known := map[string]string{
-		"port": "^[0-9]+$",
+		"port":         "^[0-9]+$",
+		"banner":       ".*",
+		"min-openssh":  `^\d+\.\d+$`,
+		"key-exchange": "^(true|false)$",
 	}
 	return known
 }
@@ -46,15 +71,40 @@ func (s *SSHTest) Example() string {
 SSH Tester
 ----------
  The ssh tester connects to a remote host and ensures that a response
- is received that looks like an ssh-server banner.
+ is received that looks like an ssh-server banner, i.e. its
+ identification string, for version 2 of the protocol.
 
  This test is invoked via input like so:
 
     host.example.com must run ssh
+
+ You can ensure the banner contains a given string:
+
+    host.example.com must run ssh with banner 'OpenSSH'
+
+ Or that the server runs at least a given version of OpenSSH:
+
+    host.example.com must run ssh with min-openssh 8.2
+
+ To ensure the server isn't broken mid-handshake you can also perform
+ the key exchange, which stops short of authenticating:
+
+    host.example.com must run ssh with key-exchange true
 `
 	return str
 }
 
+// sshReplayConn is a connection whose reads first return the data we
+// already consumed from it.
+type sshReplayConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *sshReplayConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
 // RunTest is the part of our API which is invoked to actually execute a
 // test against the given target.
 //
@@ -102,18 +152,119 @@ func (s *SSHTest) RunTest(tst test.Test, target string, opts test.Options) error
 	if err != nil {
 		return err
 	}
+	defer conn.Close()
 
 	//
-	// Read the banner.
+	// Don't wait forever for the remote host.
 	//
-	banner, err := bufio.NewReader(conn).ReadString('\n')
+	err = conn.SetDeadline(time.Now().Add(opts.Timeout))
 	if err != nil {
 		return err
 	}
-	conn.Close()
 
-	if !strings.Contains(banner, "SSH-") {
-		return errors.New("banner doesn't look like an SSH server")
+	//
+	// Read the banner, the server may send other lines before it.
+	//
+	reader := bufio.NewReader(conn)
+	consumed := ""
+	banner := ""
+	for banner == "" {
+		line, errRead := reader.ReadString('\n')
+		if errRead != nil {
+			if consumed == "" && line == "" {
+				return errRead
+			}
+			return errors.New("banner doesn't look like an SSH server")
+		}
+		consumed += line
+
+		if strings.HasPrefix(line, "SSH-") {
+			banner = strings.TrimRight(line, "\r\n")
+		} else if len(consumed) > 8192 {
+			return errors.New("banner doesn't look like an SSH server")
+		}
+	}
+
+	if opts.Verbose {
+		fmt.Printf("\tSSH banner is '%s'\n", banner)
+	}
+
+	//
+	// "1.99" is used by servers supporting both versions.
+	//
+	if !strings.HasPrefix(banner, "SSH-2.0-") && !strings.HasPrefix(banner, "SSH-1.99-") {
+		return fmt.Errorf("banner '%s' doesn't advertise version 2 of the SSH protocol", banner)
+	}
+
+	if tst.Arguments["banner"] != "" && !strings.Contains(banner, tst.Arguments["banner"]) {
+		return fmt.Errorf("banner '%s' doesn't contain '%s'", banner, tst.Arguments["banner"])
+	}
+
+	if tst.Arguments["min-openssh"] != "" {
+		if err = checkOpenSSHVersion(banner, tst.Arguments["min-openssh"]); err != nil {
+			return err
+		}
+	}
+
+	if tst.Arguments["key-exchange"] != "true" {
+		return nil
+	}
+
+	//
+	// Perform the handshake, letting the SSH library see the lines
+	// we've already read.
+	//
+	replay := &sshReplayConn{Conn: conn, reader: io.MultiReader(strings.NewReader(consumed), reader)}
+
+	hostKeyType := ""
+	config := &ssh.ClientConfig{
+		User: "overseer",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKeyType = key.Type()
+			return nil
+		},
+		Timeout: opts.Timeout,
+	}
+
+	client, _, _, err := ssh.NewClientConn(replay, address, config)
+	if err == nil {
+		// The server let us in without authenticating.
+		client.Close()
+		return nil
+	}
+
+	//
+	// We don't authenticate, so once the key exchange succeeded the
+	// server is expected to reject us.
+	//
+	if hostKeyType == "" || !strings.Contains(err.Error(), "unable to authenticate") {
+		return fmt.Errorf("SSH key exchange failed: %s", err.Error())
+	}
+
+	if opts.Verbose {
+		fmt.Printf("\tSSH key exchange succeeded, host key is %s\n", hostKeyType)
+	}
+
+	return nil
+}
+
+// checkOpenSSHVersion ensures the given banner is the one of OpenSSH, with
+// at least the given version.
+func checkOpenSSHVersion(banner string, minimum string) error {
+
+	match := opensshVersion.FindStringSubmatch(banner)
+	if match == nil {
+		return fmt.Errorf("banner '%s' isn't the one of OpenSSH", banner)
+	}
+
+	parts := strings.SplitN(minimum, ".", 2)
+	minMajor, _ := strconv.Atoi(parts[0])
+	minMinor, _ := strconv.Atoi(parts[1])
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+
+	if major < minMajor || (major == minMajor && minor < minMinor) {
+		return fmt.Errorf("OpenSSH version %d.%d is older than %s", major, minor, minimum)
 	}
 
 	return nil
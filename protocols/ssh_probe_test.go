@@ -0,0 +1,112 @@
+package protocols
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	"golang.org/x/crypto/ssh"
+)
+
+func runSSHTest(t *testing.T, handler func(conn net.Conn), args map[string]string) error {
+	port, stop := startTCPServer(t, handler)
+	defer stop()
+
+	args["port"] = port
+	tst := test.Test{Target: "127.0.0.1", Type: "ssh", Arguments: args}
+	return (&SSHTest{}).RunTest(tst, "127.0.0.1", test.Options{Timeout: 2 * time.Second})
+}
+
+func sendBanner(banner string) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		conn.Write([]byte(banner))
+	}
+}
+
+func TestSSHBanner(t *testing.T) {
+	banner := "SSH-2.0-OpenSSH_7.4p1 Debian-10+deb9u7\r\n"
+
+	if err := runSSHTest(t, sendBanner(banner), map[string]string{}); err != nil {
+		t.Errorf("expected the banner to be accepted, got %s", err)
+	}
+	if err := runSSHTest(t, sendBanner(banner), map[string]string{"banner": "OpenSSH"}); err != nil {
+		t.Errorf("expected the banner to match, got %s", err)
+	}
+	if err := runSSHTest(t, sendBanner(banner), map[string]string{"banner": "dropbear"}); err == nil {
+		t.Errorf("expected the banner not to match")
+	}
+
+	// Lines before the banner are allowed.
+	if err := runSSHTest(t, sendBanner("Welcome!\r\n"+banner), map[string]string{}); err != nil {
+		t.Errorf("expected the banner to be found, got %s", err)
+	}
+
+	// Servers supporting both protocol versions.
+	if err := runSSHTest(t, sendBanner("SSH-1.99-OpenSSH_3.9p1\r\n"), map[string]string{}); err != nil {
+		t.Errorf("expected a 1.99 banner to be accepted, got %s", err)
+	}
+
+	// Servers only supporting version 1.
+	err := runSSHTest(t, sendBanner("SSH-1.5-OpenSSH_1.2.3\r\n"), map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "version 2") {
+		t.Errorf("expected a protocol 1 banner to be rejected, got %v", err)
+	}
+
+	// Something else entirely.
+	if err = runSSHTest(t, sendBanner("220 smtp.example.com ESMTP\r\n"), map[string]string{}); err == nil {
+		t.Errorf("expected a non-SSH banner to be rejected")
+	}
+}
+
+func TestSSHMinOpenSSH(t *testing.T) {
+	banner := "SSH-2.0-OpenSSH_8.2p1 Ubuntu-4ubuntu0.5\r\n"
+
+	for _, version := range []string{"7.4", "8.2"} {
+		if err := runSSHTest(t, sendBanner(banner), map[string]string{"min-openssh": version}); err != nil {
+			t.Errorf("expected OpenSSH 8.2 to satisfy %s, got %s", version, err)
+		}
+	}
+	for _, version := range []string{"8.3", "9.0"} {
+		if err := runSSHTest(t, sendBanner(banner), map[string]string{"min-openssh": version}); err == nil {
+			t.Errorf("expected OpenSSH 8.2 not to satisfy %s", version)
+		}
+	}
+	if err := runSSHTest(t, sendBanner("SSH-2.0-dropbear_2019.78\r\n"), map[string]string{"min-openssh": "7.4"}); err == nil {
+		t.Errorf("expected dropbear not to satisfy a minimum OpenSSH version")
+	}
+}
+
+func TestSSHKeyExchange(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the host key: %s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("failed to create the host key signer: %s", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(signer)
+
+	serve := func(conn net.Conn) {
+		ssh.NewServerConn(conn, config)
+	}
+	if err = runSSHTest(t, serve, map[string]string{"key-exchange": "true"}); err != nil {
+		t.Errorf("expected the key exchange to succeed, got %s", err)
+	}
+
+	// A server which breaks after sending its banner.
+	err = runSSHTest(t, sendBanner("SSH-2.0-OpenSSH_7.4\r\n"), map[string]string{"key-exchange": "true"})
+	if err == nil || !strings.Contains(err.Error(), "key exchange failed") {
+		t.Errorf("expected the key exchange to fail, got %v", err)
+	}
+}
@@ -18,12 +18,31 @@
 //    # 12 hours (!)
 //    steve.fi must run ssl with expiration 12h
 //
+// For compliance you can require the certificate to use a given type of
+// key, RSA, ECDSA, or Ed25519, and/or a minimum key size in bits:
+//
+//    steve.fi must run ssl with key-type RSA with min-key-bits 2048
+//
+// For hosts serving many names from the same IP address, via SNI, you can
+// ensure the certificate presented for each name is valid for it.  Every
+// name is requested from the address the target resolves to, and all the
+// mismatches are reported:
+//
+//    shared.example.com must run ssl with sni-names 'a.example.com,b.example.com'
+//
+// To catch regressions of the TLS handshake, e.g. of OCSP stapling or of
+// the key exchange, you can give the longest it may take.  The measured
+// time is reported when the test fails:
+//
+//    steve.fi must run ssl with max-handshake 300ms
+//
 
 package protocols
 
 import (
 	"crypto/tls"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -40,7 +59,11 @@ type SSLTest struct {
 // their values.
 func (s *SSLTest) Arguments() map[string]string {
 	known := map[string]string{
-		"expiration": "^([0-9]+[hd]?)$",
+		"expiration":    "^([0-9]+[hd]?)$",
+		"key-type":      `^(?i)(RSA|ECDSA|Ed25519)$`,
+		"min-key-bits":  `^\d+$`,
+		"sni-names":     `^[A-Za-z0-9.*-]+(\s*,\s*[A-Za-z0-9.*-]+)*$`,
+		"max-handshake": `^[+]?([0-9]*(\.[0-9]*)?[a-z]+)+$`,
 	}
 	return known
 }
@@ -72,6 +95,24 @@ expiration period is assumed to be days:
 
    # 12 hours (!)
    steve.fi must run ssl with expiration 12h
+
+For compliance you can require the certificate to use a given type of
+key, RSA, ECDSA, or Ed25519, and/or a minimum key size in bits:
+
+   steve.fi must run ssl with key-type RSA with min-key-bits 2048
+
+For hosts serving many names from the same IP address, via SNI, you can
+ensure the certificate presented for each name is valid for it.  Every
+name is requested from the address the target resolves to, and all the
+mismatches are reported:
+
+   shared.example.com must run ssl with sni-names 'a.example.com,b.example.com'
+
+To catch regressions of the TLS handshake, e.g. of OCSP stapling or of
+the key exchange, you can give the longest it may take.  The measured
+time is reported when the test fails:
+
+   steve.fi must run ssl with max-handshake 300ms
 `
 	return str
 }
@@ -81,16 +122,16 @@ expiration period is assumed to be days:
 //
 // For the purposes of clarity this test makes a TCP dial and verifies SSL
 // certificates validity. The `test.Test` structure contains our raw test,
-// and the `target` variable contains the IP address against which to make
+// and the `ip` variable contains the IP address against which to make
 // the request.
 //
 // So:
 //
 //    tst.Target => "steve.kemp.fi
 //
-//    target => "176.9.183.100"
+//    ip => "176.9.183.100"
 //
-func (s *SSLTest) RunTest(tst test.Test, _ string, opts test.Options) error {
+func (s *SSLTest) RunTest(tst test.Test, ip string, opts test.Options) error {
 
 	var err error
 	target := tst.Target
@@ -153,12 +194,129 @@ func (s *SSLTest) RunTest(tst test.Test, _ string, opts test.Options) error {
 		}
 	}
 
+	//
+	// The remaining checks connect to the address we were given,
+	// asking for the name of the target.
+	//
+	port := "443"
+	serverName := target
+	if host, p, errSplit := net.SplitHostPort(target); errSplit == nil {
+		serverName, port = host, p
+	}
+	address := net.JoinHostPort(ip, port)
+
+	//
+	// Check the key of the certificate, if required.
+	//
+	if tst.Arguments["key-type"] != "" || tst.Arguments["min-key-bits"] != "" {
+		if err = s.checkKey(tst, address, serverName, opts.Timeout); err != nil {
+			return err
+		}
+	}
+
+	//
+	// Check the certificates served for other names, if required.
+	//
+	if tst.Arguments["sni-names"] != "" {
+
+		var names []string
+		for _, name := range strings.Split(tst.Arguments["sni-names"], ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+
+		if err = s.checkSNINames(address, names, opts); err != nil {
+			return err
+		}
+	}
+
+	//
+	// Time the TLS handshake, if required.
+	//
+	if tst.Arguments["max-handshake"] != "" {
+		elapsed, errHandshake := tlsHandshakeTime(address, serverName, opts.Timeout)
+		if errHandshake != nil {
+			return errHandshake
+		}
+		if err = checkHandshakeTime(elapsed, tst.Arguments["max-handshake"], opts.Verbose); err != nil {
+			return err
+		}
+	}
+
 	//
 	// If we reached here all is OK
 	//
 	return nil
 }
 
+// checkSNINames connects to the given address once for each name, using
+// it for SNI, and ensures the certificate presented is valid for it.
+func (s *SSLTest) checkSNINames(address string, names []string, opts test.Options) error {
+
+	var failures []string
+
+	for _, name := range names {
+
+		//
+		// The validity of the chain is checked by the test itself, here
+		// we're only interested in which certificate is served.
+		//
+		dialer := &net.Dialer{Timeout: opts.Timeout}
+		conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+			ServerName:         name,
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", name, err.Error()))
+			continue
+		}
+
+		certs := conn.ConnectionState().PeerCertificates
+		conn.Close()
+
+		if len(certs) == 0 {
+			failures = append(failures, fmt.Sprintf("%s: no certificate presented", name))
+			continue
+		}
+
+		if err = certs[0].VerifyHostname(name); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: certificate is for CN=%s, SANs %s", name, certs[0].Subject.CommonName, strings.Join(certs[0].DNSNames, ",")))
+			continue
+		}
+
+		if opts.Verbose {
+			fmt.Printf("SNI - %s is served a matching certificate\n", name)
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("wrong certificates served for %d SNI names out of %d: %s", len(failures), len(names), strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+// checkKey connects to the given address and ensures the key of the
+// certificate served for serverName meets the requirements of the test.
+func (s *SSLTest) checkKey(tst test.Test, address string, serverName string, timeout time.Duration) error {
+
+	//
+	// The validity of the chain is checked by the test itself, here
+	// we're only interested in the key.
+	//
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	return checkCertificateKey(tst, &state)
+}
+
 // SSLExpiration returns the number of hours remaining for a given
 // SSL certificate chain.
 func (s *SSLTest) SSLExpiration(host string, verbose bool) (int64, error) {
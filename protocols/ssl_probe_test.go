@@ -0,0 +1,171 @@
+package protocols
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// selfSignedCertificate returns a certificate valid for the given names.
+func selfSignedCertificate(t *testing.T, names ...string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestSSLSNINames(t *testing.T) {
+	shared := selfSignedCertificate(t, "a.example.com", "*.b.example.com")
+	other := selfSignedCertificate(t, "other.example.com")
+
+	// Names we don't know about get the certificate of someone else.
+	config := &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName == "a.example.com" || strings.HasSuffix(hello.ServerName, ".b.example.com") {
+				return &shared, nil
+			}
+			return &other, nil
+		},
+	}
+
+	port, stop := startTCPServer(t, func(conn net.Conn) {
+		tls.Server(conn, config).Handshake()
+	})
+	defer stop()
+
+	address := net.JoinHostPort("127.0.0.1", port)
+	opts := test.Options{Timeout: 2 * time.Second}
+
+	if err := (&SSLTest{}).checkSNINames(address, []string{"a.example.com", "www.b.example.com"}, opts); err != nil {
+		t.Errorf("expected all names to match, got %s", err)
+	}
+
+	err := (&SSLTest{}).checkSNINames(address, []string{"a.example.com", "c.example.com", "d.example.com"}, opts)
+	if err == nil {
+		t.Fatalf("expected the mismatches to be reported")
+	}
+	for _, expected := range []string{"2 SNI names out of 3", "c.example.com: certificate is for CN=other.example.com", "d.example.com"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected '%s' in the error, got %s", expected, err)
+		}
+	}
+	if strings.Contains(err.Error(), "a.example.com:") {
+		t.Errorf("expected a.example.com not to be reported, got %s", err)
+	}
+
+	// Nothing listening.
+	stop()
+	if err = (&SSLTest{}).checkSNINames(address, []string{"a.example.com"}, opts); err == nil {
+		t.Errorf("expected a closed port to fail")
+	}
+}
+
+func TestSSLKey(t *testing.T) {
+	cert := selfSignedCertificate(t, "a.example.com")
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	port, stop := startTCPServer(t, func(conn net.Conn) {
+		tls.Server(conn, config).Handshake()
+	})
+	defer stop()
+
+	// The key is fetched from the address given, not the name.
+	run := func(args map[string]string) error {
+		args["expiration"] = "1h"
+		tst := test.Test{Target: net.JoinHostPort("overseer.invalid", port), Type: "ssl", Arguments: args}
+		return (&SSLTest{}).RunTest(tst, "127.0.0.1", test.Options{Timeout: 2 * time.Second})
+	}
+
+	if err := run(map[string]string{"key-type": "ECDSA", "min-key-bits": "256"}); err != nil {
+		t.Errorf("expected the key to match, got %s", err)
+	}
+	if err := run(map[string]string{"key-type": "RSA"}); err == nil {
+		t.Errorf("expected the key type to be reported")
+	}
+	if err := run(map[string]string{"min-key-bits": "384"}); err == nil {
+		t.Errorf("expected the key size to be reported")
+	}
+
+	// IPv6 addresses are supported too.
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 is not available: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, errAccept := ln.Accept()
+			if errAccept != nil {
+				return
+			}
+			tls.Server(conn, config).Handshake()
+			conn.Close()
+		}
+	}()
+
+	_, port6, _ := net.SplitHostPort(ln.Addr().String())
+	tst := test.Test{Target: net.JoinHostPort("overseer.invalid", port6), Type: "ssl", Arguments: map[string]string{"expiration": "1h", "key-type": "ECDSA"}}
+	if err = (&SSLTest{}).RunTest(tst, "::1", test.Options{Timeout: 2 * time.Second}); err != nil {
+		t.Errorf("expected the key to be checked over IPv6, got %s", err)
+	}
+}
+
+func TestTLSHandshakeTime(t *testing.T) {
+	cert := selfSignedCertificate(t, "a.example.com")
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	port, stop := startTCPServer(t, func(conn net.Conn) {
+		tls.Server(conn, config).Handshake()
+	})
+	defer stop()
+
+	address := net.JoinHostPort("127.0.0.1", port)
+	elapsed, err := tlsHandshakeTime(address, "a.example.com", 2*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if elapsed <= 0 {
+		t.Errorf("expected the handshake to be timed, got %s", elapsed)
+	}
+
+	if err = checkHandshakeTime(elapsed, "10s", false); err != nil {
+		t.Errorf("expected the handshake to be fast enough, got %s", err)
+	}
+	if err = checkHandshakeTime(elapsed, "1ns", false); err == nil || !strings.Contains(err.Error(), "more than the maximum of 1ns") {
+		t.Errorf("expected the slow handshake to be reported, got %v", err)
+	}
+
+	// Not a TLS server.
+	plain, stopPlain := startTCPServer(t, func(conn net.Conn) {
+		conn.Write([]byte("220 ready\r\n"))
+	})
+	defer stopPlain()
+
+	if _, err = tlsHandshakeTime(net.JoinHostPort("127.0.0.1", plain), "a.example.com", 2*time.Second); err == nil || !strings.Contains(err.Error(), "TLS handshake failed") {
+		t.Errorf("expected the failed handshake to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+// STUN Tester
+//
+// The STUN tester sends a binding request to a remote STUN, or TURN,
+// server over UDP, and ensures that a valid binding response is returned.
+//
+// This test is invoked via input like so:
+//
+//    stun.example.com must run stun [with port 3478]
+//
+// The response of a STUN server contains the reflexive address of the
+// client, i.e. its address as seen by the server, you can also ensure
+// it is present:
+//
+//    stun.example.com must run stun with mapped-address true
+//
+
+package protocols
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	"github.com/pion/stun"
+)
+
+// stunRetransmit is how long we wait for a response before sending our
+// request again, since UDP packets might be lost.
+const stunRetransmit = 500 * time.Millisecond
+
+// STUNTest is our object
+type STUNTest struct {
+}
+
+// Arguments returns the names of arguments which this protocol-test
+// understands, along with corresponding regular-expressions to validate
+// their values.
+func (s *STUNTest) Arguments() map[string]string {
+	known := map[string]string{
+		"port":           "^[0-9]+$",
+		"mapped-address": "^(true|false)$",
+	}
+	return known
+}
+
+// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
+func (s *STUNTest) ShouldResolveHostname() bool {
+	return true
+}
+
+// Example returns sample usage-instructions for self-documentation purposes.
+func (s *STUNTest) Example() string {
+	str := `
+STUN Tester
+-----------
+ The STUN tester sends a binding request to a remote STUN, or TURN,
+ server over UDP, and ensures that a valid binding response is returned.
+
+ This test is invoked via input like so:
+
+    stun.example.com must run stun [with port 3478]
+
+ The response of a STUN server contains the reflexive address of the
+ client, i.e. its address as seen by the server, you can also ensure
+ it is present:
+
+    stun.example.com must run stun with mapped-address true
+`
+	return str
+}
+
+// RunTest is the part of our API which is invoked to actually execute a
+// test against the given target.
+//
+// In this case we send a binding request, repeating it if we don't get a
+// response, until our timeout expires.
+func (s *STUNTest) RunTest(tst test.Test, target string, opts test.Options) error {
+	var err error
+
+	//
+	// The default port to connect to.
+	//
+	port := 3478
+
+	//
+	// If the user specified a different port update to use it.
+	//
+	if tst.Arguments["port"] != "" {
+		port, err = strconv.Atoi(tst.Arguments["port"])
+		if err != nil {
+			return err
+		}
+	}
+
+	//
+	// The target might be an IPv4 or an IPv6 address.
+	//
+	address := net.JoinHostPort(target, strconv.Itoa(port))
+
+	conn, err := net.DialTimeout("udp", address, opts.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	request, err := stun.Build(stun.TransactionID, stun.BindingRequest, stun.Fingerprint)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(opts.Timeout)
+	buf := make([]byte, 1500)
+	response := new(stun.Message)
+
+	for {
+		if _, err = conn.Write(request.Raw); err != nil {
+			return err
+		}
+
+		//
+		// Wait for a response to our request, until we have to
+		// retransmit it.
+		//
+		wait := time.Now().Add(stunRetransmit)
+		if wait.After(deadline) {
+			wait = deadline
+		}
+		if err = conn.SetReadDeadline(wait); err != nil {
+			return err
+		}
+
+		received := false
+		for !received {
+			n, errRead := conn.Read(buf)
+			if errRead != nil {
+				if netErr, ok := errRead.(net.Error); ok && netErr.Timeout() {
+					break
+				}
+				return errRead
+			}
+
+			//
+			// Ignore anything which isn't a response to our request.
+			//
+			if stun.Decode(buf[:n], response) != nil || response.TransactionID != request.TransactionID {
+				continue
+			}
+			received = true
+		}
+
+		if received {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("no STUN binding response received within %s", opts.Timeout)
+		}
+	}
+
+	if response.Type != stun.BindingSuccess {
+		var code stun.ErrorCodeAttribute
+		if code.GetFrom(response) == nil {
+			return fmt.Errorf("STUN binding request failed: %s", code.String())
+		}
+		return fmt.Errorf("unexpected STUN response %s", response.Type)
+	}
+
+	//
+	// Look for our reflexive address, which modern servers send as
+	// XOR-MAPPED-ADDRESS, and older ones as MAPPED-ADDRESS.
+	//
+	mapped := ""
+	var xorAddr stun.XORMappedAddress
+	var addr stun.MappedAddress
+	if xorAddr.GetFrom(response) == nil {
+		mapped = xorAddr.String()
+	} else if addr.GetFrom(response) == nil {
+		mapped = addr.String()
+	}
+
+	if opts.Verbose && mapped != "" {
+		fmt.Printf("\tReflexive address is %s\n", mapped)
+	}
+
+	if tst.Arguments["mapped-address"] == "true" && mapped == "" {
+		return errors.New("STUN binding response doesn't contain the mapped address")
+	}
+
+	return nil
+}
+
+//
+// Register our protocol-tester.
+//
+func init() {
+	Register("stun", func() ProtocolTest {
+		return &STUNTest{}
+	})
+}
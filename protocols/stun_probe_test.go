@@ -0,0 +1,104 @@
+package protocols
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	"github.com/pion/stun"
+)
+
+// startSTUNServer starts a local UDP server, replying to binding requests
+// with the response built by the given function, or ignoring them if it
+// returns nil.  The first request is always dropped, to exercise our
+// retransmissions.
+func startSTUNServer(t *testing.T, reply func(request *stun.Message, from *net.UDPAddr) *stun.Message) (string, func()) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+
+	go func() {
+		buf := make([]byte, 1500)
+		dropped := false
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if !dropped {
+				dropped = true
+				continue
+			}
+
+			request := new(stun.Message)
+			if stun.Decode(buf[:n], request) != nil {
+				continue
+			}
+			if response := reply(request, from); response != nil {
+				conn.WriteToUDP(response.Raw, from)
+			}
+		}
+	}()
+
+	return strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port), func() { conn.Close() }
+}
+
+func TestSTUNBinding(t *testing.T) {
+	port, stop := startSTUNServer(t, func(request *stun.Message, from *net.UDPAddr) *stun.Message {
+		return stun.MustBuild(request, stun.BindingSuccess, &stun.XORMappedAddress{IP: from.IP, Port: from.Port}, stun.Fingerprint)
+	})
+	defer stop()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	tst := test.Test{Target: "127.0.0.1", Type: "stun", Arguments: map[string]string{"port": port, "mapped-address": "true"}}
+	if err := (&STUNTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the binding request to succeed, got %s", err)
+	}
+}
+
+func TestSTUNFailures(t *testing.T) {
+	opts := test.Options{Timeout: time.Second}
+
+	// A response without the mapped address.
+	port, stop := startSTUNServer(t, func(request *stun.Message, from *net.UDPAddr) *stun.Message {
+		return stun.MustBuild(request, stun.BindingSuccess)
+	})
+	defer stop()
+
+	tst := test.Test{Target: "127.0.0.1", Type: "stun", Arguments: map[string]string{"port": port}}
+	if err := (&STUNTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the binding request to succeed, got %s", err)
+	}
+	tst.Arguments["mapped-address"] = "true"
+	if err := (&STUNTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected a missing mapped address to fail")
+	}
+
+	// An error response.
+	port, stop = startSTUNServer(t, func(request *stun.Message, from *net.UDPAddr) *stun.Message {
+		return stun.MustBuild(request, stun.BindingError, stun.CodeBadRequest)
+	})
+	defer stop()
+
+	tst = test.Test{Target: "127.0.0.1", Type: "stun", Arguments: map[string]string{"port": port}}
+	err := (&STUNTest{}).RunTest(tst, "127.0.0.1", opts)
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("expected an error response to fail, got %v", err)
+	}
+
+	// No response at all.
+	port, stop = startSTUNServer(t, func(request *stun.Message, from *net.UDPAddr) *stun.Message {
+		return nil
+	})
+	defer stop()
+
+	tst = test.Test{Target: "127.0.0.1", Type: "stun", Arguments: map[string]string{"port": port}}
+	err = (&STUNTest{}).RunTest(tst, "127.0.0.1", opts)
+	if err == nil || !strings.Contains(err.Error(), "no STUN binding response") {
+		t.Errorf("expected a silent server to fail, got %v", err)
+	}
+}
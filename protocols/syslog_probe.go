@@ -0,0 +1,220 @@
+// Syslog Tester
+//
+// The syslog tester sends a well-formed RFC5424 message to a remote
+// syslog server, over UDP by default, or TCP:
+//
+//    logs.example.com must run syslog [with port 514]
+//    logs.example.com must run syslog with protocol tcp
+//
+// Over TCP the messages are framed via octet-counting (RFC6587), and the
+// test fails if the server closes the connection after receiving ours.
+// Servers only supporting newline-terminated messages can be tested by
+// changing the framing:
+//
+//    logs.example.com must run syslog with protocol tcp with framing non-transparent
+//
+// UDP delivery is best-effort, the test only fails if the host reports
+// that nothing is listening on the port.
+//
+// The text of the message can be changed, e.g. to look it up downstream:
+//
+//    logs.example.com must run syslog with message 'overseer canary'
+//
+
+package protocols
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// syslogSettle is how long the connection must stay up once our message
+// has been sent.
+const syslogSettle = 500 * time.Millisecond
+
+// syslogPriority is the priority of our messages, facility "user" and
+// severity "informational".
+const syslogPriority = 1*8 + 6
+
+// SYSLOGTest is our object
+type SYSLOGTest struct {
+}
+
+// Arguments returns the names of arguments which this protocol-test
+// understands, along with corresponding regular-expressions to validate
+// their values.
+func (s *SYSLOGTest) Arguments() map[string]string {
+	known := map[string]string{
+		"port":     "^[0-9]+$",
+		"protocol": "^(udp|tcp)$",
+		"framing":  "^(octet-counting|non-transparent)$",
+		"message":  "^[^\r\n]+$",
+	}
+	return known
+}
+
+// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
+func (s *SYSLOGTest) ShouldResolveHostname() bool {
+	return true
+}
+
+// Example returns sample usage-instructions for self-documentation purposes.
+func (s *SYSLOGTest) Example() string {
+	str := `
+Syslog Tester
+-------------
+ The syslog tester sends a well-formed RFC5424 message to a remote
+ syslog server, over UDP by default, or TCP:
+
+    logs.example.com must run syslog [with port 514]
+    logs.example.com must run syslog with protocol tcp
+
+ Over TCP the messages are framed via octet-counting (RFC6587), and the
+ test fails if the server closes the connection after receiving ours.
+ Servers only supporting newline-terminated messages can be tested by
+ changing the framing:
+
+    logs.example.com must run syslog with protocol tcp with framing non-transparent
+
+ UDP delivery is best-effort, the test only fails if the host reports
+ that nothing is listening on the port.
+
+ The text of the message can be changed, e.g. to look it up downstream:
+
+    logs.example.com must run syslog with message 'overseer canary'
+`
+	return str
+}
+
+// syslogMessage returns our RFC5424 message, with the given text.
+func syslogMessage(text string) string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s overseer %d overseer-test - %s",
+		syslogPriority,
+		time.Now().UTC().Format(time.RFC3339Nano),
+		hostname,
+		os.Getpid(),
+		text)
+}
+
+// RunTest is the part of our API which is invoked to actually execute a
+// test against the given target.
+//
+// In this case we send our message, then make sure the connection
+// isn't closed, or refused, in the following moments.
+func (s *SYSLOGTest) RunTest(tst test.Test, target string, opts test.Options) error {
+	var err error
+
+	//
+	// The default port to connect to.
+	//
+	port := 514
+
+	//
+	// If the user specified a different port update to use it.
+	//
+	if tst.Arguments["port"] != "" {
+		port, err = strconv.Atoi(tst.Arguments["port"])
+		if err != nil {
+			return err
+		}
+	}
+
+	protocol := "udp"
+	if tst.Arguments["protocol"] != "" {
+		protocol = tst.Arguments["protocol"]
+	}
+
+	text := "overseer syslog test"
+	if tst.Arguments["message"] != "" {
+		text = tst.Arguments["message"]
+	}
+
+	//
+	// The target might be an IPv4 or an IPv6 address.
+	//
+	address := net.JoinHostPort(target, strconv.Itoa(port))
+
+	conn, err := net.DialTimeout(protocol, address, opts.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(opts.Timeout))
+	if err != nil {
+		return err
+	}
+
+	//
+	// Frame our message, datagrams need no framing.
+	//
+	message := syslogMessage(text)
+	if protocol == "tcp" {
+		if tst.Arguments["framing"] == "non-transparent" {
+			message += "\n"
+		} else {
+			message = fmt.Sprintf("%d %s", len(message), message)
+		}
+	}
+
+	if opts.Verbose {
+		fmt.Printf("\tSending syslog message over %s: %s\n", protocol, strings.TrimSpace(message))
+	}
+
+	if _, err = conn.Write([]byte(message)); err != nil {
+		return err
+	}
+
+	//
+	// Syslog servers don't reply, so we only wait a moment for signs of
+	// a problem: the connection being closed, or an ICMP error.
+	//
+	settle := time.Now().Add(syslogSettle)
+	if deadline := time.Now().Add(opts.Timeout); deadline.Before(settle) {
+		settle = deadline
+	}
+	if err = conn.SetReadDeadline(settle); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 1024)
+	_, err = conn.Read(buf)
+
+	var netErr net.Error
+	switch {
+	case err == nil:
+		// Some servers might answer, which is fine.
+		return nil
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return nil
+	case err == io.EOF:
+		return errors.New("the syslog server closed the connection after receiving our message")
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("nothing is listening on %s/%d", protocol, port)
+	}
+
+	return err
+}
+
+//
+// Register our protocol-tester.
+//
+func init() {
+	Register("syslog", func() ProtocolTest {
+		return &SYSLOGTest{}
+	})
+}
@@ -0,0 +1,111 @@
+package protocols
+
+import (
+	"bufio"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// syslogMessageRegexp matches the RFC5424 messages we send.
+var syslogMessageRegexp = regexp.MustCompile(`^<14>1 \d{4}-\d\d-\d\dT[0-9:.]+Z \S+ overseer \d+ overseer-test - (.+)$`)
+
+func runSyslogTest(port string, args map[string]string) error {
+	args["port"] = port
+	tst := test.Test{Target: "127.0.0.1", Type: "syslog", Arguments: args}
+	return (&SYSLOGTest{}).RunTest(tst, "127.0.0.1", test.Options{Timeout: 2 * time.Second})
+}
+
+func TestSyslogTCP(t *testing.T) {
+	received := make(chan string, 1)
+	port, stop := startTCPServer(t, func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			return
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(length))
+		buf := make([]byte, size)
+		if _, err = reader.Read(buf); err == nil {
+			received <- string(buf)
+		}
+		// Stay connected, as a real server would.
+		time.Sleep(time.Second)
+	})
+	defer stop()
+
+	if err := runSyslogTest(port, map[string]string{"protocol": "tcp", "message": "canary"}); err != nil {
+		t.Fatalf("expected the message to be accepted, got %s", err)
+	}
+	match := syslogMessageRegexp.FindStringSubmatch(<-received)
+	if match == nil || match[1] != "canary" {
+		t.Errorf("unexpected message %v", match)
+	}
+}
+
+func TestSyslogTCPNonTransparent(t *testing.T) {
+	received := make(chan string, 1)
+	port, stop := startTCPServer(t, func(conn net.Conn) {
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err == nil {
+			received <- strings.TrimSuffix(line, "\n")
+		}
+		time.Sleep(time.Second)
+	})
+	defer stop()
+
+	if err := runSyslogTest(port, map[string]string{"protocol": "tcp", "framing": "non-transparent"}); err != nil {
+		t.Fatalf("expected the message to be accepted, got %s", err)
+	}
+	if !syslogMessageRegexp.MatchString(<-received) {
+		t.Errorf("expected a well-formed message")
+	}
+}
+
+func TestSyslogTCPClosed(t *testing.T) {
+	port, stop := startTCPServer(t, func(conn net.Conn) {
+		// Drop the connection as soon as something arrives.
+		conn.Read(make([]byte, 1024))
+	})
+	defer stop()
+
+	if err := runSyslogTest(port, map[string]string{"protocol": "tcp"}); err == nil {
+		t.Errorf("expected the closed connection to fail")
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	port := strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+
+	received := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 1500)
+		n, _, errRead := conn.ReadFrom(buf)
+		if errRead == nil {
+			received <- string(buf[:n])
+		}
+	}()
+
+	if err = runSyslogTest(port, map[string]string{}); err != nil {
+		t.Fatalf("expected the message to be sent, got %s", err)
+	}
+	if !syslogMessageRegexp.MatchString(<-received) {
+		t.Errorf("expected a well-formed message")
+	}
+
+	// Nothing listening anymore.
+	conn.Close()
+	err = runSyslogTest(port, map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "nothing is listening") {
+		t.Errorf("expected the closed port to be reported, got %v", err)
+	}
+}
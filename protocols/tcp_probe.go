@@ -16,6 +16,28 @@
 //
 //    host.example.com must run tcp with port 655 with banner '0 \S+ 17'
 //
+// For services which only talk after the client, you can send a string
+// to the remote host before reading the banner, "\r", "\n" and "\t" are
+// unescaped:
+//
+//    host.example.com must run tcp with port 6379 with send 'PING\r\n' with banner 'PONG'
+//
+// To check how the remote host copes with slow clients, slowloris-style,
+// you can send the string one byte at a time, waiting between each byte.
+// The test then passes if the remote host either responds, or closes the
+// connection, before the timeout expires, and fails if it keeps the
+// connection open without doing either:
+//
+//    host.example.com must run tcp with port 80 with send 'GET / HTTP/1.1\r\nHost: example.com\r\n\r\n' with slow-send 500ms
+//
+// For services which send multi-line responses you can also assert on
+// their structure, by giving the exact number of lines expected, and the
+// number of times the banner regular expression must match.  In this case
+// the whole response is read, until the remote host closes the connection
+// or stops sending data for half a second:
+//
+//    host.example.com must run tcp with port 4190 with lines 5 with banner '^"[A-Z]+"' with match-count 3
+//
 
 package protocols
 
@@ -23,10 +45,12 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cmaster11/overseer/test"
 )
@@ -40,8 +64,12 @@ type TCPTest struct {
 // their values.
 func (s *TCPTest) Arguments() map[string]string {
 	known := map[string]string{
-		"port":   "^[0-9]+$",
-		"banner": ".*",
+		"port":        "^[0-9]+$",
+		"banner":      ".*",
+		"send":        ".*",
+		"slow-send":   `^[+]?([0-9]*(\.[0-9]*)?[a-z]+)+$`,
+		"lines":       `^\d+$`,
+		"match-count": `^\d+$`,
 	}
 	return known
 }
@@ -71,6 +99,28 @@ TCP Tester
  banner the remote host sends on connection:
 
     host.example.com must run tcp with port 655 with banner '0 \S+ 17'
+
+ For services which only talk after the client, you can send a string
+ to the remote host before reading the banner, "\r", "\n" and "\t" are
+ unescaped:
+
+    host.example.com must run tcp with port 6379 with send 'PING\r\n' with banner 'PONG'
+
+ To check how the remote host copes with slow clients, slowloris-style,
+ you can send the string one byte at a time, waiting between each byte.
+ The test then passes if the remote host either responds, or closes the
+ connection, before the timeout expires, and fails if it keeps the
+ connection open without doing either:
+
+    host.example.com must run tcp with port 80 with send 'GET / HTTP/1.1\r\nHost: example.com\r\n\r\n' with slow-send 500ms
+
+ For services which send multi-line responses you can also assert on
+ their structure, by giving the exact number of lines expected, and the
+ number of times the banner regular expression must match.  In this case
+ the whole response is read, until the remote host closes the connection
+ or stops sending data for half a second:
+
+    host.example.com must run tcp with port 4190 with lines 5 with banner '^"[A-Z]+"' with match-count 3
 `
 	return str
 }
@@ -132,6 +182,40 @@ func (s *TCPTest) RunTest(tst test.Test, target string, opts test.Options) error
 
 	defer conn.Close()
 
+	//
+	// Don't wait forever for the remote host.
+	//
+	err = conn.SetDeadline(time.Now().Add(opts.Timeout))
+	if err != nil {
+		return err
+	}
+
+	//
+	// Are we checking how the remote host copes with a slow client?
+	//
+	if tst.Arguments["slow-send"] != "" {
+		return s.slowSend(conn, tst, opts)
+	}
+
+	//
+	// Do we need to talk first?
+	//
+	if tst.Arguments["send"] != "" {
+		unescape := strings.NewReplacer(`\r`, "\r", `\n`, "\n", `\t`, "\t")
+
+		_, err = conn.Write([]byte(unescape.Replace(tst.Arguments["send"])))
+		if err != nil {
+			return err
+		}
+	}
+
+	//
+	// Are we asserting on the structure of the whole response?
+	//
+	if tst.Arguments["lines"] != "" || tst.Arguments["match-count"] != "" {
+		return s.checkResponse(conn, tst, opts)
+	}
+
 	//
 	// If we're going to do a banner match then we should read a line
 	// from the host
@@ -162,6 +246,187 @@ func (s *TCPTest) RunTest(tst test.Test, target string, opts test.Options) error
 	return nil
 }
 
+// tcpQuietPeriod is how long we wait for more data, once the remote host
+// started sending its response, before we consider it complete.
+const tcpQuietPeriod = 500 * time.Millisecond
+
+// checkResponse reads the whole response of the remote host, and ensures
+// it has the number of lines, and banner matches, the user expects.
+func (s *TCPTest) checkResponse(conn net.Conn, tst test.Test, opts test.Options) error {
+
+	if tst.Arguments["match-count"] != "" && tst.Arguments["banner"] == "" {
+		return errors.New("match-count requires a banner to match")
+	}
+
+	re, err := regexp.Compile("(?ms)" + tst.Arguments["banner"])
+	if err != nil {
+		return err
+	}
+
+	//
+	// Read until the remote host closes the connection, or goes
+	// quiet, without extending the deadline of the whole test.
+	//
+	end := time.Now().Add(opts.Timeout)
+	var response []byte
+	buf := make([]byte, 4096)
+
+	for {
+		deadline := end
+		if len(response) > 0 && time.Now().Add(tcpQuietPeriod).Before(end) {
+			deadline = time.Now().Add(tcpQuietPeriod)
+		}
+		err = conn.SetReadDeadline(deadline)
+		if err != nil {
+			return err
+		}
+
+		n, errRead := conn.Read(buf)
+		response = append(response, buf[:n]...)
+		if errRead != nil {
+			if netErr, ok := errRead.(net.Error); ok && netErr.Timeout() {
+				if len(response) == 0 {
+					return fmt.Errorf("no response received within %s", opts.Timeout)
+				}
+				break
+			}
+			if errRead == io.EOF {
+				break
+			}
+			return errRead
+		}
+	}
+
+	text := strings.TrimRight(string(response), "\r\n")
+
+	if tst.Arguments["lines"] != "" {
+		expected, errConv := strconv.Atoi(tst.Arguments["lines"])
+		if errConv != nil {
+			return errConv
+		}
+
+		lines := 0
+		if text != "" {
+			lines = strings.Count(text, "\n") + 1
+		}
+		if lines != expected {
+			return fmt.Errorf("remote response has %d lines, expected %d: '%s'", lines, expected, text)
+		}
+	}
+
+	if tst.Arguments["banner"] != "" {
+		matches := len(re.FindAllString(text, -1))
+
+		if tst.Arguments["match-count"] != "" {
+			expected, errConv := strconv.Atoi(tst.Arguments["match-count"])
+			if errConv != nil {
+				return errConv
+			}
+			if matches != expected {
+				return fmt.Errorf("regular expression '%s' matched the remote response %d times, expected %d", tst.Arguments["banner"], matches, expected)
+			}
+		} else if matches == 0 {
+			return fmt.Errorf("remote response '%s' didn't match the regular expression '%s'", text, tst.Arguments["banner"])
+		}
+	}
+
+	return nil
+}
+
+// slowSend writes the send-string to the remote host one byte at a time,
+// waiting between each byte, and then ensures the host either responds
+// or closes the connection before our timeout expires.
+func (s *TCPTest) slowSend(conn net.Conn, tst test.Test, opts test.Options) error {
+
+	delay, err := time.ParseDuration(tst.Arguments["slow-send"])
+	if err != nil {
+		return err
+	}
+
+	if tst.Arguments["send"] == "" {
+		return errors.New("slow-send requires a string to send")
+	}
+
+	unescape := strings.NewReplacer(`\r`, "\r", `\n`, "\n", `\t`, "\t")
+	data := []byte(unescape.Replace(tst.Arguments["send"]))
+
+	//
+	// Read whatever the remote host sends us while we're still
+	// talking, or afterwards.
+	//
+	type readResult struct {
+		response string
+		err      error
+	}
+	readDone := make(chan readResult, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		n, errRead := conn.Read(buf)
+		readDone <- readResult{string(buf[:n]), errRead}
+	}()
+
+	start := time.Now()
+	sent := 0
+	var result *readResult
+
+	for sent < len(data) && result == nil {
+		if sent > 0 {
+			select {
+			case r := <-readDone:
+				result = &r
+				continue
+			case <-time.After(delay):
+			}
+		}
+
+		_, err = conn.Write(data[sent : sent+1])
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return fmt.Errorf("remote host kept the slow connection open for %s, after %d/%d bytes, without responding or closing it", time.Since(start).Round(time.Millisecond), sent, len(data))
+			}
+			if opts.Verbose {
+				fmt.Printf("\tRemote host closed the connection after %d/%d bytes, in %s\n", sent, len(data), time.Since(start))
+			}
+			return nil
+		}
+		sent++
+	}
+
+	if result == nil {
+		r := <-readDone
+		result = &r
+	}
+
+	if result.response != "" {
+		if opts.Verbose {
+			fmt.Printf("\tRemote host responded after %d/%d bytes, in %s\n", sent, len(data), time.Since(start))
+		}
+
+		if tst.Arguments["banner"] != "" {
+			re, errCompile := regexp.Compile("(?ms)" + tst.Arguments["banner"])
+			if errCompile != nil {
+				return errCompile
+			}
+			if !re.MatchString(result.response) {
+				return fmt.Errorf("remote response '%s' didn't match the regular expression '%s'", result.response, tst.Arguments["banner"])
+			}
+		}
+		return nil
+	}
+
+	if netErr, ok := result.err.(net.Error); ok && netErr.Timeout() {
+		return fmt.Errorf("remote host kept the slow connection open for %s, after %d/%d bytes, without responding or closing it", time.Since(start).Round(time.Millisecond), sent, len(data))
+	}
+
+	//
+	// The remote host closed, or reset, the connection.
+	//
+	if opts.Verbose {
+		fmt.Printf("\tRemote host closed the connection after %d/%d bytes, in %s\n", sent, len(data), time.Since(start))
+	}
+	return nil
+}
+
 //
 // Register our protocol-tester.
 //
@@ -0,0 +1,160 @@
+package protocols
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// startTCPServer starts a local TCP listener which runs handler for each
+// connection, returning its port.
+func startTCPServer(t *testing.T, handler func(conn net.Conn)) (string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handler(conn)
+			}()
+		}
+	}()
+
+	return strconv.Itoa(ln.Addr().(*net.TCPAddr).Port), func() { ln.Close() }
+}
+
+func TestTCPBanner(t *testing.T) {
+	port, stop := startTCPServer(t, func(conn net.Conn) {
+		conn.Write([]byte("SSH-2.0-OpenSSH_7.4\r\n"))
+	})
+	defer stop()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	probe := &TCPTest{}
+
+	tst := test.Test{Target: "127.0.0.1", Type: "tcp", Arguments: map[string]string{"port": port, "banner": "OpenSSH"}}
+	if err := probe.RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the banner to match, got %s", err)
+	}
+
+	tst.Arguments["banner"] = "Dropbear"
+	if err := probe.RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected the banner not to match")
+	}
+}
+
+func TestTCPSend(t *testing.T) {
+	port, stop := startTCPServer(t, func(conn net.Conn) {
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err == nil && line == "PING\r\n" {
+			conn.Write([]byte("+PONG\r\n"))
+		}
+	})
+	defer stop()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	tst := test.Test{Target: "127.0.0.1", Type: "tcp", Arguments: map[string]string{"port": port, "send": `PING\r\n`, "banner": "PONG"}}
+	if err := (&TCPTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the reply to match, got %s", err)
+	}
+}
+
+func TestTCPConnectionRefused(t *testing.T) {
+	// Grab a free port, and close it straight away
+	port, stop := startTCPServer(t, func(conn net.Conn) {})
+	stop()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	tst := test.Test{Target: "127.0.0.1", Type: "tcp", Arguments: map[string]string{"port": port}}
+	if err := (&TCPTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected the connection to be refused")
+	}
+}
+
+func TestTCPSlowSend(t *testing.T) {
+	opts := test.Options{Timeout: time.Second}
+	tst := test.Test{Target: "127.0.0.1", Type: "tcp", Arguments: map[string]string{"send": "PING\r\n", "slow-send": "10ms"}}
+
+	// A server which responds once the whole line arrived.
+	port, stop := startTCPServer(t, func(conn net.Conn) {
+		if _, err := bufio.NewReader(conn).ReadString('\n'); err == nil {
+			conn.Write([]byte("+PONG\r\n"))
+		}
+	})
+	tst.Arguments["port"] = port
+	tst.Arguments["banner"] = "PONG"
+	if err := (&TCPTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the server to respond, got %s", err)
+	}
+	stop()
+	delete(tst.Arguments, "banner")
+
+	// A server which gives up on slow clients.
+	port, stop = startTCPServer(t, func(conn net.Conn) {
+		time.Sleep(20 * time.Millisecond)
+	})
+	tst.Arguments["port"] = port
+	if err := (&TCPTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the server closing the connection to be accepted, got %s", err)
+	}
+	stop()
+
+	// A server which waits forever.
+	port, stop = startTCPServer(t, func(conn net.Conn) {
+		time.Sleep(2 * time.Second)
+	})
+	defer stop()
+	tst.Arguments["port"] = port
+	if err := (&TCPTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected the hanging server to fail the test")
+	}
+}
+
+func TestTCPResponseStructure(t *testing.T) {
+	port, stop := startTCPServer(t, func(conn net.Conn) {
+		conn.Write([]byte("\"IMPLEMENTATION\" \"Dovecot\"\r\n\"SIEVE\" \"fileinto\"\r\n"))
+		time.Sleep(50 * time.Millisecond)
+		conn.Write([]byte("\"SASL\" \"PLAIN\"\r\nOK \"Ready.\"\r\n"))
+	})
+	defer stop()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	tst := test.Test{Target: "127.0.0.1", Type: "tcp", Arguments: map[string]string{
+		"port":        port,
+		"lines":       "4",
+		"banner":      `^"[A-Z]+"`,
+		"match-count": "3",
+	}}
+	if err := (&TCPTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the response structure to match, got %s", err)
+	}
+
+	tst.Arguments["lines"] = "3"
+	if err := (&TCPTest{}).RunTest(tst, "127.0.0.1", opts); err == nil || !strings.Contains(err.Error(), "has 4 lines") {
+		t.Errorf("expected the line count not to match, got %v", err)
+	}
+
+	delete(tst.Arguments, "lines")
+	tst.Arguments["match-count"] = "2"
+	if err := (&TCPTest{}).RunTest(tst, "127.0.0.1", opts); err == nil || !strings.Contains(err.Error(), "3 times") {
+		t.Errorf("expected the match count not to match, got %v", err)
+	}
+
+	// A match-count is meaningless without a banner.
+	delete(tst.Arguments, "banner")
+	if err := (&TCPTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected a match-count without a banner to fail")
+	}
+}
@@ -0,0 +1,54 @@
+package protocols
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+// tlsHandshakeTime connects to the given address and returns how long the
+// TLS handshake took, using the given name for SNI.
+//
+// Only the handshake is timed, not the connection, and the certificate
+// isn't verified: that's up to the tests themselves.
+func tlsHandshakeTime(address string, serverName string, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	client := tls.Client(conn, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+
+	start := time.Now()
+	if err = client.Handshake(); err != nil {
+		return 0, fmt.Errorf("TLS handshake failed: %s", err.Error())
+	}
+	return time.Since(start), nil
+}
+
+// checkHandshakeTime ensures the given duration of a TLS handshake isn't
+// over the given maximum, as parsed from the max-handshake argument.
+func checkHandshakeTime(elapsed time.Duration, maxHandshake string, verbose bool) error {
+	max, err := time.ParseDuration(maxHandshake)
+	if err != nil {
+		return fmt.Errorf("invalid max-handshake '%s': %s", maxHandshake, err.Error())
+	}
+
+	if verbose {
+		fmt.Printf("\tTLS handshake took %s\n", elapsed.Round(time.Microsecond))
+	}
+
+	if elapsed > max {
+		return fmt.Errorf("TLS handshake took %s, more than the maximum of %s", elapsed.Round(time.Microsecond), max)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package protocols
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// vaultCacheTTL is how long a secret read from Vault is reused for,
+// before it is read again.
+const vaultCacheTTL = time.Minute
+
+// vaultSecret is a single value read from Vault.
+type vaultSecret struct {
+	Value  string
+	Expiry time.Time
+}
+
+// vaultSecretCache holds the secrets we've read, keyed by their full
+// vault-path, so that we don't hit Vault for every single test.
+var vaultSecretCache = struct {
+	sync.Mutex
+	secrets map[string]*vaultSecret
+}{secrets: make(map[string]*vaultSecret)}
+
+// vaultRead returns the value identified by the given vault-path, which
+// has the form `path#key`, e.g. `secret/data/db#password`.
+//
+// The address of the Vault server, and the token to use, are read from
+// the VAULT_ADDR and VAULT_TOKEN environment variables.
+func vaultRead(vaultPath string, timeout time.Duration) (string, error) {
+
+	idx := strings.LastIndex(vaultPath, "#")
+	if idx < 1 || idx == len(vaultPath)-1 {
+		return "", fmt.Errorf("invalid vault-path '%s', expected 'path#key'", vaultPath)
+	}
+	secretPath := strings.Trim(vaultPath[:idx], "/")
+	key := vaultPath[idx+1:]
+
+	vaultSecretCache.Lock()
+	secret := vaultSecretCache.secrets[vaultPath]
+	vaultSecretCache.Unlock()
+
+	if secret != nil && time.Now().Before(secret.Expiry) {
+		return secret.Value, nil
+	}
+
+	address := os.Getenv("VAULT_ADDR")
+	if address == "" {
+		return "", errors.New("VAULT_ADDR is not set, cannot read from Vault")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		return "", errors.New("VAULT_TOKEN is not set, cannot read from Vault")
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(address, "/")+"/v1/"+secretPath, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	req.Header.Set("User-Agent", "overseer/probe")
+
+	client := &http.Client{Timeout: timeout}
+	response, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read '%s' from Vault: %s", secretPath, err.Error())
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to read '%s' from Vault: status code was %d", secretPath, response.StatusCode)
+	}
+
+	var payload struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&payload); err != nil {
+		return "", fmt.Errorf("failed to parse Vault response for '%s': %s", secretPath, err.Error())
+	}
+
+	//
+	// Version 2 of the KV secrets engine nests the values, alongside
+	// their metadata.
+	//
+	data := payload.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok && data["metadata"] != nil {
+		data = inner
+	}
+
+	raw, ok := data[key]
+	if !ok || raw == nil {
+		return "", fmt.Errorf("key '%s' not found in Vault secret '%s'", key, secretPath)
+	}
+
+	value, ok := raw.(string)
+	if !ok {
+		value = fmt.Sprintf("%v", raw)
+	}
+
+	vaultSecretCache.Lock()
+	vaultSecretCache.secrets[vaultPath] = &vaultSecret{Value: value, Expiry: time.Now().Add(vaultCacheTTL)}
+	vaultSecretCache.Unlock()
+
+	return value, nil
+}
+
+// vaultPassword returns a copy of the given test, with the password
+// argument read from Vault if a vault-path was given, along with the
+// password itself so that it can be censored from the test output.
+func vaultPassword(tst test.Test, timeout time.Duration) (test.Test, string, error) {
+
+	if tst.Arguments["vault-path"] == "" {
+		return tst, "", nil
+	}
+
+	if tst.Arguments["password"] != "" {
+		return tst, "", errors.New("password and vault-path cannot be used together")
+	}
+
+	password, err := vaultRead(tst.Arguments["vault-path"], timeout)
+	if err != nil {
+		return tst, "", err
+	}
+
+	args := make(map[string]string, len(tst.Arguments)+1)
+	for k, v := range tst.Arguments {
+		args[k] = v
+	}
+	args["password"] = password
+	tst.Arguments = args
+
+	return tst, password, nil
+}
+
+// censorSecret removes the given secret from the input string.
+func censorSecret(input string, secret string) string {
+	if secret == "" {
+		return input
+	}
+	return strings.Replace(input, secret, "CENSORED", -1)
+}
+
+// censorSecretError removes the given secret from the message of the
+// given error, if present.
+func censorSecretError(err error, secret string) error {
+	if err == nil || secret == "" || !strings.Contains(err.Error(), secret) {
+		return err
+	}
+	return errors.New(censorSecret(err.Error(), secret))
+}
@@ -0,0 +1,140 @@
+package protocols
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// startVaultServer starts a fake Vault server, holding a KV version 2
+// secret at "secret/data/db", and a version 1 one at "kv/web".
+func startVaultServer(requests *int32) func() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/db":
+			w.Write([]byte(`{"data": {"data": {"password": "s3cr3t", "port": 5432}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/web":
+			w.Write([]byte(`{"data": {"password": "hunter2"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	os.Setenv("VAULT_ADDR", server.URL)
+	os.Setenv("VAULT_TOKEN", "s.token")
+
+	vaultSecretCache.Lock()
+	vaultSecretCache.secrets = make(map[string]*vaultSecret)
+	vaultSecretCache.Unlock()
+
+	return func() {
+		server.Close()
+		os.Unsetenv("VAULT_ADDR")
+		os.Unsetenv("VAULT_TOKEN")
+	}
+}
+
+func TestVaultRead(t *testing.T) {
+	var requests int32
+	stop := startVaultServer(&requests)
+	defer stop()
+
+	value, err := vaultRead("secret/data/db#password", time.Second)
+	if err != nil || value != "s3cr3t" {
+		t.Errorf("unexpected KV v2 result %q, %v", value, err)
+	}
+
+	// Non-string values are formatted.
+	value, err = vaultRead("secret/data/db#port", time.Second)
+	if err != nil || value != "5432" {
+		t.Errorf("unexpected KV v2 result %q, %v", value, err)
+	}
+
+	value, err = vaultRead("/kv/web#password", time.Second)
+	if err != nil || value != "hunter2" {
+		t.Errorf("unexpected KV v1 result %q, %v", value, err)
+	}
+
+	// Secrets are cached.
+	before := atomic.LoadInt32(&requests)
+	if value, err = vaultRead("secret/data/db#password", time.Second); err != nil || value != "s3cr3t" {
+		t.Errorf("unexpected cached result %q, %v", value, err)
+	}
+	if atomic.LoadInt32(&requests) != before {
+		t.Errorf("expected the cached secret to be used")
+	}
+
+	// Failures.
+	for _, vaultPath := range []string{"secret/data/db", "secret/data/db#", "secret/data/db#user", "secret/data/missing#password"} {
+		if _, err = vaultRead(vaultPath, time.Second); err == nil {
+			t.Errorf("expected reading %s to fail", vaultPath)
+		}
+	}
+
+	os.Setenv("VAULT_TOKEN", "wrong")
+	_, err = vaultRead("kv/other#password", time.Second)
+	if err == nil || !strings.Contains(err.Error(), "status code was 403") {
+		t.Errorf("expected an invalid token to fail, got %v", err)
+	}
+}
+
+func TestVaultPassword(t *testing.T) {
+	var requests int32
+	stop := startVaultServer(&requests)
+	defer stop()
+
+	probe := &PSQLTest{driver: "mockpq"}
+	opts := test.Options{Timeout: 5 * time.Second}
+
+	tst := test.Test{Target: "db.example.com", Type: "postgres", Arguments: map[string]string{
+		"username":   "steve",
+		"vault-path": "secret/data/db#password",
+	}}
+
+	if err := probe.RunTest(tst, "10.0.0.1", opts); err != nil {
+		t.Errorf("expected the login to succeed, got %s", err)
+	}
+	if !strings.Contains(mockPQDriver.dsn, "password='s3cr3t'") {
+		t.Errorf("expected the password from Vault to be used, got %s", mockPQDriver.dsn)
+	}
+
+	// The test itself isn't modified.
+	if _, ok := tst.Arguments["password"]; ok {
+		t.Errorf("expected the test arguments to be left alone")
+	}
+
+	// Both a password and a vault-path is ambiguous.
+	tst.Arguments["password"] = "secret"
+	if err := probe.RunTest(tst, "10.0.0.1", opts); err == nil {
+		t.Errorf("expected a password and vault-path to fail")
+	}
+}
+
+func TestCensorSecret(t *testing.T) {
+	err := censorSecretError(errors.New("login failed for password 's3cr3t'"), "s3cr3t")
+	if err.Error() != "login failed for password 'CENSORED'" {
+		t.Errorf("unexpected error %s", err)
+	}
+
+	if censorSecretError(nil, "s3cr3t") != nil {
+		t.Errorf("expected no error")
+	}
+
+	if censorSecret("password=x", "") != "password=x" {
+		t.Errorf("expected an empty secret to be ignored")
+	}
+}
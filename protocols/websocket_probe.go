@@ -0,0 +1,178 @@
+// WebSocket Tester
+//
+// The WebSocket tester connects to a remote WebSocket server, and ensures
+// that the upgrade handshake succeeds.
+//
+// This test is invoked via input like so:
+//
+//    wss://example.com/socket must run websocket
+//
+// You can also send a text message once connected, and ensure that the
+// message received in reply contains a given string:
+//
+//    wss://example.com/socket must run websocket with send 'ping' with expect 'pong'
+//
+// If no message is sent you can still ensure the first message the server
+// sends contains the expected string.
+//
+// If you need to disable failures due to expired, broken, or
+// otherwise bogus SSL certificates you can do so via the tls setting:
+//
+//    wss://self-signed.example.com/socket must run websocket with tls insecure
+//
+
+package protocols
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	"github.com/gorilla/websocket"
+)
+
+// WEBSOCKETTest is our object
+type WEBSOCKETTest struct {
+}
+
+// Arguments returns the names of arguments which this protocol-test
+// understands, along with corresponding regular-expressions to validate
+// their values.
+func (s *WEBSOCKETTest) Arguments() map[string]string {
+	known := map[string]string{
+		"send":   ".*",
+		"expect": ".*",
+		"tls":    "insecure",
+	}
+	return known
+}
+
+// ShouldResolveHostname returns if this protocol requires the hostname resolution of the first test argument
+func (s *WEBSOCKETTest) ShouldResolveHostname() bool {
+	return true
+}
+
+// Example returns sample usage-instructions for self-documentation purposes.
+func (s *WEBSOCKETTest) Example() string {
+	str := `
+WebSocket Tester
+----------------
+ The WebSocket tester connects to a remote WebSocket server, and ensures
+ that the upgrade handshake succeeds.
+
+ This test is invoked via input like so:
+
+    wss://example.com/socket must run websocket
+
+ You can also send a text message once connected, and ensure that the
+ message received in reply contains a given string:
+
+    wss://example.com/socket must run websocket with send 'ping' with expect 'pong'
+
+ If no message is sent you can still ensure the first message the server
+ sends contains the expected string.
+
+ If you need to disable failures due to expired, broken, or
+ otherwise bogus SSL certificates you can do so via the tls setting:
+
+    wss://self-signed.example.com/socket must run websocket with tls insecure
+`
+	return str
+}
+
+// RunTest is the part of our API which is invoked to actually execute a
+// test against the given target.
+//
+// In this case we perform the WebSocket handshake against the resolved
+// IP address, and then exchange a message if required.
+func (s *WEBSOCKETTest) RunTest(tst test.Test, target string, opts test.Options) error {
+
+	u, err := url.Parse(tst.Target)
+	if err != nil {
+		return err
+	}
+
+	port := ""
+	switch u.Scheme {
+	case "ws":
+		port = "80"
+	case "wss":
+		port = "443"
+	default:
+		return fmt.Errorf("unsupported scheme '%s', expected ws or wss", u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+
+	//
+	// Connect to the IP we've been given, rather than to the
+	// result of a new lookup of the hostname.
+	//
+	address := fmt.Sprintf("%s:%s", target, port)
+	if strings.Contains(target, ":") {
+		address = fmt.Sprintf("[%s]:%s", target, port)
+	}
+
+	netDialer := &net.Dialer{Timeout: opts.Timeout}
+	dialer := &websocket.Dialer{
+		NetDialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return netDialer.DialContext(ctx, network, address)
+		},
+		HandshakeTimeout: opts.Timeout,
+	}
+	if tst.Arguments["tls"] == "insecure" {
+		dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	conn, response, err := dialer.Dial(tst.Target, nil)
+	if err != nil {
+		if response != nil {
+			return fmt.Errorf("WebSocket handshake failed with status code %d: %s", response.StatusCode, err.Error())
+		}
+		return err
+	}
+	defer conn.Close()
+
+	//
+	// Don't wait forever for the remote host.
+	//
+	err = conn.SetReadDeadline(time.Now().Add(opts.Timeout))
+	if err != nil {
+		return err
+	}
+
+	if tst.Arguments["send"] != "" {
+		err = conn.WriteMessage(websocket.TextMessage, []byte(tst.Arguments["send"]))
+		if err != nil {
+			return err
+		}
+	}
+
+	if tst.Arguments["expect"] != "" {
+		_, msg, errRead := conn.ReadMessage()
+		if errRead != nil {
+			return fmt.Errorf("failed to read message: %s", errRead.Error())
+		}
+
+		if !strings.Contains(string(msg), tst.Arguments["expect"]) {
+			return fmt.Errorf("received message '%s' doesn't contain '%s'", msg, tst.Arguments["expect"])
+		}
+	}
+
+	return nil
+}
+
+//
+// Register our protocol-tester.
+//
+func init() {
+	Register("websocket", func() ProtocolTest {
+		return &WEBSOCKETTest{}
+	})
+}
@@ -0,0 +1,90 @@
+package protocols
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+	"github.com/gorilla/websocket"
+)
+
+// startWebSocketServer starts a local WebSocket server which replies
+// "pong" to "ping", and echoes anything else.  Requests with a
+// "reject" query-parameter are refused.
+func startWebSocketServer() *httptest.Server {
+	upgrader := websocket.Upgrader{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("reject") != "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			kind, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(msg) == "ping" {
+				msg = []byte("pong")
+			}
+			if err = conn.WriteMessage(kind, msg); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestWebSocket(t *testing.T) {
+	server := startWebSocketServer()
+	defer server.Close()
+
+	opts := test.Options{Timeout: 2 * time.Second}
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/socket"
+
+	// Successful handshake.
+	tst := test.Test{Target: wsURL, Type: "websocket", Arguments: map[string]string{}}
+	if err := (&WEBSOCKETTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the handshake to succeed, got %s", err)
+	}
+
+	// Successful exchange.
+	tst.Arguments = map[string]string{"send": "ping", "expect": "pong"}
+	if err := (&WEBSOCKETTest{}).RunTest(tst, "127.0.0.1", opts); err != nil {
+		t.Errorf("expected the reply to match, got %s", err)
+	}
+
+	// Mismatching reply.
+	tst.Arguments = map[string]string{"send": "hello", "expect": "pong"}
+	if err := (&WEBSOCKETTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected the reply not to match")
+	}
+
+	// Rejected handshake.
+	tst = test.Test{Target: wsURL + "?reject=1", Type: "websocket", Arguments: map[string]string{}}
+	err := (&WEBSOCKETTest{}).RunTest(tst, "127.0.0.1", opts)
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected the handshake to be rejected, got %v", err)
+	}
+}
+
+func TestWebSocketReadTimeout(t *testing.T) {
+	server := startWebSocketServer()
+	defer server.Close()
+
+	// Nothing is sent, so nothing will be received.
+	opts := test.Options{Timeout: 200 * time.Millisecond}
+	tst := test.Test{Target: "ws" + strings.TrimPrefix(server.URL, "http"), Type: "websocket", Arguments: map[string]string{"expect": "pong"}}
+	if err := (&WEBSOCKETTest{}).RunTest(tst, "127.0.0.1", opts); err == nil {
+		t.Errorf("expected the read to time out")
+	}
+}
@@ -0,0 +1,45 @@
+package sinks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// MemorySink keeps the results in memory, e.g. to show them once a local
+// run is complete.
+type MemorySink struct {
+	lock      sync.Mutex
+	results   []*test.Result
+	durations []time.Duration
+}
+
+// NewMemorySink returns an empty sink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Record stores the given result.
+func (s *MemorySink) Record(result *test.Result, duration time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.results = append(s.results, result)
+	s.durations = append(s.durations, duration)
+	return nil
+}
+
+// Results returns the results recorded so far, in order, along with the
+// time their tests took to run.
+func (s *MemorySink) Results() ([]*test.Result, []time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]*test.Result(nil), s.results...), append([]time.Duration(nil), s.durations...)
+}
+
+// Close does nothing.
+func (s *MemorySink) Close() error {
+	return nil
+}
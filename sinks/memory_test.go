@@ -0,0 +1,47 @@
+package sinks
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+func TestMemorySink(t *testing.T) {
+	sink := NewMemorySink()
+
+	if results, durations := sink.Results(); len(results) != 0 || len(durations) != 0 {
+		t.Fatalf("expected no results, got %v", results)
+	}
+
+	// Workers record their results concurrently.
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sink.Record(&test.Result{Target: "1.2.3.4", Time: int64(i)}, time.Duration(i)*time.Millisecond)
+		}(i)
+	}
+	wg.Wait()
+
+	results, durations := sink.Results()
+	if len(results) != 10 || len(durations) != 10 {
+		t.Fatalf("expected 10 results, got %d and %d durations", len(results), len(durations))
+	}
+	for i, result := range results {
+		if time.Duration(result.Time)*time.Millisecond != durations[i] {
+			t.Errorf("result %+v doesn't match its duration %s", result, durations[i])
+		}
+	}
+
+	// The results returned aren't affected by later ones.
+	sink.Record(&test.Result{}, 0)
+	if len(results) != 10 {
+		t.Errorf("expected the returned results not to change")
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,19 @@
+// Package sinks contains the places, besides the redis results queue,
+// where a worker can record the results of the tests it runs.
+package sinks
+
+import (
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+// ResultSink records the results of the tests, e.g. for offline analysis.
+type ResultSink interface {
+	// Record stores the given result, of a test which took the given
+	// time to run.
+	Record(result *test.Result, duration time.Duration) error
+
+	// Close releases the resources of the sink.
+	Close() error
+}
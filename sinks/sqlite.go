@@ -0,0 +1,72 @@
+package sinks
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+
+	// The SQLite driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteSchema creates the table holding the results, if missing.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS results (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	time        INTEGER NOT NULL,
+	type        TEXT    NOT NULL,
+	target      TEXT    NOT NULL,
+	input       TEXT    NOT NULL,
+	tag         TEXT    NOT NULL,
+	passed      INTEGER NOT NULL,
+	error       TEXT,
+	duration_ms REAL    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS results_time ON results (time);
+`
+
+// SQLiteSink records the results in the "results" table of a SQLite
+// database, which can then be queried with SQL, e.g.:
+//
+//    SELECT target, AVG(duration_ms) FROM results WHERE type = 'http' GROUP BY target;
+//
+type SQLiteSink struct {
+	db *sql.DB
+}
+
+// NewSQLiteSink opens the given SQLite database, creating it and its
+// table if missing.
+func NewSQLiteSink(path string) (*SQLiteSink, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows a writer at a time, so have our workers take
+	// turns rather than failing as the database is locked.
+	db.SetMaxOpenConns(1)
+
+	if _, err = db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create the results table in %s: %s", path, err.Error())
+	}
+
+	return &SQLiteSink{db: db}, nil
+}
+
+// Record stores the given result.
+func (s *SQLiteSink) Record(result *test.Result, duration time.Duration) error {
+	passed := result.Error == nil
+
+	_, err := s.db.Exec(`INSERT INTO results (time, type, target, input, tag, passed, error, duration_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		result.Time, result.Type, result.Target, result.Input, result.Tag, passed, result.Error,
+		float64(duration)/float64(time.Millisecond))
+	return err
+}
+
+// Close closes the database.
+func (s *SQLiteSink) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,82 @@
+package sinks
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cmaster11/overseer/test"
+)
+
+func TestSQLiteSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sinks")
+	if err != nil {
+		t.Fatalf("failed to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "results.sqlite")
+	sink, err := NewSQLiteSink(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	failure := "connection refused"
+	results := []*test.Result{
+		{Input: "example.com must run ssh", Target: "1.2.3.4", Time: 1583056800, Type: "ssh", Tag: "prod"},
+		{Input: "example.com must run ssh", Target: "1.2.3.4", Time: 1583056860, Type: "ssh", Tag: "prod", Error: &failure},
+	}
+	for _, result := range results {
+		if err = sink.Record(result, 1500*time.Microsecond); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	sink.Close()
+
+	// The results are kept once reopened.
+	sink, err = NewSQLiteSink(path)
+	if err != nil {
+		t.Fatalf("unexpected error reopening the database: %s", err)
+	}
+	defer sink.Close()
+
+	rows, err := sink.db.Query(`SELECT time, type, target, tag, passed, error, duration_ms FROM results ORDER BY id`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rows.Close()
+
+	var count int
+	for rows.Next() {
+		var when int64
+		var testType, target, tag string
+		var passed bool
+		var errorString sql.NullString
+		var duration float64
+		if err = rows.Scan(&when, &testType, &target, &tag, &passed, &errorString, &duration); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		result := results[count]
+		if when != result.Time || testType != "ssh" || target != "1.2.3.4" || tag != "prod" || duration != 1.5 {
+			t.Errorf("unexpected row %d: %d %s %s %s %f", count, when, testType, target, tag, duration)
+		}
+		if passed != (result.Error == nil) || errorString.Valid != (result.Error != nil) {
+			t.Errorf("unexpected outcome of row %d: %v %v", count, passed, errorString)
+		}
+		if errorString.Valid && errorString.String != failure {
+			t.Errorf("unexpected error of row %d: %s", count, errorString.String)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected 2 results, got %d", count)
+	}
+
+	if _, err = NewSQLiteSink(filepath.Join(dir, "missing", "results.sqlite")); err == nil {
+		t.Errorf("expected a database in a missing directory to fail")
+	}
+}
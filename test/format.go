@@ -0,0 +1,111 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ResultFormats are the formats WriteResults understands: "text" for
+// humans, "json" for machines, and "tap" for TAP-aware test harnesses.
+var ResultFormats = []string{"text", "json", "tap"}
+
+// IsResultFormat returns whether the given format is known.
+func IsResultFormat(format string) bool {
+	for _, known := range ResultFormats {
+		if format == known {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteResults writes the given results, as collected by a local run,
+// in the given format.
+func WriteResults(w io.Writer, format string, results []*Result) error {
+	switch format {
+	case "text":
+		return writeResultsText(w, results)
+	case "json":
+		return writeResultsJSON(w, results)
+	case "tap":
+		return writeResultsTAP(w, results)
+	}
+	return fmt.Errorf("unknown format '%s', expected one of %s", format, strings.Join(ResultFormats, ", "))
+}
+
+// writeResultsText shows a line per result, followed by a summary.
+func writeResultsText(w io.Writer, results []*Result) error {
+	failed := 0
+	for _, result := range results {
+		if result.Error == nil {
+			fmt.Fprintf(w, "PASS %s\n", result.Input)
+			continue
+		}
+
+		failed++
+		fmt.Fprintf(w, "FAIL %s: %s\n", result.Input, *result.Error)
+		if result.Details != nil {
+			for _, line := range strings.Split(strings.TrimRight(*result.Details, "\n"), "\n") {
+				fmt.Fprintf(w, "     %s\n", line)
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d tests, %d passed, %d failed\n", len(results), len(results)-failed, failed)
+	return err
+}
+
+// writeResultsJSON writes the results as a JSON array, encoded the same
+// way as the ones published to redis.
+func writeResultsJSON(w io.Writer, results []*Result) error {
+	if results == nil {
+		results = []*Result{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+// writeResultsTAP writes the results as a TAP version 13 stream, with
+// the failures described by YAML blocks.
+func writeResultsTAP(w io.Writer, results []*Result) error {
+	fmt.Fprintf(w, "TAP version 13\n1..%d\n", len(results))
+
+	for idx, result := range results {
+
+		// "#" starts a directive, so it can't appear in descriptions.
+		description := strings.Replace(result.Input, "#", "\\#", -1)
+
+		if result.Error == nil {
+			fmt.Fprintf(w, "ok %d - %s\n", idx+1, description)
+			continue
+		}
+
+		fmt.Fprintf(w, "not ok %d - %s\n", idx+1, description)
+		fmt.Fprintf(w, "  ---\n")
+		fmt.Fprintf(w, "  message: %s\n", tapQuote(*result.Error))
+		fmt.Fprintf(w, "  target: %s\n", tapQuote(result.Target))
+		fmt.Fprintf(w, "  type: %s\n", tapQuote(result.Type))
+		if result.Details != nil {
+			fmt.Fprintf(w, "  details: |\n")
+			for _, line := range strings.Split(strings.TrimRight(*result.Details, "\n"), "\n") {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+		}
+		if _, err := fmt.Fprintf(w, "  ...\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tapQuote quotes the given string for a YAML block, JSON strings being
+// valid YAML ones.
+func tapQuote(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
@@ -0,0 +1,90 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func formatTestResults() []*Result {
+	errorString := "connection refused"
+	details := "line 1\nline 2\n"
+	return []*Result{
+		{Input: "example.com must run http", Target: "93.184.216.34", Type: "http"},
+		{Input: "example.com must run ssh # port 22", Target: "93.184.216.34", Type: "ssh", Error: &errorString, Details: &details},
+	}
+}
+
+func TestWriteResultsText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteResults(&buf, "text", formatTestResults()); err != nil {
+		t.Fatalf("failed to write results: %s", err)
+	}
+
+	expected := `PASS example.com must run http
+FAIL example.com must run ssh # port 22: connection refused
+     line 1
+     line 2
+
+2 tests, 1 passed, 1 failed
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestWriteResultsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteResults(&buf, "json", formatTestResults()); err != nil {
+		t.Fatalf("failed to write results: %s", err)
+	}
+
+	var decoded []Result
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("failed to decode results: %s", err)
+	}
+	if len(decoded) != 2 || decoded[0].Error != nil || *decoded[1].Error != "connection refused" {
+		t.Errorf("unexpected results %+v", decoded)
+	}
+
+	// No results is still an array.
+	buf.Reset()
+	WriteResults(&buf, "json", nil)
+	if strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("expected an empty array, got %s", buf.String())
+	}
+}
+
+func TestWriteResultsTAP(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteResults(&buf, "tap", formatTestResults()); err != nil {
+		t.Fatalf("failed to write results: %s", err)
+	}
+
+	expected := `TAP version 13
+1..2
+ok 1 - example.com must run http
+not ok 2 - example.com must run ssh \# port 22
+  ---
+  message: "connection refused"
+  target: "93.184.216.34"
+  type: "ssh"
+  details: |
+    line 1
+    line 2
+  ...
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestWriteResultsUnknownFormat(t *testing.T) {
+	if IsResultFormat("xml") {
+		t.Errorf("expected xml not to be a known format")
+	}
+	if err := WriteResults(&bytes.Buffer{}, "xml", nil); err == nil {
+		t.Errorf("expected an unknown format to fail")
+	}
+}
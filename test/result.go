@@ -1,21 +1,53 @@
 package test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"strconv"
+	"strings"
 
 	"github.com/cmaster11/overseer/utils"
 )
 
+// ResultSchemaVersion is the version of the JSON encoding of the results
+// we produce, to be bumped whenever it changes in a way older consumers
+// can't cope with.
+//
+// Results without a version were produced before it was introduced, and
+// are encoded just like the ones of version 1.
+const ResultSchemaVersion = 1
+
+// SchemaVersionError is returned by ResultFromJSON for the results of a
+// newer schema version than the ones it can decode.
+type SchemaVersionError struct {
+	Version int
+}
+
+func (e *SchemaVersionError) Error() string {
+	return fmt.Sprintf("unsupported result schema version %d, expected up to %d", e.Version, ResultSchemaVersion)
+}
+
 // Result contains a single test result
 type Result struct {
+	// Version of the encoding of the result, see ResultSchemaVersion
+	SchemaVersion int `json:"schemaVersion"`
+
 	Input  string `json:"input"`
 	Target string `json:"target"`
 	Time   int64  `json:"time"`
 	Type   string `json:"type"`
 	Tag    string `json:"tag"`
 
+	// Human-readable label of the test, if it has one
+	Name string `json:"name,omitempty"`
+
+	// Name of the worker which ran the test, its hostname unless overridden
+	Worker string `json:"worker,omitempty"`
+
 	// If not nil, test has failed
 	Error *string `json:"error"`
 
@@ -27,17 +59,106 @@ type Result struct {
 
 	// If true, this alert has recovered from a previous error
 	Recovered bool `json:"recovered"`
+
+	// For how long the test was failing, in seconds, if it has recovered and that is known
+	DowntimeSeconds int64 `json:"downtimeSeconds,omitempty"`
+
+	// If not empty, only the listed bridges should handle this result, as
+	// "bridge" or "bridge:destination" (e.g. "slack:#oncall")
+	NotifyTargets []string `json:"notifyTargets,omitempty"`
 }
 
 // Hash generates a unique identifier for the original test (e.g. to deduplicate same results)
+//
+// The name, if any, is part of it, so that tests which only differ by
+// their name are told apart, while the identifiers of the unnamed ones
+// don't change.
 func (result *Result) Hash() string {
+	if result.Name != "" {
+		return utils.GetMD5Hash(result.Input + result.Target + result.Type + result.Tag + "\x00" + result.Name)
+	}
 	return utils.GetMD5Hash(result.Input + result.Target + result.Type + result.Tag)
 }
 
-// ResultFromJSON creates a result struct from a JSON payload
+// IsNotifyTarget returns whether the named bridge should handle this result:
+// all of them should, unless the result lists its targets.
+func (result *Result) IsNotifyTarget(bridge string) bool {
+	if len(result.NotifyTargets) == 0 {
+		return true
+	}
+
+	for _, target := range result.NotifyTargets {
+		if target == bridge || strings.HasPrefix(target, bridge+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// NotifyDestinations returns the destinations the result lists for the
+// named bridge, e.g. "#oncall" for "slack:#oncall", which override the
+// ones the bridge has been configured with.
+func (result *Result) NotifyDestinations(bridge string) []string {
+	var destinations []string
+	for _, target := range result.NotifyTargets {
+		if strings.HasPrefix(target, bridge+":") {
+			destinations = append(destinations, strings.TrimPrefix(target, bridge+":"))
+		}
+	}
+	return destinations
+}
+
+// compressedResultMagic prefixes the results which have been compressed,
+// so that they can be told apart from the plain JSON ones.
+var compressedResultMagic = []byte{0, 'g', 'z'}
+
+// CompressResult gzips the given JSON payload, prefixing it with our
+// magic bytes.
+func CompressResult(msg []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(compressedResultMagic)
+
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(msg); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ResultFromJSON creates a result struct from a JSON payload, which might
+// have been compressed via CompressResult
+//
+// Results without a schema version are given the first one, while the
+// ones of a version newer than ResultSchemaVersion are rejected with a
+// *SchemaVersionError.
 func ResultFromJSON(msg []byte) (*Result, error) {
 	testResult := new(Result)
 
+	if bytes.HasPrefix(msg, compressedResultMagic) {
+		r, err := gzip.NewReader(bytes.NewReader(msg[len(compressedResultMagic):]))
+		if err != nil {
+			return nil, err
+		}
+		msg, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//
+	// Look at the version first, as newer results might not even decode.
+	//
+	var version struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if json.Unmarshal(msg, &version) == nil && version.SchemaVersion > ResultSchemaVersion {
+		return nil, &SchemaVersionError{Version: version.SchemaVersion}
+	}
+
 	if err := json.Unmarshal(msg, testResult); err != nil {
 		// Is this old-overseer message type?
 		data := map[string]string{}
@@ -60,17 +181,22 @@ func ResultFromJSON(msg []byte) (*Result, error) {
 			}
 
 			return &Result{
-				Input:  data["input"],
-				Target: data["target"],
-				Time:   timeInt,
-				Type:   data["type"],
-				Tag:    data["tag"],
-				Error:  errorPtr,
+				SchemaVersion: 1,
+				Input:         data["input"],
+				Target:        data["target"],
+				Time:          timeInt,
+				Type:          data["type"],
+				Tag:           data["tag"],
+				Error:         errorPtr,
 			}, nil
 		}
 
 		return nil, errors.New("failed to parse test result entry")
 	}
 
+	if testResult.SchemaVersion == 0 {
+		testResult.SchemaVersion = 1
+	}
+
 	return testResult, nil
 }
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestCompressedResult(t *testing.T) {
+	errorString := "connection refused"
+	details := "some details"
+	result := Result{Input: "example.com must run ssh", Target: "10.0.0.1", Type: "ssh", Error: &errorString, Details: &details}
+
+	msg, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("failed to encode result: %s", err)
+	}
+
+	compressed, err := CompressResult(msg)
+	if err != nil {
+		t.Fatalf("failed to compress result: %s", err)
+	}
+
+	// Both forms must be readable, so that queues can mix them.
+	for _, payload := range [][]byte{msg, compressed} {
+		decoded, err := ResultFromJSON(payload)
+		if err != nil {
+			t.Fatalf("failed to decode result: %s", err)
+		}
+		if decoded.Input != result.Input || *decoded.Error != errorString || *decoded.Details != details {
+			t.Errorf("unexpected result %+v", decoded)
+		}
+	}
+
+	if _, err = ResultFromJSON(compressed[:len(compressed)-4]); err == nil {
+		t.Errorf("expected a truncated payload to fail")
+	}
+}
+
+func TestResultName(t *testing.T) {
+	result := Result{Input: "example.com must run http", Target: "10.0.0.1", Type: "http", Name: "Checkout API health"}
+
+	msg, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("failed to encode result: %s", err)
+	}
+	decoded, err := ResultFromJSON(msg)
+	if err != nil {
+		t.Fatalf("failed to decode result: %s", err)
+	}
+	if decoded.Name != result.Name {
+		t.Errorf("expected the name '%s', got '%s'", result.Name, decoded.Name)
+	}
+
+	// Unnamed results keep their hash, named ones are told apart.
+	unnamed := Result{Input: result.Input, Target: result.Target, Type: result.Type}
+	if unnamed.Hash() != "a9d5c6a4e4a8da8d3130cf08a05490bd" {
+		t.Errorf("expected the hash of unnamed results not to change, got %s", unnamed.Hash())
+	}
+	if unnamed.Hash() == result.Hash() {
+		t.Errorf("expected the name to change the hash")
+	}
+	other := result
+	other.Name = "Search API health"
+	if other.Hash() == result.Hash() {
+		t.Errorf("expected tests with different names to have different hashes")
+	}
+}
+
+func TestResultSchemaVersion(t *testing.T) {
+
+	// Results of old producers get the first version.
+	for _, payload := range []string{
+		`{"input": "example.com must run ssh", "target": "10.0.0.1", "type": "ssh", "time": 1}`,
+		`{"input": "example.com must run ssh", "target": "10.0.0.1", "type": "ssh", "time": "1", "result": "passed"}`,
+	} {
+		decoded, err := ResultFromJSON([]byte(payload))
+		if err != nil {
+			t.Fatalf("failed to decode result %s: %s", payload, err)
+		}
+		if decoded.SchemaVersion != 1 || decoded.Input != "example.com must run ssh" {
+			t.Errorf("unexpected result %+v", decoded)
+		}
+	}
+
+	// Current ones are decoded as they are.
+	msg, err := json.Marshal(Result{SchemaVersion: ResultSchemaVersion, Input: "example.com must run ssh"})
+	if err != nil {
+		t.Fatalf("failed to encode result: %s", err)
+	}
+	decoded, err := ResultFromJSON(msg)
+	if err != nil {
+		t.Fatalf("failed to decode result: %s", err)
+	}
+	if decoded.SchemaVersion != ResultSchemaVersion {
+		t.Errorf("expected version %d, got %d", ResultSchemaVersion, decoded.SchemaVersion)
+	}
+
+	// Future ones are rejected, even if their fields changed.
+	future := fmt.Sprintf(`{"schemaVersion": %d, "input": ["example.com must run ssh"]}`, ResultSchemaVersion+1)
+	_, err = ResultFromJSON([]byte(future))
+	versionErr, ok := err.(*SchemaVersionError)
+	if !ok {
+		t.Fatalf("expected a *SchemaVersionError, got %v", err)
+	}
+	if versionErr.Version != ResultSchemaVersion+1 {
+		t.Errorf("expected the version %d in the error, got %d", ResultSchemaVersion+1, versionErr.Version)
+	}
+}
+
+func TestNotifyTargets(t *testing.T) {
+	result := Result{}
+	if !result.IsNotifyTarget("slack") || len(result.NotifyDestinations("slack")) != 0 {
+		t.Errorf("expected results without targets to go to every bridge")
+	}
+
+	result.NotifyTargets = []string{"slack:#oncall", "slack:#ops", "pagerduty"}
+	for bridge, expected := range map[string]bool{"slack": true, "pagerduty": true, "email": false, "slac": false} {
+		if result.IsNotifyTarget(bridge) != expected {
+			t.Errorf("expected IsNotifyTarget(%s) to be %v", bridge, expected)
+		}
+	}
+
+	destinations := result.NotifyDestinations("slack")
+	if len(destinations) != 2 || destinations[0] != "#oncall" || destinations[1] != "#ops" {
+		t.Errorf("unexpected destinations %v", destinations)
+	}
+	if len(result.NotifyDestinations("pagerduty")) != 0 {
+		t.Errorf("expected no destinations for pagerduty")
+	}
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// JSONSchema returns a JSON Schema document describing the JSON encoding
+// of the given value, generated by reflection so that it follows the
+// struct as it gains fields.
+func JSONSchema(v interface{}, title string) map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(v))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = title
+	return schema
+}
+
+// durationType is special-cased, as it is encoded as an integer.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// typeSchema returns the schema of the given type.
+func typeSchema(t reflect.Type) map[string]interface{} {
+
+	if t == durationType {
+		return map[string]interface{}{
+			"type":        "integer",
+			"description": "A duration, in nanoseconds.",
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		//
+		// Pointers are encoded as null when not set.
+		//
+		schema := typeSchema(t.Elem())
+		if kind, ok := schema["type"].(string); ok {
+			schema["type"] = []string{kind, "null"}
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  []string{"array", "null"},
+			"items": typeSchema(t.Elem()),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 []string{"object", "null"},
+			"additionalProperties": typeSchema(t.Elem()),
+		}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := []string{}
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			name := field.Name
+			omitEmpty := false
+			if tag, ok := field.Tag.Lookup("json"); ok {
+				parts := strings.Split(tag, ",")
+				if parts[0] == "-" {
+					continue
+				}
+				if parts[0] != "" {
+					name = parts[0]
+				}
+				for _, option := range parts[1:] {
+					if option == "omitempty" {
+						omitEmpty = true
+					}
+				}
+			}
+
+			properties[name] = typeSchema(field.Type)
+			if !omitEmpty {
+				required = append(required, name)
+			}
+		}
+
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+
+	return map[string]interface{}{}
+}
@@ -0,0 +1,50 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	schema := JSONSchema(Result{}, "Result")
+	properties := schema["properties"].(map[string]interface{})
+
+	// Every field must be described, using its JSON name.
+	if len(properties) != reflect.TypeOf(Result{}).NumField() {
+		t.Errorf("expected %d properties, found %d", reflect.TypeOf(Result{}).NumField(), len(properties))
+	}
+
+	expected := map[string]interface{}{
+		"input":     "string",
+		"time":      "integer",
+		"error":     []string{"string", "null"},
+		"recovered": "boolean",
+	}
+	for name, kind := range expected {
+		property, ok := properties[name].(map[string]interface{})
+		if !ok {
+			t.Errorf("property %s not found", name)
+			continue
+		}
+		if !reflect.DeepEqual(property["type"], kind) {
+			t.Errorf("expected %s to be %v, found %v", name, kind, property["type"])
+		}
+	}
+
+	schema = JSONSchema(Test{}, "Test")
+	properties = schema["properties"].(map[string]interface{})
+
+	if len(properties) != reflect.TypeOf(Test{}).NumField() {
+		t.Errorf("expected %d properties, found %d", reflect.TypeOf(Test{}).NumField(), len(properties))
+	}
+
+	arguments := properties["Arguments"].(map[string]interface{})
+	if arguments["additionalProperties"].(map[string]interface{})["type"] != "string" {
+		t.Errorf("expected the arguments to be a map of strings, found %v", arguments)
+	}
+
+	timeout := properties["Timeout"].(map[string]interface{})
+	if !reflect.DeepEqual(timeout["type"], []string{"integer", "null"}) {
+		t.Errorf("expected the timeout to be a nullable integer, found %v", timeout["type"])
+	}
+}
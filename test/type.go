@@ -19,7 +19,9 @@ package test
 
 import (
 	"fmt"
+	"net/url"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -69,6 +71,62 @@ type Test struct {
 
 	// If > 0, tests which resolve hostnames will run only for the first MaxTargetsCount found target
 	MaxTargetsCount int
+
+	// IPVersion, 4 or 6, makes tests which resolve hostnames run only against the addresses of that family,
+	// regardless of the ones enabled on the worker. If 0, the worker settings apply.
+	IPVersion int
+
+	// Priority [1-10] makes the enqueue command push the test to the head of the jobs queue, instead of its tail.
+	// Higher priorities end up closer to the head. If 0, the test is queued normally.
+	Priority int
+
+	// Tag overrides the worker tag for the results of this test
+	Tag string
+
+	// Name is a human-readable label of the test, shown in its notifications
+	Name string
+
+	// Pops contains the IP addresses the test will be run against, instead of the ones the target resolves to, e.g.
+	// to test each edge node of a CDN. The results are aggregated in a single one.
+	Pops []string
+
+	// NotifyTargets lists the bridges which should handle the results of this test, as "bridge" or
+	// "bridge:destination" (e.g. "slack:#oncall"). If empty, all of them should.
+	NotifyTargets []string
+
+	// OnFailExec is the path of a command to execute when the test fails, if the worker allows it
+	OnFailExec string
+}
+
+// ArgumentSeparator joins the values of the arguments which can be given
+// multiple times, e.g. "header".
+const ArgumentSeparator = "\n"
+
+// RepeatableArguments contains the names of the arguments which can be
+// given multiple times, whose values are joined by ArgumentSeparator.
+var RepeatableArguments = map[string]bool{
+	"header":        true,
+	"header-match":  true,
+	"header-absent": true,
+	"json":          true,
+}
+
+// sensitiveArguments contains the names of the arguments whose values
+// must never be visible in results.
+var sensitiveArguments = map[string]bool{
+	"bearer":               true,
+	"password":             true,
+	"oauth2-client-secret": true,
+	"token":                true,
+}
+
+// ArgumentValues returns the values of the given argument, which might
+// have been given multiple times.
+func (obj *Test) ArgumentValues(name string) []string {
+	if obj.Arguments[name] == "" {
+		return nil
+	}
+	return strings.Split(obj.Arguments[name], ArgumentSeparator)
 }
 
 // Sanitize returns a copy of the input string, but with any password
@@ -90,8 +148,25 @@ func (obj *Test) Sanitize() string {
 		tmp := ""
 
 		// Censor passwords
-		if k == "password" {
-			tmp = " with password 'CENSORED'"
+		if sensitiveArguments[k] {
+			tmp = fmt.Sprintf(" with %s 'CENSORED'", k)
+		} else if k == "header" {
+
+			// Headers often carry API keys, so only keep their names.
+			for _, header := range obj.ArgumentValues(k) {
+				name := strings.SplitN(header, ":", 2)[0]
+				tmp += fmt.Sprintf(" with %s '%s: CENSORED'", k, name)
+			}
+		} else if k == "proxy" {
+
+			// Proxies might embed their credentials.
+			tmp = fmt.Sprintf(" with %s '%s'", k, censorURL(obj.Arguments[k]))
+		} else if RepeatableArguments[k] {
+
+			// Repeat the argument for each of its values.
+			for _, value := range obj.ArgumentValues(k) {
+				tmp += fmt.Sprintf(" with %s '%s'", k, value)
+			}
 		} else {
 
 			// Otherwise leave alone.
@@ -103,6 +178,24 @@ func (obj *Test) Sanitize() string {
 	return res
 }
 
+// censorURL returns the given URL with the password it embeds, if any,
+// replaced by "CENSORED".
+func censorURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "CENSORED"
+	}
+	if u.User == nil {
+		return value
+	}
+	if _, ok := u.User.Password(); !ok {
+		return value
+	}
+
+	u.User = url.UserPassword(u.User.Username(), "CENSORED")
+	return u.String()
+}
+
 // Options are options which are passed to every test-handler.
 //
 // The options might change the way the test operates.
@@ -112,4 +205,17 @@ type Options struct {
 
 	// Should the protocol-tests run verbosely?
 	Verbose bool
+
+	// Limits for the idle (keep-alive) connections of the HTTP transports,
+	// which each HTTP test builds for its own requests.
+	//
+	// Zero values mean no limit, except for the timeout, where zero means
+	// idle connections are closed as soon as the test completes.
+	HTTPMaxIdleConns        int
+	HTTPMaxIdleConnsPerHost int
+	HTTPIdleConnTimeout     time.Duration
+
+	// The maximum size of the HTTP response bodies, in bytes, unless a
+	// test sets its own.  Zero means the default of the HTTP probe.
+	HTTPMaxSize int64
 }
@@ -0,0 +1,22 @@
+package utils
+
+import "net"
+
+// SelectAddresses returns the given addresses, as strings, keeping only
+// the IPv4 and/or IPv6 ones as required.
+func SelectAddresses(ips []net.IP, ipv4 bool, ipv6 bool) []string {
+	var targets []string
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			if ipv4 {
+				targets = append(targets, ip.String())
+			}
+		}
+		if ip.To16() != nil && ip.To4() == nil {
+			if ipv6 {
+				targets = append(targets, ip.String())
+			}
+		}
+	}
+	return targets
+}
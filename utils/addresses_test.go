@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestSelectAddresses(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("192.0.2.1"),
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("192.0.2.2"),
+		net.ParseIP("2001:db8::2"),
+	}
+
+	tests := []struct {
+		ipv4     bool
+		ipv6     bool
+		expected []string
+	}{
+		{true, true, []string{"192.0.2.1", "2001:db8::1", "192.0.2.2", "2001:db8::2"}},
+		{true, false, []string{"192.0.2.1", "192.0.2.2"}},
+		{false, true, []string{"2001:db8::1", "2001:db8::2"}},
+		{false, false, nil},
+	}
+
+	for _, tst := range tests {
+		found := SelectAddresses(ips, tst.ipv4, tst.ipv6)
+		if !reflect.DeepEqual(found, tst.expected) {
+			t.Errorf("expected %v with IPv4 %t and IPv6 %t, got %v", tst.expected, tst.ipv4, tst.ipv6, found)
+		}
+	}
+
+	// IPv4 addresses in their 16-byte form are still IPv4 ones.
+	if found := SelectAddresses([]net.IP{net.ParseIP("192.0.2.1").To16()}, false, true); len(found) != 0 {
+		t.Errorf("expected an IPv4-mapped address not to be IPv6, got %v", found)
+	}
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryBackoff computes how long to wait before retrying a failing test.
+type RetryBackoff struct {
+	// Exponential doubles the delay after each attempt, otherwise it's
+	// always the same.
+	Exponential bool
+
+	// Delay is the wait after the first attempt.
+	Delay time.Duration
+
+	// MaxDelay caps the exponential delays, if > 0.
+	MaxDelay time.Duration
+
+	// Jitter is the fraction [0-1] by which the delays are randomly
+	// increased or decreased.
+	Jitter float64
+
+	// Random returns numbers in [0, 1), rand.Float64 if nil.
+	Random func() float64
+}
+
+// Wait returns the delay before the retry following the given attempt,
+// starting at 1.
+func (b RetryBackoff) Wait(attempt uint) time.Duration {
+	delay := b.Delay
+
+	if b.Exponential {
+		for i := uint(1); i < attempt && delay < math.MaxInt64/2; i++ {
+			delay *= 2
+		}
+		if b.MaxDelay > 0 && delay > b.MaxDelay {
+			delay = b.MaxDelay
+		}
+	}
+
+	if b.Jitter > 0 {
+		random := b.Random
+		if random == nil {
+			random = rand.Float64
+		}
+
+		// Scale by a factor in [1-jitter, 1+jitter).
+		delay = time.Duration(float64(delay) * (1 + b.Jitter*(2*random()-1)))
+	}
+
+	return delay
+}
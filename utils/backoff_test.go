@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// waits returns the delays of the first given number of attempts.
+func waits(b RetryBackoff, attempts uint) []time.Duration {
+	var result []time.Duration
+	for attempt := uint(1); attempt <= attempts; attempt++ {
+		result = append(result, b.Wait(attempt))
+	}
+	return result
+}
+
+func TestRetryBackoffFixed(t *testing.T) {
+	b := RetryBackoff{Delay: 5 * time.Second}
+
+	expected := []time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second, 5 * time.Second}
+	if delays := waits(b, 4); !reflect.DeepEqual(delays, expected) {
+		t.Errorf("expected %v, got %v", expected, delays)
+	}
+
+	// The cap only applies to exponential delays.
+	b.MaxDelay = time.Second
+	if delays := waits(b, 4); !reflect.DeepEqual(delays, expected) {
+		t.Errorf("expected %v, got %v", expected, delays)
+	}
+}
+
+func TestRetryBackoffExponential(t *testing.T) {
+	b := RetryBackoff{Exponential: true, Delay: time.Second, MaxDelay: 10 * time.Second}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	if delays := waits(b, 6); !reflect.DeepEqual(delays, expected) {
+		t.Errorf("expected %v, got %v", expected, delays)
+	}
+
+	// Without a cap the delays don't overflow.
+	b.MaxDelay = 0
+	if delay := b.Wait(200); delay < time.Second {
+		t.Errorf("expected a huge delay, got %s", delay)
+	}
+}
+
+func TestRetryBackoffJitter(t *testing.T) {
+	values := []float64{0, 0.5, 0.99}
+	next := 0
+	b := RetryBackoff{Exponential: true, Delay: time.Second, MaxDelay: time.Minute, Jitter: 0.5, Random: func() float64 {
+		value := values[next%len(values)]
+		next++
+		return value
+	}}
+
+	expected := []time.Duration{500 * time.Millisecond, 2 * time.Second, 5960 * time.Millisecond}
+	if delays := waits(b, 3); !reflect.DeepEqual(delays, expected) {
+		t.Errorf("expected %v, got %v", expected, delays)
+	}
+
+	// Any random number stays within the jitter.
+	b.Random = rand.New(rand.NewSource(1)).Float64
+	b.Exponential = false
+	for _, delay := range waits(b, 100) {
+		if delay < 500*time.Millisecond || delay >= 1500*time.Millisecond {
+			t.Errorf("expected the delay to be within 50%% of a second, got %s", delay)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// DeadLetter describes a job which couldn't be parsed, as pushed to the
+// dead-letter queue.
+type DeadLetter struct {
+	// Raw is the job, as fetched from the jobs queue.
+	Raw string `json:"raw"`
+
+	// Size is the size of the job, in bytes.
+	Size int `json:"size"`
+
+	// Error explains why the job couldn't be parsed.
+	Error string `json:"error"`
+
+	// Worker identifies the worker which fetched the job.
+	Worker string `json:"worker"`
+
+	// Time is when the job was rejected, in seconds past the epoch.
+	Time int64 `json:"time"`
+}
+
+// PushDeadLetter pushes the given job, rejected due to the given error,
+// to the given dead-letter queue.
+func PushDeadLetter(r redis.Cmdable, queue string, raw string, parseErr error, worker string) error {
+	letter, err := json.Marshal(DeadLetter{
+		Raw:    raw,
+		Size:   len(raw),
+		Error:  parseErr.Error(),
+		Worker: worker,
+		Time:   time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.RPush(queue, letter).Err()
+}
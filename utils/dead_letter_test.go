@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+)
+
+func TestPushDeadLetter(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start redis: %s", err)
+	}
+	defer s.Close()
+
+	r := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer r.Close()
+
+	parseErr := errors.New("unknown test type 'htp' in input 'example.com must run htp'")
+	if err = PushDeadLetter(r, "overseer.deadletter", "example.com must run htp", parseErr, "host/W1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	letters, err := s.List("overseer.deadletter")
+	if err != nil || len(letters) != 1 {
+		t.Fatalf("expected a dead letter, got %v (%v)", letters, err)
+	}
+
+	var letter DeadLetter
+	if err = json.Unmarshal([]byte(letters[0]), &letter); err != nil {
+		t.Fatalf("failed to decode the dead letter: %s", err)
+	}
+	if letter.Raw != "example.com must run htp" || letter.Size != 24 || letter.Error != parseErr.Error() || letter.Worker != "host/W1" || letter.Time == 0 {
+		t.Errorf("unexpected dead letter %+v", letter)
+	}
+}
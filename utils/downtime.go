@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// FirstFailureKey returns the key storing when the test identified by the
+// given hash started failing.
+func FirstFailureKey(hash string) string {
+	return fmt.Sprintf("overseer.dedup-first-failure.%s", hash)
+}
+
+// RecordFailure stores the given time as the one the test identified by
+// the given hash started failing, unless it was failing already, and
+// keeps it for the given time-to-live.
+func RecordFailure(r redis.Cmdable, hash string, now time.Time, ttl time.Duration) error {
+	key := FirstFailureKey(hash)
+	if err := r.SetNX(key, now.Unix(), ttl).Err(); err != nil {
+		return err
+	}
+
+	return r.Expire(key, ttl).Err()
+}
+
+// RecoverFailure forgets when the test identified by the given hash
+// started failing, returning for how long it failed as of the given time,
+// or zero if that isn't known.
+func RecoverFailure(r redis.Cmdable, hash string, now time.Time) (time.Duration, error) {
+	key := FirstFailureKey(hash)
+	first, err := r.Get(key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err = r.Del(key).Err(); err != nil {
+		return 0, err
+	}
+
+	downtime := now.Sub(time.Unix(first, 0))
+	if downtime < 0 {
+		return 0, nil
+	}
+	return downtime, nil
+}
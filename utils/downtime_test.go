@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+)
+
+func TestDowntime(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start redis: %s", err)
+	}
+	defer s.Close()
+
+	r := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer r.Close()
+
+	// Nothing is known of tests which never failed.
+	downtime, err := RecoverFailure(r, "hash", time.Now())
+	if err != nil || downtime != 0 {
+		t.Errorf("expected no downtime, got %s (%v)", downtime, err)
+	}
+
+	// Only the first of the failures counts.
+	start := time.Unix(1500000000, 0)
+	for i := 0; i < 3; i++ {
+		if err = RecordFailure(r, "hash", start.Add(time.Duration(i)*time.Minute), time.Hour); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if ttl := s.TTL(FirstFailureKey("hash")); ttl != time.Hour {
+		t.Errorf("expected the first failure to be kept for an hour, got %s", ttl)
+	}
+
+	downtime, err = RecoverFailure(r, "hash", start.Add(5*time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if downtime != 5*time.Minute {
+		t.Errorf("expected a downtime of 5m, got %s", downtime)
+	}
+
+	// And it is forgotten once recovered.
+	if s.Exists(FirstFailureKey("hash")) {
+		t.Errorf("expected the first failure to be removed")
+	}
+	if downtime, _ = RecoverFailure(r, "hash", start.Add(10*time.Minute)); downtime != 0 {
+		t.Errorf("expected no downtime after the recovery, got %s", downtime)
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// HookOutputLimit is the maximum number of bytes of the output of a hook
+// we keep, the rest is discarded.
+const HookOutputLimit = 4096
+
+// limitedBuffer is a buffer which keeps only the first HookOutputLimit
+// bytes written to it.
+//
+// The buffer isn't embedded, so that its ReadFrom method can't bypass our
+// limit.
+type limitedBuffer struct {
+	buffer    bytes.Buffer
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	room := HookOutputLimit - b.buffer.Len()
+	if room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buffer.Write(p)
+}
+
+// RunHook executes the given command, without a shell and with only the
+// given environment variables (plus PATH), killing it after the given
+// timeout.  It returns the combined output of the command, limited to
+// HookOutputLimit bytes.
+func RunHook(command string, env map[string]string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command)
+
+	// Don't leak the environment of the worker, which might contain
+	// credentials, to the hook.
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	for name, value := range env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+
+	output := &limitedBuffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err := cmd.Run()
+
+	out := output.buffer.String()
+	if output.truncated {
+		out += "\n... (truncated)"
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return out, fmt.Errorf("timed out after %s", timeout)
+	}
+	return out, err
+}
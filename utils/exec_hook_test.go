@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeScript writes the given shell script to an executable file, in the
+// given directory.
+func writeScript(t *testing.T, dir string, body string) string {
+	path := filepath.Join(dir, fmt.Sprintf("hook%d.sh", time.Now().UnixNano()))
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatalf("failed to write script: %s", err)
+	}
+	return path
+}
+
+// tempDir creates a temporary directory for our scripts.
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "hook")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	return dir
+}
+
+func TestRunHook(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	script := writeScript(t, dir, "echo \"$OVERSEER_TYPE failed: $OVERSEER_ERROR\"\necho \"secret=$SECRET\" >&2\n")
+
+	os.Setenv("SECRET", "hunter2")
+	defer os.Unsetenv("SECRET")
+
+	out, err := RunHook(script, map[string]string{"OVERSEER_TYPE": "http", "OVERSEER_ERROR": "timeout"}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(out, "http failed: timeout") {
+		t.Errorf("expected the test details in the output, got %s", out)
+	}
+	if strings.Contains(out, "hunter2") {
+		t.Errorf("expected the environment of the worker not to be passed, got %s", out)
+	}
+}
+
+func TestRunHookFailures(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	out, err := RunHook(writeScript(t, dir, "echo oops\nexit 3\n"), nil, 5*time.Second)
+	if err == nil || !strings.Contains(out, "oops") {
+		t.Errorf("expected the exit-code to be reported along with the output, got %v: %s", err, out)
+	}
+
+	_, err = RunHook(writeScript(t, dir, "exec sleep 5\n"), nil, 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+
+	out, err = RunHook(writeScript(t, dir, "head -c 10000 /dev/zero | tr '\\0' x\n"), nil, 5*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(out) > HookOutputLimit+20 || !strings.HasSuffix(out, "(truncated)") {
+		t.Errorf("expected the output to be truncated, got %d bytes", len(out))
+	}
+
+	if _, err = RunHook("/nonexistent/hook", nil, time.Second); err == nil {
+		t.Errorf("expected an error running a missing command")
+	}
+}
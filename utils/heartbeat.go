@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// WorkerHeartbeatPrefix is the prefix of the keys workers use to announce
+// they are alive.
+const WorkerHeartbeatPrefix = "overseer.workers."
+
+// Heartbeat is the status of a worker, as announced via its heartbeat key.
+type Heartbeat struct {
+	// LastSeen is when the heartbeat was written, in seconds past the
+	// epoch.
+	LastSeen int64 `json:"last_seen"`
+
+	// Tag is the tag the worker applies to its results.
+	Tag string `json:"tag"`
+
+	// JobsProcessed is the number of jobs the worker has fetched.
+	JobsProcessed uint64 `json:"jobs_processed"`
+}
+
+// HeartbeatKey returns the heartbeat key of the given worker of the given
+// host.
+func HeartbeatKey(hostname string, workerIdx uint) string {
+	return fmt.Sprintf("%s%s.%d", WorkerHeartbeatPrefix, hostname, workerIdx)
+}
+
+// WriteHeartbeat stores the given heartbeat in the given key, which
+// expires after the given time-to-live unless written again.
+func WriteHeartbeat(r redis.Cmdable, key string, beat Heartbeat, ttl time.Duration) error {
+	status, err := json.Marshal(beat)
+	if err != nil {
+		return err
+	}
+
+	return r.Set(key, status, ttl).Err()
+}
+
+// RunHeartbeats writes the heartbeats returned by the given function,
+// keyed by their redis key, now and then every interval, until the given
+// context is cancelled.
+//
+// The keys expire after three intervals, so that a worker which died is
+// soon forgotten, and are removed once we're done.
+func RunHeartbeats(ctx context.Context, r redis.Cmdable, interval time.Duration, beats func() map[string]Heartbeat, log *Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var keys []string
+	for {
+		keys = keys[:0]
+		now := time.Now().Unix()
+		for key, beat := range beats() {
+			beat.LastSeen = now
+			if err := WriteHeartbeat(r, key, beat, 3*interval); err != nil {
+				log.Error(LogFields{}, "Failed to write heartbeat %s: %s", key, err.Error())
+			}
+			keys = append(keys, key)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if len(keys) > 0 {
+				r.Del(keys...)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+)
+
+func TestRunHeartbeats(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start redis: %s", err)
+	}
+	defer s.Close()
+
+	r := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer r.Close()
+
+	key := HeartbeatKey("host", 1)
+	if key != "overseer.workers.host.1" {
+		t.Errorf("unexpected key %s", key)
+	}
+
+	var processed uint64
+	beats := func() map[string]Heartbeat {
+		return map[string]Heartbeat{key: {Tag: "prod", JobsProcessed: atomic.LoadUint64(&processed)}}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunHeartbeats(ctx, r, 50*time.Millisecond, beats, nil)
+		close(done)
+	}()
+
+	// read returns the heartbeat currently stored, once written.
+	read := func() Heartbeat {
+		var beat Heartbeat
+		for i := 0; i < 100; i++ {
+			if status, errGet := s.Get(key); errGet == nil {
+				if err = json.Unmarshal([]byte(status), &beat); err != nil {
+					t.Fatalf("failed to decode the heartbeat: %s", err)
+				}
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return beat
+	}
+
+	beat := read()
+	if beat.Tag != "prod" || beat.LastSeen == 0 || beat.JobsProcessed != 0 {
+		t.Errorf("unexpected heartbeat %+v", beat)
+	}
+	if ttl := s.TTL(key); ttl <= 0 || ttl > 150*time.Millisecond {
+		t.Errorf("expected the heartbeat to expire after three intervals, got %s", ttl)
+	}
+
+	// Updated over time.
+	atomic.StoreUint64(&processed, 7)
+	for i := 0; i < 100 && beat.JobsProcessed != 7; i++ {
+		time.Sleep(10 * time.Millisecond)
+		beat = read()
+	}
+	if beat.JobsProcessed != 7 {
+		t.Errorf("expected the heartbeat to be updated, got %+v", beat)
+	}
+
+	// And removed once we're done.
+	cancel()
+	<-done
+	if s.Exists(key) {
+		t.Errorf("expected the heartbeat to be removed")
+	}
+}
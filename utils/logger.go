@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The levels of the log messages.
+const (
+	LogDebug = "debug"
+	LogInfo  = "info"
+	LogWarn  = "warn"
+	LogError = "error"
+)
+
+// LogFields are the structured fields of a log message, describing the
+// worker which wrote it and the test it refers to.  Empty fields are
+// omitted.
+type LogFields struct {
+	Worker   uint   `json:"worker,omitempty"`
+	TestType string `json:"test_type,omitempty"`
+	Target   string `json:"target,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+}
+
+// logEntry is a log message, as written in json-format.
+type logEntry struct {
+	Time  string `json:"ts"`
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+	LogFields
+}
+
+// Logger writes log messages either as plain text or, to ease their
+// aggregation, as one JSON object per line.
+//
+// All the methods can be invoked on a nil object, in which case the
+// messages are written as plain text to the standard output, without the
+// debug ones.
+type Logger struct {
+	// Should the messages be written as JSON?
+	JSON bool
+
+	// Should the debug messages be written?
+	Verbose bool
+
+	out  io.Writer
+	lock sync.Mutex
+}
+
+// NewLogger returns a logger writing to the given writer, in the given
+// format: "text" or "json".
+func NewLogger(format string, verbose bool, out io.Writer) (*Logger, error) {
+	if format != "text" && format != "json" {
+		return nil, fmt.Errorf("unknown format '%s', must be 'text' or 'json'", format)
+	}
+
+	return &Logger{JSON: format == "json", Verbose: verbose, out: out}, nil
+}
+
+// Debug writes a message only if the logger is verbose.
+func (l *Logger) Debug(fields LogFields, format string, args ...interface{}) {
+	l.Log(LogDebug, fields, format, args...)
+}
+
+// Info writes a message.
+func (l *Logger) Info(fields LogFields, format string, args ...interface{}) {
+	l.Log(LogInfo, fields, format, args...)
+}
+
+// Warn writes a warning.
+func (l *Logger) Warn(fields LogFields, format string, args ...interface{}) {
+	l.Log(LogWarn, fields, format, args...)
+}
+
+// Error writes an error.
+func (l *Logger) Error(fields LogFields, format string, args ...interface{}) {
+	l.Log(LogError, fields, format, args...)
+}
+
+// Log writes a message of the given level.
+//
+// In text-format the message is prefixed by the worker, e.g. "[W1] ", and
+// warnings by "WARNING: ".
+func (l *Logger) Log(level string, fields LogFields, format string, args ...interface{}) {
+	if l == nil {
+		l = &Logger{out: os.Stdout}
+	}
+	if level == LogDebug && !l.Verbose {
+		return
+	}
+
+	msg := strings.TrimRight(fmt.Sprintf(format, args...), "\n")
+
+	var line []byte
+	if l.JSON {
+		entry := logEntry{
+			Time:      time.Now().UTC().Format(time.RFC3339Nano),
+			Level:     level,
+			Msg:       msg,
+			LogFields: fields,
+		}
+
+		var err error
+		line, err = json.Marshal(entry)
+		if err != nil {
+			return
+		}
+	} else {
+		if level == LogWarn {
+			msg = "WARNING: " + msg
+		}
+		if fields.Worker != 0 {
+			msg = fmt.Sprintf("[W%d] %s", fields.Worker, msg)
+		}
+		line = []byte(msg)
+	}
+
+	// Messages are written by many workers at the same time, so make
+	// sure their lines don't get mixed.
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.out.Write(append(line, '\n'))
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	l, err := NewLogger("json", false, out)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	fields := LogFields{Worker: 2, TestType: "http", Target: "https://example.com/", Tag: "prod"}
+	l.Info(fields, "Running '%s' test\n", "http")
+	l.Debug(fields, "not verbose, so not written")
+	l.Warn(LogFields{}, "something odd")
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), out.String())
+	}
+
+	var entry map[string]interface{}
+	if err = json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("invalid JSON '%s': %s", lines[0], err)
+	}
+
+	expected := map[string]interface{}{
+		"level":     "info",
+		"msg":       "Running 'http' test",
+		"worker":    float64(2),
+		"test_type": "http",
+		"target":    "https://example.com/",
+		"tag":       "prod",
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("expected %s to be %v, got %v", key, value, entry[key])
+		}
+	}
+	if _, err = time.Parse(time.RFC3339Nano, entry["ts"].(string)); err != nil {
+		t.Errorf("invalid ts '%v': %s", entry["ts"], err)
+	}
+
+	// Empty fields are omitted.
+	entry = nil
+	if err = json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("invalid JSON '%s': %s", lines[1], err)
+	}
+	if entry["level"] != "warn" || entry["msg"] != "something odd" {
+		t.Errorf("unexpected entry %v", entry)
+	}
+	for _, key := range []string{"worker", "test_type", "target", "tag"} {
+		if _, ok := entry[key]; ok {
+			t.Errorf("expected %s to be omitted, got %v", key, entry)
+		}
+	}
+}
+
+func TestLoggerText(t *testing.T) {
+	out := &bytes.Buffer{}
+	l, err := NewLogger("text", true, out)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	l.Debug(LogFields{Worker: 1, TestType: "dns"}, "[%d/%d] - Test passed.\n", 1, 5)
+	l.Warn(LogFields{}, "Failed to resolve %s", "example.com")
+	l.Error(LogFields{}, "Result addition failed")
+
+	expected := "[W1] [1/5] - Test passed.\nWARNING: Failed to resolve example.com\nResult addition failed\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+
+	if _, err = NewLogger("xml", false, out); err == nil {
+		t.Errorf("expected the xml format to be invalid")
+	}
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// RedisHost is a redis address, along with its weight in the rotation
+// of a list of hosts.
+type RedisHost struct {
+	Addr   string
+	Weight int
+}
+
+// ParseRedisHosts parses a comma-separated list of redis addresses, each
+// optionally followed by its weight, e.g. "10.0.0.1:6379,10.0.0.2:6379*2".
+func ParseRedisHosts(list string) ([]RedisHost, error) {
+	var hosts []RedisHost
+
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		host := RedisHost{Addr: entry, Weight: 1}
+		if idx := strings.LastIndex(entry, "*"); idx != -1 {
+			weight, err := strconv.Atoi(entry[idx+1:])
+			if err != nil || weight < 1 {
+				return nil, fmt.Errorf("invalid weight in redis host '%s', must be a positive number", entry)
+			}
+			host.Addr = strings.TrimSpace(entry[:idx])
+			host.Weight = weight
+		}
+
+		if host.Addr == "" {
+			return nil, fmt.Errorf("invalid redis host '%s'", entry)
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no redis hosts given")
+	}
+
+	return hosts, nil
+}
+
+// WeightedRotation returns the order in which the given hosts should be
+// used, by index, so that each is picked as often as its weight says.
+//
+// The picks of the different hosts are interleaved, rather than grouped,
+// the same way smooth weighted round-robin balancers do.
+func WeightedRotation(hosts []RedisHost) []int {
+	total := 0
+	for _, host := range hosts {
+		total += host.Weight
+	}
+
+	current := make([]int, len(hosts))
+	rotation := make([]int, 0, total)
+
+	for len(rotation) < total {
+		best := 0
+		for i, host := range hosts {
+			current[i] += host.Weight
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		rotation = append(rotation, best)
+	}
+
+	return rotation
+}
+
+// StableIndex maps the given key to one of count slots, always the same
+// one for the same key.
+func StableIndex(key string, count int) int {
+	if count <= 1 {
+		return 0
+	}
+
+	hasher := fnv.New32a()
+	hasher.Write([]byte(key))
+	return int(hasher.Sum32() % uint32(count))
+}
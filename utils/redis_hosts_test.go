@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRedisHosts(t *testing.T) {
+	hosts, err := ParseRedisHosts("10.0.0.1:6379, 10.0.0.2:6379*3,")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []RedisHost{{Addr: "10.0.0.1:6379", Weight: 1}, {Addr: "10.0.0.2:6379", Weight: 3}}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("expected %v, got %v", expected, hosts)
+	}
+
+	for _, invalid := range []string{"", ",", "10.0.0.1:6379*0", "10.0.0.1:6379*x", "*2"} {
+		if _, err = ParseRedisHosts(invalid); err == nil {
+			t.Errorf("expected '%s' to be invalid", invalid)
+		}
+	}
+}
+
+func TestWeightedRotation(t *testing.T) {
+	rotation := WeightedRotation([]RedisHost{{Addr: "a", Weight: 1}, {Addr: "b", Weight: 1}, {Addr: "c", Weight: 1}})
+	if !reflect.DeepEqual(rotation, []int{0, 1, 2}) {
+		t.Errorf("unexpected rotation %v", rotation)
+	}
+
+	// Heavier hosts are picked more often, but not in a row.
+	rotation = WeightedRotation([]RedisHost{{Addr: "a", Weight: 1}, {Addr: "b", Weight: 2}})
+	if !reflect.DeepEqual(rotation, []int{1, 0, 1}) {
+		t.Errorf("unexpected rotation %v", rotation)
+	}
+
+	rotation = WeightedRotation([]RedisHost{{Addr: "a", Weight: 5}, {Addr: "b", Weight: 1}, {Addr: "c", Weight: 1}})
+	counts := make(map[int]int)
+	for _, idx := range rotation {
+		counts[idx]++
+	}
+	if len(rotation) != 7 || counts[0] != 5 || counts[1] != 1 || counts[2] != 1 {
+		t.Errorf("unexpected rotation %v", rotation)
+	}
+}
+
+func TestStableIndex(t *testing.T) {
+	if idx := StableIndex("anything", 1); idx != 0 {
+		t.Errorf("expected a single slot to always be used, got %d", idx)
+	}
+
+	seen := make(map[int]bool)
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		idx := StableIndex(key, 3)
+		if idx < 0 || idx >= 3 {
+			t.Fatalf("index %d out of range", idx)
+		}
+		if StableIndex(key, 3) != idx {
+			t.Errorf("expected the index of '%s' to be stable", key)
+		}
+		seen[idx] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("expected the keys to be spread across the slots")
+	}
+}
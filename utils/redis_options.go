@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// RedisOptions describes how to connect to a redis-server: via its
+// address, a unix socket, the sentinels monitoring it, or the nodes of
+// the cluster it belongs to.
+type RedisOptions struct {
+	// Host is the address of the server, used unless Socket or
+	// Sentinels are set.
+	Host string
+
+	// Socket is the path of the unix socket of the server.
+	Socket string
+
+	// Sentinels is a comma-separated list of the addresses of the
+	// sentinels monitoring the server, which is then looked up by its
+	// MasterName.
+	Sentinels  string
+	MasterName string
+
+	// Cluster is a comma-separated list of the addresses of some nodes
+	// of a redis cluster, used to discover the others.
+	Cluster string
+
+	Password    string
+	DB          int
+	DialTimeout time.Duration
+
+	// TLS connects via TLS, which is implied by any of the other TLS
+	// settings.
+	TLS bool
+
+	// TLSCA is the path of the PEM file of the CA certificates to
+	// trust, instead of the system ones.
+	TLSCA string
+
+	// TLSCert and TLSKey are the paths of the PEM files of the client
+	// certificate, and of its key, to authenticate with.
+	TLSCert string
+	TLSKey  string
+
+	// TLSInsecure disables the verification of the server certificate.
+	TLSInsecure bool
+}
+
+// Client returns a client for the described redis-server.
+func (o RedisOptions) Client() (redis.UniversalClient, error) {
+	tlsConfig, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Cluster != "" {
+		if o.Sentinels != "" || o.MasterName != "" {
+			return nil, fmt.Errorf("a redis cluster can't be monitored by sentinels")
+		}
+
+		options, errCluster := o.clusterOptions()
+		if errCluster != nil {
+			return nil, errCluster
+		}
+		options.TLSConfig = tlsConfig
+		return redis.NewClusterClient(options), nil
+	}
+
+	if o.Sentinels == "" && o.MasterName == "" {
+		options := o.clientOptions()
+		options.TLSConfig = tlsConfig
+		return redis.NewClient(options), nil
+	}
+
+	options, err := o.failoverOptions()
+	if err != nil {
+		return nil, err
+	}
+	options.TLSConfig = tlsConfig
+	return redis.NewFailoverClient(options), nil
+}
+
+// tlsConfig returns the TLS configuration of the connections, or nil if
+// TLS isn't used.
+func (o RedisOptions) tlsConfig() (*tls.Config, error) {
+	if !o.TLS && o.TLSCA == "" && o.TLSCert == "" && o.TLSKey == "" && !o.TLSInsecure {
+		return nil, nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: o.TLSInsecure}
+
+	if o.TLSCA != "" {
+		pem, err := ioutil.ReadFile(o.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the redis CA certificates: %s", err.Error())
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", o.TLSCA)
+		}
+	}
+
+	if o.TLSCert != "" || o.TLSKey != "" {
+		if o.TLSCert == "" || o.TLSKey == "" {
+			return nil, fmt.Errorf("the redis client certificate and key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(o.TLSCert, o.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the redis client certificate: %s", err.Error())
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// clientOptions returns the options of a client for a single server.
+func (o RedisOptions) clientOptions() *redis.Options {
+	options := &redis.Options{
+		Addr:        o.Host,
+		Password:    o.Password,
+		DB:          o.DB,
+		DialTimeout: o.DialTimeout,
+	}
+
+	if o.Socket != "" {
+		options.Network = "unix"
+		options.Addr = o.Socket
+	}
+
+	return options
+}
+
+// failoverOptions returns the options of a client for a server monitored
+// by sentinels.
+func (o RedisOptions) failoverOptions() (*redis.FailoverOptions, error) {
+	sentinels := splitAddrs(o.Sentinels)
+	if len(sentinels) == 0 {
+		return nil, fmt.Errorf("the master '%s' needs the addresses of its sentinels", o.MasterName)
+	}
+	if o.MasterName == "" {
+		return nil, fmt.Errorf("the name of the master monitored by the sentinels is required")
+	}
+
+	return &redis.FailoverOptions{
+		MasterName:    o.MasterName,
+		SentinelAddrs: sentinels,
+		Password:      o.Password,
+		DB:            o.DB,
+		DialTimeout:   o.DialTimeout,
+	}, nil
+}
+
+// clusterOptions returns the options of a client for a redis cluster.
+func (o RedisOptions) clusterOptions() (*redis.ClusterOptions, error) {
+	nodes := splitAddrs(o.Cluster)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("the addresses of the nodes of the redis cluster are required")
+	}
+
+	// Clusters only have a single database.
+	if o.DB != 0 {
+		return nil, fmt.Errorf("a redis cluster only supports database 0, not %d", o.DB)
+	}
+
+	return &redis.ClusterOptions{
+		Addrs:       nodes,
+		Password:    o.Password,
+		DialTimeout: o.DialTimeout,
+	}, nil
+}
+
+// splitAddrs splits a comma-separated list of addresses, ignoring empty
+// ones.
+func splitAddrs(list string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(list, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+func TestRedisClientOptions(t *testing.T) {
+	o := RedisOptions{Host: "10.0.0.1:6379", Password: "secret", DB: 2, DialTimeout: 3 * time.Second}
+
+	expected := &redis.Options{Addr: "10.0.0.1:6379", Password: "secret", DB: 2, DialTimeout: 3 * time.Second}
+	if options := o.clientOptions(); !reflect.DeepEqual(options, expected) {
+		t.Errorf("expected %+v, got %+v", expected, options)
+	}
+
+	// The socket wins over the host.
+	o.Socket = "/var/run/redis.sock"
+	expected.Network = "unix"
+	expected.Addr = "/var/run/redis.sock"
+	if options := o.clientOptions(); !reflect.DeepEqual(options, expected) {
+		t.Errorf("expected %+v, got %+v", expected, options)
+	}
+}
+
+func TestRedisFailoverOptions(t *testing.T) {
+	o := RedisOptions{Host: "ignored:6379", Sentinels: "10.0.0.1:26379, 10.0.0.2:26379,", MasterName: "mymaster", Password: "secret", DB: 2, DialTimeout: 3 * time.Second}
+
+	options, err := o.failoverOptions()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := &redis.FailoverOptions{
+		MasterName:    "mymaster",
+		SentinelAddrs: []string{"10.0.0.1:26379", "10.0.0.2:26379"},
+		Password:      "secret",
+		DB:            2,
+		DialTimeout:   3 * time.Second,
+	}
+	if !reflect.DeepEqual(options, expected) {
+		t.Errorf("expected %+v, got %+v", expected, options)
+	}
+
+	for _, invalid := range []RedisOptions{{Sentinels: "10.0.0.1:26379"}, {MasterName: "mymaster"}, {Sentinels: ",", MasterName: "mymaster"}} {
+		if _, err = invalid.Client(); err == nil {
+			t.Errorf("expected %+v to be invalid", invalid)
+		}
+	}
+}
+
+func TestRedisClusterOptions(t *testing.T) {
+	o := RedisOptions{Host: "ignored:6379", Cluster: "10.0.0.1:6379,10.0.0.2:6379", Password: "secret", DialTimeout: 3 * time.Second}
+
+	options, err := o.clusterOptions()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := &redis.ClusterOptions{
+		Addrs:       []string{"10.0.0.1:6379", "10.0.0.2:6379"},
+		Password:    "secret",
+		DialTimeout: 3 * time.Second,
+	}
+	if !reflect.DeepEqual(options, expected) {
+		t.Errorf("expected %+v, got %+v", expected, options)
+	}
+
+	for _, invalid := range []RedisOptions{{Cluster: ","}, {Cluster: "10.0.0.1:6379", DB: 1}, {Cluster: "10.0.0.1:6379", Sentinels: "10.0.0.1:26379", MasterName: "mymaster"}} {
+		if _, err = invalid.Client(); err == nil {
+			t.Errorf("expected %+v to be invalid", invalid)
+		}
+	}
+}
+
+func TestRedisClient(t *testing.T) {
+	r, err := RedisOptions{Host: "10.0.0.1:6379"}.Client()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer r.Close()
+	if client, ok := r.(*redis.Client); !ok || client.Options().Addr != "10.0.0.1:6379" {
+		t.Errorf("expected a client for the host, got %T", r)
+	}
+
+	r, err = RedisOptions{Sentinels: "10.0.0.1:26379", MasterName: "mymaster"}.Client()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer r.Close()
+	if client, ok := r.(*redis.Client); !ok || client.Options().Addr != "FailoverClient" {
+		t.Errorf("expected a failover client, got %T", r)
+	}
+
+	r, err = RedisOptions{Cluster: "10.0.0.1:6379,10.0.0.2:6379"}.Client()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer r.Close()
+	if client, ok := r.(*redis.ClusterClient); !ok || !reflect.DeepEqual(client.Options().Addrs, []string{"10.0.0.1:6379", "10.0.0.2:6379"}) {
+		t.Errorf("expected a cluster client, got %T", r)
+	}
+}
+
+// writeCertificate writes a self-signed certificate, and its key, to PEM
+// files in the given directory, returning their paths.
+func writeCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode key: %s", err)
+	}
+
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	return certPath, keyPath
+}
+
+func TestRedisTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "redis-tls")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cert, key := writeCertificate(t, dir)
+
+	// No TLS by default.
+	if config, _ := (RedisOptions{}).tlsConfig(); config != nil {
+		t.Errorf("expected no TLS, got %+v", config)
+	}
+
+	config, err := RedisOptions{TLS: true}.tlsConfig()
+	if err != nil || config == nil || config.RootCAs != nil || config.InsecureSkipVerify {
+		t.Errorf("expected TLS with the system CAs, got %+v (%v)", config, err)
+	}
+
+	// Any of the other settings implies TLS.
+	config, err = RedisOptions{TLSInsecure: true}.tlsConfig()
+	if err != nil || config == nil || !config.InsecureSkipVerify {
+		t.Errorf("expected TLS without verification, got %+v (%v)", config, err)
+	}
+
+	config, err = RedisOptions{TLSCA: cert, TLSCert: cert, TLSKey: key}.tlsConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if config.RootCAs == nil || len(config.RootCAs.Subjects()) != 1 {
+		t.Errorf("expected the CA to be loaded, got %+v", config.RootCAs)
+	}
+	if len(config.Certificates) != 1 {
+		t.Errorf("expected the client certificate to be loaded, got %d", len(config.Certificates))
+	}
+
+	for _, invalid := range []RedisOptions{{TLSCA: filepath.Join(dir, "missing.pem")}, {TLSCA: key}, {TLSCert: cert}, {TLSCert: cert, TLSKey: cert}} {
+		if _, err = invalid.tlsConfig(); err == nil {
+			t.Errorf("expected %+v to be invalid", invalid)
+		}
+	}
+
+	// The configuration is given to all kinds of clients.
+	r, err := RedisOptions{Host: "10.0.0.1:6379", TLSInsecure: true}.Client()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer r.Close()
+	if r.(*redis.Client).Options().TLSConfig == nil {
+		t.Errorf("expected the client to use TLS")
+	}
+
+	r, err = RedisOptions{Cluster: "10.0.0.1:6379", TLSInsecure: true}.Client()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer r.Close()
+	if r.(*redis.ClusterClient).Options().TLSConfig == nil {
+		t.Errorf("expected the cluster client to use TLS")
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// PingRedis ensures the given redis-server is reachable, retrying up to
+// the given number of times, with the given backoff, so that we can be
+// started before the server is up.
+func PingRedis(r redis.Cmdable, retries int, backoff RetryBackoff) error {
+	for attempt := 1; ; attempt++ {
+		_, err := r.Ping().Result()
+		if err == nil || attempt > retries {
+			return err
+		}
+
+		delay := backoff.Wait(uint(attempt))
+		fmt.Printf("Redis connection failed (attempt %d/%d): %s - retrying in %s\n", attempt, retries+1, err.Error(), delay)
+		time.Sleep(delay)
+	}
+}
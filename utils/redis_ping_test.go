@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+)
+
+func TestPingRedis(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start redis: %s", err)
+	}
+	defer s.Close()
+
+	addr := s.Addr()
+	r := redis.NewClient(&redis.Options{Addr: addr})
+	defer r.Close()
+
+	backoff := RetryBackoff{Delay: 50 * time.Millisecond}
+	if err = PingRedis(r, 0, backoff); err != nil {
+		t.Fatalf("expected the ping to succeed, got %s", err)
+	}
+
+	// Redis going away makes us give up, once out of retries.
+	s.Close()
+	r = redis.NewClient(&redis.Options{Addr: addr})
+	defer r.Close()
+	if err = PingRedis(r, 1, backoff); err == nil {
+		t.Fatalf("expected the ping to fail")
+	}
+
+	// Until it comes back in time.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		s.Restart()
+	}()
+	if err = PingRedis(r, 20, backoff); err != nil {
+		t.Errorf("expected the ping to succeed once redis was back, got %s", err)
+	}
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// PushResult pushes the given test-result to each of the given queues,
+// in a single round-trip.
+//
+// If maxLen is > 0 the queues are trimmed to their newest maxLen results,
+// and if ttl is > 0 they expire once no result was pushed for that long,
+// so that they can't grow forever if nobody consumes them.
+func PushResult(r redis.Cmdable, queues []string, result []byte, maxLen int64, ttl time.Duration) error {
+	_, err := r.Pipelined(func(pipe redis.Pipeliner) error {
+		for _, queue := range queues {
+			pipe.RPush(queue, result)
+			if maxLen > 0 {
+				pipe.LTrim(queue, -maxLen, -1)
+			}
+			if ttl > 0 {
+				pipe.Expire(queue, ttl)
+			}
+		}
+		return nil
+	})
+	return err
+}
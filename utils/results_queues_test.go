@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+)
+
+func TestPushResult(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start redis: %s", err)
+	}
+	defer s.Close()
+
+	r := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer r.Close()
+
+	result := []byte(`{"input":"example.com must run ping","type":"ping"}`)
+
+	queues := []string{"overseer.results", "overseer.archive", "overseer.slack"}
+	if err = PushResult(r, queues, result, 0, 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, queue := range queues {
+		list, errList := s.List(queue)
+		if errList != nil || len(list) != 1 || list[0] != string(result) {
+			t.Errorf("expected the result in %s, got %v (%v)", queue, list, errList)
+		}
+	}
+
+	// A single queue works as well.
+	if err = PushResult(r, []string{"overseer.results"}, result, 0, 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if list, _ := s.List("overseer.results"); len(list) != 2 {
+		t.Errorf("expected two results, got %v", list)
+	}
+	if list, _ := s.List("overseer.archive"); len(list) != 1 {
+		t.Errorf("expected the other queues to be untouched, got %v", list)
+	}
+}
+
+func TestPushResultLimits(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start redis: %s", err)
+	}
+	defer s.Close()
+
+	r := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer r.Close()
+
+	for i := 1; i <= 5; i++ {
+		if err = PushResult(r, []string{"overseer.results"}, []byte(fmt.Sprintf("result %d", i)), 3, time.Hour); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// Only the newest results are kept.
+	list, err := s.List("overseer.results")
+	if err != nil || len(list) != 3 || list[0] != "result 3" || list[2] != "result 5" {
+		t.Errorf("expected the list to be trimmed to the newest 3 results, got %v (%v)", list, err)
+	}
+	if ttl := s.TTL("overseer.results"); ttl != time.Hour {
+		t.Errorf("expected the list to expire after an hour, got %s", ttl)
+	}
+
+	// And it eventually expires, if no more results are pushed.
+	s.FastForward(time.Hour)
+	if s.Exists("overseer.results") {
+		t.Errorf("expected the list to expire")
+	}
+}
@@ -2,20 +2,56 @@ package utils
 
 import (
 	"bytes"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"mime/quotedprintable"
 	"net/smtp"
+	"strings"
 )
 
 // Inspired by https://github.com/tangingw/go_smtp
 
+// The ways of securing the connection to the SMTP server:
+//
+//   auto:     STARTTLS, if the server offers it
+//   starttls: STARTTLS, failing if the server doesn't offer it
+//   tls:      TLS from the start, usually on port 465
+const (
+	SMTPTLSAuto     = "auto"
+	SMTPTLSStartTLS = "starttls"
+	SMTPTLS         = "tls"
+)
+
+// smtpClient is the part of smtp.Client we use, so that it can be mocked.
+type smtpClient interface {
+	Extension(ext string) (bool, string)
+	StartTLS(config *tls.Config) error
+	Auth(a smtp.Auth) error
+	Mail(from string) error
+	Rcpt(to string) error
+	Data() (io.WriteCloser, error)
+	Quit() error
+	Close() error
+}
+
 type EmailSender struct {
 	Host string
 	Port uint
 
 	User     string
 	Password string
+
+	// The address emails are sent from, the user if empty
+	From string
+
+	// How to secure the connection, one of the SMTPTLS* constants
+	TLS string
+
+	// Connects to the server, replaced by tests
+	dial func(sender *EmailSender) (smtpClient, error)
 }
 
 func NewEmailSender(Host string, Port uint, Username, Password string) *EmailSender {
@@ -32,18 +68,103 @@ func NewEmailSender(Host string, Port uint, Username, Password string) *EmailSen
 		log.Fatal("missing smtp password")
 	}
 
-	return &EmailSender{Host, Port, Username, Password}
+	return &EmailSender{Host: Host, Port: Port, User: Username, Password: Password, From: Username, TLS: SMTPTLSAuto}
+}
+
+// ParseEmailAddresses splits a comma-separated list of addresses, skipping
+// the empty ones.
+func ParseEmailAddresses(list string) []string {
+	var addresses []string
+	for _, address := range strings.Split(list, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// from returns the address emails are sent from.
+func (sender *EmailSender) from() string {
+	if sender.From != "" {
+		return sender.From
+	}
+	return sender.User
+}
+
+// connect opens a connection to the SMTP server, over TLS if required.
+func connect(sender *EmailSender) (smtpClient, error) {
+	addr := fmt.Sprintf("%s:%d", sender.Host, sender.Port)
+
+	if sender.TLS != SMTPTLS {
+		return smtp.Dial(addr)
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: sender.Host})
+	if err != nil {
+		return nil, err
+	}
+	client, err := smtp.NewClient(conn, sender.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
 }
 
 func (sender *EmailSender) SendRawMail(to []string, msg string) error {
 
-	err := smtp.SendMail(fmt.Sprintf("%s:%d", sender.Host, sender.Port),
-		smtp.PlainAuth("", sender.User, sender.Password, sender.Host),
-		sender.User, to, []byte(msg))
+	dial := sender.dial
+	if dial == nil {
+		dial = connect
+	}
 
+	client, err := dial(sender)
 	if err != nil {
 		return err
 	}
+	defer client.Close()
+
+	if sender.TLS != SMTPTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(&tls.Config{ServerName: sender.Host}); err != nil {
+				return err
+			}
+		} else if sender.TLS == SMTPTLSStartTLS {
+			return errors.New("the SMTP server doesn't support STARTTLS")
+		}
+	}
+
+	if ok, _ := client.Extension("AUTH"); ok && sender.User != "" {
+		if err = client.Auth(smtp.PlainAuth("", sender.User, sender.Password, sender.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(sender.from()); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err = client.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	if err = client.Quit(); err != nil {
+		return err
+	}
 
 	fmt.Printf("Mail sent successfully to %+v\n", to)
 	return nil
@@ -52,15 +173,8 @@ func (sender *EmailSender) SendRawMail(to []string, msg string) error {
 func (sender *EmailSender) WriteEmail(dest []string, contentType, subject, bodyMessage string) string {
 
 	header := make(map[string]string)
-	header["From"] = sender.User
-
-	receipient := ""
-
-	for _, user := range dest {
-		receipient = receipient + user
-	}
-
-	header["To"] = receipient
+	header["From"] = sender.from()
+	header["To"] = strings.Join(dest, ", ")
 	header["Subject"] = subject
 	header["MIME-Version"] = "1.0"
 	header["Content-Type"] = fmt.Sprintf("%s; charset=\"utf-8\"", contentType)
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/smtp"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mockSMTPClient records what is sent through it.
+type mockSMTPClient struct {
+	extensions map[string]bool
+
+	startTLS bool
+	auth     bool
+	from     string
+	to       []string
+	data     bytes.Buffer
+	quit     bool
+}
+
+func (c *mockSMTPClient) Extension(ext string) (bool, string) {
+	return c.extensions[ext], ""
+}
+
+func (c *mockSMTPClient) StartTLS(config *tls.Config) error {
+	c.startTLS = true
+	return nil
+}
+
+func (c *mockSMTPClient) Auth(a smtp.Auth) error {
+	c.auth = true
+	return nil
+}
+
+func (c *mockSMTPClient) Mail(from string) error {
+	c.from = from
+	return nil
+}
+
+func (c *mockSMTPClient) Rcpt(to string) error {
+	if strings.HasSuffix(to, "@invalid") {
+		return errors.New("550 no such user")
+	}
+	c.to = append(c.to, to)
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func (c *mockSMTPClient) Data() (io.WriteCloser, error) {
+	return nopWriteCloser{&c.data}, nil
+}
+
+func (c *mockSMTPClient) Quit() error {
+	c.quit = true
+	return nil
+}
+
+func (c *mockSMTPClient) Close() error {
+	return nil
+}
+
+func newMockSender(client *mockSMTPClient, mode string) *EmailSender {
+	sender := NewEmailSender("smtp.example.com", 587, "user@example.com", "secret")
+	sender.TLS = mode
+	sender.dial = func(*EmailSender) (smtpClient, error) {
+		return client, nil
+	}
+	return sender
+}
+
+func TestParseEmailAddresses(t *testing.T) {
+	addresses := ParseEmailAddresses(" ops@example.com,,dev@example.com , ")
+	if !reflect.DeepEqual(addresses, []string{"ops@example.com", "dev@example.com"}) {
+		t.Errorf("unexpected addresses %q", addresses)
+	}
+	if addresses = ParseEmailAddresses(""); len(addresses) != 0 {
+		t.Errorf("expected no addresses, got %q", addresses)
+	}
+}
+
+func TestWritePlainEmail(t *testing.T) {
+	sender := NewEmailSender("smtp.example.com", 587, "user@example.com", "secret")
+	sender.From = "overseer@example.com"
+
+	message := sender.WritePlainEmail([]string{"ops@example.com", "dev@example.com"}, "[overseer] FAIL http example.com", "Error: timeout")
+	for _, expected := range []string{
+		"From: overseer@example.com\r\n",
+		"To: ops@example.com, dev@example.com\r\n",
+		"Subject: [overseer] FAIL http example.com\r\n",
+		"\r\n\r\nError: timeout",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("expected %q in the message, got %q", expected, message)
+		}
+	}
+}
+
+func TestSendRawMail(t *testing.T) {
+	client := &mockSMTPClient{extensions: map[string]bool{"STARTTLS": true, "AUTH": true}}
+	sender := newMockSender(client, SMTPTLSAuto)
+
+	if err := sender.SendRawMail([]string{"ops@example.com", "dev@example.com"}, "hello"); err != nil {
+		t.Fatalf("expected the email to be sent, got %s", err)
+	}
+	if !client.startTLS || !client.auth || !client.quit {
+		t.Errorf("expected STARTTLS, authentication and QUIT, got %+v", client)
+	}
+	if client.from != "user@example.com" || !reflect.DeepEqual(client.to, []string{"ops@example.com", "dev@example.com"}) {
+		t.Errorf("unexpected envelope %s -> %q", client.from, client.to)
+	}
+	if client.data.String() != "hello" {
+		t.Errorf("unexpected data %q", client.data.String())
+	}
+
+	// Invalid recipients are reported.
+	client = &mockSMTPClient{extensions: map[string]bool{}}
+	if err := newMockSender(client, SMTPTLSAuto).SendRawMail([]string{"nobody@invalid"}, "hello"); err == nil {
+		t.Errorf("expected the invalid recipient to fail")
+	}
+}
+
+func TestSendRawMailTLSModes(t *testing.T) {
+
+	// Without STARTTLS "auto" carries on in clear text, "starttls" fails.
+	client := &mockSMTPClient{extensions: map[string]bool{}}
+	if err := newMockSender(client, SMTPTLSAuto).SendRawMail([]string{"ops@example.com"}, "hello"); err != nil {
+		t.Errorf("expected the email to be sent, got %s", err)
+	}
+
+	client = &mockSMTPClient{extensions: map[string]bool{}}
+	if err := newMockSender(client, SMTPTLSStartTLS).SendRawMail([]string{"ops@example.com"}, "hello"); err == nil {
+		t.Errorf("expected the missing STARTTLS to fail")
+	}
+	if client.from != "" {
+		t.Errorf("expected nothing to be sent without STARTTLS")
+	}
+
+	// The connection is already secured with "tls".
+	client = &mockSMTPClient{extensions: map[string]bool{"STARTTLS": true}}
+	if err := newMockSender(client, SMTPTLS).SendRawMail([]string{"ops@example.com"}, "hello"); err != nil {
+		t.Errorf("expected the email to be sent, got %s", err)
+	}
+	if client.startTLS {
+		t.Errorf("expected STARTTLS not to be used over TLS")
+	}
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseTypeLimits parses a comma-separated list of test types, each with
+// the maximum number of tests of that type which can run at the same
+// time, e.g. "http=50,dns=5".
+func ParseTypeLimits(list string) (map[string]int, error) {
+	limits := make(map[string]int)
+
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid type limit '%s', must be e.g. dns=5", entry)
+		}
+
+		limit, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("invalid limit in '%s', must be a number > 0", entry)
+		}
+
+		limits[strings.TrimSpace(parts[0])] = limit
+	}
+
+	return limits, nil
+}
+
+// TypeLimiter caps the number of tests of each type which can run at the
+// same time.
+//
+// All the methods can be invoked on a nil object, in which case there are
+// no limits.
+type TypeLimiter struct {
+	// slots holds a semaphore for each limited type, with one slot
+	// for each test of the type which can run
+	slots map[string]chan struct{}
+}
+
+// NewTypeLimiter returns a limiter enforcing the given limits, as returned
+// by ParseTypeLimits.  Types without a limit are unlimited.
+func NewTypeLimiter(limits map[string]int) *TypeLimiter {
+	l := &TypeLimiter{slots: make(map[string]chan struct{})}
+	for testType, limit := range limits {
+		l.slots[testType] = make(chan struct{}, limit)
+	}
+	return l
+}
+
+// Acquire waits until a test of the given type can run.
+//
+// Each call must be followed by a call to Release, once the test is done.
+func (l *TypeLimiter) Acquire(testType string) {
+	if l == nil || l.slots[testType] == nil {
+		return
+	}
+	l.slots[testType] <- struct{}{}
+}
+
+// Release marks a test of the given type as done, letting another one run.
+func (l *TypeLimiter) Release(testType string) {
+	if l == nil || l.slots[testType] == nil {
+		return
+	}
+	<-l.slots[testType]
+}
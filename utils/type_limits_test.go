@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseTypeLimits(t *testing.T) {
+	limits, err := ParseTypeLimits("http=50, dns = 5,")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := map[string]int{"http": 50, "dns": 5}
+	if !reflect.DeepEqual(limits, expected) {
+		t.Errorf("expected %v, got %v", expected, limits)
+	}
+
+	for _, invalid := range []string{"http", "http=", "=5", "dns=0", "dns=-1", "dns=x"} {
+		if _, err = ParseTypeLimits(invalid); err == nil {
+			t.Errorf("expected '%s' to be invalid", invalid)
+		}
+	}
+}
+
+// runConcurrently runs the given number of fake tests of the given type,
+// all at once, and returns the most which were running at the same time.
+func runConcurrently(l *TypeLimiter, testType string, count int) int32 {
+	var running, max int32
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			l.Acquire(testType)
+			defer l.Release(testType)
+
+			now := atomic.AddInt32(&running, 1)
+			for {
+				seen := atomic.LoadInt32(&max)
+				if now <= seen || atomic.CompareAndSwapInt32(&max, seen, now) {
+					break
+				}
+			}
+
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}()
+	}
+
+	wg.Wait()
+	return max
+}
+
+func TestTypeLimiter(t *testing.T) {
+	l := NewTypeLimiter(map[string]int{"dns": 3})
+
+	if max := runConcurrently(l, "dns", 20); max > 3 {
+		t.Errorf("expected at most 3 dns tests at the same time, got %d", max)
+	} else if max < 1 {
+		t.Errorf("expected the dns tests to run")
+	}
+
+	// Unlisted types aren't limited.
+	if max := runConcurrently(l, "http", 20); max <= 3 {
+		t.Errorf("expected the http tests not to be limited, got at most %d at the same time", max)
+	}
+
+	// Nor is anything, without a limiter.
+	var none *TypeLimiter
+	if max := runConcurrently(none, "dns", 20); max <= 3 {
+		t.Errorf("expected no limits, got at most %d tests at the same time", max)
+	}
+}
@@ -0,0 +1,17 @@
+package utils
+
+import "os"
+
+// WorkerName returns the name identifying a worker in its results: the
+// given one, if any, or the hostname of the machine it runs on.
+func WorkerName(name string) string {
+	if name != "" {
+		return name
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"os"
+	"testing"
+)
+
+func TestWorkerName(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skipf("no hostname: %s", err)
+	}
+
+	if name := WorkerName(""); name != hostname {
+		t.Errorf("expected the hostname %s, got %s", hostname, name)
+	}
+	if name := WorkerName("worker-eu-1"); name != "worker-eu-1" {
+		t.Errorf("expected the given name to win over the hostname, got %s", name)
+	}
+}